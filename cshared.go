@@ -0,0 +1,68 @@
+package main
+
+// Built with -buildmode=c-shared, the tool doubles as a C library for
+// programs that need to read gob files without reimplementing the format:
+//
+//	go build -buildmode=c-shared -o libgob.so .
+//
+// GobDecodeToJSON decodes a gob stream, as written by encodeAndWriteToFile,
+// and returns it as JSON. On return *out points to a NUL-terminated buffer
+// of *outLen bytes, which holds the JSON document when the status is 0 and
+// an error message otherwise. The buffer belongs to the caller and must be
+// released with GobFree; input is only read and never retained. Calls may
+// be made concurrently from any number of threads.
+//
+// See examples/gobdecode.py for use from Python through ctypes.
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"test-gob/gobtool"
+)
+
+// maxCInput caps the input accepted through the C API.
+const maxCInput = 64 << 20
+
+//export GobDecodeToJSON
+func GobDecodeToJSON(input *C.char, inputLen C.int, out **C.char, outLen *C.int) C.int {
+	if out == nil || outLen == nil {
+		return 2
+	}
+	result, err := decodeToJSON(input, inputLen)
+	if err != nil {
+		result = []byte(err.Error())
+	}
+	*out = (*C.char)(C.CBytes(append(result, 0)))
+	*outLen = C.int(len(result))
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+//export GobFree
+func GobFree(p *C.char) {
+	C.free(unsafe.Pointer(p))
+}
+
+func decodeToJSON(input *C.char, inputLen C.int) (result []byte, err error) {
+	if input == nil || inputLen < 0 {
+		return nil, fmt.Errorf("invalid input")
+	}
+	if inputLen > maxCInput {
+		return nil, fmt.Errorf("input of %d bytes exceeds the %d byte limit", inputLen, maxCInput)
+	}
+	// A panic must not unwind into the caller's C frames.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("decode: %v", r)
+		}
+	}()
+	return gobtool.DecodeToJSON(C.GoBytes(unsafe.Pointer(input), inputLen))
+}
@@ -0,0 +1,66 @@
+//go:build cgo && linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"test-gob/gobtool"
+)
+
+// TestCSharedDecodeAndFree builds the tool as a C library and runs
+// testdata/cshared/check.c against it, which calls GobDecodeToJSON and
+// checks that GobFree releases the buffer it returned.
+func TestCSharedDecodeAndFree(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the c-shared library")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "libgob.so")
+	build := exec.Command("go", "build", "-buildmode=c-shared", "-o", lib, ".")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	check := filepath.Join(dir, "check")
+	compile := exec.Command(cc, "-o", check, "-I", dir, "testdata/cshared/check.c", lib, "-Wl,-rpath,"+dir)
+	if out, err := compile.CombinedOutput(); err != nil {
+		t.Fatalf("cc: %v\n%s", err, out)
+	}
+
+	// The JSON has to be larger than the mmap threshold check.c sets.
+	blob := strings.Repeat("x", 1<<20)
+	var data bytes.Buffer
+	if err := gobtool.Encode(&data, map[interface{}]interface{}{"blob": blob, "n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "root.gob")
+	if err := os.WriteFile(name, data.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(check, name)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	var got struct {
+		Blob string
+		N    int
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("%v: %.100s", err, out)
+	}
+	if got.Blob != blob || got.N != 1 {
+		t.Errorf("got %d-byte blob, n=%d", len(got.Blob), got.N)
+	}
+}
@@ -0,0 +1,23 @@
+// Package errclass names the categories of failure to read a gob file,
+// stable enough for scripts and wrappers to branch on. The tool reports
+// them in manifest -errors-out summaries and record baselines, and a
+// program that imports this package can compare against the constants
+// rather than matching strings.
+package errclass
+
+// Class is the category of a failure to read a file. An unregistered-type
+// failure is reported with the type name appended, as
+// unregistered-type:main.T.
+type Class string
+
+// Error classes. Classes are never renamed.
+const (
+	NotGob           Class = "not-gob"           // not a gob stream, or corrupt
+	Truncated        Class = "truncated"         // the data ends early
+	UnregisteredType Class = "unregistered-type" // an interface holds a type not registered here
+	TypeMismatch     Class = "type-mismatch"     // valid gob of another type than asked for
+	LimitExceeded    Class = "limit-exceeded"    // a size, output, time or nesting limit was hit
+	IOError          Class = "io-error"          // the file could not be opened or read
+	ChecksumMismatch Class = "checksum-mismatch" // the content no longer matches its recorded hash
+	Other            Class = "other"             // none of the above
+)
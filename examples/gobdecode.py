@@ -0,0 +1,36 @@
+"""Decode a gob file through libgob.so, built with:
+
+    go build -buildmode=c-shared -o libgob.so .
+
+Usage: python3 gobdecode.py path/to/libgob.so file.gob
+"""
+import ctypes
+import json
+import sys
+
+lib = ctypes.CDLL(sys.argv[1])
+lib.GobDecodeToJSON.argtypes = [
+    ctypes.c_char_p,
+    ctypes.c_int,
+    ctypes.POINTER(ctypes.c_void_p),
+    ctypes.POINTER(ctypes.c_int),
+]
+lib.GobDecodeToJSON.restype = ctypes.c_int
+lib.GobFree.argtypes = [ctypes.c_void_p]
+
+
+def gob_decode(data):
+    out = ctypes.c_void_p()
+    out_len = ctypes.c_int()
+    status = lib.GobDecodeToJSON(data, len(data), ctypes.byref(out), ctypes.byref(out_len))
+    try:
+        text = ctypes.string_at(out, out_len.value).decode()
+    finally:
+        lib.GobFree(out)
+    if status != 0:
+        raise ValueError(text)
+    return json.loads(text)
+
+
+with open(sys.argv[2], "rb") as f:
+    print(json.dumps(gob_decode(f.read()), ensure_ascii=False, indent=2))
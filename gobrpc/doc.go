@@ -0,0 +1,7 @@
+// Package gobrpc is the gRPC interface of the tool's grpc-serve command:
+// the messages and the GobTool service generated from gobrpc.proto,
+// with the client Go programs call it through. Programs in other
+// languages generate their own from gobrpc.proto.
+package gobrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative gobrpc.proto
@@ -0,0 +1,638 @@
+// The gob tool as a service, for programs that would otherwise shell out
+// to it. Payloads are gob streams as the tool reads and writes them; JSON
+// is what decode -format json prints and encode reads.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: gobrpc.proto
+
+package gobrpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DecodeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecodeRequest) Reset() {
+	*x = DecodeRequest{}
+	mi := &file_gobrpc_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeRequest) ProtoMessage() {}
+
+func (x *DecodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeRequest.ProtoReflect.Descriptor instead.
+func (*DecodeRequest) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DecodeRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type DecodeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Json  string                 `protobuf:"bytes,1,opt,name=json,proto3" json:"json,omitempty"`
+	// The Go type of every value, the root included, which JSON alone
+	// does not keep: an int64 and a float64 both become a number.
+	Types         []*TypeEntry `protobuf:"bytes,2,rep,name=types,proto3" json:"types,omitempty"`
+	Warnings      []*Warning   `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DecodeResponse) Reset() {
+	*x = DecodeResponse{}
+	mi := &file_gobrpc_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DecodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DecodeResponse) ProtoMessage() {}
+
+func (x *DecodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DecodeResponse.ProtoReflect.Descriptor instead.
+func (*DecodeResponse) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DecodeResponse) GetJson() string {
+	if x != nil {
+		return x.Json
+	}
+	return ""
+}
+
+func (x *DecodeResponse) GetTypes() []*TypeEntry {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *DecodeResponse) GetWarnings() []*Warning {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+// TypeEntry is the Go type of the value at a path, as decode -format
+// flat names paths; the root is the empty path.
+type TypeEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TypeEntry) Reset() {
+	*x = TypeEntry{}
+	mi := &file_gobrpc_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TypeEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TypeEntry) ProtoMessage() {}
+
+func (x *TypeEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TypeEntry.ProtoReflect.Descriptor instead.
+func (*TypeEntry) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TypeEntry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *TypeEntry) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+// Warning is something the conversion lost, as -warnings-out reports it.
+type Warning struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Path          string                 `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Warning) Reset() {
+	*x = Warning{}
+	mi := &file_gobrpc_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Warning) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Warning) ProtoMessage() {}
+
+func (x *Warning) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Warning.ProtoReflect.Descriptor instead.
+func (*Warning) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Warning) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Warning) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Warning) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Warning) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type EncodeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// A JSON object.
+	Json          string         `protobuf:"bytes,1,opt,name=json,proto3" json:"json,omitempty"`
+	Options       *EncodeOptions `protobuf:"bytes,2,opt,name=options,proto3" json:"options,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncodeRequest) Reset() {
+	*x = EncodeRequest{}
+	mi := &file_gobrpc_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncodeRequest) ProtoMessage() {}
+
+func (x *EncodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncodeRequest.ProtoReflect.Descriptor instead.
+func (*EncodeRequest) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EncodeRequest) GetJson() string {
+	if x != nil {
+		return x.Json
+	}
+	return ""
+}
+
+func (x *EncodeRequest) GetOptions() *EncodeOptions {
+	if x != nil {
+		return x.Options
+	}
+	return nil
+}
+
+// EncodeOptions are the flags of encode that apply to a single object.
+type EncodeOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Read annotations of interchange JSON as Go values.
+	Interchange bool `protobuf:"varint,1,opt,name=interchange,proto3" json:"interchange,omitempty"`
+	// Sort slices of one scalar type.
+	SortSlices bool `protobuf:"varint,2,opt,name=sort_slices,json=sortSlices,proto3" json:"sort_slices,omitempty"`
+	// Leave out these top-level keys, or these keys at any depth with deep.
+	Drop          []string `protobuf:"bytes,3,rep,name=drop,proto3" json:"drop,omitempty"`
+	Deep          bool     `protobuf:"varint,4,opt,name=deep,proto3" json:"deep,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncodeOptions) Reset() {
+	*x = EncodeOptions{}
+	mi := &file_gobrpc_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncodeOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncodeOptions) ProtoMessage() {}
+
+func (x *EncodeOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncodeOptions.ProtoReflect.Descriptor instead.
+func (*EncodeOptions) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EncodeOptions) GetInterchange() bool {
+	if x != nil {
+		return x.Interchange
+	}
+	return false
+}
+
+func (x *EncodeOptions) GetSortSlices() bool {
+	if x != nil {
+		return x.SortSlices
+	}
+	return false
+}
+
+func (x *EncodeOptions) GetDrop() []string {
+	if x != nil {
+		return x.Drop
+	}
+	return nil
+}
+
+func (x *EncodeOptions) GetDeep() bool {
+	if x != nil {
+		return x.Deep
+	}
+	return false
+}
+
+type EncodeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	Warnings      []*Warning             `protobuf:"bytes,2,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EncodeResponse) Reset() {
+	*x = EncodeResponse{}
+	mi := &file_gobrpc_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EncodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncodeResponse) ProtoMessage() {}
+
+func (x *EncodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncodeResponse.ProtoReflect.Descriptor instead.
+func (*EncodeResponse) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *EncodeResponse) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *EncodeResponse) GetWarnings() []*Warning {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+type InspectRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InspectRequest) Reset() {
+	*x = InspectRequest{}
+	mi := &file_gobrpc_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InspectRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectRequest) ProtoMessage() {}
+
+func (x *InspectRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectRequest.ProtoReflect.Descriptor instead.
+func (*InspectRequest) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *InspectRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type InspectResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The type definitions, as the types command prints them.
+	Report string `protobuf:"bytes,1,opt,name=report,proto3" json:"report,omitempty"`
+	// The type of each top-level value, in order.
+	Values        []string `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InspectResponse) Reset() {
+	*x = InspectResponse{}
+	mi := &file_gobrpc_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InspectResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InspectResponse) ProtoMessage() {}
+
+func (x *InspectResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gobrpc_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InspectResponse.ProtoReflect.Descriptor instead.
+func (*InspectResponse) Descriptor() ([]byte, []int) {
+	return file_gobrpc_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *InspectResponse) GetReport() string {
+	if x != nil {
+		return x.Report
+	}
+	return ""
+}
+
+func (x *InspectResponse) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+var File_gobrpc_proto protoreflect.FileDescriptor
+
+const file_gobrpc_proto_rawDesc = "" +
+	"\n" +
+	"\fgobrpc.proto\x12\n" +
+	"gobtool.v1\"#\n" +
+	"\rDecodeRequest\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"\x82\x01\n" +
+	"\x0eDecodeResponse\x12\x12\n" +
+	"\x04json\x18\x01 \x01(\tR\x04json\x12+\n" +
+	"\x05types\x18\x02 \x03(\v2\x15.gobtool.v1.TypeEntryR\x05types\x12/\n" +
+	"\bwarnings\x18\x03 \x03(\v2\x13.gobtool.v1.WarningR\bwarnings\"3\n" +
+	"\tTypeEntry\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\"_\n" +
+	"\aWarning\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04path\x18\x03 \x01(\tR\x04path\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\"X\n" +
+	"\rEncodeRequest\x12\x12\n" +
+	"\x04json\x18\x01 \x01(\tR\x04json\x123\n" +
+	"\aoptions\x18\x02 \x01(\v2\x19.gobtool.v1.EncodeOptionsR\aoptions\"z\n" +
+	"\rEncodeOptions\x12 \n" +
+	"\vinterchange\x18\x01 \x01(\bR\vinterchange\x12\x1f\n" +
+	"\vsort_slices\x18\x02 \x01(\bR\n" +
+	"sortSlices\x12\x12\n" +
+	"\x04drop\x18\x03 \x03(\tR\x04drop\x12\x12\n" +
+	"\x04deep\x18\x04 \x01(\bR\x04deep\"U\n" +
+	"\x0eEncodeResponse\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\x12/\n" +
+	"\bwarnings\x18\x02 \x03(\v2\x13.gobtool.v1.WarningR\bwarnings\"$\n" +
+	"\x0eInspectRequest\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"A\n" +
+	"\x0fInspectResponse\x12\x16\n" +
+	"\x06report\x18\x01 \x01(\tR\x06report\x12\x16\n" +
+	"\x06values\x18\x02 \x03(\tR\x06values2\xcf\x01\n" +
+	"\aGobTool\x12?\n" +
+	"\x06Decode\x12\x19.gobtool.v1.DecodeRequest\x1a\x1a.gobtool.v1.DecodeResponse\x12?\n" +
+	"\x06Encode\x12\x19.gobtool.v1.EncodeRequest\x1a\x1a.gobtool.v1.EncodeResponse\x12B\n" +
+	"\aInspect\x12\x1a.gobtool.v1.InspectRequest\x1a\x1b.gobtool.v1.InspectResponseB\x11Z\x0ftest-gob/gobrpcb\x06proto3"
+
+var (
+	file_gobrpc_proto_rawDescOnce sync.Once
+	file_gobrpc_proto_rawDescData []byte
+)
+
+func file_gobrpc_proto_rawDescGZIP() []byte {
+	file_gobrpc_proto_rawDescOnce.Do(func() {
+		file_gobrpc_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gobrpc_proto_rawDesc), len(file_gobrpc_proto_rawDesc)))
+	})
+	return file_gobrpc_proto_rawDescData
+}
+
+var file_gobrpc_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_gobrpc_proto_goTypes = []any{
+	(*DecodeRequest)(nil),   // 0: gobtool.v1.DecodeRequest
+	(*DecodeResponse)(nil),  // 1: gobtool.v1.DecodeResponse
+	(*TypeEntry)(nil),       // 2: gobtool.v1.TypeEntry
+	(*Warning)(nil),         // 3: gobtool.v1.Warning
+	(*EncodeRequest)(nil),   // 4: gobtool.v1.EncodeRequest
+	(*EncodeOptions)(nil),   // 5: gobtool.v1.EncodeOptions
+	(*EncodeResponse)(nil),  // 6: gobtool.v1.EncodeResponse
+	(*InspectRequest)(nil),  // 7: gobtool.v1.InspectRequest
+	(*InspectResponse)(nil), // 8: gobtool.v1.InspectResponse
+}
+var file_gobrpc_proto_depIdxs = []int32{
+	2, // 0: gobtool.v1.DecodeResponse.types:type_name -> gobtool.v1.TypeEntry
+	3, // 1: gobtool.v1.DecodeResponse.warnings:type_name -> gobtool.v1.Warning
+	5, // 2: gobtool.v1.EncodeRequest.options:type_name -> gobtool.v1.EncodeOptions
+	3, // 3: gobtool.v1.EncodeResponse.warnings:type_name -> gobtool.v1.Warning
+	0, // 4: gobtool.v1.GobTool.Decode:input_type -> gobtool.v1.DecodeRequest
+	4, // 5: gobtool.v1.GobTool.Encode:input_type -> gobtool.v1.EncodeRequest
+	7, // 6: gobtool.v1.GobTool.Inspect:input_type -> gobtool.v1.InspectRequest
+	1, // 7: gobtool.v1.GobTool.Decode:output_type -> gobtool.v1.DecodeResponse
+	6, // 8: gobtool.v1.GobTool.Encode:output_type -> gobtool.v1.EncodeResponse
+	8, // 9: gobtool.v1.GobTool.Inspect:output_type -> gobtool.v1.InspectResponse
+	7, // [7:10] is the sub-list for method output_type
+	4, // [4:7] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_gobrpc_proto_init() }
+func file_gobrpc_proto_init() {
+	if File_gobrpc_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gobrpc_proto_rawDesc), len(file_gobrpc_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_gobrpc_proto_goTypes,
+		DependencyIndexes: file_gobrpc_proto_depIdxs,
+		MessageInfos:      file_gobrpc_proto_msgTypes,
+	}.Build()
+	File_gobrpc_proto = out.File
+	file_gobrpc_proto_goTypes = nil
+	file_gobrpc_proto_depIdxs = nil
+}
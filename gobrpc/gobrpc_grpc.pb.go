@@ -0,0 +1,211 @@
+// The gob tool as a service, for programs that would otherwise shell out
+// to it. Payloads are gob streams as the tool reads and writes them; JSON
+// is what decode -format json prints and encode reads.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: gobrpc.proto
+
+package gobrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	GobTool_Decode_FullMethodName  = "/gobtool.v1.GobTool/Decode"
+	GobTool_Encode_FullMethodName  = "/gobtool.v1.GobTool/Encode"
+	GobTool_Inspect_FullMethodName = "/gobtool.v1.GobTool/Inspect"
+)
+
+// GobToolClient is the client API for GobTool service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GobToolClient interface {
+	// Decode decodes the top-level value of a gob stream, gzip-compressed
+	// or not, to JSON.
+	Decode(ctx context.Context, in *DecodeRequest, opts ...grpc.CallOption) (*DecodeResponse, error)
+	// Encode encodes a JSON object as a gob map.
+	Encode(ctx context.Context, in *EncodeRequest, opts ...grpc.CallOption) (*EncodeResponse, error)
+	// Inspect reports the type definitions of a gob stream without
+	// decoding its values.
+	Inspect(ctx context.Context, in *InspectRequest, opts ...grpc.CallOption) (*InspectResponse, error)
+}
+
+type gobToolClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGobToolClient(cc grpc.ClientConnInterface) GobToolClient {
+	return &gobToolClient{cc}
+}
+
+func (c *gobToolClient) Decode(ctx context.Context, in *DecodeRequest, opts ...grpc.CallOption) (*DecodeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DecodeResponse)
+	err := c.cc.Invoke(ctx, GobTool_Decode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobToolClient) Encode(ctx context.Context, in *EncodeRequest, opts ...grpc.CallOption) (*EncodeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EncodeResponse)
+	err := c.cc.Invoke(ctx, GobTool_Encode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gobToolClient) Inspect(ctx context.Context, in *InspectRequest, opts ...grpc.CallOption) (*InspectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InspectResponse)
+	err := c.cc.Invoke(ctx, GobTool_Inspect_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GobToolServer is the server API for GobTool service.
+// All implementations must embed UnimplementedGobToolServer
+// for forward compatibility.
+type GobToolServer interface {
+	// Decode decodes the top-level value of a gob stream, gzip-compressed
+	// or not, to JSON.
+	Decode(context.Context, *DecodeRequest) (*DecodeResponse, error)
+	// Encode encodes a JSON object as a gob map.
+	Encode(context.Context, *EncodeRequest) (*EncodeResponse, error)
+	// Inspect reports the type definitions of a gob stream without
+	// decoding its values.
+	Inspect(context.Context, *InspectRequest) (*InspectResponse, error)
+	mustEmbedUnimplementedGobToolServer()
+}
+
+// UnimplementedGobToolServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGobToolServer struct{}
+
+func (UnimplementedGobToolServer) Decode(context.Context, *DecodeRequest) (*DecodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Decode not implemented")
+}
+func (UnimplementedGobToolServer) Encode(context.Context, *EncodeRequest) (*EncodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Encode not implemented")
+}
+func (UnimplementedGobToolServer) Inspect(context.Context, *InspectRequest) (*InspectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Inspect not implemented")
+}
+func (UnimplementedGobToolServer) mustEmbedUnimplementedGobToolServer() {}
+func (UnimplementedGobToolServer) testEmbeddedByValue()                 {}
+
+// UnsafeGobToolServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GobToolServer will
+// result in compilation errors.
+type UnsafeGobToolServer interface {
+	mustEmbedUnimplementedGobToolServer()
+}
+
+func RegisterGobToolServer(s grpc.ServiceRegistrar, srv GobToolServer) {
+	// If the following call pancis, it indicates UnimplementedGobToolServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&GobTool_ServiceDesc, srv)
+}
+
+func _GobTool_Decode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DecodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobToolServer).Decode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobTool_Decode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobToolServer).Decode(ctx, req.(*DecodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobTool_Encode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EncodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobToolServer).Encode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobTool_Encode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobToolServer).Encode(ctx, req.(*EncodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GobTool_Inspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GobToolServer).Inspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: GobTool_Inspect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GobToolServer).Inspect(ctx, req.(*InspectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// GobTool_ServiceDesc is the grpc.ServiceDesc for GobTool service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GobTool_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gobtool.v1.GobTool",
+	HandlerType: (*GobToolServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Decode",
+			Handler:    _GobTool_Decode_Handler,
+		},
+		{
+			MethodName: "Encode",
+			Handler:    _GobTool_Encode_Handler,
+		},
+		{
+			MethodName: "Inspect",
+			Handler:    _GobTool_Inspect_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "gobrpc.proto",
+}
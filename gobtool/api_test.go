@@ -0,0 +1,62 @@
+package gobtool_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"testing"
+
+	"test-gob/gobtool"
+)
+
+type apiEvent struct {
+	Name string
+	N    int
+}
+
+// TestImportedAPI uses the library as another program would, from
+// outside the package: everything it needs has to be exported.
+func TestImportedAPI(t *testing.T) {
+	// The writer registers its types with gob, as for any gob.Encoder.
+	gob.Register(apiEvent{})
+	var buf bytes.Buffer
+	data := map[interface{}]interface{}{"event": apiEvent{"login", 1}, "user": "ada"}
+	if err := gobtool.Encode(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	upper := gobtool.TransformRule{PathGlob: "user", Func: func(v interface{}) (interface{}, error) {
+		return strings.ToUpper(v.(string)), nil
+	}}
+	got, err := gobtool.DecodeValue(bytes.NewReader(buf.Bytes()),
+		gobtool.WithRegistry(apiEvent{}), gobtool.WithTransforms(upper), gobtool.WithLimits(gobtool.Limits{MaxBytes: 1 << 20}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[interface{}]interface{}{"event": apiEvent{"login", 1}, "user": "ADA"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeValue = %#v, want %#v", got, want)
+	}
+	if _, err := gobtool.CanonicalHash(got); err != nil {
+		t.Errorf("CanonicalHash: %v", err)
+	}
+	if err := gobtool.CanRead(gobtool.FormatDelta, gobtool.DeltaVersion); err != nil {
+		t.Errorf("CanRead: %v", err)
+	}
+
+	var file bytes.Buffer
+	for _, e := range []apiEvent{{"a", 1}, {"b", 2}} {
+		if _, err := gobtool.EncodeIndependent(&file, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := gobtool.NewSeekDecoder(bytes.NewReader(file.Bytes()))
+	var first, second apiEvent
+	next, err := d.DecodeAt(0, &first)
+	if err == nil {
+		_, err = d.DecodeAt(next, &second)
+	}
+	if err != nil || first.Name != "a" || second.Name != "b" {
+		t.Errorf("SeekDecoder: %+v, %+v, %v", first, second, err)
+	}
+}
@@ -0,0 +1,44 @@
+package gobtool
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Booleans print as true and false by default. For reports read by
+// people rather than scripts, the text outputs (tree, flat, dot and grep)
+// print them in the style -bool-style names instead, keys included.
+// JSON output always has true and false.
+
+// boolStyles are the words -bool-style can print, true first.
+var boolStyles = map[string][2]string{
+	"true-false": {"true", "false"},
+	"yes-no":     {"yes", "no"},
+	"check":      {"✓", "✗"},
+}
+
+// boolWords is the pair booleans are printed with; nil keeps the
+// default. Set by -bool-style.
+var boolWords *[2]string
+
+// setBoolStyle implements -bool-style.
+func setBoolStyle(s string) error {
+	words, ok := boolStyles[s]
+	if !ok {
+		return fmt.Errorf("%q is not a bool style: want true-false, yes-no or check", s)
+	}
+	boolWords = &words
+	return nil
+}
+
+// boolString returns the words for rv in the -bool-style style, and false
+// if v is not a bool or no style is set.
+func boolString(rv reflect.Value) (string, bool) {
+	if boolWords == nil || rv.Kind() != reflect.Bool || !rv.CanInterface() {
+		return "", false
+	}
+	if rv.Bool() {
+		return boolWords[0], true
+	}
+	return boolWords[1], true
+}
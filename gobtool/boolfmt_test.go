@@ -0,0 +1,36 @@
+package gobtool
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBoolStyleYesNo(t *testing.T) {
+	defer func(old *[2]string) { boolWords = old }(boolWords)
+	if err := setBoolStyle("yes-no"); err != nil {
+		t.Fatal(err)
+	}
+	flags := map[interface{}]interface{}{"on": true, "off": false}
+	var buf bytes.Buffer
+	if err := writeFlat(&buf, flags, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := "off = no (bool)\non = yes (bool)\n"; buf.String() != want {
+		t.Errorf("flat: got %q, want %q", buf.String(), want)
+	}
+	buf.Reset()
+	if err := renderers["tree"](renderOptions{}).render(&buf, flags); err != nil {
+		t.Fatal(err)
+	}
+	if tree := buf.String(); !strings.Contains(tree, "yes (bool)") || !strings.Contains(tree, "no (bool)") || strings.Contains(tree, "true") {
+		t.Errorf("tree:\n%s", tree)
+	}
+}
+
+func TestBoolStyleUnknown(t *testing.T) {
+	defer func(old *[2]string) { boolWords = old }(boolWords)
+	if err := setBoolStyle("maybe"); err == nil {
+		t.Error("no error")
+	}
+}
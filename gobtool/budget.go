@@ -0,0 +1,157 @@
+package gobtool
+
+import (
+	"crypto/rand"
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+)
+
+// cookieExpansion approximates how much securecookie grows a gob payload:
+// the value is base64-encoded, wrapped with a timestamp and MAC, and the
+// whole is base64-encoded again, so each payload byte costs about 16/9.
+const cookieExpansion = 16.0 / 9.0
+
+// KeySize is the share of an encoding attributed to one top-level key.
+type KeySize struct {
+	Key  interface{}
+	Size int
+}
+
+// BudgetExceededError reports an encoding larger than its budget, with the
+// top-level keys largest first.
+type BudgetExceededError struct {
+	What  string // what was measured, such as "gob" or "cookie"
+	Size  int
+	Limit int
+	Keys  []KeySize
+}
+
+func (e *BudgetExceededError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "encoded %s is %d bytes, over the %d byte budget by %d", e.What, e.Size, e.Limit, e.Size-e.Limit)
+	for _, k := range e.Keys {
+		fmt.Fprintf(&b, "\n  %6d  %v", k.Size, k.Key)
+	}
+	return b.String()
+}
+
+// gobSize returns the size of v encoded on its own.
+func gobSize(v interface{}) (int, error) {
+	var n countWriter
+	if err := gob.NewEncoder(&n).Encode(v); err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+type countWriter int64
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	*c += countWriter(len(p))
+	return len(p), nil
+}
+
+// keySizes attributes the encoding of data to its keys: each key is
+// charged what a map holding only that entry costs, scaled by factor.
+func keySizes(data map[interface{}]interface{}, factor float64) ([]KeySize, error) {
+	sizes := make([]KeySize, 0, len(data))
+	for _, k := range sortedMapKeys(reflect.ValueOf(data)) {
+		key := k.Interface()
+		n, err := gobSize(map[interface{}]interface{}{key: data[key]})
+		if err != nil {
+			return nil, fmt.Errorf("key %v: %w", key, err)
+		}
+		sizes = append(sizes, KeySize{key, int(float64(n) * factor)})
+	}
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].Size > sizes[j].Size })
+	return sizes, nil
+}
+
+// CheckEncodedSize returns a *BudgetExceededError if data encodes to more
+// than limit bytes.
+func CheckEncodedSize(data map[interface{}]interface{}, limit int) error {
+	n, err := gobSize(data)
+	if err != nil {
+		return err
+	}
+	return checkBudget("gob", data, n, limit, 1)
+}
+
+// CheckCookieSize is CheckEncodedSize for data stored in a securecookie
+// named name, encrypted or not, as gorilla/sessions' cookie store does.
+func CheckCookieSize(name string, data map[interface{}]interface{}, encrypted bool, limit int) error {
+	n, err := cookieSize(name, data, encrypted)
+	if err != nil {
+		return err
+	}
+	return checkBudget("cookie", data, n, limit, cookieExpansion)
+}
+
+func checkBudget(what string, data map[interface{}]interface{}, size, limit int, factor float64) error {
+	if size <= limit {
+		return nil
+	}
+	keys, err := keySizes(data, factor)
+	if err != nil {
+		return err
+	}
+	return &BudgetExceededError{What: what, Size: size, Limit: limit, Keys: keys}
+}
+
+// cookieSize measures the actual cookie value with throwaway keys; only
+// the length matters.
+func cookieSize(name string, data map[interface{}]interface{}, encrypted bool) (int, error) {
+	hashKey := make([]byte, 64)
+	rand.Read(hashKey)
+	var blockKey []byte
+	if encrypted {
+		blockKey = make([]byte, 32)
+		rand.Read(blockKey)
+	}
+	sc := securecookie.New(hashKey, blockKey).MaxLength(0)
+	v, err := sc.Encode(name, data)
+	if err != nil {
+		return 0, err
+	}
+	return len(v), nil
+}
+
+// budgetFlags are the size budget flags shared by the encoding commands.
+type budgetFlags struct {
+	max       int
+	warnOnly  bool
+	cookie    string
+	encrypted bool
+}
+
+func (b *budgetFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&b.max, "max-encoded-size", 0, "fail when the encoding is larger than this many bytes")
+	fs.BoolVar(&b.warnOnly, "warn-only", false, "with -max-encoded-size, only warn")
+	fs.StringVar(&b.cookie, "cookie", "", "measure the budget as a securecookie with this name")
+	fs.BoolVar(&b.encrypted, "cookie-encrypted", false, "with -cookie, measure an encrypted cookie")
+}
+
+// check applies the budget to data. Warnings go to w.
+func (b *budgetFlags) check(w io.Writer, data map[interface{}]interface{}) error {
+	if b.max <= 0 {
+		return nil
+	}
+	var err error
+	if b.cookie != "" {
+		err = CheckCookieSize(b.cookie, data, b.encrypted, b.max)
+	} else {
+		err = CheckEncodedSize(data, b.max)
+	}
+	if _, over := err.(*BudgetExceededError); over && b.warnOnly {
+		fmt.Fprintf(w, "warning: %v\n", err)
+		return nil
+	}
+	return err
+}
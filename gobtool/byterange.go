@@ -0,0 +1,112 @@
+package gobtool
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ByteRange selects the part of a file that holds the gob payload, for
+// container formats that embed one at a known place. A negative Offset
+// counts back from the end of the file, for trailer-style containers;
+// a zero Length runs to the end of the file.
+type ByteRange struct {
+	Offset int64
+	Length int64
+}
+
+func (r *ByteRange) register(fs *flag.FlagSet) {
+	fs.Int64Var(&r.Offset, "offset", 0, "the payload starts at this byte offset (negative: from the end of the file)")
+	fs.Int64Var(&r.Length, "length", 0, "the payload is this many bytes long (0: to the end of the file)")
+}
+
+// resolve returns the absolute [start, end) of the range in a file of
+// the given size.
+func (r ByteRange) resolve(size int64) (start, end int64, err error) {
+	start = r.Offset
+	if start < 0 {
+		start += size
+		if start < 0 {
+			return 0, 0, fmt.Errorf("offset %d is before the start of the %d-byte file", r.Offset, size)
+		}
+	}
+	if start > size {
+		return 0, 0, fmt.Errorf("offset %d is past the end of the %d-byte file", start, size)
+	}
+	switch {
+	case r.Length < 0:
+		return 0, 0, fmt.Errorf("negative length %d", r.Length)
+	case r.Length == 0:
+		end = size
+	default:
+		end = start + r.Length
+		if end > size {
+			return 0, 0, fmt.Errorf("range %d-%d runs past the end of the %d-byte file", start, end, size)
+		}
+	}
+	return start, end, nil
+}
+
+// rangeReader reads a range of a file and knows where in the file it is,
+// so that errors can give absolute offsets.
+type rangeReader struct {
+	*io.SectionReader
+	start, end int64
+}
+
+// OpenRange returns a reader for range r of f. A pipe or other file that
+// cannot be read at an offset is read into memory first.
+func OpenRange(f inputFile, r ByteRange) (*rangeReader, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	ra, ok := f.(io.ReaderAt)
+	size := fi.Size()
+	if !ok || !fi.Mode().IsRegular() {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		ra, size = bytes.NewReader(data), int64(len(data))
+	}
+	start, end, err := r.resolve(size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	return &rangeReader{io.NewSectionReader(ra, start, end-start), start, end}, nil
+}
+
+// pos is the absolute file offset of the next byte to be read.
+func (r *rangeReader) pos() int64 {
+	off, _ := r.Seek(0, io.SeekCurrent)
+	return r.start + off
+}
+
+// annotate adds the absolute file offsets involved to a decode error.
+// Reads are buffered, so the offset reached is an upper bound of where
+// the failure lies.
+func (r *rangeReader) annotate(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("payload at file offsets %d-%d, read up to offset %d: %w", r.start, r.end, r.pos(), err)
+}
+
+// DecodeRange decodes the gob payload held in range r of the named file,
+// with the same envelope detection as decodeAnyFile.
+func DecodeRange(name string, r ByteRange) (interface{}, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rr, err := OpenRange(f, r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeAny(rr, name)
+	return data, rr.annotate(err)
+}
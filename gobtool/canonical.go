@@ -0,0 +1,143 @@
+package gobtool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// writeCanonical writes a deterministic textual form of v. Every value is
+// prefixed by its type, map entries are sorted and pointers are followed,
+// so the same logical content always produces the same bytes no matter
+// which order gob happened to encode the maps in.
+func writeCanonical(buf *bytes.Buffer, v interface{}) error {
+	return canonicalValue(buf, reflect.ValueOf(v), 0)
+}
+
+// maxCanonicalDepth stops runaway recursion on self-referencing values.
+const maxCanonicalDepth = 1000
+
+func canonicalValue(buf *bytes.Buffer, v reflect.Value, depth int) error {
+	if depth > maxCanonicalDepth {
+		return fmt.Errorf("value nested deeper than %d levels", maxCanonicalDepth)
+	}
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || ((v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil()) {
+		buf.WriteString("nil")
+		return nil
+	}
+
+	t := v.Type()
+	if v.CanInterface() {
+		switch m := v.Interface().(type) {
+		case encoding.TextMarshaler:
+			text, err := m.MarshalText()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%s(%q)", t, text)
+			return nil
+		case gob.GobEncoder:
+			data, err := m.GobEncode()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%s(%s)", t, hex.EncodeToString(data))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var e bytes.Buffer
+			if err := canonicalValue(&e, iter.Key(), depth+1); err != nil {
+				return err
+			}
+			e.WriteString(": ")
+			if err := canonicalValue(&e, iter.Value(), depth+1); err != nil {
+				return err
+			}
+			entries = append(entries, e.String())
+		}
+		sort.Strings(entries)
+		fmt.Fprintf(buf, "%s{", t)
+		for i, e := range entries {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(e)
+		}
+		buf.WriteString("}")
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Array && !v.CanAddr() {
+				// Bytes needs an addressable array, which one reached
+				// through a map value or an interface is not.
+				a := reflect.New(t).Elem()
+				a.Set(v)
+				v = a
+			}
+			fmt.Fprintf(buf, "%s(%x)", t, v.Bytes())
+			return nil
+		}
+		fmt.Fprintf(buf, "%s[", t)
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			if err := canonicalValue(buf, v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case reflect.Struct:
+		fmt.Fprintf(buf, "%s{", t)
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			// gob only carries exported fields
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if !first {
+				buf.WriteString(", ")
+			}
+			first = false
+			buf.WriteString(t.Field(i).Name + ": ")
+			if err := canonicalValue(buf, v.Field(i), depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	case reflect.String:
+		fmt.Fprintf(buf, "%s(%s)", t, strconv.Quote(v.String()))
+	case reflect.Float32, reflect.Float64:
+		fmt.Fprintf(buf, "%s(%s)", t, strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	default:
+		fmt.Fprintf(buf, "%s(%v)", t, v)
+	}
+	return nil
+}
+
+// CanonicalHash is the SHA-256 of the canonical form of v. It depends only
+// on the content, not on the order gob happened to write map entries in.
+func CanonicalHash(v interface{}) ([32]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(buf.Bytes()), nil
+}
@@ -0,0 +1,89 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type canonicalID struct {
+	ID [16]byte
+}
+
+func TestCanonicalHashByteArrayInMapValue(t *testing.T) {
+	id := [16]byte{1, 2, 3, 15: 0xff}
+	data := map[string]interface{}{
+		"struct": canonicalID{id},
+		"array":  id,
+	}
+	h1, err := CanonicalHash(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2, err := CanonicalHash(map[string]interface{}{"array": id, "struct": canonicalID{id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 {
+		t.Errorf("same content hashes differently: %x vs %x", h1, h2)
+	}
+
+	id[0] = 9
+	h3, err := CanonicalHash(map[string]interface{}{"array": id, "struct": canonicalID{id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h3 == h1 {
+		t.Error("changing a byte in the array does not change the hash")
+	}
+}
+
+func TestCanonicalByteArrayText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, map[string]canonicalID{"k": {[16]byte{0xab}}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[16]uint8(ab000000000000000000000000000000)"; !strings.Contains(buf.String(), want) {
+		t.Errorf("canonical form %s does not contain %s", buf.String(), want)
+	}
+}
+
+// TestCanonicalHashMapOrder encodes one map until gob writes its entries
+// in two different orders, and checks that both decode to the same hash.
+func TestCanonicalHashMapOrder(t *testing.T) {
+	registerKnownTypes()
+	data := map[interface{}]interface{}{}
+	for i := 0; i < 20; i++ {
+		data[fmt.Sprint("key", i)] = map[string]interface{}{"i": i, "s": strings.Repeat("x", i)}
+	}
+	encode := func() []byte {
+		var buf bytes.Buffer
+		if err := encodeRoot(gob.NewEncoder(&buf), data); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	first := encode()
+	var second []byte
+	for i := 0; i < 100 && (second == nil || bytes.Equal(first, second)); i++ {
+		second = encode()
+	}
+	if bytes.Equal(first, second) {
+		t.Fatal("gob wrote the map in the same order 100 times")
+	}
+	var hashes [2][32]byte
+	for i, raw := range [][]byte{first, second} {
+		v, err := decodeAny(bytes.NewReader(raw), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hashes[i], err = CanonicalHash(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("the same map hashes to %x and %x", hashes[0], hashes[1])
+	}
+}
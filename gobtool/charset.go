@@ -0,0 +1,100 @@
+package gobtool
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// Blobs from older systems sometimes hold strings in a legacy charset
+// rather than UTF-8. -string-encoding names that charset, and the text
+// renderers transcode strings from it for display; the decoded data
+// itself is left alone. The single-byte charsets are built in; GBK comes
+// from the tables in golang.org/x/text/encoding.
+
+// stringDecoder transcodes displayed strings to UTF-8; nil leaves them
+// as they are. Set by -string-encoding.
+var stringDecoder func(string) string
+
+// cp1252 maps the bytes 0x80-0x9f of Windows-1252 to Unicode; zero
+// entries are undefined and shown as U+FFFD.
+var cp1252 = [32]rune{
+	0x20ac, 0, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017d, 0,
+	0, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0, 0x017e, 0x0178,
+}
+
+// singleByteDecoder returns a decoder for a charset that maps each byte
+// to one rune, with ASCII unchanged.
+func singleByteDecoder(high func(b byte) rune) func(string) string {
+	return func(s string) string {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			if s[i] < utf8.RuneSelf {
+				b.WriteByte(s[i])
+			} else {
+				b.WriteRune(high(s[i]))
+			}
+		}
+		return b.String()
+	}
+}
+
+// textDecoder returns a decoder for a charset of golang.org/x/text,
+// which shows bytes the charset does not define as U+FFFD.
+func textDecoder(e encoding.Encoding) func(string) string {
+	return func(s string) string {
+		out, err := e.NewDecoder().String(s)
+		if err != nil {
+			return s
+		}
+		return out
+	}
+}
+
+var stringDecoders = map[string]func(string) string{
+	"utf-8":  nil,
+	"latin1": singleByteDecoder(func(b byte) rune { return rune(b) }),
+	"windows-1252": singleByteDecoder(func(b byte) rune {
+		if b < 0xa0 && cp1252[b-0x80] != 0 {
+			return cp1252[b-0x80]
+		}
+		if b < 0xa0 {
+			return utf8.RuneError
+		}
+		return rune(b)
+	}),
+	"gbk": textDecoder(simplifiedchinese.GBK),
+}
+
+// charsetAliases maps other common names to the keys of stringDecoders.
+var charsetAliases = map[string]string{
+	"utf8":       "utf-8",
+	"iso-8859-1": "latin1",
+	"iso8859-1":  "latin1",
+	"cp1252":     "windows-1252",
+	"cp936":      "gbk",
+	"gb2312":     "gbk", // a subset of GBK
+}
+
+// setStringEncoding implements -string-encoding.
+func setStringEncoding(name string) error {
+	key := strings.ToLower(name)
+	if alias, ok := charsetAliases[key]; ok {
+		key = alias
+	}
+	dec, ok := stringDecoders[key]
+	if !ok {
+		switch key {
+		case "gb18030", "big5", "shift_jis", "euc-jp", "euc-kr":
+			return fmt.Errorf("%s is not supported: of the multi-byte charsets this build includes only GBK", name)
+		}
+		return fmt.Errorf("unknown charset %q (have utf-8, latin1, windows-1252, gbk)", name)
+	}
+	stringDecoder = dec
+	return nil
+}
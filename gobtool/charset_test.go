@@ -0,0 +1,51 @@
+package gobtool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringEncodingSingleByte(t *testing.T) {
+	defer func() { stringDecoder = nil }()
+	for _, c := range []struct {
+		charset, in, want string
+	}{
+		{"latin1", "caf\xe9", "café"},
+		{"ISO-8859-1", "\xfcber", "über"},
+		{"windows-1252", "\x93quoted\x94 \x80", "“quoted” €"},
+		{"cp1252", "\x81", "�"},
+	} {
+		if err := setStringEncoding(c.charset); err != nil {
+			t.Fatalf("%s: %v", c.charset, err)
+		}
+		if got := stringDecoder(c.in); got != c.want {
+			t.Errorf("%s: %q gives %q, want %q", c.charset, c.in, got, c.want)
+		}
+	}
+}
+
+func TestStringEncodingGBK(t *testing.T) {
+	defer func() { stringDecoder = nil }()
+	for _, charset := range []string{"gbk", "GB2312", "cp936"} {
+		if err := setStringEncoding(charset); err != nil {
+			t.Fatalf("%s: %v", charset, err)
+		}
+		// 中文 in GBK, then a lead byte with nothing after it
+		if got, want := stringDecoder("\xd6\xd0\xce\xc4 ok \xd6"), "中文 ok \ufffd"; got != want {
+			t.Errorf("%s: got %q, want %q", charset, got, want)
+		}
+	}
+}
+
+// Big5 needs tables this build does not include; asking for it must
+// fail rather than show mojibake.
+func TestStringEncodingBig5Unsupported(t *testing.T) {
+	defer func() { stringDecoder = nil }()
+	err := setStringEncoding("big5")
+	if err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("got %v, want a not supported error", err)
+	}
+	if stringDecoder != nil {
+		t.Error("a failed -string-encoding left a decoder set")
+	}
+}
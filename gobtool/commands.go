@@ -0,0 +1,160 @@
+package gobtool
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// command is a subcommand of the tool, selected by the first argument.
+type command struct {
+	name  string
+	usage string
+	// flags registers the command's flags on fs and returns the function
+	// that runs it on the arguments left once they are parsed. __complete
+	// calls it too, only to list the flags.
+	flags func(fs *flag.FlagSet) func(args []string) error
+	subs  []*command // subcommands, selected by the next argument
+	// hidden commands are left out of the usage message
+	hidden bool
+}
+
+// run parses the command's flags from args and runs it. A command with
+// subcommands runs the one args starts with instead.
+func (c *command) run(args []string) error {
+	if len(c.subs) == 0 {
+		fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+		return c.flags(fs)(parseArgs(fs, args))
+	}
+	if len(args) > 0 {
+		if sub := c.sub(args[0]); sub != nil {
+			fs := flag.NewFlagSet(c.name+" "+sub.name, flag.ExitOnError)
+			return sub.flags(fs)(parseArgs(fs, args[1:]))
+		}
+	}
+	return errors.New("usage: " + c.usage)
+}
+
+// sub returns c's subcommand called name, or nil.
+func (c *command) sub(name string) *command {
+	for _, s := range c.subs {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+var commands = []*command{
+	{name: "goth", usage: "goth [-strip-options] [-pretty-session [-show-tokens]] [file]", flags: gothFlags},
+	{name: "decode", usage: "decode [-format tree|flat|dot|json|jsonschema] [flags] file.gob (decode -h lists them)", flags: decodeFlags},
+	{name: "ls", usage: "ls file.gob", flags: lsFlags},
+	{name: "contains", usage: "contains file.gob key-or-path", flags: containsFlags},
+	{name: "encode", usage: "encode [-in file.json] [-store dir | -shards N] [-buffer-size N] [-stream [-count-footer] | -from-ndjson] [-sort-slices] [-interchange] [-drop key... [-deep]] [-max-encoded-size N [-warn-only] [-cookie name [-cookie-encrypted]]] [-strict-warnings] out.gob", flags: encodeFlags},
+	{name: "manifest", usage: "manifest [-errors-out file] dir | manifest -verify manifest.txt [-errors-out file]", flags: manifestFlags},
+	{name: "record", usage: "record [-out baseline.jsonl] dir", flags: recordFlags},
+	{name: "replay", usage: "replay baseline.jsonl dir", flags: replayFlags},
+	{name: "registry", usage: "registry gen [-n] [dir | dir/...]...", subs: []*command{{name: "gen", flags: registryGenFlags}}},
+	{name: "reencode", usage: "reencode [-drop glob]... [-transform glob=fn]... [-root-type t] [-trim-leading] in.gob out.gob", flags: reencodeFlags},
+	{name: "compare", usage: "compare [-format table|json|csv] [-ignore glob]... [-root-type t] [-trim-leading] a.gob b.gob [c.gob...]", flags: compareFlags},
+	{name: "hash", usage: "hash [-w | -verify] file.gob...", flags: hashFlags},
+	{name: "session", usage: "session head [-column name=expr]... source... | session gc -dir source [flags]", subs: []*command{{name: "head", flags: sessionHeadFlags}, {name: "gc", flags: sessionGCFlags}}},
+	{name: "aliasing", usage: "aliasing file.gob", flags: aliasingFlags},
+	{name: "migrate", usage: "migrate [flags] in.gob out.gob", flags: migrateFlags},
+	{name: "identify", usage: "identify file...", flags: identifyFlags},
+	{name: "diff", usage: "diff [-side-by-side [-width n]] [-flatten-depth n] [-float-format verb] [-float-epsilon x] [-root-type t] [-trim-leading] a.gob b.gob", flags: diffFlags},
+	{name: "selftest", usage: "selftest [-sizes n,n,...] [-seed n] [-keep]", flags: selftestFlags},
+	{name: "stats", usage: "stats [-format table|json] [-buckets b,b,...] [-value-buckets b,b,...] [-top n] [-sample N[:first|stride|random]] file-or-dir...", flags: statsFlags},
+	{name: "filter", usage: "filter -where expr -out out.gob in.gob", flags: filterFlags},
+	{name: "eval", usage: "eval [-session] expr file", flags: evalFlags},
+	{name: "types", usage: "types [-max-type-depth n] file.gob", flags: typesFlags},
+	{name: "corrupt", usage: "corrupt [-truncate pct]... [-flip-bits n]... [-zero off:len]... [-duplicate] [-strip-last] [-seed n] [-out dir] in.gob", flags: corruptFlags},
+	{name: "optimize", usage: "optimize [-gzip] [-framed] -out out.gob file-or-dir...", flags: optimizeFlags},
+	{name: "rename-key", usage: "rename-key -from glob -to name [-on-conflict skip|overwrite|error] [-yes] file-or-dir...", flags: renameKeyFlags},
+	{name: "shrink", usage: "shrink (-fails-with text | -cmd command [-exit-code n] [-fails-with text]) [-seed n] [-max-tests n] [-out file] in.gob", flags: shrinkFlags},
+	{name: "delta", usage: "delta base.gob new.gob -out new.delta", flags: deltaFlags},
+	{name: "apply-delta", usage: "apply-delta base.gob new.delta [-out new.gob]", flags: applyDeltaFlags},
+	{name: "gc", usage: "gc -store dir [-yes] ref-file-or-dir...", flags: gcFlags},
+	{name: "info", usage: "info file", flags: infoFlags},
+	{name: "grpc-serve", usage: "grpc-serve [-listen addr] [-tls-cert file -tls-key file] [-max-bytes n] [-value-timeout d] [-allow-type name]...", flags: grpcServeFlags},
+}
+
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-read-only] [-audit-file file] [-broken-pipe-exit n] [-deadline d] [command] [flags] [args]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		if !c.hidden {
+			fmt.Fprintf(os.Stderr, "  %s\n", c.usage)
+		}
+	}
+}
+
+// runCommand dispatches args[0] to its subcommand and exits on failure.
+func runCommand(args []string) {
+	args, err := parseGlobalFlags(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(args) == 0 {
+		printUsage()
+		os.Exit(2)
+	}
+	c := lookupCommand(args[0])
+	if c == nil {
+		printUsage()
+		os.Exit(2)
+	}
+	handleBrokenPipes()
+	safety.command = c.name
+	for _, sub := range c.subs {
+		if len(args) > 1 && args[1] == sub.name {
+			safety.command += " " + sub.name
+		}
+	}
+	defer startDeadline()()
+	err = c.run(args[1:])
+	var code exitCode
+	if errors.As(err, &code) {
+		os.Exit(int(code))
+	}
+	if isRunDeadline(err) {
+		exitAtDeadline(err)
+	}
+	if isBrokenPipe(err) {
+		os.Exit(brokenPipeExit)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", c.name, err)
+	}
+}
+
+// exitCode is returned by commands whose exit status carries meaning
+// beyond success or failure; any message has already been printed.
+type exitCode int
+
+func (c exitCode) Error() string { return fmt.Sprintf("exit status %d", int(c)) }
+
+// parseArgs parses flags that may appear before, between or after the
+// positional arguments, which it returns.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	var pos []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
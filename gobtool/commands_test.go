@@ -0,0 +1,35 @@
+package gobtool
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// runCaptured runs the named command with args and returns what it
+// printed on stdout.
+func runCaptured(t *testing.T, name string, args ...string) (string, error) {
+	t.Helper()
+	c := lookupCommand(name)
+	if c == nil {
+		t.Fatalf("no command %s", name)
+	}
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+	runErr := c.run(args)
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	printed, err := io.ReadAll(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(printed), runErr
+}
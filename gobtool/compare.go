@@ -0,0 +1,152 @@
+package gobtool
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// compare lines up any number of snapshots leaf by leaf and reports the
+// paths whose values are not the same in all of them, with the files
+// grouped by the value they hold.
+
+const missingValue = "<missing>"
+
+// valueGroup is one distinct value of a path and the files holding it.
+type valueGroup struct {
+	Value string   `json:"value"`
+	Files []string `json:"files"`
+}
+
+// pathDiff is a path whose value differs between files.
+type pathDiff struct {
+	Path   string       `json:"path"`
+	Values []valueGroup `json:"values"`
+	byFile []string     // value in each file, for the matrix view
+}
+
+// compareFiles decodes each file and returns the differing leaf paths,
+// sorted, leaving out the ignored ones.
+func compareFiles(files []string, ignore ignoreGlobs) ([]*pathDiff, error) {
+	leaves := make([]map[string]string, len(files))
+	all := make(map[string]bool)
+	for i, name := range files {
+		data, err := decodeAnyFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		lines, err := flatLines(data)
+		if err != nil {
+			return nil, err
+		}
+		leaves[i] = make(map[string]string, len(lines))
+		for p, line := range lines {
+			leaves[i][p] = strings.TrimPrefix(line, p+" = ")
+			all[p] = true
+		}
+	}
+	paths := make([]string, 0, len(all))
+	for p := range all {
+		if !ignore.ignored(p) {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var diffs []*pathDiff
+	for _, p := range paths {
+		d := &pathDiff{Path: p, byFile: make([]string, len(files))}
+		index := make(map[string]int)
+		for i, name := range files {
+			v, ok := leaves[i][p]
+			if !ok {
+				v = missingValue
+			}
+			d.byFile[i] = v
+			g, ok := index[v]
+			if !ok {
+				g = len(d.Values)
+				index[v] = g
+				d.Values = append(d.Values, valueGroup{Value: v})
+			}
+			d.Values[g].Files = append(d.Values[g].Files, name)
+		}
+		if len(d.Values) > 1 {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs, nil
+}
+
+func writeCompareTable(w io.Writer, diffs []*pathDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tVALUE\tFILES")
+	for _, d := range diffs {
+		for i, g := range d.Values {
+			p := d.Path
+			if i > 0 {
+				p = ""
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", p, g.Value, strings.Join(g.Files, ", "))
+		}
+	}
+	return tw.Flush()
+}
+
+// writeCompareCSV writes the matrix: one row per path, one column per file.
+func writeCompareCSV(w io.Writer, files []string, diffs []*pathDiff) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"path"}, files...))
+	for _, d := range diffs {
+		cw.Write(append([]string{d.Path}, d.byFile...))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// compareFlags registers the flags of compare; the command returned
+// reports the paths that differ across several gob files.
+func compareFlags(fs *flag.FlagSet) func(args []string) error {
+	format := fs.String("format", "table", "output format: table, json or csv")
+	fs.Func("float-format", "print floats with this fmt verb, such as %.6g or %.2f", setFloatFormat)
+	fs.Func("root-type", rootTypeUsage, setRootType)
+	fs.BoolVar(&trimLeading, "trim-leading", false, trimLeadingUsage)
+	var ignore ignoreGlobs
+	fs.Var(&ignore, "ignore", "leave out paths matching this glob (repeatable)")
+	return func(files []string) error {
+		if len(files) < 2 {
+			return errors.New("usage: compare [-format table|json|csv] [-ignore glob]... [-root-type t] [-trim-leading] a.gob b.gob [c.gob...]")
+		}
+		registerKnownTypes()
+		diffs, err := compareFiles(files, ignore)
+		if err != nil {
+			return err
+		}
+		switch *format {
+		case "table":
+			if err := writeCompareTable(os.Stdout, diffs); err != nil {
+				return err
+			}
+			fmt.Printf("%d paths differ across %d files\n", len(diffs), len(files))
+			return nil
+		case "json":
+			if diffs == nil {
+				diffs = []*pathDiff{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			return enc.Encode(diffs)
+		case "csv":
+			return writeCompareCSV(os.Stdout, files, diffs)
+		}
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
@@ -0,0 +1,103 @@
+package gobtool
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// __complete lists subcommands and flags for shell completion scripts.
+// Its output is one item per line:
+//
+//	__complete                 command names
+//	__complete cmd [sub]       subcommand names of cmd, then its flags
+//	__complete -all            every command path and flag
+//
+// Flags are printed as "-name<TAB>bool|value<TAB>usage", and -all
+// prefixes each line with the command path and a tab.
+
+func init() {
+	commands = append(commands, &command{name: "__complete", usage: "__complete [-all | cmd [sub]]", flags: completeFlags, hidden: true})
+}
+
+// commandFlags returns the flag set c registers, without running c.
+func commandFlags(c *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.flags(fs)
+	return fs
+}
+
+// flagLines describes the flags of fs in __complete's format, sorted by
+// name.
+func flagLines(fs *flag.FlagSet) []string {
+	var lines []string
+	fs.VisitAll(func(f *flag.Flag) {
+		kind := "value"
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			kind = "bool"
+		}
+		usage := strings.ReplaceAll(f.Usage, "\n", " ")
+		lines = append(lines, fmt.Sprintf("-%s\t%s\t%s", f.Name, kind, usage))
+	})
+	return lines
+}
+
+// completeFlags registers the flags of __complete; the command returned
+// prints what __complete lists.
+func completeFlags(fs *flag.FlagSet) func(args []string) error {
+	all := fs.Bool("all", false, "list every command path and its flags")
+	return func(args []string) error {
+		switch {
+		case *all && len(args) == 0:
+			for _, c := range commands {
+				if c.hidden {
+					continue
+				}
+				if len(c.subs) == 0 {
+					fmt.Println(c.name)
+					for _, l := range flagLines(commandFlags(c)) {
+						fmt.Printf("%s\t%s\n", c.name, l)
+					}
+					continue
+				}
+				for _, sub := range c.subs {
+					path := c.name + " " + sub.name
+					fmt.Println(path)
+					for _, l := range flagLines(commandFlags(sub)) {
+						fmt.Printf("%s\t%s\n", path, l)
+					}
+				}
+			}
+			return nil
+		case *all:
+			return errors.New("usage: __complete [-all | cmd [sub]]")
+		case len(args) == 0:
+			for _, c := range commands {
+				if !c.hidden {
+					fmt.Println(c.name)
+				}
+			}
+			return nil
+		}
+		c := lookupCommand(args[0])
+		if c == nil || c.hidden || len(args) > 2 || len(args) == 2 && len(c.subs) == 0 {
+			return errors.New("usage: __complete [-all | cmd [sub]]")
+		}
+		if len(c.subs) > 0 {
+			if len(args) == 1 {
+				for _, sub := range c.subs {
+					fmt.Println(sub.name)
+				}
+				return nil
+			}
+			if c = c.sub(args[1]); c == nil {
+				return errors.New("usage: __complete [-all | cmd [sub]]")
+			}
+		}
+		for _, l := range flagLines(commandFlags(c)) {
+			fmt.Println(l)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,27 @@
+package gobtool
+
+import "testing"
+
+func TestEveryCommandRegistersFlags(t *testing.T) {
+	for _, c := range commands {
+		if len(c.subs) == 0 && c.flags == nil {
+			t.Errorf("%s has no flags function", c.name)
+		}
+		for _, sub := range c.subs {
+			if sub.flags == nil {
+				t.Errorf("%s %s has no flags function", c.name, sub.name)
+			}
+		}
+	}
+}
+
+func TestCommandFlagsDoesNotRun(t *testing.T) {
+	// With no arguments, running session gc would fail for want of -dir
+	// and reencode for want of files; listing their flags must not.
+	if fs := commandFlags(lookupCommand("session").sub("gc")); fs.Lookup("dir") == nil {
+		t.Error("session gc: no -dir flag")
+	}
+	if fs := commandFlags(lookupCommand("reencode")); fs.Lookup("transform") == nil {
+		t.Error("reencode: no -transform flag")
+	}
+}
@@ -0,0 +1,102 @@
+package gobtool
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// openPayload returns the gob stream behind r: the blob of a store
+// reference, decoded if it is base64 text, and decompressed if it is gzip
+// data. See unwrapPayload.
+func openPayload(r io.Reader, refPath string) (io.Reader, error) {
+	return unwrapPayload(r, refPath, nil)
+}
+
+// gunzipReader remembers whether decompression itself failed, so that a
+// decode error can be blamed on the right layer.
+type gunzipReader struct {
+	zr  *gzip.Reader
+	err error
+}
+
+func (g *gunzipReader) Read(p []byte) (int, error) {
+	n, err := g.zr.Read(p)
+	if err != nil && err != io.EOF {
+		g.err = err
+	}
+	return n, err
+}
+
+// payloadError explains a decode error on a stream from openPayload. A
+// format error in decompressed data says so; other errors, such as an
+// unregistered type, a type mismatch or a deadline, are about the caller
+// rather than the data and pass through unchanged.
+func payloadError(r io.Reader, err error) error {
+	g, ok := r.(*gunzipReader)
+	switch {
+	case err == nil || !ok:
+		return err
+	case g.err != nil:
+		return &corruptPayloadError{"gzip data is corrupt", g.err}
+	case isGobFormatError(err):
+		return &corruptPayloadError{"decompressed gzip content is not valid gob", err}
+	default:
+		return err
+	}
+}
+
+// corruptPayloadError is a failure to read a payload that is blamed on
+// the data: a layer around the gob stream, or the gob inside it.
+type corruptPayloadError struct {
+	what string
+	err  error
+}
+
+func (e *corruptPayloadError) Error() string { return e.what + ": " + e.err.Error() }
+
+func (e *corruptPayloadError) Unwrap() error { return e.err }
+
+// gobFormatMarkers are fragments of the messages encoding/gob gives for
+// malformed data. encoding/gob has no error types or sentinels to test
+// for, so its errors can only be told apart by their text; the readers
+// here return formatError and the sentinels isGobFormatError checks
+// first.
+var gobFormatMarkers = []string{
+	"corrupted data",
+	"bad data",
+	"unknown type id",
+	"bad type id",
+	"message length",
+	"exceeds input",
+	"left over",
+	"extra data",
+	"invalid uint",
+	"bad field delta",
+	"type definition",
+	"out of range",
+	"slice length",
+	"type name length",
+	"duplicate type received",
+}
+
+// isGobFormatError reports whether err, from decoding gob, means the data
+// is not well-formed gob. Matching encoding/gob's messages is the last
+// resort, for errors that have nothing else to go by.
+func isGobFormatError(err error) bool {
+	var format *formatError
+	if errors.As(err, &format) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errWireOverrun) ||
+		errors.Is(err, errTypesTooDeep) || errors.Is(err, errBadTypeDef) {
+		return true
+	}
+	msg := err.Error()
+	for _, m := range gobFormatMarkers {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package gobtool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func gzipped(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// decodeGzipped decodes the gob inside the gzip data b into v and returns
+// the error as payloadError explains it.
+func decodeGzipped(t *testing.T, b []byte, v interface{}) error {
+	t.Helper()
+	r, err := openPayload(bytes.NewReader(gzipped(t, b)), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payloadError(r, gob.NewDecoder(r).Decode(v))
+}
+
+func TestPayloadErrorFormatError(t *testing.T) {
+	var v map[string]int
+	err := decodeGzipped(t, []byte("\x05\xff\xff\xff\xff\xff"), &v)
+	if err == nil || !strings.Contains(err.Error(), "not valid gob") {
+		t.Errorf("got %v, want a \"not valid gob\" error", err)
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	err = decodeGzipped(t, buf.Bytes()[:buf.Len()-2], &v)
+	if err == nil || !strings.Contains(err.Error(), "not valid gob") {
+		t.Errorf("truncated: got %v, want a \"not valid gob\" error", err)
+	}
+}
+
+func TestPayloadErrorPassesOtherErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	err := decodeGzipped(t, buf.Bytes(), &s)
+	if err == nil || strings.Contains(err.Error(), "not valid gob") {
+		t.Errorf("type mismatch: got %v", err)
+	}
+
+	r := &gunzipReader{}
+	if got := payloadError(r, os.ErrDeadlineExceeded); got != os.ErrDeadlineExceeded {
+		t.Errorf("deadline: got %v", got)
+	}
+	unregistered := errors.New(`gob: name not registered for interface: "main.T"`)
+	if got := payloadError(r, unregistered); got != unregistered {
+		t.Errorf("unregistered type: got %v", got)
+	}
+}
+
+// TestGzipMembers reads a file of two gzip members, each a gob stream of
+// its own, as log rotation concatenates them.
+func TestGzipMembers(t *testing.T) {
+	want := []interface{}{
+		map[interface{}]interface{}{"member": 1},
+		map[interface{}]interface{}{"member": 2},
+	}
+	var file []byte
+	for _, v := range want {
+		var buf bytes.Buffer
+		if err := encodeRoot(gob.NewEncoder(&buf), v); err != nil {
+			t.Fatal(err)
+		}
+		file = append(file, gzipped(t, buf.Bytes())...)
+	}
+	var st layoutStats
+	var got []interface{}
+	if err := readLayoutBytes(file, &st, func(rec interface{}, _ reflect.Type) { got = append(got, rec) }); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want both members %v", got, want)
+	}
+}
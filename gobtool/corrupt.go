@@ -0,0 +1,271 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// corrupt makes damaged variants of a valid gob file, for testing how
+// services handle bad session data. Every variant is written next to the
+// others with a suffix saying what was done, and a manifest records the
+// details, down to the bits flipped, together with identify's verdict on
+// the result. Random choices come from -seed, so the same command always
+// makes the same files.
+
+// corruption is one variant, as listed in the manifest.
+type corruption struct {
+	File     string  `json:"file"`
+	Op       string  `json:"op"` // truncate, flip-bits, zero, duplicate or strip-last
+	Detail   string  `json:"detail"`
+	Bits     []int64 `json:"bits,omitempty"` // bits flipped, as byte*8+bit
+	Size     int     `json:"size"`
+	Identify string  `json:"identify"`
+}
+
+// corruptOptions selects the variants. With none selected, all are made
+// with their defaults.
+type corruptOptions struct {
+	truncate []int // percentages
+	flips    []int // bit counts
+	zero     []string
+	dup      bool
+	strip    bool
+	seed     int64
+}
+
+func corruptFlags(fs *flag.FlagSet) func(args []string) error {
+	var o corruptOptions
+	fs.Func("truncate", "cut the file at this percentage of its size (repeatable)", func(s string) error {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "%"))
+		if err != nil || n < 0 || n >= 100 {
+			return fmt.Errorf("want a percentage below 100, not %q", s)
+		}
+		o.truncate = append(o.truncate, n)
+		return nil
+	})
+	fs.Func("flip-bits", "flip this many random bits (repeatable)", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("want a positive bit count, not %q", s)
+		}
+		o.flips = append(o.flips, n)
+		return nil
+	})
+	fs.Func("zero", "zero the byte range off:len (repeatable)", func(s string) error {
+		if _, _, err := parseByteSpan(s); err != nil {
+			return err
+		}
+		o.zero = append(o.zero, s)
+		return nil
+	})
+	fs.BoolVar(&o.dup, "duplicate", false, "repeat the last top-level value, or the last frame of a netstring-framed log")
+	fs.BoolVar(&o.strip, "strip-last", false, "remove the last top-level value, or the last frame of a netstring-framed log")
+	fs.Int64Var(&o.seed, "seed", 1, "seed for -flip-bits")
+	outDir := fs.String("out", "", "write the variants here (default: next to the input)")
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errors.New("usage: corrupt [-truncate pct]... [-flip-bits n]... [-zero off:len]... [-duplicate] [-strip-last] [-seed n] [-out dir] in.gob")
+		}
+		in := args[0]
+		f, err := openFile(in)
+		if err != nil {
+			return err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("%s: empty file", in)
+		}
+		if len(o.truncate) == 0 && len(o.flips) == 0 && len(o.zero) == 0 && !o.dup && !o.strip {
+			o.truncate = []int{25, 50, 75}
+			o.flips = []int{1, 8}
+			o.zero = []string{fmt.Sprintf("%d:%d", len(data)/2, min(16, len(data)-len(data)/2))}
+			o.dup, o.strip = true, true
+		}
+
+		dir := *outDir
+		base, ext := strings.TrimSuffix(filepath.Base(in), filepath.Ext(in)), filepath.Ext(in)
+		if isDataURI(in) || strings.HasPrefix(in, "fd:") {
+			// There is no path to put the variants next to or name
+			// them after.
+			if dir == "" {
+				return errors.New("-out is needed for a data: URI or fd:N input")
+			}
+			base, ext = "input", ".gob"
+		}
+		if dir == "" {
+			dir = filepath.Dir(in)
+		}
+		var made []corruption
+		add := func(suffix string, c corruption, variant []byte) error {
+			c.File = filepath.Join(dir, base+"."+suffix+ext)
+			c.Size = len(variant)
+			if err := writeFileAtomic(c.File, variant); err != nil {
+				return err
+			}
+			if id, err := identify(c.File); err == nil {
+				c.Identify = fmt.Sprintf("%s (%s confidence)", id.Format, id.Confidence)
+			}
+			made = append(made, c)
+			fmt.Printf("%s: %s %s\n", c.File, c.Op, c.Detail)
+			return nil
+		}
+
+		for _, pct := range o.truncate {
+			n := len(data) * pct / 100
+			detail := fmt.Sprintf("kept %d of %d bytes", n, len(data))
+			if err := add(fmt.Sprintf("trunc%d", pct), corruption{Op: "truncate", Detail: detail}, data[:n]); err != nil {
+				return err
+			}
+		}
+		for _, n := range o.flips {
+			variant, bits := flipBits(data, n, o.seed)
+			detail := fmt.Sprintf("%d bits, seed %d", n, o.seed)
+			if n == 1 {
+				detail = fmt.Sprintf("1 bit, seed %d", o.seed)
+			}
+			suffix := fmt.Sprintf("flip%d-seed%d", n, o.seed)
+			if err := add(suffix, corruption{Op: "flip-bits", Detail: detail, Bits: bits}, variant); err != nil {
+				return err
+			}
+		}
+		for _, span := range o.zero {
+			off, n, _ := parseByteSpan(span)
+			if off+n > int64(len(data)) {
+				return fmt.Errorf("-zero %s: beyond the %d-byte file", span, len(data))
+			}
+			variant := bytes.Clone(data)
+			clear(variant[off : off+n])
+			detail := fmt.Sprintf("bytes %d to %d", off, off+n)
+			if err := add(fmt.Sprintf("zero%d-%d", off, off+n), corruption{Op: "zero", Detail: detail}, variant); err != nil {
+				return err
+			}
+		}
+		if o.dup || o.strip {
+			spans, unit := netstringSpans(data), "netstring frame"
+			if spans == nil {
+				if spans, err = valueSpans(data); err != nil {
+					return fmt.Errorf("%s: %w", in, err)
+				}
+				unit = "value"
+			}
+			last := spans[len(spans)-1]
+			where := fmt.Sprintf("%s %d, bytes %d to %d", unit, len(spans)-1, last[0], last[1])
+			if o.dup {
+				variant := append(bytes.Clone(data[:last[1]]), data[last[0]:]...)
+				if err := add("dup", corruption{Op: "duplicate", Detail: where + ", repeated"}, variant); err != nil {
+					return err
+				}
+			}
+			if o.strip {
+				variant := append(bytes.Clone(data[:last[0]]), data[last[1]:]...)
+				if err := add("striplast", corruption{Op: "strip-last", Detail: where + ", removed"}, variant); err != nil {
+					return err
+				}
+			}
+		}
+
+		manifest := filepath.Join(dir, base+".corrupt.json")
+		b, err := json.MarshalIndent(made, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(manifest, append(b, '\n')); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d variants\n", manifest, len(made))
+		return nil
+	}
+}
+
+// parseByteSpan parses off:len.
+func parseByteSpan(s string) (off, n int64, err error) {
+	a, b, ok := strings.Cut(s, ":")
+	if ok {
+		off, err = strconv.ParseInt(a, 10, 64)
+		if err == nil {
+			n, err = strconv.ParseInt(b, 10, 64)
+		}
+	}
+	if !ok || err != nil || off < 0 || n <= 0 {
+		return 0, 0, fmt.Errorf("want off:len, not %q", s)
+	}
+	return off, n, nil
+}
+
+// flipBits returns data with n distinct bits flipped, chosen from seed,
+// and the flipped bits in the order chosen.
+func flipBits(data []byte, n int, seed int64) ([]byte, []int64) {
+	rng := rand.New(rand.NewSource(seed))
+	total := int64(len(data)) * 8
+	n = int(min(int64(n), total))
+	out := bytes.Clone(data)
+	seen := make(map[int64]bool, n)
+	offsets := make([]int64, 0, n)
+	for len(offsets) < n {
+		bit := rng.Int63n(total)
+		if seen[bit] {
+			continue
+		}
+		seen[bit] = true
+		out[bit/8] ^= 1 << (bit % 8)
+		offsets = append(offsets, bit)
+	}
+	return out, offsets
+}
+
+// valueSpans returns the byte range of each top-level value message in
+// data, type definitions excluded.
+func valueSpans(data []byte) ([][2]int64, error) {
+	w := newWireReader(bytes.NewReader(data), 0)
+	var spans [][2]int64
+	for {
+		id, err := w.nextMessage()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("not a valid gob stream: %w", err)
+		}
+		start := w.msg
+		if _, err := w.topValue(id, false); err != nil {
+			return nil, fmt.Errorf("not a valid gob stream: %w", err)
+		}
+		if err := w.skip(w.remain); err != nil {
+			return nil, err
+		}
+		spans = append(spans, [2]int64{start, w.off})
+	}
+	if len(spans) == 0 {
+		return nil, errors.New("no values")
+	}
+	return spans, nil
+}
+
+// netstringSpans returns the byte range of each frame if data is a
+// netstring-framed log, and nil otherwise.
+func netstringSpans(data []byte) [][2]int64 {
+	d := NewNetstringDecoder(bytes.NewReader(data))
+	var spans [][2]int64
+	for {
+		start := d.off
+		if _, err := d.frame(); err != nil {
+			if err == io.EOF {
+				return spans
+			}
+			return nil
+		}
+		spans = append(spans, [2]int64{start, d.off})
+	}
+}
@@ -0,0 +1,86 @@
+package gobtool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"test-gob/errclass"
+)
+
+// TestCorruptVariantClasses checks the error class that record, and so
+// replay and manifest, give each kind of damage.
+func TestCorruptVariantClasses(t *testing.T) {
+	registerKnownTypes()
+	var buf bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&buf), map[string]interface{}{"a": []int{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	badID := rewriteValue(t, data, func(id typeID, body []byte) (typeID, []byte) { return id + 50, body })
+	// The body of the value message is a zero byte and the map's count.
+	badCount := rewriteValue(t, data, func(id typeID, body []byte) (typeID, []byte) {
+		if body[0] != 0 || body[1] != 1 {
+			t.Fatalf("unexpected value message: % x", body)
+		}
+		body[1] = 0x40
+		return id, body
+	})
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(data)
+	zw.Close()
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.gob")
+	if err := os.WriteFile(in, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCaptured(t, "corrupt", "-truncate", "50", in); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name string
+		data []byte // nil for a file corrupt wrote
+		want errclass.Class
+	}{
+		{"in.gob", data, ""},
+		{"in.trunc50.gob", nil, errclass.Truncated},
+		{"type-id.gob", badID, errclass.NotGob},
+		{"count.gob", badCount, errclass.NotGob},
+		{"gzip-truncated.gob", gz.Bytes()[:gz.Len()/2], errclass.Truncated},
+		{"gzip-not-deflated.gob", append(bytes.Clone(gz.Bytes()[:10]), data...), errclass.NotGob},
+		{"zstd.gob", append([]byte{0x28, 0xb5, 0x2f, 0xfd}, data...), errclass.NotGob},
+	} {
+		name := filepath.Join(dir, c.name)
+		if c.data != nil {
+			if err := os.WriteFile(name, c.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := recordFile(name).ErrorClass; got != c.want {
+			_, err := decodeAnyFile(name)
+			t.Errorf("%s: class %q, want %q (%v)", c.name, got, c.want, err)
+		}
+	}
+}
+
+// rewriteValue re-encodes the first value message of data, after the
+// type definitions, with edit applied to its type id and a copy of its
+// body.
+func rewriteValue(t *testing.T, data []byte, edit func(id typeID, body []byte) (typeID, []byte)) []byte {
+	t.Helper()
+	w := newWireReader(bytes.NewReader(data), 0)
+	id, err := w.nextMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := w.off + w.remain
+	id, body := edit(id, bytes.Clone(data[w.off:end]))
+	msg := append(appendInt(nil, int64(id)), body...)
+	out := append(bytes.Clone(data[:w.msg]), appendUint(nil, uint64(len(msg)))...)
+	return append(append(out, msg...), data[end:]...)
+}
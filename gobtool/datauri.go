@@ -0,0 +1,135 @@
+package gobtool
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Input files may also be given as a data URI, for gob embedded in HTML
+// or config as data:application/gob;base64,... The payload may be base64,
+// standard or URL-safe and padded or not, or percent-encoded, as RFC 2397
+// allows, and the media type must be application/gob, with or without
+// parameters. openFile returns a data URI as a dataFile, which reads like
+// a pipe: every command that opens its input through openFile takes one.
+
+// gobMediaType is the media type a data URI must declare.
+const gobMediaType = "application/gob"
+
+// isDataURI reports whether name is a data URI rather than a path.
+func isDataURI(name string) bool {
+	return len(name) >= 5 && strings.EqualFold(name[:5], "data:")
+}
+
+// parseDataURI returns the payload of a data:application/gob URI.
+func parseDataURI(uri string) ([]byte, error) {
+	f, err := openDataURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("data URI payload: %w", err)
+	}
+	return b, nil
+}
+
+// openDataURI checks the header and payload of uri and returns a dataFile
+// that decodes the payload as it is read.
+func openDataURI(uri string) (*dataFile, error) {
+	if !isDataURI(uri) {
+		return nil, fmt.Errorf("not a data URI")
+	}
+	header, payload, ok := strings.Cut(uri[5:], ",")
+	if !ok {
+		return nil, fmt.Errorf("data URI has no comma before its payload")
+	}
+	name := uri[:5] + header
+	header, isBase64 := strings.CutSuffix(header, ";base64")
+	if header == "" {
+		return nil, fmt.Errorf("data URI has no media type, want %s", gobMediaType)
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return nil, fmt.Errorf("data URI media type %q: %w", header, err)
+	}
+	if mediaType != gobMediaType {
+		return nil, fmt.Errorf("data URI media type is %s, want %s", mediaType, gobMediaType)
+	}
+	// Base64 in a URI is often percent-encoded again.
+	if !isBase64 || strings.Contains(payload, "%") {
+		if payload, err = url.PathUnescape(payload); err != nil {
+			return nil, fmt.Errorf("data URI payload: %w", err)
+		}
+	}
+	if !isBase64 {
+		return &dataFile{Reader: strings.NewReader(payload), name: name, size: int64(len(payload))}, nil
+	}
+	enc, err := dataURIEncoding(payload)
+	if err != nil {
+		return nil, fmt.Errorf("data URI payload is not base64: %w", err)
+	}
+	return &dataFile{
+		Reader: base64.NewDecoder(enc, strings.NewReader(payload)),
+		name:   name,
+		size:   int64(len(strings.TrimRight(payload, "=")) * 6 / 8),
+	}, nil
+}
+
+// dataURIEncoding returns the base64 encoding s is in: standard or
+// URL-safe by the characters it uses, padded if it ends in "=". Unlike
+// base64Encoding, which sniffs unlabelled input, it takes short payloads
+// and rejects anything else.
+func dataURIEncoding(s string) (*base64.Encoding, error) {
+	std, urlSafe := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9':
+		case c == '+' || c == '/':
+			std = true
+		case c == '-' || c == '_':
+			urlSafe = true
+		case c == '=' && strings.TrimLeft(s[i:], "=") == "":
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+		}
+	}
+	if std && urlSafe {
+		return nil, errors.New("mixes the standard and URL-safe alphabets")
+	}
+	enc := base64.StdEncoding
+	if urlSafe {
+		enc = base64.URLEncoding
+	}
+	if !strings.HasSuffix(s, "=") {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc, nil
+}
+
+// dataFile is the payload of a data URI, read as openFile's files are.
+// Like a pipe, it cannot seek and Stat reports it as irregular.
+type dataFile struct {
+	io.Reader
+	name string // the URI up to its payload
+	size int64
+}
+
+func (f *dataFile) Name() string               { return f.name }
+func (f *dataFile) Close() error               { return nil }
+func (f *dataFile) Stat() (fs.FileInfo, error) { return dataFileInfo{f}, nil }
+
+type dataFileInfo struct{ f *dataFile }
+
+func (fi dataFileInfo) Name() string       { return fi.f.name }
+func (fi dataFileInfo) Size() int64        { return fi.f.size }
+func (fi dataFileInfo) Mode() fs.FileMode  { return fs.ModeIrregular | 0o444 }
+func (fi dataFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi dataFileInfo) IsDir() bool        { return false }
+func (fi dataFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,110 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dataURIPayload is a gob stream whose base64 uses both '+' or '-' and
+// '/' or '_', and needs padding.
+func dataURIPayload(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&buf), map[string]interface{}{"a": "x?>>", "b": 2}); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	s := base64.StdEncoding.EncodeToString(raw)
+	if !strings.ContainsAny(s, "+/") || !strings.HasSuffix(s, "=") {
+		t.Fatalf("payload base64 %s does not exercise the alphabets", s)
+	}
+	return raw
+}
+
+func TestParseDataURIEncodings(t *testing.T) {
+	raw := dataURIPayload(t)
+	for name, payload := range map[string]string{
+		"std":            base64.StdEncoding.EncodeToString(raw),
+		"std unpadded":   base64.RawStdEncoding.EncodeToString(raw),
+		"url":            base64.URLEncoding.EncodeToString(raw),
+		"url unpadded":   base64.RawURLEncoding.EncodeToString(raw),
+		"percent-quoted": url.QueryEscape(base64.StdEncoding.EncodeToString(raw)),
+	} {
+		for _, header := range []string{"application/gob;base64", "application/gob;v=1;base64"} {
+			got, err := parseDataURI("data:" + header + "," + payload)
+			if err != nil {
+				t.Errorf("%s, %s: %v", name, header, err)
+			} else if !bytes.Equal(got, raw) {
+				t.Errorf("%s, %s: wrong payload", name, header)
+			}
+		}
+	}
+	got, err := parseDataURI("data:application/gob," + url.PathEscape(string(raw)))
+	if err != nil || !bytes.Equal(got, raw) {
+		t.Errorf("percent-encoded: %v", err)
+	}
+}
+
+func TestParseDataURIErrors(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString(dataURIPayload(t))
+	for _, uri := range []string{
+		"data:text/plain;base64," + b64,
+		"data:;base64," + b64,
+		"data:application/gob;base64",
+		"data:application/gob;base64,not*base64",
+		"data:application/gob;base64,ab+c-d==",
+		"data:application/gob;base64,ab=cd",
+		"data:application/gob;base64,abcde",
+	} {
+		if _, err := parseDataURI(uri); err == nil {
+			t.Errorf("%.40s: no error", uri)
+		}
+	}
+}
+
+func TestOpenFileDataURI(t *testing.T) {
+	raw := dataURIPayload(t)
+	f, err := openFile("data:application/gob;base64," + base64.RawURLEncoding.EncodeToString(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().IsRegular() || fi.Size() != int64(len(raw)) {
+		t.Errorf("Stat: mode %v, size %d, want irregular and %d", fi.Mode(), fi.Size(), len(raw))
+	}
+	if f.Name() != "data:application/gob;base64" {
+		t.Errorf("Name() = %q", f.Name())
+	}
+	got, err := decodeAny(f, f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := got.(map[interface{}]interface{}); m["a"] != "x?>>" {
+		t.Errorf("decoded %#v", got)
+	}
+}
+
+func TestCorruptDataURI(t *testing.T) {
+	uri := "data:application/gob;base64," + base64.StdEncoding.EncodeToString(dataURIPayload(t))
+	corrupt := lookupCommand("corrupt")
+	if err := corrupt.run([]string{"-truncate", "50", uri}); err == nil {
+		t.Error("corrupt of a data URI without -out: no error")
+	}
+	dir := t.TempDir()
+	if err := corrupt.run([]string{"-truncate", "50", "-out", dir, uri}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "input.trunc50.gob")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,75 @@
+package gobtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// -deadline d, given before the command, bounds the whole run, for CI
+// jobs that must not hang. Decodes and session sources take their
+// context from runContext, so they stop at the deadline with an error
+// saying so; anything still running then, such as a read of a pipe that
+// never ends, is cut short by a watcher. Either way the run prints the
+// deadline on stderr and exits with deadlineExit, 124 as timeout(1)
+// does, so a script can tell it from a failure. -timeout and
+// -decode-timeout still apply to single decodes within it.
+
+// deadlineExit is the exit status of a run stopped by -deadline.
+const deadlineExit = 124
+
+// runDeadline is the -deadline flag; 0 means none.
+var runDeadline time.Duration
+
+// runContext is the context of the whole run. It ends at -deadline.
+var runContext = context.Background()
+
+// RunDeadlineError is the cause of runContext ending at -deadline.
+type RunDeadlineError struct {
+	Deadline time.Duration
+}
+
+func (e *RunDeadlineError) Error() string {
+	return fmt.Sprintf("run deadline of %s exceeded (-deadline)", e.Deadline)
+}
+
+func (e *RunDeadlineError) Unwrap() error { return context.DeadlineExceeded }
+
+var deadlineOnce sync.Once
+
+// exitAtDeadline reports err, a RunDeadlineError, and exits. It is
+// called by the watcher and by runCommand, whichever sees the deadline
+// first.
+func exitAtDeadline(err error) {
+	deadlineOnce.Do(func() {
+		log.Printf("%s: %v", safety.command, err)
+		os.Exit(deadlineExit)
+	})
+}
+
+// startDeadline sets runContext from -deadline and starts its watcher.
+// stop releases them once the command is done.
+func startDeadline() (stop func()) {
+	if runDeadline <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeoutCause(context.Background(), runDeadline, &RunDeadlineError{runDeadline})
+	runContext = ctx
+	go func() {
+		<-ctx.Done()
+		if err := context.Cause(ctx); isRunDeadline(err) {
+			exitAtDeadline(err)
+		}
+	}()
+	return cancel
+}
+
+// isRunDeadline reports whether err comes from -deadline expiring.
+func isRunDeadline(err error) bool {
+	var de *RunDeadlineError
+	return errors.As(err, &de)
+}
@@ -0,0 +1,38 @@
+package gobtool
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestRunDeadlineStopsDecode checks that -deadline stops a decode of a
+// slow reader with a RunDeadlineError, though the decode has no timeout
+// of its own.
+func TestRunDeadlineStopsDecode(t *testing.T) {
+	name := writeRoot(t, syntheticData(50, 1))
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	defer func(old context.Context) { runContext = old }(runContext)
+	const d = 50 * time.Millisecond
+	run, stop := context.WithTimeoutCause(context.Background(), d, &RunDeadlineError{d})
+	defer stop()
+	runContext = run
+	ctx, cancel, err := decodeContext(f, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	start := time.Now()
+	err = slowDecode(t, ctx, name)
+	if !isRunDeadline(err) {
+		t.Errorf("got %v, want the run deadline", err)
+	}
+	if took := time.Since(start); took > 20*d {
+		t.Errorf("stopped after %s for a %s deadline", took, d)
+	}
+}
@@ -0,0 +1,447 @@
+package gobtool
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// decodeFlags registers the flags of decode; the command returned
+// decodes a gob file and prints it in the chosen format.
+func decodeFlags(fs *flag.FlagSet) func(args []string) error {
+	format := fs.String("format", "tree", "output format: "+strings.Join(rendererNames(), ", ")+", or json-array for every record of a stream as one JSON array")
+	goPackage := fs.String("package", "fixtures", "with -format gofile, the package clause of the generated file")
+	flattenDepth := fs.Int("flatten-depth", 0, "with -format flat, flatten only this many levels (0 = all)")
+	renderJSONBytes := fs.Bool("render-json-bytes", false, "show byte slices that hold valid JSON as JSON (json.RawMessage always is)")
+	fs.BoolVar(&rawStrings, "raw-strings", false, "print strings as they are, without escaping control characters or replacing invalid UTF-8")
+	fs.Func("float-format", "print floats with this fmt verb, such as %.6g or %.2f", setFloatFormat)
+	fs.Func("bool-style", "print booleans as true-false (the default), yes-no or check (✓/✗)", setBoolStyle)
+	fs.Func("string-encoding", "show strings transcoded from this charset: latin1, windows-1252 or gbk", setStringEncoding)
+	collapse := fs.Bool("collapse-singletons", false, "with -format tree, show chains of one-element maps and slices as one entry with the combined path (display only)")
+	showUnexported := fs.Bool("show-unexported", false, "with -format tree, also show unexported struct fields (debugging aid; needs a build with -tags gobunsafe)")
+	var outCap outputCapFlag
+	outCap.register(fs)
+	var sample sampler
+	sample.register(fs)
+	var warns warningFlags
+	warns.register(fs)
+	var outputs outputFlags
+	outputs.register(fs)
+	var header headerFlags
+	header.register(fs)
+	var byteRange ByteRange
+	byteRange.register(fs)
+	var jwts jwtFlags
+	jwts.register(fs)
+	var renames []string
+	fs.Func("rename", "apply a field rename, Type.WireField=GoField (repeatable)", func(s string) error {
+		renames = append(renames, s)
+		return nil
+	})
+	renameFile := fs.String("rename-file", "", "read renames from this file, one per line")
+	schemaFile := fs.String("schema", "", "decode into the type described by this JSON Schema file")
+	strict := fs.Bool("strict", false, "fail if any decoded field has nowhere to go")
+	grep := fs.String("grep", "", "print only the string values matching this regexp, with their paths")
+	grepAll := fs.Bool("grep-all", false, "with -grep, match every scalar by its printed form")
+	var index *int
+	fs.Func("index", "decode only the record at this index of a multi-value stream (negative: from the end)", func(s string) error {
+		n, err := strconv.Atoi(s)
+		index = &n
+		return err
+	})
+	fs.Func("root-type", rootTypeUsage, setRootType)
+	fs.BoolVar(&trimLeading, "trim-leading", false, trimLeadingUsage)
+	tmplFile := fs.String("template", "", "render each top-level value through this text/template file")
+	var durations []durationPair
+	fs.Func("compute-durations", "after the output, print the time between two time fields, from=to such as created_at=expires_at (repeatable)", func(s string) error {
+		p, err := parseDurationPair(s)
+		durations = append(durations, p)
+		return err
+	})
+	countFooter := fs.Bool("count-footer", false, "with -index, -format json-array, -format jsonschema or -template, require the record-count footer written by encode -stream -count-footer and check it")
+	normalize := fs.Bool("normalize-ints", false, "convert decoded integers to int64, so output does not depend on how they were sent")
+	histogram := fs.Bool("type-histogram", false, "print how many values of each concrete type the file holds")
+	transformFile := fs.String("transform-file", "", "apply the transform rules in this file to the decoded values")
+	transformDryRun := fs.Bool("transform-dry-run", false, "with -transform-file, list the paths each rule would change and stop")
+	timeout := fs.Duration("timeout", 0, "give up decoding after this long")
+	timeoutPerMB := fs.Duration("timeout-per-mb", 0, "add this much to -timeout per megabyte of input; the total is at least 1s")
+	summaryDepth := fs.Int("summary-depth", 0, "decode only this many levels and summarize what is below, as map[12 keys] or string(52KB)")
+	valueTimeout := fs.Duration("decode-timeout", 0, "give up if the top-level value takes longer than this to decode, whatever -timeout allows")
+	fs.IntVar(&shardWorkers, "shard-workers", 0, "decode at most this many shards of a file written by encode -shards at once, 0 for one per CPU")
+	var retry retryPolicy
+	retry.register(fs)
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errors.New("usage: decode [flags] file.gob")
+		}
+		if err := jwts.apply(); err != nil {
+			return err
+		}
+		if *renameFile != "" {
+			specs, err := readRenameFile(*renameFile)
+			if err != nil {
+				return err
+			}
+			renames = append(renames, specs...)
+		}
+		rules, err := parseRenameRules(renames)
+		if err != nil {
+			return err
+		}
+		if index != nil && (*tmplFile != "" || *schemaFile != "" || len(rules) > 0 || *strict) {
+			return errors.New("-index cannot be combined with -template, -schema, -rename or -strict")
+		}
+		if rootType != "auto" && (index != nil || *schemaFile != "" || len(rules) > 0 || *strict) {
+			return errors.New("-root-type cannot be combined with -index, -schema, -rename or -strict")
+		}
+		if *showUnexported && !unexportedSupported {
+			return errors.New("-show-unexported needs a build with -tags gobunsafe")
+		}
+		if *collapse && *format != "tree" {
+			return errors.New("-collapse-singletons applies only to -format tree")
+		}
+		if *showUnexported && *format != "tree" {
+			return errors.New("-show-unexported applies only to -format tree")
+		}
+		jsonArray := *format == "json-array"
+		if jsonArray && (index != nil || *tmplFile != "" || *schemaFile != "" || len(rules) > 0 || *strict ||
+			rootType != "auto" || *grep != "" || *histogram || *transformDryRun || len(outputs.targets) > 0 || outputs.tee != "") {
+			return errors.New("-format json-array cannot be combined with -index, -template, -schema, -rename, -strict, -root-type, -grep, -type-histogram, -transform-dry-run, -out-<format> or -tee")
+		}
+		// -format jsonschema merges the schemas of every record of a stream
+		jsonSchema := *format == "jsonschema"
+		if jsonSchema && (index != nil || *tmplFile != "" || *schemaFile != "" || len(rules) > 0 || *strict || rootType != "auto" ||
+			*grep != "" || *histogram || *transformDryRun || *summaryDepth > 0 || durations != nil || sample.enabled() || len(outputs.targets) > 0 || outputs.tee != "") {
+			return errors.New("-format jsonschema cannot be combined with -index, -template, -schema, -rename, -strict, -root-type, -grep, -type-histogram, -transform-dry-run, -summary-depth, -compute-durations, -sample, -out-<format> or -tee")
+		}
+		if *countFooter && index == nil && !jsonArray && !jsonSchema && *tmplFile == "" {
+			return errors.New("-count-footer needs -index, -format json-array, -format jsonschema or -template, which read every record")
+		}
+		if durations != nil && (jsonArray || *tmplFile != "" || *transformDryRun) {
+			return errors.New("-compute-durations cannot be combined with -template, -transform-dry-run or -format json-array")
+		}
+		if sample.enabled() && (jsonArray || *tmplFile != "" || *format == "gofile") {
+			return errors.New("-sample cannot be combined with -template, -format json-array or -format gofile")
+		}
+		if *summaryDepth < 0 {
+			return errors.New("-summary-depth must be positive")
+		}
+		if *summaryDepth > 0 && (index != nil || *tmplFile != "" || *schemaFile != "" || len(rules) > 0 || *strict || rootType != "auto" || jsonArray) {
+			return errors.New("-summary-depth cannot be combined with -index, -template, -schema, -rename, -strict, -root-type or -format json-array")
+		}
+		if retry.retries < 0 {
+			return errors.New("-retries must not be negative")
+		}
+		if retry.retries > 0 && (jsonArray || *transformDryRun) {
+			return errors.New("-retries cannot be combined with -format json-array or -transform-dry-run, which write as they read")
+		}
+		if *valueTimeout > 0 && (jsonArray || *tmplFile != "") {
+			return errors.New("-decode-timeout bounds a single top-level value and cannot be combined with -template or -format json-array")
+		}
+		newRenderer, ok := renderers[*format]
+		if !ok && !jsonArray {
+			return fmt.Errorf("unknown format %q", *format)
+		}
+		opts := renderOptions{flattenDepth: *flattenDepth, showUnexported: *showUnexported, collapse: *collapse, guessJSON: *renderJSONBytes, goPackage: *goPackage, warnings: &warns.Warnings}
+		out, finish := outCap.wrap(os.Stdout, &warns.Warnings)
+		var schemaType reflect.Type
+		if *schemaFile != "" {
+			if schemaType, err = readSchemaType(*schemaFile); err != nil {
+				return err
+			}
+		}
+		var transforms []TransformRule
+		var transformSpecs []string
+		if *transformFile != "" {
+			if transforms, transformSpecs, err = readTransformFile(*transformFile); err != nil {
+				return err
+			}
+		}
+		var grepRE *regexp.Regexp
+		if *grep != "" {
+			if grepRE, err = regexp.Compile(*grep); err != nil {
+				return fmt.Errorf("-grep: %w", err)
+			}
+		}
+
+		f, err := openFile(args[0])
+		if err != nil {
+			return err
+		}
+		defer func() { f.Close() }()
+		src, err := OpenRange(f, byteRange)
+		if err != nil {
+			return err
+		}
+		// inRange adds absolute file offsets to errors when decoding a range
+		inRange := func(err error) error {
+			if byteRange == (ByteRange{}) {
+				return err
+			}
+			return src.annotate(err)
+		}
+		// openInput runs the input through the reader middleware: the
+		// header the flags describe, then whatever wraps the gob stream.
+		input := []ReaderMiddleware{header.middleware, payloadMiddleware(args[0])}
+		openInput := func(in io.Reader) (io.Reader, error) {
+			r, err := applyReaderMiddleware(in, input)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", args[0], err)
+			}
+			return r, nil
+		}
+		ctx, cancel, err := decodeContext(f, *timeout, *timeoutPerMB)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		in := ctxReader{ctx, src}
+		// reopen starts the input over for a -retries attempt. A path is
+		// opened again; an fd:N can only be read again if it is a file.
+		reopen := func() error {
+			if !strings.HasPrefix(args[0], "fd:") {
+				nf, err := openFile(args[0])
+				if err != nil {
+					return err
+				}
+				f.Close()
+				f = nf
+			} else if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+				return fmt.Errorf("%s: %w: not a regular file", args[0], errNoRetry)
+			}
+			if src, err = OpenRange(f, byteRange); err != nil {
+				return err
+			}
+			in = ctxReader{ctx, src}
+			return nil
+		}
+
+		registerKnownTypes()
+		if jsonArray {
+			err := withContext(ctx, func() error {
+				r, err := openInput(in)
+				if err != nil {
+					return err
+				}
+				return payloadError(r, writeJSONArray(out, r, transforms, *countFooter, &warns.Warnings))
+			})
+			if err := finish(inRange(err)); err != nil {
+				return err
+			}
+			return warns.report(os.Stderr)
+		}
+		if *tmplFile != "" || jsonSchema {
+			var records []interface{}
+			err := retry.do(args[0], func(attempt int) error {
+				if attempt > 0 {
+					if err := reopen(); err != nil {
+						return err
+					}
+				}
+				return withContext(ctx, func() error {
+					r, err := openInput(in)
+					if err != nil {
+						return err
+					}
+					records, err = decodeRecords(r, *countFooter)
+					return payloadError(r, err)
+				})
+			})
+			if err != nil {
+				return inRange(err)
+			}
+			for _, rec := range records {
+				if _, err := ApplyTransforms(rec, transforms, false); err != nil {
+					return err
+				}
+			}
+			if jsonSchema {
+				err = writeJSONSchema(out, records, &warns.Warnings)
+			} else {
+				err = renderTemplate(out, *tmplFile, records)
+			}
+			if err := finish(err); err != nil {
+				return err
+			}
+			return warns.report(os.Stderr)
+		}
+		var data interface{}
+		err = retry.do(args[0], func(attempt int) error {
+			if attempt > 0 {
+				if err := reopen(); err != nil {
+					return err
+				}
+			}
+			return withContext(ctx, func() (err error) {
+				data, err = decodeWithDeadline(in, *valueTimeout, func(in io.Reader) (interface{}, error) {
+					// the decoders below open the payload again and find
+					// nothing more to remove
+					in, err := openInput(in)
+					if err != nil {
+						return nil, err
+					}
+					switch {
+					case *summaryDepth > 0:
+						return decodeSummary(in, args[0], *summaryDepth)
+					case index != nil:
+						return decodeIndexed(in, args[0], *index, *countFooter)
+					case schemaType != nil:
+						return decodeSchema(in, args[0], schemaType, *strict)
+					case len(rules) == 0 && !*strict:
+						return decodeAny(in, args[0])
+					default:
+						return decodeMapRenamed(in, args[0], rules, *strict)
+					}
+				})
+				return err
+			})
+		})
+		if err != nil {
+			return inRange(err)
+		}
+		if *normalize {
+			if data, err = normalizeInts(data); err != nil {
+				return fmt.Errorf("-normalize-ints: %w", err)
+			}
+		}
+		if transforms != nil {
+			paths, err := ApplyTransforms(data, transforms, *transformDryRun)
+			if err != nil {
+				return err
+			}
+			if *transformDryRun {
+				for _, p := range paths {
+					fmt.Fprintf(out, "%s\t(%s)\n", p.Path, transformSpecs[p.Rule])
+				}
+				_, err := fmt.Fprintf(out, "%d paths affected\n", len(paths))
+				if err := finish(err); err != nil {
+					return err
+				}
+				return warns.report(os.Stderr)
+			}
+		}
+		data = sample.apply(data)
+		// File outputs go first; one failing does not stop the printed output.
+		outErr := outputs.write(data, *format, opts)
+		switch {
+		case grepRE != nil:
+			var n int
+			n, err = writeGrep(out, data, grepRE, *grepAll)
+			if err == nil {
+				err = sample.writeNotes(os.Stderr)
+			}
+			if err == nil && n == 0 && outErr == nil {
+				return exitCode(1)
+			}
+			err = finish(err)
+		case *histogram:
+			err = writeTypeHistogram(out, data)
+			if err == nil {
+				err = sample.writeNotes(os.Stderr)
+			}
+			err = finish(err)
+		default:
+			err = newRenderer(opts).render(out, data)
+			// keep machine-readable output parseable
+			side := io.Writer(os.Stderr)
+			if *format == "tree" || *format == "flat" {
+				side = out
+			}
+			if err == nil && durations != nil {
+				err = writeDurations(side, data, durations, &warns.Warnings)
+			}
+			if err == nil {
+				err = sample.writeNotes(side)
+			}
+			err = finish(err)
+		}
+		if err != nil {
+			return err
+		}
+		if err := warns.report(os.Stderr); err != nil {
+			return err
+		}
+		return outErr
+	}
+}
+
+// decodeMapRenamed decodes the top-level map through DecodeRenamed,
+// reporting the fields dropped on the way.
+func decodeMapRenamed(r io.Reader, filename string, rules RenameRules, strict bool) (map[interface{}]interface{}, error) {
+	r, err := openPayload(r, filename)
+	if err != nil {
+		return nil, err
+	}
+	var data map[interface{}]interface{}
+	dropped, err := DecodeRenamed(r, &data, rules)
+	if err != nil {
+		return nil, payloadError(r, err)
+	}
+	for _, path := range dropped {
+		fmt.Fprintf(os.Stderr, "dropped field: %s\n", path)
+	}
+	if strict && len(dropped) > 0 {
+		return nil, fmt.Errorf("%d fields dropped", len(dropped))
+	}
+	return data, nil
+}
+
+// decodeIndexed decodes the record at index through DecodeRecordAt,
+// checking the record-count footer when counted is set.
+func decodeIndexed(r io.Reader, filename string, index int, counted bool) (interface{}, error) {
+	r, err := openPayload(r, filename)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := decodeRecordAt(r, index, counted)
+	return rec, payloadError(r, err)
+}
+
+// decodeSchema decodes the top-level value as type t through
+// DecodeAsType, reporting the fields dropped on the way.
+func decodeSchema(r io.Reader, filename string, t reflect.Type, strict bool) (interface{}, error) {
+	r, err := openPayload(r, filename)
+	if err != nil {
+		return nil, err
+	}
+	data, dropped, err := DecodeAsType(r, t)
+	if err != nil {
+		return nil, payloadError(r, err)
+	}
+	for _, path := range dropped {
+		fmt.Fprintf(os.Stderr, "dropped field: %s\n", path)
+	}
+	if strict && len(dropped) > 0 {
+		return nil, fmt.Errorf("%d fields dropped", len(dropped))
+	}
+	return data, nil
+}
+
+// registerKnownTypes registers the types the tool expects to meet behind
+// interfaces: the basic ones encodeAndWriteToFile registers, and those
+// that session files hold.
+func registerKnownTypes() {
+	safeRegister(map[string]interface{}{})
+	safeRegister([]interface{}{})
+	safeRegister([]int{})
+	safeRegister(struct {
+		X int
+		Y int
+	}{})
+
+	safeRegister(json.RawMessage{})
+	// Session files hold these behind interfaces as well.
+	safeRegister(&sessions.Session{})
+	safeRegister(&sessions.Options{})
+	safeRegister(map[interface{}]interface{}{})
+	registerInterchangeTypes()
+}
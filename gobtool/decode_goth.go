@@ -0,0 +1,283 @@
+package gobtool
+
+import (
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+func gothFlags(fs *flag.FlagSet) func(args []string) error {
+	stripOptions := fs.Bool("strip-options", false, "print only the name, ID and Values of each session")
+	prettySession := fs.Bool("pretty-session", false, "print goth users and provider sessions found in the session as readable blocks")
+	showTokens := fs.Bool("show-tokens", false, "with -pretty-session, do not redact tokens")
+	var jwts jwtFlags
+	jwts.register(fs)
+	return func(args []string) error {
+		if err := jwts.apply(); err != nil {
+			return err
+		}
+
+		filename := "goth-session.bin"
+		if len(args) > 0 {
+			filename = args[0]
+		}
+		// Open the file
+		file, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("error opening file: %v", err)
+		}
+		defer file.Close()
+
+		// Register likely types
+		safeRegister(&sessions.Session{})
+		safeRegister(&sessions.Options{}) // Added Options explicitly
+		safeRegister(map[string]interface{}{})
+		safeRegister(map[interface{}]interface{}{})
+
+		// Create a decoder
+		decoder := gob.NewDecoder(file)
+
+		var data map[interface{}]interface{}
+
+		if err := decoder.Decode(&data); err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+
+		if *prettySession {
+			n := printGothUsers(os.Stdout, data, *showTokens)
+			if n += printGothProviderSessions(os.Stdout, data, *showTokens); n > 0 {
+				return nil
+			}
+			fmt.Println("no goth user or provider session found in the session")
+		}
+		if *stripOptions {
+			printSessionValues(data)
+			return nil
+		}
+		fmt.Printf("Decoded Data: %#v\n", data)
+		printDetails(data, "")
+		return nil
+	}
+}
+
+// printSessionValues prints the top-level entries of data, showing each
+// session by its name, ID and Values only and leaving out its Options.
+func printSessionValues(data map[interface{}]interface{}) {
+	writeSessionValues(os.Stdout, data)
+}
+
+// writeSessionValues writes what printSessionValues prints to w. Every
+// entry is laid out as the tree format lays out a map entry, a session
+// being a value of its own kind, so sessions and other values line up.
+func writeSessionValues(w io.Writer, data map[interface{}]interface{}) error {
+	t := &treeWriter{w: w}
+	for _, k := range sortedMapKeys(reflect.ValueOf(data)) {
+		key := k.Interface()
+		t.line(0, "Key: %s (%T)\n", cleanString(sprint(key)), key)
+		s, ok := data[key].(*sessions.Session)
+		if !ok {
+			t.line(1, "Value: (%T)\n", data[key])
+			t.value(data[key], 2, cycleGuard{})
+			continue
+		}
+		// gob does not carry the unexported name, but the key usually is it.
+		name := s.Name()
+		if name == "" {
+			name = fmt.Sprint(key)
+		}
+		t.line(1, "Session %s:\n", cleanString(name))
+		t.line(2, "ID: %s\n", cleanString(s.ID))
+		t.line(2, "Values:\n")
+		t.value(s.Values, 3, cycleGuard{})
+	}
+	return t.err
+}
+
+func printDetails(data interface{}, indent string) {
+	writeDetails(os.Stdout, data, indent)
+}
+
+// writeDetails writes the tree rendering of data to w, stopping at the
+// first write error.
+func writeDetails(w io.Writer, data interface{}, indent string) error {
+	t := &treeWriter{w: w, base: indent}
+	t.value(data, 0, cycleGuard{})
+	return t.err
+}
+
+type treeWriter struct {
+	w   io.Writer
+	err error
+
+	// showUnexported displays unexported struct fields too, read through
+	// unexportedField. Debugging aid only; see unexported_unsafe.go.
+	showUnexported bool
+	// guessJSON renders byte slices holding valid JSON as JSON, as is
+	// always done for json.RawMessage.
+	guessJSON bool
+	// collapse shows a chain of maps and slices of one element each as a
+	// single entry labelled with the combined path, such as a[0].only.x.
+	collapse bool
+
+	// base is written before every line. Each level of depth adds two
+	// spaces after it; indents caches the prefix of each depth, so deep
+	// trees do not build a new indent string for every node.
+	base    string
+	indents []string
+}
+
+func (t *treeWriter) printf(format string, args ...interface{}) {
+	if t.err == nil {
+		_, t.err = fmt.Fprintf(t.w, format, args...)
+	}
+}
+
+// line writes the indent for depth and then the formatted text.
+func (t *treeWriter) line(depth int, format string, args ...interface{}) {
+	for len(t.indents) <= depth {
+		t.indents = append(t.indents, t.base+strings.Repeat("  ", len(t.indents)))
+	}
+	if t.err == nil {
+		_, t.err = io.WriteString(t.w, t.indents[depth])
+	}
+	t.printf(format, args...)
+}
+
+func (t *treeWriter) value(data interface{}, depth int, guard cycleGuard) {
+	if t.err != nil {
+		return
+	}
+	val, leave, ok := guard.descend(reflect.ValueOf(data))
+	if !ok {
+		t.line(depth, "(cycle: %T)\n", data)
+		return
+	}
+	defer leave()
+
+	if !val.IsValid() {
+		t.line(depth, "nil\n")
+		return
+	}
+	if s, ok := data.(valueSummary); ok {
+		t.line(depth, "%s (summarized)\n", s.Summary)
+		return
+	}
+	if text, ok := jsonBytes(val, t.guessJSON, "  "); ok {
+		t.line(depth, "JSON (%s):\n", val.Type())
+		for _, line := range strings.Split(text, "\n") {
+			t.line(depth+1, "%s\n", line)
+		}
+		return
+	}
+
+	switch val.Kind() {
+	case reflect.Map:
+		t.line(depth, "Map:\n")
+		iter := val.MapRange()
+		for iter.Next() && t.err == nil {
+			k := iter.Key()
+			v := iter.Value()
+			if suffix, end := t.collapsed(v); suffix != "" {
+				t.line(depth+1, "Key: %s%s (%T, collapsed)\n", cleanString(sprint(k.Interface())), cleanString(suffix), k.Interface())
+				v = end
+			} else {
+				t.line(depth+1, "Key: %s (%T)\n", cleanString(sprint(k.Interface())), k.Interface())
+			}
+			t.line(depth+1, "Value: (%T)\n", v.Interface())
+			t.value(v.Interface(), depth+2, guard)
+		}
+	case reflect.Slice, reflect.Array:
+		t.line(depth, "Slice/Array:\n")
+		for i := 0; i < val.Len() && t.err == nil; i++ {
+			v := val.Index(i)
+			if suffix, end := t.collapsed(v); suffix != "" {
+				t.line(depth+1, "Index %d%s (collapsed):\n", i, cleanString(suffix))
+				v = end
+			} else {
+				t.line(depth+1, "Index %d:\n", i)
+			}
+			t.value(v.Interface(), depth+2, guard)
+		}
+	case reflect.Struct:
+		t.line(depth, "Struct %s:\n", val.Type().Name())
+		for i := 0; i < val.NumField() && t.err == nil; i++ {
+			field := val.Type().Field(i)
+			// Skip unexported fields
+			if field.PkgPath != "" {
+				if t.showUnexported {
+					t.unexported(val, i, depth+1, guard)
+				}
+				continue
+			}
+			v := val.Field(i)
+			if suffix, end := t.collapsed(v); suffix != "" {
+				t.line(depth+1, "Field %s%s (%s, collapsed):\n", field.Name, cleanString(suffix), field.Type)
+				v = end
+			} else {
+				t.line(depth+1, "Field %s (%s):\n", field.Name, field.Type)
+			}
+			t.value(v.Interface(), depth+2, guard)
+		}
+	default:
+		t.line(depth, "%s (%T)\n", cleanString(sprint(data)), data)
+		if val.Kind() == reflect.String && jwtExpand != nil {
+			lines, _ := jwtExpand.describe(val.String())
+			for _, l := range lines {
+				t.line(depth+1, "%s\n", l)
+			}
+		}
+	}
+}
+
+// collapsed follows v down through maps and slices holding a single
+// element while collapsing is on. It returns the path walked, such as
+// "[0].only.x", and the value at its end; the path is empty when v is
+// not such a container.
+func (t *treeWriter) collapsed(v reflect.Value) (string, reflect.Value) {
+	if !t.collapse {
+		return "", v
+	}
+	var path strings.Builder
+	seen := make(map[uintptr]bool)
+	for {
+		iv := indirect(v)
+		switch {
+		case iv.Kind() == reflect.Map && iv.Len() == 1 && !seen[iv.Pointer()]:
+			seen[iv.Pointer()] = true
+			k := iv.MapKeys()[0]
+			path.WriteString("." + pathKey(k))
+			v = iv.MapIndex(k)
+		case (iv.Kind() == reflect.Slice || iv.Kind() == reflect.Array) && iv.Len() == 1 &&
+			iv.Type().Elem().Kind() != reflect.Uint8 && (iv.Kind() == reflect.Array || !seen[iv.Pointer()]):
+			if iv.Kind() == reflect.Slice {
+				seen[iv.Pointer()] = true
+			}
+			path.WriteString("[0]")
+			v = iv.Index(0)
+		default:
+			return path.String(), v
+		}
+	}
+}
+
+// unexported prints unexported field i of struct val, marked as such.
+func (t *treeWriter) unexported(val reflect.Value, i int, depth int, guard cycleGuard) {
+	field := val.Type().Field(i)
+	fv, ok := unexportedField(val, i)
+	if !ok {
+		t.line(depth, "Field %s (%s) [unexported, unreadable]\n", field.Name, field.Type)
+		return
+	}
+	t.line(depth, "Field %s (%s) [unexported]:\n", field.Name, field.Type)
+	if fv.Kind() == reflect.Interface && fv.IsNil() || !fv.CanInterface() {
+		t.line(depth+1, "nil\n")
+		return
+	}
+	t.value(fv.Interface(), depth+1, guard)
+}
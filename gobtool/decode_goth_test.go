@@ -0,0 +1,131 @@
+package gobtool
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestGothStripOptions(t *testing.T) {
+	registerKnownTypes()
+	s := sessions.NewSession(nil, "web")
+	s.ID = "abc123"
+	s.Values["user"] = "ada"
+	s.Options = &sessions.Options{Path: "/admin", MaxAge: 3600}
+	name := writeRoot(t, map[interface{}]interface{}{"web": s, "count": 2})
+
+	out, err := runCaptured(t, "goth", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "/admin") {
+		t.Errorf("without -strip-options, the options are missing:\n%s", out)
+	}
+	out, err = runCaptured(t, "goth", "-strip-options", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sessions and other values are laid out alike, as map entries.
+	want := `Key: count (string)
+  Value: (int)
+    2 (int)
+Key: web (string)
+  Session web:
+    ID: abc123
+    Values:
+      Map:
+        Key: user (string)
+        Value: (string)
+          ada (string)
+`
+	if out != want {
+		t.Errorf("-strip-options printed:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestWriteDetails(t *testing.T) {
+	var b strings.Builder
+	data := map[string]interface{}{"a": []int{1}, "b": "x"}
+	if err := writeDetails(&b, data, "> "); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		if !strings.HasPrefix(line, "> ") {
+			t.Errorf("line %q does not start with the base indent", line)
+		}
+	}
+}
+
+func TestCollapseSingletons(t *testing.T) {
+	data := map[string]interface{}{
+		"a": []interface{}{map[string]interface{}{"only": map[string]int{"x": 1}}},
+		"b": []int{1, 2},
+	}
+	var b strings.Builder
+	tw := &treeWriter{w: &b, collapse: true}
+	tw.value(data, 0, cycleGuard{})
+	if tw.err != nil {
+		t.Fatal(tw.err)
+	}
+	for _, want := range []string{
+		"  Key: a[0].only.x (string, collapsed)\n  Value: (int)\n    1 (int)\n",
+		"  Key: b (string)\n  Value: ([]int)\n    Slice/Array:\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, b.String())
+		}
+	}
+
+	// A map holding only itself is a cycle, not an endless chain.
+	self := map[string]interface{}{}
+	self["me"] = self
+	b.Reset()
+	tw = &treeWriter{w: &b, collapse: true}
+	tw.value(map[string]interface{}{"root": self}, 0, cycleGuard{})
+	if !strings.Contains(b.String(), "Key: root.me (string, collapsed)") {
+		t.Errorf("cycle:\n%s", b.String())
+	}
+}
+
+// nestedTree builds a map of the given depth with width entries per
+// level, ending in strings.
+func nestedTree(depth, width int) interface{} {
+	if depth == 0 {
+		return "leaf"
+	}
+	m := make(map[string]interface{}, width)
+	for i := 0; i < width; i++ {
+		m[string(rune('a'+i))] = nestedTree(depth-1, width)
+	}
+	return m
+}
+
+func BenchmarkWriteDetails(b *testing.B) {
+	data := nestedTree(6, 4)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := writeDetails(io.Discard, data, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestGothDecodeError checks that a file that does not decode fails the
+// command rather than only being logged.
+func TestGothDecodeError(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "bad.gob")
+	if err := os.WriteFile(name, []byte("not a gob stream"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCaptured(t, "goth", name)
+	if err == nil || !strings.HasPrefix(err.Error(), "decode: ") {
+		t.Errorf("err = %v, want the decode error", err)
+	}
+	if out != "" {
+		t.Errorf("printed %q", out)
+	}
+}
@@ -0,0 +1,251 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// A delta is the list of operations that turns one decoded value (the
+// base) into another (the target). Maps are diffed key by key; any other
+// value that differs is replaced whole. Both content hashes are kept so
+// applying a delta to the wrong base, or getting the wrong result, is
+// caught instead of silently producing bad data.
+
+// DeltaOp sets or deletes the value found by following Path from the
+// root: a map key at each map, an int index at each slice. An empty Path
+// means the root itself.
+type DeltaOp struct {
+	Delete bool
+	Path   []interface{}
+	Value  interface{}
+}
+
+// Delta is what a .delta file holds.
+type Delta struct {
+	Version    int
+	BaseHash   [32]byte
+	TargetHash [32]byte
+	Ops        []DeltaOp
+}
+
+// diffDelta computes the operations that turn base into target.
+func diffDelta(base, target interface{}) (*Delta, error) {
+	bh, err := CanonicalHash(base)
+	if err != nil {
+		return nil, err
+	}
+	th, err := CanonicalHash(target)
+	if err != nil {
+		return nil, err
+	}
+	d := &Delta{Version: DeltaVersion, BaseHash: bh, TargetHash: th}
+	if bh != th {
+		diffValue(d, nil, reflect.ValueOf(base), reflect.ValueOf(target))
+	}
+	return d, nil
+}
+
+func diffValue(d *Delta, path []interface{}, a, b reflect.Value) {
+	a, b = indirect(a), indirect(b)
+	if a.IsValid() && b.IsValid() && a.Kind() == reflect.Map && a.Type() == b.Type() {
+		for _, k := range sortedMapKeys(a) {
+			sub := append(path[:len(path):len(path)], k.Interface())
+			if bv := b.MapIndex(k); !bv.IsValid() {
+				d.Ops = append(d.Ops, DeltaOp{Delete: true, Path: sub})
+			} else {
+				diffValue(d, sub, a.MapIndex(k), bv)
+			}
+		}
+		for _, k := range sortedMapKeys(b) {
+			if !a.MapIndex(k).IsValid() {
+				sub := append(path[:len(path):len(path)], k.Interface())
+				d.Ops = append(d.Ops, DeltaOp{Path: sub, Value: b.MapIndex(k).Interface()})
+			}
+		}
+		return
+	}
+	var av, bv interface{}
+	if a.IsValid() {
+		av = a.Interface()
+	}
+	if b.IsValid() {
+		bv = b.Interface()
+	}
+	if !reflect.DeepEqual(av, bv) {
+		d.Ops = append(d.Ops, DeltaOp{Path: path, Value: bv})
+	}
+}
+
+// applyDelta applies d to base, which is modified in place where it can
+// be, and returns the target value after checking both hashes.
+func applyDelta(base interface{}, d *Delta) (interface{}, error) {
+	if err := CanRead(FormatDelta, d.Version); err != nil {
+		return nil, err
+	}
+	if h, err := CanonicalHash(base); err != nil {
+		return nil, err
+	} else if h != d.BaseHash {
+		return nil, errors.New("delta was not made against this base")
+	}
+	root := base
+	for i, op := range d.Ops {
+		if len(op.Path) == 0 {
+			root = op.Value
+			continue
+		}
+		if err := applyOp(reflect.ValueOf(root), op); err != nil {
+			return nil, fmt.Errorf("op %d at %v: %w", i, op.Path, err)
+		}
+	}
+	if h, err := CanonicalHash(root); err != nil {
+		return nil, err
+	} else if h != d.TargetHash {
+		return nil, errors.New("result does not match the delta's target hash")
+	}
+	return root, nil
+}
+
+func applyOp(v reflect.Value, op DeltaOp) error {
+	for i, seg := range op.Path {
+		v = indirect(v)
+		last := i == len(op.Path)-1
+		switch v.Kind() {
+		case reflect.Map:
+			k := reflect.ValueOf(seg)
+			if !k.Type().AssignableTo(v.Type().Key()) {
+				return fmt.Errorf("key %v does not fit %s", seg, v.Type())
+			}
+			if !last {
+				v = v.MapIndex(k)
+				if !v.IsValid() {
+					return fmt.Errorf("missing key %v", seg)
+				}
+				continue
+			}
+			if op.Delete {
+				v.SetMapIndex(k, reflect.Value{})
+				return nil
+			}
+			val := reflect.ValueOf(op.Value)
+			if !val.IsValid() {
+				val = reflect.Zero(v.Type().Elem())
+			}
+			if !val.Type().AssignableTo(v.Type().Elem()) {
+				return fmt.Errorf("value of type %s does not fit %s", val.Type(), v.Type())
+			}
+			v.SetMapIndex(k, val)
+			return nil
+		default:
+			return fmt.Errorf("cannot descend into %s", v.Kind())
+		}
+	}
+	return nil
+}
+
+// deltaFlags registers the flags of delta; the command returned writes
+// the delta between two gob files.
+func deltaFlags(fs *flag.FlagSet) func(args []string) error {
+	out := fs.String("out", "", "file to write the delta to")
+	return func(args []string) error {
+		if len(args) != 2 || *out == "" {
+			return errors.New("usage: delta base.gob new.gob -out new.delta")
+		}
+		registerKnownTypes()
+		base, err := decodeAnyFile(args[0])
+		if err != nil {
+			return err
+		}
+		target, err := decodeAnyFile(args[1])
+		if err != nil {
+			return err
+		}
+		d, err := diffDelta(base, target)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(d); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(*out, buf.Bytes()); err != nil {
+			return err
+		}
+		full, err := os.Stat(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d ops, delta %d bytes vs %d bytes full", len(d.Ops), buf.Len(), full.Size())
+		if saved := 1 - float64(buf.Len())/float64(full.Size()); full.Size() > 0 && saved >= 0 {
+			fmt.Printf(" (%.1f%% smaller)", 100*saved)
+		} else if full.Size() > 0 {
+			fmt.Printf(" (%.1f%% larger, keep the full snapshot)", -100*saved)
+		}
+		fmt.Println()
+		return nil
+	}
+}
+
+// applyDeltaFlags registers the flags of apply-delta; the command
+// returned rebuilds the target of a delta from its base.
+func applyDeltaFlags(fs *flag.FlagSet) func(args []string) error {
+	out := fs.String("out", "", "write the result here instead of printing it")
+	return func(args []string) error {
+		if len(args) != 2 {
+			return errors.New("usage: apply-delta base.gob new.delta [-out new.gob]")
+		}
+		registerKnownTypes()
+		// the result is written back as the type of the base
+		values, types, err := readTypedFile(args[0])
+		if err != nil {
+			return err
+		}
+		if len(values) != 1 {
+			return fmt.Errorf("%s: a delta applies to a single value, not %d records", args[0], len(values))
+		}
+		base := values[0]
+		d, err := readDelta(args[1])
+		if err != nil {
+			return err
+		}
+		result, err := applyDelta(base, d)
+		if err != nil {
+			return err
+		}
+		if *out == "" {
+			printDetails(result, "")
+			return nil
+		}
+		for _, op := range d.Ops {
+			if len(op.Path) == 0 {
+				// the root was replaced whole, and the delta does not say
+				// what type it had, so it goes out as the type it holds
+				types = nil
+			}
+		}
+		data, err := encodeStreamValues([]interface{}{result}, types)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(*out, data)
+	}
+}
+
+// readDelta reads a .delta file. Its version is checked when it is
+// applied.
+func readDelta(name string) (*Delta, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := new(Delta)
+	if err := gob.NewDecoder(f).Decode(d); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return d, nil
+}
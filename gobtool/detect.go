@@ -0,0 +1,383 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Every command that reads a gob file finds the stream behind whatever
+// wraps it with unwrapPayload, and identify reports what that same code
+// saw, so the two cannot disagree about a file. unwrapPayload only
+// recognizes the layers; each is opened by the reader middleware in
+// options.go that a caller of Decode would give WithReaderMiddleware.
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// utf8BOM is the byte order mark some editors and tools prepend. gob
+// never starts with it, so it is always damage.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// trimLeading is -trim-leading: skip a leading BOM and ASCII whitespace
+// before the data. Whitespace is only skipped on request, and only when
+// the data does not read as gob without skipping it, since a gob message
+// of 9, 10, 13 or 32 bytes starts with the same byte as whitespace.
+var trimLeading bool
+
+const trimLeadingUsage = "skip a UTF-8 byte order mark and whitespace that a tool prepended to the data"
+
+// sniffWindow is how much of the input sniffLayer looks at.
+const sniffWindow = 4096
+
+// maxLayers bounds nesting, such as base64 inside base64.
+const maxLayers = 4
+
+// layer is one wrapping recognized around the data.
+type layer struct {
+	Name     string // "store-ref", "shards", "base64", "gzip", "zstd", "securecookie", "bom", "leading-space"
+	Evidence string
+}
+
+// unsupportedLayerError reports a wrapping that is recognized but cannot
+// be opened here.
+type unsupportedLayerError struct {
+	layer
+	hint string
+}
+
+func (e *unsupportedLayerError) Error() string {
+	return fmt.Sprintf("input is %s (%s): %s", e.Name, e.Evidence, e.hint)
+}
+
+// sniffLayer looks at the start of br for a known wrapping. It returns
+// false when the data should be taken as the gob stream itself.
+func sniffLayer(br *bufio.Reader) (layer, bool) {
+	head, _ := br.Peek(sniffWindow)
+	switch {
+	case len(head) == 0:
+		return layer{}, false
+	case bytes.HasPrefix(head, []byte(storeRefPrefix)):
+		return layer{"store-ref", "starts with the store reference header"}, true
+	case bytes.HasPrefix(head, []byte(shardsPrefix)):
+		return layer{"shards", "starts with the sharded file header"}, true
+	case bytes.HasPrefix(head, gzipMagic):
+		return layer{"gzip", "magic bytes 1f 8b"}, true
+	case bytes.HasPrefix(head, zstdMagic):
+		return layer{"zstd", "magic bytes 28 b5 2f fd"}, true
+	case bytes.HasPrefix(head, utf8BOM):
+		return layer{"bom", "UTF-8 byte order mark ef bb bf"}, true
+	}
+	enc, ok := base64Encoding(head)
+	if !ok {
+		return layer{}, false
+	}
+	text := bytes.TrimSpace(head)
+	text = bytes.ReplaceAll(bytes.ReplaceAll(text, []byte("\n"), nil), []byte("\r"), nil)
+	if len(head) == sniffWindow {
+		// Only whole quantums of a longer input can be decoded here.
+		text = text[:len(text)/4*4]
+	}
+	dec, err := enc.DecodeString(strings.TrimRight(string(text), "="))
+	if err != nil {
+		return layer{}, false
+	}
+	if isSecureCookie(dec) {
+		return layer{"securecookie", "base64 decodes to timestamp|value|mac"}, true
+	}
+	alphabet := "standard"
+	if enc == base64.RawURLEncoding {
+		alphabet = "URL-safe"
+	}
+	return layer{"base64", fmt.Sprintf("first %d bytes are %s base64 text", len(head), alphabet)}, true
+}
+
+// base64Encoding reports whether head looks like base64 text, and in
+// which alphabet. Short inputs are not taken as base64, since too many
+// plain strings would qualify.
+func base64Encoding(head []byte) (*base64.Encoding, bool) {
+	text := bytes.TrimSpace(head)
+	if len(text) < 16 {
+		return nil, false
+	}
+	url := false
+	body := bytes.TrimRight(text, "=")
+	if len(text)-len(body) > 2 {
+		return nil, false
+	}
+	for _, c := range body {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '\n', c == '\r':
+		case c == '-' || c == '_':
+			url = true
+		case c == '+' || c == '/':
+			if url {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	if url {
+		return base64.RawURLEncoding, true
+	}
+	return base64.RawStdEncoding, true
+}
+
+// isSecureCookie reports whether dec has the shape of a decoded
+// securecookie value: a unix timestamp, the base64 payload and the MAC,
+// separated by '|'.
+func isSecureCookie(dec []byte) bool {
+	ts, rest, ok := bytes.Cut(dec, []byte("|"))
+	if !ok || len(ts) < 9 || len(ts) > 11 {
+		return false
+	}
+	for _, c := range ts {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	// The MAC may lie beyond the sniffed window.
+	value, _, _ := bytes.Cut(rest, []byte("|"))
+	for _, c := range bytes.TrimRight(value, "=") {
+		if !isBase64URL(c) {
+			return false
+		}
+	}
+	return len(value) > 0
+}
+
+func isBase64URL(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// unwrapPayload returns the gob stream behind r, calling visit for each
+// wrapping it removes, outermost first. Data inside gzip is taken to be
+// gob, so that payloadError can tell decompression failures apart, and r
+// is returned as it is when it already reads from inside gzip.
+func unwrapPayload(r io.Reader, refPath string, visit func(layer)) (io.Reader, error) {
+	if g, ok := r.(*gunzipReader); ok {
+		return g, nil
+	}
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	skipLeadingSpace(br, visit)
+	for i := 0; i < maxLayers; i++ {
+		l, ok := sniffLayer(br)
+		if !ok {
+			return br, nil
+		}
+		if visit != nil {
+			visit(l)
+		}
+		switch l.Name {
+		case "store-ref":
+			r, err := storeRefMiddleware(refPath)(br)
+			if err != nil {
+				return nil, err
+			}
+			br = bufio.NewReader(r)
+		case "shards":
+			return openSharded(br)
+		case "gzip":
+			return gunzipMiddleware(br)
+		case "base64":
+			r, err := base64Middleware(br)
+			if err != nil {
+				return nil, err
+			}
+			br = bufio.NewReader(r)
+		case "zstd":
+			return nil, &unsupportedLayerError{l, "zstd decompression is not supported; decompress it with zstd -d first"}
+		case "securecookie":
+			return nil, &unsupportedLayerError{l, "decode it with the hash and block keys, as session does"}
+		case "bom":
+			if !trimLeading {
+				return nil, &unsupportedLayerError{l, "a tool prepended it; rerun with -trim-leading to skip it"}
+			}
+			br.Discard(len(utf8BOM))
+			skipLeadingSpace(br, visit)
+		}
+	}
+	return nil, fmt.Errorf("more than %d layers of wrapping", maxLayers)
+}
+
+// skipLeadingSpace discards the ASCII whitespace at the start of br
+// under -trim-leading, unless the data already reads as gob from its
+// first byte or reads as nothing known after it either.
+func skipLeadingSpace(br *bufio.Reader, visit func(layer)) {
+	if !trimLeading {
+		return
+	}
+	head, _ := br.Peek(sniffWindow)
+	n := 0
+	for n < len(head) && (head[n] == ' ' || head[n] == '\t' || head[n] == '\n' || head[n] == '\r') {
+		n++
+	}
+	if n == 0 || readsAsGob(head) {
+		return
+	}
+	// gob's first byte may itself look like whitespace, so skip the
+	// least that makes the rest read as gob or start a known wrapping.
+	for k := 1; k <= n; k++ {
+		rest := head[k:]
+		if readsAsGob(rest) || bytes.HasPrefix(rest, gzipMagic) || bytes.HasPrefix(rest, zstdMagic) || bytes.HasPrefix(rest, []byte(storeRefPrefix)) {
+			br.Discard(k)
+			if visit != nil {
+				visit(layer{"leading-space", fmt.Sprintf("%d whitespace bytes skipped", k)})
+			}
+			return
+		}
+	}
+}
+
+// readsAsGob reports whether the first value of head can be read, or at
+// least all of it head holds.
+func readsAsGob(head []byte) bool {
+	w := newWireReader(bytes.NewReader(head), 0)
+	id, err := w.nextMessage()
+	if err == nil {
+		_, err = w.topValue(id, true)
+	}
+	return err == nil || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func peekAll(br *bufio.Reader) []byte {
+	head, _ := br.Peek(sniffWindow)
+	return head
+}
+
+// trimPadding drops '=' and surrounding whitespace, so that both padded
+// and unpadded text decode with the raw encodings.
+type trimPadding struct{ r io.Reader }
+
+func (t *trimPadding) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	j := 0
+	for _, c := range p[:n] {
+		if c != '=' && c != ' ' && c != '\t' {
+			p[j] = c
+			j++
+		}
+	}
+	return j, err
+}
+
+// identification is identify's verdict on one input.
+type identification struct {
+	Format     string   // such as "gob", "base64+gzip+gob" or "not gob-like"
+	Confidence string   // "high", "medium" or "low"
+	Evidence   []string // what the verdict rests on
+	Values     int      // complete top-level values
+	Types      []string // their wire type names, in stream order
+}
+
+// identify examines the file name.
+func identify(name string) (*identification, error) {
+	f, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	id := new(identification)
+	var layers []string
+	r, err := unwrapPayload(f, name, func(l layer) {
+		layers = append(layers, l.Name)
+		id.Evidence = append(id.Evidence, l.Name+": "+l.Evidence)
+	})
+	var unsupported *unsupportedLayerError
+	switch {
+	case errors.As(err, &unsupported):
+		id.Format = strings.Join(layers, "+")
+		id.Confidence = "high"
+		id.Evidence = append(id.Evidence, "contents not examined: "+unsupported.hint)
+		return id, nil
+	case err != nil:
+		id.Format = strings.Join(append(layers, "?"), "+")
+		id.Confidence = "low"
+		id.Evidence = append(id.Evidence, err.Error())
+		return id, nil
+	}
+
+	w := newWireReader(r, 0)
+	var werr error
+	for {
+		tid, err := w.nextMessage()
+		if err == nil {
+			_, err = w.topValue(tid, false)
+		}
+		if err != nil {
+			if err != io.EOF {
+				werr = payloadError(r, err)
+			}
+			break
+		}
+		id.Values++
+		id.Types = append(id.Types, typeString(w.types, tid))
+	}
+	switch {
+	case id.Values == 0 && len(w.types) > 0:
+		id.Confidence = "low"
+		id.Evidence = append(id.Evidence, fmt.Sprintf("%d type definitions read, but no complete value: %v", len(w.types), werr))
+	case id.Values == 0:
+		id.Format = "not gob-like"
+		if len(layers) > 0 {
+			id.Format = strings.Join(layers, "+") + " of non-gob data"
+		}
+		id.Confidence = "high"
+		if werr == nil {
+			werr = errors.New("no data")
+		}
+		id.Evidence = append(id.Evidence, "no gob value could be read: "+werr.Error())
+		return id, nil
+	case werr != nil:
+		id.Confidence = "medium"
+		id.Evidence = append(id.Evidence, fmt.Sprintf("%d values read, then: %v", id.Values, werr))
+	default:
+		id.Confidence = "high"
+		id.Evidence = append(id.Evidence, fmt.Sprintf("%d values read to a clean end at byte %d", id.Values, w.off))
+	}
+	id.Format = strings.Join(append(layers, "gob"), "+")
+	return id, nil
+}
+
+func (id *identification) print(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s: %s (%s confidence)\n", name, id.Format, id.Confidence)
+	for _, e := range id.Evidence {
+		fmt.Fprintf(w, "  %s\n", e)
+	}
+	if id.Values > 0 {
+		fmt.Fprintf(w, "  values: %d (%s)\n", id.Values, strings.Join(id.Types, ", "))
+	}
+}
+
+// identifyFlags registers the flags of identify; the command returned
+// implements "identify file...".
+func identifyFlags(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) == 0 {
+			return errors.New("usage: identify file...")
+		}
+		failed := false
+		for _, name := range args {
+			id, err := identify(name)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+				continue
+			}
+			id.print(os.Stdout, name)
+		}
+		if failed {
+			return exitCode(1)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,55 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strings"
+	"testing"
+)
+
+func TestTrimLeading(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&buf), map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	stream := buf.Bytes()
+	defer func(old bool) { trimLeading = old }(trimLeading)
+
+	for _, c := range []struct {
+		name, prefix string
+		layers       []string
+	}{
+		{"bom", "\xef\xbb\xbf", []string{"bom"}},
+		{"space", " \r\n\t", []string{"leading-space"}},
+		{"bom and space", "\xef\xbb\xbf\n", []string{"bom", "leading-space"}},
+	} {
+		input := append([]byte(c.prefix), stream...)
+
+		trimLeading = false
+		_, err := unwrapPayload(bytes.NewReader(input), "", nil)
+		if bytes.HasPrefix(input, utf8BOM) && err == nil {
+			t.Errorf("%s: a BOM without -trim-leading: no error", c.name)
+		}
+
+		trimLeading = true
+		var layers []string
+		r, err := unwrapPayload(bytes.NewReader(input), "", func(l layer) { layers = append(layers, l.Name) })
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if strings.Join(layers, ",") != strings.Join(c.layers, ",") {
+			t.Errorf("%s: layers %v, want %v", c.name, layers, c.layers)
+		}
+		var got map[string]interface{}
+		if err := gob.NewDecoder(r).Decode(&got); err != nil || got["a"] != 1 {
+			t.Errorf("%s: decoded %v, %v", c.name, got, err)
+		}
+	}
+
+	// Data that reads as gob from its first byte is left alone.
+	var layers []string
+	if _, err := unwrapPayload(bytes.NewReader(stream), "", func(l layer) { layers = append(layers, l.Name) }); err != nil || layers != nil {
+		t.Errorf("plain gob: layers %v, %v", layers, err)
+	}
+}
@@ -0,0 +1,318 @@
+package gobtool
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// diff compares two files through their flat representations: one line
+// per leaf, lined up by path, so that a changed value shows as a changed
+// line rather than as a removal and an addition.
+
+// diffRow is one aligned pair of flat lines. Mark is ' ' for equal lines,
+// '|' for a path whose value changed, '<' for a line only on the left and
+// '>' for a line only on the right, as in diff -y.
+type diffRow struct {
+	Left, Right string
+	Mark        byte
+}
+
+// flatFileLines decodes name and returns its flat representation.
+func flatFileLines(name string, maxDepth int) ([]string, error) {
+	data, err := decodeAnyFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := writeFlat(&buf, data, maxDepth); err != nil {
+		return nil, err
+	}
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), nil
+}
+
+// flatPath is the path part of a flat line.
+func flatPath(line string) string {
+	path, _, _ := strings.Cut(line, " = ")
+	return path
+}
+
+// maxLCSCells bounds the table alignFlat fills, of len(a)*len(b) ints.
+// Larger inputs, which would take that much memory and time, are aligned
+// by alignByKey instead.
+const maxLCSCells = 1 << 22
+
+// alignFlat pairs up the lines of a and b by path, keeping the longest
+// common sequence of paths in order. Float leaves within eps of each other
+// count as equal.
+func alignFlat(a, b []string, eps float64) []diffRow {
+	if (len(a)+1)*(len(b)+1) > maxLCSCells {
+		return alignByKey(a, b, eps)
+	}
+	// lcs[i][j] is the length of the common sequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if flatPath(a[i]) == flatPath(b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var rows []diffRow
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && flatPath(a[i]) == flatPath(b[j]):
+			rows = append(rows, pairRow(a[i], b[j], eps))
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			rows = append(rows, diffRow{Left: a[i], Mark: '<'})
+			i++
+		default:
+			rows = append(rows, diffRow{Right: b[j], Mark: '>'})
+			j++
+		}
+	}
+	return rows
+}
+
+// alignByKey is alignFlat in O(n log n) time and linear space. A line is
+// matched with the line of the same path on the other side when the path
+// occurs once on each, and of those matches the longest sequence in order
+// on both sides is kept, as the longest increasing subsequence of their
+// right-hand positions. Paths are unique within a flat listing, so this
+// finds as long an alignment as the table does; only lines that repeat a
+// path, such as those of a multi-line string, go unmatched.
+func alignByKey(a, b []string, eps float64) []diffRow {
+	inA := make(map[string]int, len(a))
+	for _, line := range a {
+		inA[flatPath(line)]++
+	}
+	inB := make(map[string]int, len(b))
+	at := make(map[string]int, len(b))
+	for j, line := range b {
+		p := flatPath(line)
+		inB[p]++
+		at[p] = j
+	}
+	// Matches in left-hand order, as positions on both sides.
+	var left, right []int
+	for i, line := range a {
+		if p := flatPath(line); inA[p] == 1 && inB[p] == 1 {
+			left = append(left, i)
+			right = append(right, at[p])
+		}
+	}
+	// tails[k] is the match ending the best increasing run of length k+1
+	// found so far, and prev links each match to the one before it.
+	var tails []int
+	prev := make([]int, len(right))
+	for m, j := range right {
+		k := sort.Search(len(tails), func(k int) bool { return right[tails[k]] >= j })
+		prev[m] = -1
+		if k > 0 {
+			prev[m] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, m)
+		} else {
+			tails[k] = m
+		}
+	}
+	keep := make([]int, len(tails))
+	if len(tails) > 0 {
+		for k, m := len(tails)-1, tails[len(tails)-1]; k >= 0; k, m = k-1, prev[m] {
+			keep[k] = m
+		}
+	}
+
+	var rows []diffRow
+	i, j := 0, 0
+	for _, m := range append(keep, -1) {
+		ei, ej := len(a), len(b)
+		if m >= 0 {
+			ei, ej = left[m], right[m]
+		}
+		for ; i < ei; i++ {
+			rows = append(rows, diffRow{Left: a[i], Mark: '<'})
+		}
+		for ; j < ej; j++ {
+			rows = append(rows, diffRow{Right: b[j], Mark: '>'})
+		}
+		if m >= 0 {
+			rows = append(rows, pairRow(a[i], b[j], eps))
+			i++
+			j++
+		}
+	}
+	return rows
+}
+
+// pairRow is the row of two lines with the same path.
+func pairRow(l, r string, eps float64) diffRow {
+	mark := byte(' ')
+	if l != r && !floatsWithin(l, r, eps) {
+		mark = '|'
+	}
+	return diffRow{l, r, mark}
+}
+
+// floatsWithin reports whether flat lines a and b both hold floats and
+// differ by at most eps.
+func floatsWithin(a, b string, eps float64) bool {
+	x, ok1 := flatFloat(a)
+	y, ok2 := flatFloat(b)
+	return ok1 && ok2 && math.Abs(x-y) <= eps
+}
+
+// flatFloat parses the value of a flat line for a float leaf.
+func flatFloat(line string) (float64, bool) {
+	_, val, _ := strings.Cut(line, " = ")
+	val, ok := strings.CutSuffix(val, " (float64)")
+	if !ok {
+		if val, ok = strings.CutSuffix(val, " (float32)"); !ok {
+			return 0, false
+		}
+	}
+	f, err := strconv.ParseFloat(val, 64)
+	return f, err == nil
+}
+
+// writeLineDiff prints the rows that differ, "-" for the left file and
+// "+" for the right.
+func writeLineDiff(w io.Writer, rows []diffRow) error {
+	for _, r := range rows {
+		if r.Mark == ' ' {
+			continue
+		}
+		if r.Mark != '>' {
+			if _, err := fmt.Fprintf(w, "- %s\n", r.Left); err != nil {
+				return err
+			}
+		}
+		if r.Mark != '<' {
+			if _, err := fmt.Fprintf(w, "+ %s\n", r.Right); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeSideBySide prints all rows in two columns fitting in width
+// terminal cells, with the mark between them. Lines too long for their
+// column are cut short.
+func writeSideBySide(w io.Writer, rows []diffRow, width int) error {
+	col := (width - 3) / 2
+	for _, r := range rows {
+		line := padCells(fitCells(r.Left, col), col) + " " + string(r.Mark) + " " + fitCells(r.Right, col)
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cellWidth is the number of terminal cells r takes up: two for wide
+// East Asian characters, one otherwise.
+func cellWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115f, r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3, r >= 0xf900 && r <= 0xfaff, r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60, r >= 0xffe0 && r <= 0xffe6, r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+func stringCells(s string) int {
+	n := 0
+	for _, r := range s {
+		n += cellWidth(r)
+	}
+	return n
+}
+
+// fitCells cuts s to at most n cells.
+func fitCells(s string, n int) string {
+	used := 0
+	for i, r := range s {
+		if used+cellWidth(r) > n {
+			return s[:i]
+		}
+		used += cellWidth(r)
+	}
+	return s
+}
+
+// padCells pads s with spaces to n cells.
+func padCells(s string, n int) string {
+	if pad := n - stringCells(s); pad > 0 {
+		return s + strings.Repeat(" ", pad)
+	}
+	return s
+}
+
+// diffFlags registers the flags of diff; the command returned implements
+// "diff a.gob b.gob". Like diff(1) it exits with status 1 when the files
+// differ.
+func diffFlags(fs *flag.FlagSet) func(args []string) error {
+	sideBySide := fs.Bool("side-by-side", false, "print both files in aligned columns, as diff -y does")
+	width := fs.Int("width", 0, "output width in columns for -side-by-side (default: the terminal width, or 80)")
+	fs.Func("float-format", "print floats with this fmt verb, such as %.6g or %.2f", setFloatFormat)
+	fs.Func("root-type", rootTypeUsage, setRootType)
+	fs.BoolVar(&trimLeading, "trim-leading", false, trimLeadingUsage)
+	eps := fs.Float64("float-epsilon", 0, "treat floats that differ by at most this much as equal")
+	depth := fs.Int("flatten-depth", 0, "flatten only this many levels, summarizing deeper values inline (0 = all)")
+	return func(files []string) error {
+		if len(files) != 2 {
+			return errors.New("usage: diff [-side-by-side [-width n]] [-flatten-depth n] [-float-format verb] [-float-epsilon x] [-root-type t] [-trim-leading] a.gob b.gob")
+		}
+		if *width == 0 {
+			*width = outputWidth()
+		}
+		if *width < 5 {
+			return errors.New("-width must be at least 5")
+		}
+		registerKnownTypes()
+		a, err := flatFileLines(files[0], *depth)
+		if err != nil {
+			return err
+		}
+		b, err := flatFileLines(files[1], *depth)
+		if err != nil {
+			return err
+		}
+		rows := alignFlat(a, b, *eps)
+		if *sideBySide {
+			err = writeSideBySide(os.Stdout, rows, *width)
+		} else {
+			err = writeLineDiff(os.Stdout, rows)
+		}
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if r.Mark != ' ' {
+				return exitCode(1)
+			}
+		}
+		return nil
+	}
+}
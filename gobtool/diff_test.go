@@ -0,0 +1,136 @@
+package gobtool
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlignFlat(t *testing.T) {
+	a := []string{"a = 1 (int)", "b = x (string)", "c = 0.1 (float64)", "d = gone (string)"}
+	b := []string{"a = 1 (int)", "b = y (string)", "c = 0.10000001 (float64)", "e = new (string)"}
+	marks := func(rows []diffRow) string {
+		var s []byte
+		for _, r := range rows {
+			s = append(s, r.Mark)
+		}
+		return string(s)
+	}
+	if got := marks(alignFlat(a, b, 0)); got != " ||<>" {
+		t.Errorf("exact: got marks %q", got)
+	}
+	if got := marks(alignFlat(a, b, 1e-6)); got != " | <>" {
+		t.Errorf("epsilon: got marks %q", got)
+	}
+	rows := alignFlat(a, b, 0)
+	if want := (diffRow{Left: "d = gone (string)", Mark: '<'}); rows[3] != want {
+		t.Errorf("row 3: got %+v", rows[3])
+	}
+}
+
+func TestWriteSideBySide(t *testing.T) {
+	rows := []diffRow{
+		{"a = 1 (int)", "a = 1 (int)", ' '},
+		{"name = 张三 (string)", "name = 李四四 (string)", '|'},
+		{"", "new = x (string)", '>'},
+	}
+	var b strings.Builder
+	if err := writeSideBySide(&b, rows, 43); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		padCells("a = 1 (int)", 20) + "   a = 1 (int)",
+		"name = 张三 (string) | name = 李四四 (strin",
+		padCells("", 20) + " > new = x (string)",
+	}
+	if got := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for _, line := range want {
+		if n := stringCells(line); n > 43 {
+			t.Errorf("%q is %d cells wide", line, n)
+		}
+	}
+}
+
+func TestFitCells(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"abc", 5, "abc"},
+		{"abcdef", 3, "abc"},
+		{"张三李", 5, "张三"},
+		{"a张", 2, "a"},
+	} {
+		if got := fitCells(c.s, c.n); got != c.want {
+			t.Errorf("fitCells(%q, %d) = %q, want %q", c.s, c.n, got, c.want)
+		}
+	}
+}
+
+func TestDiffCommand(t *testing.T) {
+	a := writeRoot(t, map[string]interface{}{"a": 1, "b": "x"})
+	b := writeRoot(t, map[string]interface{}{"a": 1, "b": "y"})
+	out, err := runCaptured(t, "diff", a, b)
+	if code, ok := err.(exitCode); !ok || code != 1 {
+		t.Errorf("different files: got %v, want exit status 1", err)
+	}
+	if want := "- b = x (string)\n+ b = y (string)\n"; out != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+	// Not a terminal, so the width is the default.
+	out, err = runCaptured(t, "diff", "-side-by-side", a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := padCells("a = 1 (int)", (defaultWidth-3)/2) + "   a = 1 (int)\n"; !strings.HasPrefix(out, want) {
+		t.Errorf("got %q, want it to start with %q", out, want)
+	}
+}
+
+// TestAlignByKey checks that inputs too large for alignFlat's table are
+// aligned by key, as long as the table would align them, without
+// filling a table the size of both inputs.
+func TestAlignByKey(t *testing.T) {
+	a := []string{"a = 1 (int)", "b = x (string)", "c = 0.1 (float64)", "d = gone (string)"}
+	b := []string{"a = 1 (int)", "b = y (string)", "c = 0.10000001 (float64)", "e = new (string)"}
+	if got, want := alignByKey(a, b, 1e-6), alignFlat(a, b, 1e-6); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the table's %v", got, want)
+	}
+
+	// Moved paths cannot all stay in order; the longest run that can does.
+	a = []string{"x = 1 (int)", "a = 1 (int)", "b = 1 (int)", "c = 1 (int)"}
+	b = []string{"a = 1 (int)", "b = 2 (int)", "c = 1 (int)", "x = 1 (int)"}
+	var marks []byte
+	for _, r := range alignByKey(a, b, 0) {
+		marks = append(marks, r.Mark)
+	}
+	if string(marks) != "< | >" {
+		t.Errorf("moved path: got marks %q", marks)
+	}
+
+	const n = 20000
+	big := func(changed int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("k%05d = %d (int)", i, i)
+		}
+		lines[changed] = fmt.Sprintf("k%05d = changed (string)", changed)
+		return lines
+	}
+	if (n+1)*(n+1) <= maxLCSCells {
+		t.Fatalf("%d lines each fit the table", n)
+	}
+	rows := alignFlat(big(5), big(n-5), 0)
+	if len(rows) != n {
+		t.Fatalf("%d rows, want %d", len(rows), n)
+	}
+	for i, r := range rows {
+		if want := i == 5 || i == n-5; (r.Mark == '|') != want || r.Mark != '|' && r.Mark != ' ' {
+			t.Errorf("row %d: mark %q", i, r.Mark)
+		}
+	}
+}
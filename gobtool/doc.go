@@ -0,0 +1,7 @@
+// Package gobtool reads, writes, checks and repairs gob files. It is the
+// library behind the test-gob command: Decode, DecodeValue and Encode,
+// configured by the options in options.go, are its entry points, with
+// SeekDecoder, LazyMap and the stream functions for files too large to
+// decode whole, and CanonicalHash, CanRead and the transform rules for
+// comparing, versioning and rewriting them. Main runs the command line.
+package gobtool
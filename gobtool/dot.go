@@ -0,0 +1,95 @@
+package gobtool
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// dotWriter renders a value as a Graphviz digraph. Maps, slices and
+// structs become box nodes, scalars become leaf nodes, and each edge is
+// labelled with the key, index or field name that leads to its child.
+type dotWriter struct {
+	w     io.Writer
+	next  int
+	guard cycleGuard
+	err   error // first write error; nothing is written after it
+}
+
+func writeDot(w io.Writer, data interface{}) error {
+	d := &dotWriter{w: w, guard: cycleGuard{}}
+	d.printf("digraph gob {\n")
+	d.printf("  node [fontname=\"monospace\"];\n")
+	d.node(reflect.ValueOf(data))
+	d.printf("}\n")
+	return d.err
+}
+
+func (d *dotWriter) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+// node emits v and everything below it, returning v's node id.
+func (d *dotWriter) node(v reflect.Value) string {
+	id := fmt.Sprintf("n%d", d.next)
+	d.next++
+	if d.err != nil {
+		return id
+	}
+
+	// Pointers are drawn as the value they point to.
+	typ := "nil"
+	if v.IsValid() {
+		typ = v.Type().String()
+	}
+	v, leave, ok := d.guard.descend(v)
+	if !ok {
+		d.printf("  %s [label=%s, shape=octagon];\n", id, dotQuote("cycle: "+typ))
+		return id
+	}
+	defer leave()
+	if !v.IsValid() {
+		d.printf("  %s [label=\"nil\", shape=plaintext];\n", id)
+		return id
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		d.printf("  %s [label=%s, shape=box];\n", id, dotQuote(fmt.Sprintf("%s (%d)", v.Type(), v.Len())))
+		for _, k := range sortedMapKeys(v) {
+			if d.err != nil {
+				break
+			}
+			d.edge(id, d.node(v.MapIndex(k)), cleanString(sprint(k)))
+		}
+	case reflect.Slice, reflect.Array:
+		d.printf("  %s [label=%s, shape=box];\n", id, dotQuote(fmt.Sprintf("%s (%d)", v.Type(), v.Len())))
+		for i := 0; i < v.Len() && d.err == nil; i++ {
+			d.edge(id, d.node(v.Index(i)), fmt.Sprintf("[%d]", i))
+		}
+	case reflect.Struct:
+		d.printf("  %s [label=%s, shape=box];\n", id, dotQuote(v.Type().String()))
+		for i := 0; i < v.NumField() && d.err == nil; i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			d.edge(id, d.node(v.Field(i)), v.Type().Field(i).Name)
+		}
+	default:
+		d.printf("  %s [label=%s];\n", id, dotQuote(cleanString(sprint(v))+"\n("+v.Type().String()+")"))
+	}
+	return id
+}
+
+func (d *dotWriter) edge(from, to, label string) {
+	d.printf("  %s -> %s [label=%s];\n", from, to, dotQuote(label))
+}
+
+// dotQuote makes s a DOT double-quoted string.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
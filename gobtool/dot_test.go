@@ -0,0 +1,74 @@
+package gobtool
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteDot(t *testing.T) {
+	data := map[string]interface{}{
+		"quote": `say "hi"`,
+		"tags":  []string{"a", "b"},
+		"inner": map[string]interface{}{"n": 1, "path": `C:\tmp`},
+	}
+	var b strings.Builder
+	if err := writeDot(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.HasPrefix(out, "digraph gob {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("not a digraph:\n%s", out)
+	}
+
+	// Braces balance outside quoted strings, and no string runs past the
+	// end of its line.
+	depth, quoted := 0, false
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted && c == '\n':
+			t.Fatalf("string runs past the end of a line:\n%s", out)
+		case !quoted && c == '{':
+			depth++
+		case !quoted && c == '}':
+			depth--
+			if depth < 0 {
+				t.Fatalf("unbalanced }:\n%s", out)
+			}
+		}
+	}
+	if depth != 0 || quoted {
+		t.Errorf("unbalanced braces or quotes:\n%s", out)
+	}
+
+	// One node per value: the two maps, the slice and five scalars.
+	nodes := map[string]bool{}
+	for _, m := range regexp.MustCompile(`(?m)^  (n\d+) \[label=`).FindAllStringSubmatch(out, -1) {
+		if nodes[m[1]] {
+			t.Errorf("node %s declared twice", m[1])
+		}
+		nodes[m[1]] = true
+	}
+	if len(nodes) != 8 {
+		t.Errorf("got %d nodes, want 8:\n%s", len(nodes), out)
+	}
+	edges := regexp.MustCompile(`(?m)^  (n\d+) -> (n\d+) `).FindAllStringSubmatch(out, -1)
+	if len(edges) != len(nodes)-1 {
+		t.Errorf("got %d edges for %d nodes", len(edges), len(nodes))
+	}
+	for _, e := range edges {
+		if !nodes[e[1]] || !nodes[e[2]] {
+			t.Errorf("edge %s -> %s to an undeclared node", e[1], e[2])
+		}
+	}
+
+	for _, want := range []string{`label="say \"hi\"\n(string)"`, `label="C:\\tmp\n(string)"`, `label="[1]"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %s:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package gobtool
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// durationPair is one -compute-durations from=to: the paths of two time
+// fields, as walkPaths names them.
+type durationPair struct {
+	from, to string
+}
+
+func parseDurationPair(s string) (durationPair, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok || from == "" || to == "" {
+		return durationPair{}, fmt.Errorf("want from=to, such as created_at=expires_at, not %q", s)
+	}
+	return durationPair{from, to}, nil
+}
+
+// writeDurations prints, for each pair, the time from its from field to
+// its to field. A pair whose fields are missing or not times is skipped
+// with a warning at the offending path.
+func writeDurations(w io.Writer, data interface{}, pairs []durationPair, ws *Warnings) error {
+	for _, p := range pairs {
+		from, ok := timeAt(data, p.from, ws)
+		if !ok {
+			continue
+		}
+		to, ok := timeAt(data, p.to, ws)
+		if !ok {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "duration %s -> %s: %s\n", p.from, p.to, to.Sub(from)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeAt returns the time.Time at path, warning when there is none.
+func timeAt(data interface{}, path string, ws *Warnings) (time.Time, bool) {
+	v, ok := lookupPath(data, path)
+	if !ok {
+		ws.add(WarnNoDuration, path, "no such field")
+		return time.Time{}, false
+	}
+	v = indirect(v)
+	if !v.IsValid() {
+		ws.add(WarnNoDuration, path, "the field is nil")
+		return time.Time{}, false
+	}
+	t, ok := v.Interface().(time.Time)
+	if !ok {
+		ws.add(WarnNoDuration, path, "a %s, not a time.Time", v.Type())
+	}
+	return t, ok
+}
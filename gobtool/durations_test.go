@@ -0,0 +1,51 @@
+package gobtool
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDurations(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := map[interface{}]interface{}{
+		"created_at": created,
+		"expires_at": created.Add(90 * time.Minute),
+		"name":       "x",
+		"gone":       nil,
+	}
+	pairs := []durationPair{
+		{"created_at", "expires_at"},
+		{"created_at", "name"},
+		{"missing", "expires_at"},
+		{"gone", "expires_at"},
+	}
+	var b strings.Builder
+	var ws Warnings
+	if err := writeDurations(&b, data, pairs, &ws); err != nil {
+		t.Fatal(err)
+	}
+	if want := "duration created_at -> expires_at: 1h30m0s\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+	want := []string{"name: a string, not a time.Time", "missing: no such field", "gone: the field is nil"}
+	if len(ws.List) != len(want) {
+		t.Fatalf("got warnings %v", ws.List)
+	}
+	for i, w := range ws.List {
+		if w.Code != WarnNoDuration || w.Path+": "+w.Message != want[i] {
+			t.Errorf("warning %d: got %s %s: %s, want %s", i, w.Code, w.Path, w.Message, want[i])
+		}
+	}
+}
+
+func TestParseDurationPair(t *testing.T) {
+	if p, err := parseDurationPair("a.b=c"); err != nil || p != (durationPair{"a.b", "c"}) {
+		t.Errorf("a.b=c: got %v, %v", p, err)
+	}
+	for _, s := range []string{"a", "=b", "a="} {
+		if _, err := parseDurationPair(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package gobtool
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// encodeFlags registers the flags of encode; the command returned
+// encodes a JSON object into a gob file.
+func encodeFlags(fs *flag.FlagSet) func(args []string) error {
+	in := fs.String("in", "-", "JSON input file, - for stdin or fd:N for an inherited file descriptor")
+	store := fs.String("store", "", "keep the payload in this content-addressed store and write a reference")
+	fs.IntVar(&encodeBufferSize, "buffer-size", encodeBufferSize, "write buffer size in bytes, 0 for unbuffered")
+	stream := fs.Bool("stream", false, "encode each JSON value of the input as a separate record")
+	ndjson := fs.Bool("from-ndjson", false, "read one JSON object per line and encode each as a map[string]interface{} record with a single encoder")
+	countFooter := fs.Bool("count-footer", false, "with -stream, end the file with the number of records, for decode -count-footer to check")
+	sorted := fs.Bool("sort-slices", false, "sort the elements of slices of one scalar type, for reproducible output (changes the data: only for slices whose order means nothing)")
+	var drop []string
+	fs.Func("drop", "leave out this top-level key (repeatable)", func(s string) error {
+		drop = append(drop, s)
+		return nil
+	})
+	deep := fs.Bool("deep", false, "with -drop, leave the keys out at any depth")
+	shards := fs.Int("shards", 0, "split the top-level map into this many shards, encoded in parallel into one sharded file")
+	interchange := fs.Bool("interchange", false, "read the input as interchange JSON, turning {\"__type\": ..., \"value\": ...} annotations into Go values")
+	var budget budgetFlags
+	budget.register(fs)
+	var warns warningFlags
+	warns.register(fs)
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errors.New("usage: encode [-in file.json] [-store dir | -shards N] [-buffer-size N] [-stream [-count-footer] | -from-ndjson] [-sort-slices] [-interchange] [-drop key... [-deep]] [-max-encoded-size N [-warn-only] [-cookie name [-cookie-encrypted]]] [-strict-warnings] out.gob")
+		}
+		out := args[0]
+
+		if *deep && drop == nil {
+			return errors.New("-deep needs -drop")
+		}
+		if *countFooter && !*stream {
+			return errors.New("-count-footer needs -stream")
+		}
+		if *shards != 0 && (*shards < 1 || *shards > maxShards) {
+			return fmt.Errorf("-shards %d: want 1 to %d", *shards, maxShards)
+		}
+		if *shards != 0 && (*stream || *ndjson || *store != "") {
+			return errors.New("-shards cannot be combined with -stream, -from-ndjson or -store")
+		}
+		if *ndjson {
+			if *stream || *store != "" || drop != nil || *countFooter {
+				return errors.New("-from-ndjson cannot be combined with -stream, -store, -drop or -count-footer")
+			}
+			safeRegister(map[string]interface{}{})
+			safeRegister([]interface{}{})
+			registerInterchangeTypes()
+			r, closeIn, err := openInput(*in)
+			if err != nil {
+				return err
+			}
+			defer closeIn()
+			n, err := encodeNDJSON(r, out, *sorted, *interchange, &warns.Warnings)
+			if err != nil {
+				return err
+			}
+			if err := warns.report(os.Stderr); err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d records\n", out, n)
+			return nil
+		}
+		if *stream {
+			if *store != "" || drop != nil {
+				return errors.New("-stream cannot be combined with -store or -drop")
+			}
+			safeRegister(map[string]interface{}{})
+			safeRegister([]interface{}{})
+			registerInterchangeTypes()
+			r, closeIn, err := openInput(*in)
+			if err != nil {
+				return err
+			}
+			defer closeIn()
+			n, err := encodeJSONStream(r, out, *sorted, *interchange, *countFooter, &warns.Warnings)
+			if err != nil {
+				return err
+			}
+			if err := warns.report(os.Stderr); err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d records\n", out, n)
+			return nil
+		}
+
+		data, err := readJSONMap(*in, &warns.Warnings)
+		if err != nil {
+			return err
+		}
+		if *interchange {
+			if _, err := fromInterchange(data, "", &warns.Warnings); err != nil {
+				return err
+			}
+		}
+		if drop != nil {
+			data = dropKeys(data, drop, *deep)
+		}
+		if *sorted {
+			sortSlices(data, &warns.Warnings)
+		}
+		if err := warns.report(os.Stderr); err != nil {
+			return err
+		}
+		safeRegister(map[string]interface{}{})
+		safeRegister([]interface{}{})
+		registerInterchangeTypes()
+		if err := budget.check(os.Stderr, data); err != nil {
+			return err
+		}
+		if *shards != 0 {
+			if err := EncodeSharded(out, data, *shards); err != nil {
+				return err
+			}
+			fmt.Printf("%s: %d keys in %d shards\n", out, len(data), *shards)
+			return nil
+		}
+		if *store == "" {
+			return encodeAndWriteToFile(data, out)
+		}
+		ref, err := EncodeToStore(*store, out, data)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s -> %s (%d bytes)\n", out, ref.Hash, ref.Size)
+		return nil
+	}
+}
+
+// EncodeFiltered writes data to path as encode does, without the
+// top-level keys listed in drop. data itself is left as it was.
+func EncodeFiltered(path string, data map[interface{}]interface{}, drop []string) error {
+	return encodeAndWriteToFile(dropKeys(data, drop, false), path)
+}
+
+// EncodeFilteredDeep is EncodeFiltered dropping the keys from maps at
+// any depth, inside slices too.
+func EncodeFilteredDeep(path string, data map[interface{}]interface{}, drop []string) error {
+	return encodeAndWriteToFile(dropKeys(data, drop, true), path)
+}
+
+// dropKeys returns a copy of data without the string keys in drop, and
+// with deep set, nested maps and slices copied without them as well.
+// reencode -drop does the same by path glob on files.
+func dropKeys(data map[interface{}]interface{}, drop []string, deep bool) map[interface{}]interface{} {
+	names := make(map[string]bool, len(drop))
+	for _, d := range drop {
+		names[d] = true
+	}
+	var walk func(v interface{}) interface{}
+	walk = func(v interface{}) interface{} {
+		if !deep {
+			return v
+		}
+		switch x := v.(type) {
+		case map[interface{}]interface{}:
+			out := make(map[interface{}]interface{}, len(x))
+			for k, e := range x {
+				if s, ok := k.(string); !ok || !names[s] {
+					out[k] = walk(e)
+				}
+			}
+			return out
+		case map[string]interface{}:
+			out := make(map[string]interface{}, len(x))
+			for k, e := range x {
+				if !names[k] {
+					out[k] = walk(e)
+				}
+			}
+			return out
+		case []interface{}:
+			out := make([]interface{}, len(x))
+			for i, e := range x {
+				out[i] = walk(e)
+			}
+			return out
+		}
+		return v
+	}
+	out := make(map[interface{}]interface{}, len(data))
+	for k, v := range data {
+		if s, ok := k.(string); !ok || !names[s] {
+			out[k] = walk(v)
+		}
+	}
+	return out
+}
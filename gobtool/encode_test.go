@@ -0,0 +1,46 @@
+package gobtool
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeFiltered(t *testing.T) {
+	registerKnownTypes()
+	data := map[interface{}]interface{}{
+		"secret": "s",
+		"keep":   1,
+		7:        "int key",
+		"nested": map[interface{}]interface{}{
+			"secret": "t",
+			"list":   []interface{}{map[string]interface{}{"secret": "u", "x": 2}},
+		},
+	}
+	for _, c := range []struct {
+		name   string
+		encode func(string, map[interface{}]interface{}, []string) error
+		nested interface{}
+	}{
+		{"shallow", EncodeFiltered, data["nested"]},
+		{"deep", EncodeFilteredDeep, map[interface{}]interface{}{
+			"list": []interface{}{map[string]interface{}{"x": 2}},
+		}},
+	} {
+		name := filepath.Join(t.TempDir(), "out.gob")
+		if err := c.encode(name, data, []string{"secret", "7"}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := decodeAnyFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[interface{}]interface{}{"keep": 1, 7: "int key", "nested": c.nested}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %#v, want %#v", c.name, got, want)
+		}
+	}
+	if _, ok := data["secret"]; !ok {
+		t.Error("the input map was changed")
+	}
+}
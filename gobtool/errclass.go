@@ -0,0 +1,162 @@
+package gobtool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"test-gob/errclass"
+)
+
+// ErrChecksumMismatch is returned for a file whose content hash differs
+// from the one recorded for it.
+var ErrChecksumMismatch = errors.New("content hash does not match")
+
+// unregisteredName matches the message encoding/gob gives for an
+// interface value of a type not registered here.
+var unregisteredName = regexp.MustCompile(`name not registered for interface: "([^"]*)"`)
+
+// ClassifyError returns the class of err, and for
+// errclass.UnregisteredType the name of the type as it was registered by
+// the writer. A nil error has no class. Commands that work through many
+// files, such as manifest, summarize their failures by class.
+//
+// Errors are classified by the types and sentinels they wrap, which the
+// code that found the fault returns. encoding/gob's own errors have
+// neither, so the messages it gives are matched as a last resort, after
+// everything that can be tested with errors.Is and errors.As.
+func ClassifyError(err error) (errclass.Class, string) {
+	if err == nil {
+		return "", ""
+	}
+	var (
+		pathErr     *fs.PathError
+		budgetErr   *BudgetExceededError
+		deadlineErr *ValueDeadlineError
+		layerErr    *unsupportedLayerError
+		formatErr   *formatError
+		payloadErr  *corruptPayloadError
+	)
+	switch {
+	case errors.Is(err, ErrChecksumMismatch):
+		return errclass.ChecksumMismatch, ""
+	case errors.As(err, &budgetErr), errors.As(err, &deadlineErr), errors.Is(err, errInputLimit),
+		errors.Is(err, errOutputCap), errors.Is(err, errTypesTooDeep), errors.Is(err, context.DeadlineExceeded):
+		return errclass.LimitExceeded, ""
+	case errors.As(err, &pathErr):
+		return errclass.IOError, ""
+	case errors.As(err, &layerErr):
+		return errclass.NotGob, ""
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF), errors.Is(err, errEmptyStream):
+		return errclass.Truncated, ""
+	case errors.As(err, &formatErr), errors.As(err, &payloadErr),
+		errors.Is(err, errWireOverrun), errors.Is(err, errBadTypeDef):
+		return errclass.NotGob, ""
+	}
+
+	// The last resort: encoding/gob's messages.
+	msg := err.Error()
+	if m := unregisteredName.FindStringSubmatch(msg); m != nil {
+		return errclass.UnregisteredType, m[1]
+	}
+	switch {
+	case strings.Contains(msg, "unexpected EOF"), strings.HasSuffix(msg, ": EOF"):
+		return errclass.Truncated, ""
+	case strings.Contains(msg, "gob: decoding into local type"), strings.Contains(msg, "gob: type mismatch"),
+		strings.Contains(msg, "gob: wrong type"):
+		return errclass.TypeMismatch, ""
+	case strings.Contains(msg, "gob: "):
+		return errclass.NotGob, ""
+	}
+	return errclass.Other, ""
+}
+
+// errorBucket is the key failures are grouped under: the class, followed
+// for unregistered types by the type name, as unregistered-type:main.T.
+func errorBucket(err error) string {
+	class, name := ClassifyError(err)
+	if class == errclass.UnregisteredType {
+		return string(class) + ":" + name
+	}
+	return string(class)
+}
+
+// maxErrorExamples is how many files each bucket of an errorSummary names.
+const maxErrorExamples = 3
+
+// errorSummary groups the failures of a run over many files by bucket.
+type errorSummary struct {
+	Failed  int                 `json:"failed"`
+	Buckets []*errorBucketCount `json:"classes"`
+	byKey   map[string]*errorBucketCount
+}
+
+type errorBucketCount struct {
+	Class    string   `json:"class"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples"` // the first files that failed so
+	Message  string   `json:"message"`  // the error of the first of them
+}
+
+func (s *errorSummary) add(file string, err error) {
+	key := errorBucket(err)
+	b := s.byKey[key]
+	if b == nil {
+		if s.byKey == nil {
+			s.byKey = make(map[string]*errorBucketCount)
+		}
+		b = &errorBucketCount{Class: key, Message: err.Error()}
+		s.byKey[key] = b
+		s.Buckets = append(s.Buckets, b)
+	}
+	b.Count++
+	if len(b.Examples) < maxErrorExamples {
+		b.Examples = append(b.Examples, file)
+	}
+	s.Failed++
+}
+
+// sort orders the buckets most frequent first.
+func (s *errorSummary) sort() {
+	sort.SliceStable(s.Buckets, func(i, j int) bool {
+		if s.Buckets[i].Count != s.Buckets[j].Count {
+			return s.Buckets[i].Count > s.Buckets[j].Count
+		}
+		return s.Buckets[i].Class < s.Buckets[j].Class
+	})
+}
+
+// write prints the summary as a table of buckets with example files.
+func (s *errorSummary) write(w io.Writer, total int) error {
+	s.sort()
+	fmt.Fprintf(w, "%d of %d files failed:\n", s.Failed, total)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, b := range s.Buckets {
+		more := ""
+		if b.Count > len(b.Examples) {
+			more = ", ..."
+		}
+		fmt.Fprintf(tw, "  %s\t%d\te.g. %s%s\n", b.Class, b.Count, strings.Join(b.Examples, ", "), more)
+	}
+	return tw.Flush()
+}
+
+// writeFile writes the summary to name as JSON, for -errors-out.
+func (s *errorSummary) writeFile(name string) error {
+	s.sort()
+	if s.Buckets == nil {
+		s.Buckets = []*errorBucketCount{}
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(name, append(b, '\n'))
+}
@@ -0,0 +1,173 @@
+package gobtool
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"test-gob/errclass"
+)
+
+// errclassProbe is registered under one name and then written under
+// another, to make a file holding an unregistered type.
+type errclassProbe struct{ N int }
+
+func init() { gob.RegisterName("errclass-probe-A", errclassProbe{}) }
+
+// TestErrorClassCorpus runs manifest over a corpus with one file for
+// each kind of failure a file can have, and checks the classes of the
+// failures that other inputs and options cause. None may land in other.
+func TestErrorClassCorpus(t *testing.T) {
+	registerKnownTypes()
+	encode := func(v interface{}) []byte {
+		var buf bytes.Buffer
+		if err := encodeRoot(gob.NewEncoder(&buf), v); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	valid := encode(map[string]interface{}{"a": []int{1, 2, 3}})
+	badID := rewriteValue(t, valid, func(id typeID, body []byte) (typeID, []byte) { return id + 50, body })
+	unregistered := bytes.ReplaceAll(encode(map[interface{}]interface{}{"v": errclassProbe{1}}), []byte("probe-A"), []byte("probe-B"))
+	deep := encode(map[string][]map[string][]int{"a": {{"b": {1}}}})
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"valid.gob":     valid,
+		"truncated.gob": valid[:len(valid)-5],
+		"empty.gob":     {},
+		"text.txt":      []byte("not a gob file\n"),
+		"type-id.gob":   badID,
+		"zstd.gob":      append([]byte{0x28, 0xb5, 0x2f, 0xfd}, valid...),
+		"bom.gob":       append([]byte("\xef\xbb\xbf"), valid...),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("missing.gob", filepath.Join(dir, "dangling.gob")); err != nil {
+		t.Fatal(err)
+	}
+
+	summary := func(t *testing.T, args ...string) map[string][]string {
+		t.Helper()
+		out := filepath.Join(t.TempDir(), "errors.json")
+		if _, err := runCaptured(t, "manifest", append([]string{"-errors-out", out}, args...)...); err == nil {
+			t.Fatal("manifest: no error for failing files")
+		}
+		b, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s errorSummary
+		if err := json.Unmarshal(b, &s); err != nil {
+			t.Fatal(err)
+		}
+		got := map[string][]string{}
+		for _, b := range s.Buckets {
+			for _, f := range b.Examples {
+				got[b.Class] = append(got[b.Class], filepath.Base(f))
+			}
+			sort.Strings(got[b.Class])
+		}
+		return got
+	}
+	got := summary(t, dir)
+	want := map[string][]string{
+		string(errclass.NotGob):    {"bom.gob", "text.txt", "type-id.gob"},
+		string(errclass.Truncated): {"empty.gob", "truncated.gob"},
+		string(errclass.IOError):   {"dangling.gob"},
+	}
+	// zstd.gob makes a fourth not-gob failure, past the three examples.
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("manifest classes:\n got %v\nwant %v", got, want)
+	}
+
+	manifest := filepath.Join(t.TempDir(), "manifest.txt")
+	hash, err := fileContentHash(filepath.Join(dir, "valid.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifest, []byte(fmt.Sprintf("%s  %s\n", hash, filepath.Join(dir, "type-id.gob"))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "type-id.gob"), encode(map[string]interface{}{"a": 1}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := summary(t, "-verify", manifest); !reflect.DeepEqual(got, map[string][]string{string(errclass.ChecksumMismatch): {"type-id.gob"}}) {
+		t.Errorf("manifest -verify classes: %v", got)
+	}
+
+	// Failures that come from options and callers rather than the file.
+	// Reading a whole file decodes an unregistered type as its fields, so
+	// only a decode into Go types fails on one.
+	if err := os.WriteFile(filepath.Join(dir, "deep.gob"), deep, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	t.Cleanup(func() { maxTypeDepth = defaultMaxTypeDepth })
+	for _, c := range []struct {
+		name string
+		err  func() error
+		want string // the bucket, which names unregistered types
+	}{
+		{"size limit", func() error {
+			_, err := Decode(bytes.NewReader(valid), WithLimits(Limits{MaxBytes: 10}))
+			return err
+		}, string(errclass.LimitExceeded)},
+		{"type depth", func() error {
+			_, err := runCaptured(t, "types", "-max-type-depth", "1", filepath.Join(dir, "deep.gob"))
+			return err
+		}, string(errclass.LimitExceeded)},
+		{"size budget", func() error {
+			return CheckEncodedSize(map[interface{}]interface{}{"a": strings.Repeat("x", 100)}, 10)
+		}, string(errclass.LimitExceeded)},
+		{"deadline", func() error { return fmt.Errorf("listing: %w", ctx.Err()) }, string(errclass.LimitExceeded)},
+		{"unregistered type", func() error {
+			_, err := Decode(bytes.NewReader(unregistered))
+			return err
+		}, string(errclass.UnregisteredType) + ":errclass-probe-B"},
+		{"other root type", func() error {
+			_, err := Decode(bytes.NewReader(encode([]int{1})))
+			return err
+		}, string(errclass.TypeMismatch)},
+	} {
+		err := c.err()
+		if got := errorBucket(err); got != c.want {
+			t.Errorf("%s: class %q, want %q (%v)", c.name, got, c.want, err)
+		}
+	}
+}
+
+// TestClassifyErrorTyped checks that errors are classified by the types
+// and sentinels they wrap, whatever their messages say.
+func TestClassifyErrorTyped(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want errclass.Class
+	}{
+		{fmt.Errorf("record 2: %w", &formatError{"field 9 of nothing"}), errclass.NotGob},
+		{&corruptPayloadError{"gzip data is corrupt", errors.New("flate: corrupt input")}, errclass.NotGob},
+		{&corruptPayloadError{"decompressed gzip content is not valid gob", io.ErrUnexpectedEOF}, errclass.Truncated},
+		{fmt.Errorf("type 70: %w", errBadTypeDef), errclass.NotGob},
+		{fmt.Errorf("reading: %w", errEmptyStream), errclass.Truncated},
+	} {
+		if got, _ := ClassifyError(c.err); got != c.want {
+			t.Errorf("%v: class %q, want %q", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package gobtool
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"test-gob/sessionsource"
+)
+
+// Beyond filtering, the -where language computes values: session head
+// -column name=expr adds a column to every line, and eval prints what an
+// expression gives for one file, to try it out first:
+//
+//	session head -column 'age_days=daysBetween(Values.created_at, now)' sessions/
+//	eval 'exists(Values.access_token)' -session sessions/abc
+//
+// An expression that is a single operand gives its value; any other
+// gives true or false. On top of filter's operands there is now, the
+// time the expression was parsed, so the same for every record of a run,
+// and a deliberately small set of functions:
+//
+//	exists(x)         x is present and not nil
+//	len(x)            characters of a string, elements of a slice or map
+//	lower(s) upper(s) s in lower or upper case
+//	contains(s, sub)  s contains sub
+//	daysBetween(a, b) whole days from time a to time b
+//
+// A path given to a function reaches it as it is, so len and exists see
+// maps and slices that comparisons cannot. Times may be time.Time
+// values, RFC 3339 strings, or the encoded bytes a time sent as an
+// unregistered interface decodes to. An expression that fails for one
+// line, say daysBetween of a string that is not a time, reports the
+// error in that line's cell and on stderr and the rest go on; the
+// command fails at the end.
+
+// exprArg is one argument of a call, with its raw value when it was a
+// path.
+type exprArg struct {
+	what  string        // the path or value, for messages
+	raw   reflect.Value // pointers and interfaces followed
+	found bool
+}
+
+// scalar is the argument as comparisons see it.
+func (a exprArg) scalar() (interface{}, error) {
+	if !a.found {
+		return nil, nil
+	}
+	return scalarValue(a.raw, a.what)
+}
+
+func (a exprArg) str(fn string) (string, error) {
+	v, err := a.scalar()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s: %s is %s, not a string", fn, a.what, exprString(v))
+	}
+	return s, nil
+}
+
+func (a exprArg) time(fn string) (time.Time, error) {
+	if a.found {
+		if t, ok := asTimeValue(a.raw); ok {
+			return t, nil
+		}
+		if a.raw.Kind() == reflect.String {
+			if t, err := time.Parse(time.RFC3339Nano, a.raw.String()); err == nil {
+				return t, nil
+			}
+		}
+	}
+	v, _ := a.scalar()
+	return time.Time{}, fmt.Errorf("%s: %s is %s, not a time", fn, a.what, exprString(v))
+}
+
+// asTimeValue returns v as a time if it is one, either a time.Time or
+// the bytes time.Time.MarshalBinary writes, which is what a time sent as
+// an interface decodes to when its type is not registered.
+func asTimeValue(v reflect.Value) (time.Time, bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return time.Time{}, false
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return x, true
+	case []byte:
+		var t time.Time
+		if len(x) >= 15 && t.UnmarshalBinary(x) == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// exprFunc is one function expressions can call.
+type exprFunc struct {
+	args int
+	fn   func(name string, args []exprArg) (interface{}, error)
+}
+
+var exprFuncs = map[string]exprFunc{
+	"exists": {1, func(_ string, a []exprArg) (interface{}, error) {
+		return a[0].found, nil
+	}},
+	"len": {1, func(name string, a []exprArg) (interface{}, error) {
+		if !a[0].found {
+			return nil, nil
+		}
+		switch v := a[0].raw; v.Kind() {
+		case reflect.String:
+			return float64(utf8.RuneCountInString(v.String())), nil
+		case reflect.Map, reflect.Slice, reflect.Array:
+			return float64(v.Len()), nil
+		default:
+			return nil, fmt.Errorf("%s: %s is %s, which has no length", name, a[0].what, v.Type())
+		}
+	}},
+	"lower": {1, func(name string, a []exprArg) (interface{}, error) {
+		s, err := a[0].str(name)
+		return strings.ToLower(s), err
+	}},
+	"upper": {1, func(name string, a []exprArg) (interface{}, error) {
+		s, err := a[0].str(name)
+		return strings.ToUpper(s), err
+	}},
+	"contains": {2, func(name string, a []exprArg) (interface{}, error) {
+		s, err := a[0].str(name)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := a[1].str(name)
+		return strings.Contains(s, sub), err
+	}},
+	"daysBetween": {2, func(name string, a []exprArg) (interface{}, error) {
+		from, err := a[0].time(name)
+		if err != nil {
+			return nil, err
+		}
+		to, err := a[1].time(name)
+		if err != nil {
+			return nil, err
+		}
+		return math.Floor(to.Sub(from).Hours() / 24), nil
+	}},
+}
+
+// callOperand calls a function on its arguments.
+type callOperand struct {
+	name string
+	fn   exprFunc
+	args []filterOperand
+}
+
+func (c callOperand) value(r filterRecord) (interface{}, error) {
+	args := make([]exprArg, len(c.args))
+	for i, a := range c.args {
+		if p, ok := a.(pathOperand); ok {
+			v, found := lookupPath(r.value, p.path)
+			args[i] = exprArg{what: p.path, raw: v, found: found && v.IsValid()}
+			continue
+		}
+		v, err := a.value(r)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = exprArg{what: exprString(v), raw: reflect.ValueOf(v), found: v != nil}
+	}
+	return c.fn.fn(c.name, args)
+}
+
+// call parses the arguments of a call to name, the "(" being next.
+func (p *filterParser) call(name string) (filterOperand, error) {
+	fn, ok := exprFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	p.pos++
+	c := callOperand{name: name, fn: fn}
+	for p.peek() != ")" {
+		if len(c.args) > 0 {
+			if p.peek() != "," {
+				return nil, fmt.Errorf("%s: want , or ) after argument %d", name, len(c.args))
+			}
+			p.pos++
+		}
+		a, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, a)
+	}
+	p.pos++
+	if len(c.args) != fn.args {
+		return nil, fmt.Errorf("%s takes %d %s, not %d", name, fn.args, plural(int64(fn.args), "argument", "arguments"), len(c.args))
+	}
+	return c, nil
+}
+
+// evalExpr gives the value of e for r: the operand's value if e is a
+// single operand, and otherwise whether it holds.
+func evalExpr(e filterExpr, r filterRecord) (interface{}, error) {
+	if c, ok := e.(cmpExpr); ok && c.op == "" {
+		return c.l.value(r)
+	}
+	return e.eval(r)
+}
+
+// exprString renders a value an expression gave.
+func exprString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+// exprColumn is one -column name=expr.
+type exprColumn struct {
+	name string
+	expr filterExpr
+}
+
+// exprColumns is a repeatable -column flag.
+type exprColumns []exprColumn
+
+func (cs *exprColumns) register(fs *flag.FlagSet) {
+	fs.Func("column", "add a column computed by an expression, as name=expr (repeatable; see eval)", func(s string) error {
+		name, src, ok := strings.Cut(s, "=")
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return fmt.Errorf("%q: want name=expr", s)
+		}
+		e, err := parseFilter(src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*cs = append(*cs, exprColumn{name, e})
+		return nil
+	})
+}
+
+// cells evaluates the columns for r as name=value, with the error in
+// place of the value where one fails. failed reports whether any did;
+// the errors are also printed on stderr, for the row what.
+func (cs exprColumns) cells(what string, r filterRecord) (cells []string, failed bool) {
+	for _, c := range cs {
+		v, err := evalExpr(c.expr, r)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: column %s: %v\n", what, c.name, err)
+			cells = append(cells, fmt.Sprintf("%s=error: %v", c.name, err))
+			failed = true
+			continue
+		}
+		cells = append(cells, c.name+"="+exprString(v))
+	}
+	return cells, failed
+}
+
+// evalFlags registers the flags of eval; the command returned implements
+// "eval expr file", printing what expr gives for each record of file, or
+// with -session, for the session as session head -column sees it.
+func evalFlags(fs *flag.FlagSet) func(args []string) error {
+	session := fs.Bool("session", false, "evaluate against the session as session head -column sees it: File, Size, ID, IsNew, Options and Values")
+	return func(args []string) error {
+		if len(args) != 2 {
+			return errors.New("usage: eval [-session] expr file")
+		}
+		e, err := parseFilter(args[0])
+		if err != nil {
+			return err
+		}
+		registerKnownTypes()
+		var records []filterRecord
+		if *session {
+			src, err := sessionsource.Open(args[1])
+			if err != nil {
+				return err
+			}
+			refs, err := src.List(runContext)
+			if err != nil {
+				return err
+			}
+			if len(refs) != 1 {
+				return fmt.Errorf("%s: want one session file, found %d", args[1], len(refs))
+			}
+			h, err := readSessionHead(runContext, src, refs[0], false)
+			if err != nil {
+				return err
+			}
+			records = append(records, h.record())
+		} else {
+			f, err := openFile(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r, err := openPayload(f, args[1])
+			if err != nil {
+				return err
+			}
+			rr := newRecordReader(r)
+			for {
+				rec, _, name, _, err := rr.nextTyped(func(string) bool { return true }, false)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					return payloadError(r, err)
+				}
+				records = append(records, filterRecord{name, rec})
+			}
+		}
+		failed := false
+		for i, rec := range records {
+			prefix := ""
+			if len(records) > 1 {
+				prefix = fmt.Sprintf("record %d: ", i)
+			}
+			v, err := evalExpr(e, rec)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s%v\n", prefix, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s%s\n", prefix, exprString(v))
+		}
+		if failed {
+			return exitCode(1)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,107 @@
+package gobtool
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestExprColumns checks the functions -column offers on session records
+// like session head builds, and that a failing row reports its error
+// without stopping the others.
+func TestExprColumns(t *testing.T) {
+	created := time.Now().Add(-72*time.Hour - time.Minute)
+	stamp, err := created.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []filterRecord{
+		{"session", map[string]interface{}{"ID": "a", "Values": map[interface{}]interface{}{
+			"created_at": created, "access_token": "t0k", "name": "Ada", "roles": []string{"x", "y"},
+		}}},
+		{"session", map[string]interface{}{"ID": "b", "Values": map[interface{}]interface{}{
+			"created_at": stamp, "name": "Bob",
+		}}},
+		{"session", map[string]interface{}{"ID": "c", "Values": map[interface{}]interface{}{
+			"created_at": created.Format(time.RFC3339), "name": "Cy",
+		}}},
+		{"session", map[string]interface{}{"ID": "d", "Values": map[interface{}]interface{}{
+			"created_at": "last week", "name": "Di",
+		}}},
+	}
+	var columns exprColumns
+	fs := flag.NewFlagSet("expr", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	columns.register(fs)
+	if err := fs.Parse([]string{
+		"-column", "age_days=daysBetween(Values.created_at, now)",
+		"-column", "has_token=exists(Values.access_token)",
+		"-column", "who=upper(Values.name)",
+		"-column", "short=len(Values.name) < 3 || contains(lower(Values.name), \"a\")",
+		"-column", "roles=len(Values.roles)",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{`age_days=3`, `has_token=true`, `who="ADA"`, `short=true`, `roles=2`},
+		{`age_days=3`, `has_token=false`, `who="BOB"`, `short=false`, `roles=nil`},
+		{`age_days=3`, `has_token=false`, `who="CY"`, `short=true`, `roles=nil`},
+		{`age_days=error: daysBetween: Values.created_at is "last week", not a time`, `has_token=false`, `who="DI"`, `short=true`, `roles=nil`},
+	}
+	stderr := os.Stderr
+	os.Stderr, _ = os.Open(os.DevNull)
+	defer func() { os.Stderr = stderr }()
+	for i, r := range rows {
+		got, failed := columns.cells(fmt.Sprintf("row %d", i), r)
+		if !reflect.DeepEqual(got, want[i]) || failed != (i == 3) {
+			t.Errorf("row %d: got %q (failed %v), want %q", i, got, failed, want[i])
+		}
+	}
+}
+
+func TestExprColumnErrors(t *testing.T) {
+	for _, bad := range []string{"nope(ID)", "len(ID, ID)", "len(ID", "=len(ID)"} {
+		var columns exprColumns
+		fs := flag.NewFlagSet("expr", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		columns.register(fs)
+		if err := fs.Parse([]string{"-column", bad}); err == nil {
+			t.Errorf("-column %s: no error", bad)
+		}
+	}
+	if _, err := parseFilter("len(Values) + 1"); err == nil {
+		t.Error("len(Values) + 1: no error")
+	}
+}
+
+func TestExprLen(t *testing.T) {
+	data := syntheticData(30, 1)
+	e, err := parseFilter("len(Values)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := evalExpr(e, filterRecord{"session", map[string]interface{}{"Values": data}}); err != nil || v != float64(len(data)) {
+		t.Errorf("got %v, %v; want %d", v, err, len(data))
+	}
+}
+
+// now is read when the expression is parsed, not once for the process,
+// so a long-running program sees the time move on.
+func TestExprNowAtParse(t *testing.T) {
+	before := time.Now()
+	e, err := parseFilter("now")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := evalExpr(e, filterRecord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if now, ok := got.(time.Time); !ok || now.Before(before) {
+		t.Errorf("now = %v, want a time after %v", got, before)
+	}
+}
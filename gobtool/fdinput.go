@@ -0,0 +1,50 @@
+package gobtool
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Input files may be given as "fd:N" to read from file descriptor N,
+// already open and inherited from the parent process, instead of opening a
+// path. This lets a privileged parent open the file and hand it to a
+// sandboxed decoder. The descriptor is read from its current offset and
+// closed when the command is done with it, and a pipe works as well as a
+// file, though -timeout-per-mb sees a pipe as empty. On Windows N is a
+// handle value rather than a descriptor number.
+
+// inputFile is a file openFile opened: an *os.File, or a *dataFile for a
+// data URI.
+type inputFile interface {
+	io.ReadCloser
+	Name() string
+	Stat() (fs.FileInfo, error)
+}
+
+// openFile is os.Open, except that "fd:N" names an inherited descriptor
+// and "data:..." a data URI (see datauri.go).
+func openFile(name string) (inputFile, error) {
+	if isDataURI(name) {
+		return openDataURI(name)
+	}
+	s, ok := strings.CutPrefix(name, "fd:")
+	if !ok {
+		return os.Open(name)
+	}
+	fd, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: bad file descriptor number", name)
+	}
+	f := os.NewFile(uintptr(fd), name)
+	if f == nil {
+		return nil, fmt.Errorf("%s: bad file descriptor number", name)
+	}
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("%s: file descriptor is not open: %w", name, err)
+	}
+	return f, nil
+}
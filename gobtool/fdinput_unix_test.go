@@ -0,0 +1,53 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package gobtool
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestOpenFileDescriptor(t *testing.T) {
+	f, err := os.Open(writeRoot(t, map[string]interface{}{"a": "from fd"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// openFile takes ownership of the descriptor it is given.
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "fd:" + strconv.Itoa(fd)
+	in, err := openFile(name)
+	if err != nil {
+		syscall.Close(fd)
+		t.Fatal(err)
+	}
+	defer in.Close()
+	if in.Name() != name {
+		t.Errorf("Name() = %q, want %q", in.Name(), name)
+	}
+	got, err := decodeAny(in, in.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := got.(map[interface{}]interface{}); m["a"] != "from fd" {
+		t.Errorf("decoded %#v", got)
+	}
+}
+
+func TestOpenFileDescriptorErrors(t *testing.T) {
+	for name, want := range map[string]string{
+		"fd:x":      "bad file descriptor number",
+		"fd:-1":     "bad file descriptor number",
+		"fd:100000": "file descriptor is not open",
+	} {
+		if _, err := openFile(name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: got %v, want %q", name, err, want)
+		}
+	}
+}
@@ -0,0 +1,603 @@
+package gobtool
+
+import (
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// filter copies the records of a stream that satisfy a -where expression
+// into a new stream. The expression language is small:
+//
+//	type == "LoginEvent" && Values.user_id == "42"
+//	!(count < 10) || name != nil
+//
+// Operands are string, number, true, false and nil literals, the record's
+// type, now, flat paths into the record as walkPaths names them, and the
+// few function calls in expr.go. Paths that do not exist are nil. Comparisons are ==, !=, <, <=, > and >=, and
+// an operand on its own is true unless it is nil, false, 0 or "". Values
+// of different kinds are never equal, and cannot be ordered, except that
+// nil is less, greater and equal to nothing but itself, so a record
+// without the path simply does not match.
+//
+// type is the name the record's concrete type was registered under, or
+// for a record not sent as an interface the wire type name. It also
+// equals the name without its package and pointer star, so "LoginEvent"
+// matches "*events.LoginEvent". When the expression is a conjunction that
+// includes type == "...", records of any other type are skipped on the
+// wire without being decoded.
+//
+// Matching records are re-encoded from their decoded form as the types
+// they were sent as, rebuilt from their wire types as typed.go describes,
+// so a reader of the original types can decode the output.
+
+// filterRecord is what an expression is evaluated against.
+type filterRecord struct {
+	typeName string
+	value    interface{}
+}
+
+type filterExpr interface {
+	eval(r filterRecord) (bool, error)
+}
+
+type andExpr struct{ l, r filterExpr }
+type orExpr struct{ l, r filterExpr }
+type notExpr struct{ x filterExpr }
+
+// cmpExpr compares two operands, or with op "" tests one for truth.
+type cmpExpr struct {
+	l, r filterOperand
+	op   string
+}
+
+func (e andExpr) eval(r filterRecord) (bool, error) {
+	ok, err := e.l.eval(r)
+	if err != nil || !ok {
+		return false, err
+	}
+	return e.r.eval(r)
+}
+
+func (e orExpr) eval(r filterRecord) (bool, error) {
+	ok, err := e.l.eval(r)
+	if err != nil || ok {
+		return ok, err
+	}
+	return e.r.eval(r)
+}
+
+func (e notExpr) eval(r filterRecord) (bool, error) {
+	ok, err := e.x.eval(r)
+	return !ok, err
+}
+
+func (e cmpExpr) eval(r filterRecord) (bool, error) {
+	l, err := e.l.value(r)
+	if err != nil {
+		return false, err
+	}
+	if e.op == "" {
+		return truthy(l), nil
+	}
+	rv, err := e.r.value(r)
+	if err != nil {
+		return false, err
+	}
+	if t, ok := e.typeTest(); ok && (e.op == "==" || e.op == "!=") {
+		return typeMatches(r.typeName, t) == (e.op == "=="), nil
+	}
+	switch e.op {
+	case "==":
+		return l == rv, nil
+	case "!=":
+		return l != rv, nil
+	}
+	if l == nil || rv == nil {
+		return false, nil
+	}
+	var c int
+	switch l := l.(type) {
+	case float64:
+		x, ok := rv.(float64)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %v %s %v", l, e.op, rv)
+		}
+		c = compareFloat(l, x)
+	case string:
+		x, ok := rv.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %q %s %v", l, e.op, rv)
+		}
+		c = strings.Compare(l, x)
+	case time.Time:
+		x, ok := rv.(time.Time)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %s %s %v", l.Format(time.RFC3339), e.op, rv)
+		}
+		c = l.Compare(x)
+	default:
+		return false, fmt.Errorf("cannot order %v", l)
+	}
+	switch e.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+// typeTest returns the type name compared with type, if e is such a
+// comparison.
+func (e cmpExpr) typeTest() (string, bool) {
+	l, r := e.l, e.r
+	if _, ok := r.(typeOperand); ok {
+		l, r = r, l
+	}
+	if _, ok := l.(typeOperand); !ok {
+		return "", false
+	}
+	lit, ok := r.(literal)
+	if !ok {
+		return "", false
+	}
+	s, ok := lit.v.(string)
+	return s, ok
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
+
+// typeMatches reports whether the registered name matches want, either
+// exactly or without its package and pointer star.
+func typeMatches(name, want string) bool {
+	if name == want {
+		return true
+	}
+	short := strings.TrimPrefix(name, "*")
+	if i := strings.LastIndex(short, "."); i >= 0 {
+		short = short[i+1:]
+	}
+	return short == want
+}
+
+// requiredTypes returns the type names a record must match for e to hold,
+// from the type == "..." terms of its top-level conjunction.
+func requiredTypes(e filterExpr) []string {
+	switch e := e.(type) {
+	case andExpr:
+		return append(requiredTypes(e.l), requiredTypes(e.r)...)
+	case cmpExpr:
+		if t, ok := e.typeTest(); ok && e.op == "==" {
+			return []string{t}
+		}
+	}
+	return nil
+}
+
+type filterOperand interface {
+	value(r filterRecord) (interface{}, error)
+}
+
+type literal struct{ v interface{} }
+type typeOperand struct{}
+type pathOperand struct{ path string }
+
+func (l literal) value(filterRecord) (interface{}, error)       { return l.v, nil }
+func (typeOperand) value(r filterRecord) (interface{}, error)   { return r.typeName, nil }
+func (p pathOperand) value(r filterRecord) (interface{}, error) { return pathValue(r.value, p.path) }
+
+// pathValue is the value at path in data as a string, float64, bool or
+// nil, the kinds expressions work with.
+func pathValue(data interface{}, path string) (interface{}, error) {
+	v, ok := lookupPath(data, path)
+	if !ok || !v.IsValid() {
+		return nil, nil
+	}
+	return scalarValue(v, path)
+}
+
+// scalarValue is v, the value at path, as a string, float64, bool or
+// time.Time.
+func scalarValue(v reflect.Value, path string) (interface{}, error) {
+	if t, ok := asTimeValue(v); ok {
+		return t, nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+	return nil, fmt.Errorf("%s is %s, not a string, number, bool or time", path, v.Type())
+}
+
+// filterParser parses -where expressions by recursive descent.
+type filterParser struct {
+	toks []string
+	pos  int
+}
+
+func parseFilter(src string) (filterExpr, error) {
+	toks, err := tokenizeFilter(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %s", p.toks[p.pos])
+	}
+	return e, nil
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) or() (filterExpr, error) {
+	l, err := p.and()
+	for err == nil && p.peek() == "||" {
+		p.pos++
+		var r filterExpr
+		if r, err = p.and(); err == nil {
+			l = orExpr{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *filterParser) and() (filterExpr, error) {
+	l, err := p.unary()
+	for err == nil && p.peek() == "&&" {
+		p.pos++
+		var r filterExpr
+		if r, err = p.unary(); err == nil {
+			l = andExpr{l, r}
+		}
+	}
+	return l, err
+}
+
+func (p *filterParser) unary() (filterExpr, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		x, err := p.unary()
+		return notExpr{x}, err
+	case "(":
+		p.pos++
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return x, nil
+	}
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch op := p.peek(); op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.pos++
+		r, err := p.operand()
+		return cmpExpr{l, r, op}, err
+	}
+	return cmpExpr{l: l}, nil
+}
+
+func (p *filterParser) operand() (filterOperand, error) {
+	tok := p.peek()
+	if tok == "" {
+		return nil, errors.New("unexpected end of expression")
+	}
+	p.pos++
+	switch tok {
+	case "true", "false":
+		return literal{tok == "true"}, nil
+	case "nil":
+		return literal{nil}, nil
+	case "type":
+		return typeOperand{}, nil
+	case "now":
+		// read once here, not per record, so every record of a run
+		// sees the same time
+		return literal{time.Now()}, nil
+	}
+	switch c := tok[0]; {
+	case c == '"':
+		s, err := strconv.Unquote(tok)
+		return literal{s}, err
+	case c == '-' || c == '.' || c >= '0' && c <= '9':
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %s", tok)
+		}
+		return literal{f}, nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		if p.peek() == "(" {
+			return p.call(tok)
+		}
+		return pathOperand{tok}, nil
+	}
+	return nil, fmt.Errorf("unexpected %s", tok)
+}
+
+// tokenizeFilter splits src into operators, quoted strings, numbers and
+// paths. A path runs until a space or an operator, so it may hold dots,
+// indexes and quoted keys: Values.user_id, items[0], m."a b".
+func tokenizeFilter(src string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case strings.HasPrefix(src[i:], "&&"), strings.HasPrefix(src[i:], "||"),
+			strings.HasPrefix(src[i:], "=="), strings.HasPrefix(src[i:], "!="),
+			strings.HasPrefix(src[i:], "<="), strings.HasPrefix(src[i:], ">="):
+			toks = append(toks, src[i:i+2])
+			i += 2
+			continue
+		case strings.ContainsRune("()!<>,", rune(c)):
+			toks = append(toks, src[i:i+1])
+			i++
+			continue
+		}
+		j := i
+		inQuote := false
+		for j < len(src) {
+			d := src[j]
+			if inQuote {
+				if d == '\\' {
+					j++
+				} else if d == '"' {
+					inQuote = false
+				}
+				j++
+				continue
+			}
+			if d == '"' {
+				inQuote = true
+				j++
+				continue
+			}
+			if strings.ContainsRune(" \t\n()!<>=&|,", rune(d)) {
+				break
+			}
+			j++
+		}
+		if inQuote {
+			return nil, errors.New("unterminated string")
+		}
+		if j == i {
+			return nil, fmt.Errorf("unexpected %q", c)
+		}
+		toks = append(toks, src[i:j])
+		i = j
+	}
+	return toks, nil
+}
+
+// nextTyped reads the next record like next, but first learns its type
+// name, and when accept rejects it skips the value on the wire instead.
+// skipped reports that it did. With typed set, a record that is kept
+// comes with the Go type it goes back out as, as nextWithType gives it.
+func (rr *recordReader) nextTyped(accept func(name string) bool, typed bool) (rec interface{}, t reflect.Type, name string, skipped bool, err error) {
+	w := rr.w
+	id, err := rr.nextID()
+	if err != nil {
+		return nil, nil, "", false, err
+	}
+	name = typeString(w.types, id)
+	var v interface{}
+	if id == tInterface {
+		if delta, err := w.readUint(); err != nil {
+			return nil, nil, name, false, err
+		} else if delta != 0 {
+			return nil, nil, name, false, formatErrorf("gob: corrupted data: non-zero delta for singleton at offset %d", w.off)
+		}
+		if name, err = w.readString(); err != nil {
+			return nil, nil, name, false, err
+		}
+		isNil := name == ""
+		if isNil {
+			name = "nil"
+		}
+		skipped = !accept(name)
+		if !isNil {
+			if v, err = w.ifaceValue(name, !skipped); err != nil {
+				return nil, nil, name, false, err
+			}
+		}
+		if w.remain != 0 {
+			return nil, nil, name, false, formatErrorf("gob: %d bytes left over at offset %d", w.remain, w.off)
+		}
+	} else {
+		skipped = !accept(name)
+		if v, err = w.topValue(id, !skipped); err != nil {
+			return nil, nil, name, false, err
+		}
+	}
+	rr.n++
+	if skipped {
+		return nil, nil, name, true, nil
+	}
+	if typed {
+		if rr.g == nil {
+			rr.g = newGoTypes(w.types)
+		}
+		if t, err = rr.g.goType(id); err == nil {
+			err = rr.g.registerSent(v)
+		}
+	}
+	if err == nil {
+		err = rr.a.assign(reflect.ValueOf(&rec).Elem(), v, "")
+	}
+	if err != nil {
+		return nil, nil, name, false, &badRecordError{rr.n - 1, err}
+	}
+	return rec, t, name, false, nil
+}
+
+// badRecordError is a record that was read off the wire but could not be
+// built as its registered type. The stream itself is still intact.
+type badRecordError struct {
+	index int
+	err   error
+}
+
+func (e *badRecordError) Error() string { return fmt.Sprintf("record %d: %v", e.index, e.err) }
+func (e *badRecordError) Unwrap() error { return e.err }
+
+// filterCounts tallies what filter did with each record.
+type filterCounts struct {
+	matched, skipped, unmatched, errored int
+}
+
+// filterStream copies the records of r for which where holds to w.
+func filterStream(r io.Reader, w io.Writer, where filterExpr) (filterCounts, error) {
+	var n filterCounts
+	types := requiredTypes(where)
+	accept := func(name string) bool {
+		for _, t := range types {
+			if !typeMatches(name, t) {
+				return false
+			}
+		}
+		return true
+	}
+	rr := newRecordReader(r)
+	bw := newEncodeWriter(w)
+	enc := gob.NewEncoder(bw)
+	for {
+		rec, t, name, skipped, err := rr.nextTyped(accept, true)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var bad *badRecordError
+		if errors.As(err, &bad) {
+			fmt.Fprintln(os.Stderr, err)
+			n.errored++
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		if skipped {
+			n.skipped++
+			continue
+		}
+		ok, err := where.eval(filterRecord{name, rec})
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "record %d: %v\n", rr.n-1, err)
+			n.errored++
+		case !ok:
+			n.unmatched++
+		default:
+			if err := encodeTyped(enc, t, rec); err != nil {
+				return n, fmt.Errorf("record %d: %w", rr.n-1, err)
+			}
+			n.matched++
+		}
+	}
+	if rr.counted {
+		// the kept records are counted afresh
+		if err := enc.Encode(recordCountFooter{int64(n.matched)}); err != nil {
+			return n, fmt.Errorf("record-count footer: %w", err)
+		}
+	}
+	return n, bw.Flush()
+}
+
+// filterFlags registers the flags of filter; the command returned
+// implements "filter -where expr -out out.gob in.gob".
+func filterFlags(fs *flag.FlagSet) func(args []string) error {
+	whereSrc := fs.String("where", "", "keep the records for which this expression holds")
+	out := fs.String("out", "", "write the kept records to this file")
+	return func(args []string) error {
+		if len(args) != 1 || *whereSrc == "" || *out == "" {
+			return errors.New("usage: filter -where expr -out out.gob in.gob")
+		}
+		where, err := parseFilter(*whereSrc)
+		if err != nil {
+			return fmt.Errorf("-where: %w", err)
+		}
+
+		registerKnownTypes()
+		f, err := openFile(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r, err := openPayload(f, args[0])
+		if err != nil {
+			return err
+		}
+		var n filterCounts
+		err = writeAtomic(*out, func(file *os.File) error {
+			var err error
+			n, err = filterStream(r, file, where)
+			return payloadError(r, err)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		fmt.Printf("%s -> %s: %d matched, %d skipped by type, %d not matched, %d errors\n",
+			args[0], *out, n.matched, n.skipped, n.unmatched, n.errored)
+		if n.errored > 0 {
+			return exitCode(1)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package gobtool
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type filterEvent struct {
+	User  string
+	Count int
+	Tags  []string
+}
+
+// TestFilterKeepsTypes checks that filter writes the records it keeps as
+// the types they were sent as, so a reader of those types decodes them,
+// whether they were sent bare or as interfaces.
+func TestFilterKeepsTypes(t *testing.T) {
+	if err := safeRegister(filterEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	events := []filterEvent{
+		{User: "a", Count: 1, Tags: []string{"x"}},
+		{User: "b", Count: 2, Tags: []string{"y", "z"}},
+		{User: "c", Count: 3},
+	}
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "in.gob"), filepath.Join(dir, "out.gob")
+	f, err := os.Create(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := gob.NewEncoder(f)
+	for i, e := range events {
+		if i == 1 {
+			var v interface{} = e
+			err = enc.Encode(&v)
+		} else {
+			err = enc.Encode(e)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCaptured(t, "filter", "-where", `Count >= 2`, "-out", out, in); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	dec := gob.NewDecoder(b)
+	var wrapped interface{}
+	if err := dec.Decode(&wrapped); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(wrapped, events[1]) {
+		t.Errorf("record 0 = %#v, want %#v", wrapped, events[1])
+	}
+	var bare filterEvent
+	if err := dec.Decode(&bare); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bare, events[2]) {
+		t.Errorf("record 1 = %#v, want %#v", bare, events[2])
+	}
+	if err := dec.Decode(&wrapped); !errors.Is(err, io.EOF) {
+		t.Errorf("after the kept records: %v, want EOF", err)
+	}
+}
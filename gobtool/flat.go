@@ -0,0 +1,122 @@
+package gobtool
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// writeFlat prints one "path = value (type)" line per leaf, which keeps
+// nested data greppable. With maxDepth > 0 only the first maxDepth levels
+// are flattened; anything deeper is summarized inline on the value side.
+func writeFlat(w io.Writer, data interface{}, maxDepth int) error {
+	return flatWriter{maxDepth: maxDepth}.write(w, data)
+}
+
+type flatWriter struct {
+	maxDepth int
+	// guessJSON prints byte slices holding valid JSON as compact JSON,
+	// as is always done for json.RawMessage.
+	guessJSON bool
+}
+
+func (f flatWriter) write(w io.Writer, data interface{}) error {
+	maxDepth := f.maxDepth
+	var err error
+	walkPaths(reflect.ValueOf(data), func(path string, depth int, v reflect.Value) bool {
+		if err != nil {
+			return false
+		}
+		if path == "" {
+			path = "(root)"
+		}
+		if text, ok := jsonBytes(v, f.guessJSON, ""); ok {
+			_, err = fmt.Fprintf(w, "%s = %s (%s, JSON)\n", path, text, v.Type())
+			return false
+		}
+		switch {
+		case !v.IsValid():
+			_, err = fmt.Fprintf(w, "%s = nil\n", path)
+
+		case !isContainer(v):
+			_, err = fmt.Fprintf(w, "%s = %s (%s)\n", path, cleanString(sprint(v)), v.Type())
+		case depth == maxDepth && depth > 0 || containerLen(v) == 0:
+			_, err = fmt.Fprintf(w, "%s = %s (%s)\n", path, cleanString(inlineValue(v)), v.Type())
+		default:
+			return true
+		}
+		return false
+	})
+	return err
+}
+
+func containerLen(v reflect.Value) int {
+	if v.Kind() == reflect.Struct {
+		return v.NumField()
+	}
+	return v.Len()
+}
+
+// inlineValue renders v compactly on one line: {k: v, ...} for maps,
+// [a, b] for slices and Type{Field: v} for structs.
+func inlineValue(v reflect.Value) string {
+	var b strings.Builder
+	writeInline(&b, v, cycleGuard{})
+	return b.String()
+}
+
+func writeInline(b *strings.Builder, v reflect.Value, guard cycleGuard) {
+	v, leave, ok := guard.descend(v)
+	if !ok {
+		b.WriteString("<cycle>")
+		return
+	}
+	defer leave()
+	if !v.IsValid() {
+		b.WriteString("nil")
+		return
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		b.WriteString("{")
+		for i, k := range sortedMapKeys(v) {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(sprint(k) + ": ")
+			writeInline(b, v.MapIndex(k), guard)
+		}
+		b.WriteString("}")
+	case reflect.Slice, reflect.Array:
+		if !isContainer(v) {
+			fmt.Fprint(b, v)
+			return
+		}
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeInline(b, v.Index(i), guard)
+		}
+		b.WriteString("]")
+	case reflect.Struct:
+		b.WriteString(v.Type().Name() + "{")
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if !first {
+				b.WriteString(", ")
+			}
+			first = false
+			b.WriteString(v.Type().Field(i).Name + ": ")
+			writeInline(b, v.Field(i), guard)
+		}
+		b.WriteString("}")
+	default:
+		b.WriteString(sprint(v))
+	}
+}
@@ -0,0 +1,59 @@
+package gobtool
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteFlatDepth(t *testing.T) {
+	data := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "Ada",
+			"addr": map[string]interface{}{"city": "Oslo", "zip": 150},
+		},
+		"tags":  []string{"a", "b"},
+		"empty": []int{},
+	}
+	for _, c := range []struct {
+		depth int
+		want  string
+	}{
+		{0, `empty = [] ([]int)
+tags[0] = a (string)
+tags[1] = b (string)
+user.addr.city = Oslo (string)
+user.addr.zip = 150 (int)
+user.name = Ada (string)
+`},
+		{1, `empty = [] ([]int)
+tags = [a, b] ([]string)
+user = {addr: {city: Oslo, zip: 150}, name: Ada} (map[string]interface {})
+`},
+		{2, `empty = [] ([]int)
+tags[0] = a (string)
+tags[1] = b (string)
+user.addr = {city: Oslo, zip: 150} (map[string]interface {})
+user.name = Ada (string)
+`},
+	} {
+		var buf bytes.Buffer
+		if err := writeFlat(&buf, data, c.depth); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("depth %d: got\n%s\nwant\n%s", c.depth, buf.String(), c.want)
+		}
+	}
+}
+
+func TestWriteFlatCycle(t *testing.T) {
+	m := map[string]interface{}{"name": "loop"}
+	m["self"] = m
+	var buf bytes.Buffer
+	if err := writeFlat(&buf, m, 0); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 || buf.Len() > 1000 {
+		t.Errorf("got %d bytes:\n%s", buf.Len(), buf.String())
+	}
+}
@@ -0,0 +1,47 @@
+package gobtool
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// Floats print with Go's shortest formatting by default, which shows
+// 0.1+0.2 as 0.30000000000000004 and large values in exponent form. The
+// text outputs (tree, flat, dot, grep, compare and diff) print them with
+// -float-format instead when it is given, keys included. JSON output is
+// always full precision.
+
+// floatFormat is the fmt verb floats are printed with; "" keeps the
+// default. Set by -float-format.
+var floatFormat string
+
+var floatVerb = regexp.MustCompile(`^%[-+# 0]*[0-9]*(\.[0-9]+)?[eEfFgG]$`)
+
+// setFloatFormat implements -float-format.
+func setFloatFormat(s string) error {
+	if !floatVerb.MatchString(s) {
+		return fmt.Errorf("%q is not a float verb such as %%.6g or %%.2f", s)
+	}
+	floatFormat = s
+	return nil
+}
+
+// sprint is fmt.Sprint for values being printed, with floats, whether
+// given directly or as a reflect.Value, formatted by -float-format, and
+// booleans by -bool-style.
+func sprint(v interface{}) string {
+	if floatFormat != "" || boolWords != nil {
+		rv, ok := v.(reflect.Value)
+		if !ok {
+			rv = reflect.ValueOf(v)
+		}
+		if k := rv.Kind(); floatFormat != "" && (k == reflect.Float32 || k == reflect.Float64) && rv.CanInterface() {
+			return fmt.Sprintf(floatFormat, rv.Interface())
+		}
+		if s, ok := boolString(rv); ok {
+			return s
+		}
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,94 @@
+package gobtool
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The files this tool writes in formats of its own, store references,
+// deltas and sharded files, carry a version number: store references and
+// sharded files in their first line, deltas in their Version field. Gob streams and netstring frames are not
+// ours and have none. A build reads the version it writes and the one
+// before it, so services built at different times can share files across
+// one upgrade; a newer file is refused with an error naming the version
+// it needs, and an older one with a pointer to migrate it first. CanRead
+// lets a service check before it reads, and info prints the version of a
+// file. Fixtures of every version still read are in testdata/formats,
+// and TestFormatFixtures reads them all.
+
+// Format names a versioned file format.
+type Format string
+
+const (
+	FormatStoreRef Format = "store-ref" // reference files left by encode -store
+	FormatDelta    Format = "delta"     // files written by delta
+	FormatShards   Format = "shards"    // files written by encode -shards
+)
+
+// The versions this build writes.
+const (
+	StoreRefVersion = 1
+	DeltaVersion    = 1
+	ShardsVersion   = 1
+)
+
+var formatVersions = map[Format]int{
+	FormatStoreRef: StoreRefVersion,
+	FormatDelta:    DeltaVersion,
+	FormatShards:   ShardsVersion,
+}
+
+// ReadableVersions returns the oldest and newest versions of f this build
+// reads, or 0, 0 for a format it does not know.
+func ReadableVersions(f Format) (oldest, newest int) {
+	newest = formatVersions[f]
+	if newest == 0 {
+		return 0, 0
+	}
+	return max(newest-1, 1), newest
+}
+
+// ErrFormatVersion is matched by every error CanRead returns.
+var ErrFormatVersion = errors.New("unsupported format version")
+
+// FormatVersionError is returned for a file in a version of its format
+// this build does not read.
+type FormatVersionError struct {
+	Format         Format
+	Version        int
+	Oldest, Newest int // the versions this build reads
+}
+
+func (e *FormatVersionError) Error() string {
+	if e.Version > e.Newest {
+		return fmt.Sprintf("%s format v%d is newer than this build reads (v%d to v%d): please upgrade to a release that reads %s v%d",
+			e.Format, e.Version, e.Oldest, e.Newest, e.Format, e.Version)
+	}
+	return fmt.Sprintf("%s format v%d is older than this build reads (v%d to v%d): convert it with a release that reads v%d first",
+		e.Format, e.Version, e.Oldest, e.Newest, e.Version)
+}
+
+func (e *FormatVersionError) Unwrap() error { return ErrFormatVersion }
+
+// CanRead reports whether this build reads version of format f, and if
+// not, why.
+func CanRead(f Format, version int) error {
+	oldest, newest := ReadableVersions(f)
+	if newest == 0 {
+		return fmt.Errorf("%w: unknown format %q", ErrFormatVersion, f)
+	}
+	if version < oldest || version > newest {
+		return &FormatVersionError{f, version, oldest, newest}
+	}
+	return nil
+}
+
+// formatLine describes version of f for info.
+func formatLine(f Format, version int) string {
+	oldest, newest := ReadableVersions(f)
+	status := "readable"
+	if CanRead(f, version) != nil {
+		status = "not readable"
+	}
+	return fmt.Sprintf("%s v%d (%s; this build reads v%d to v%d)", f, version, status, oldest, newest)
+}
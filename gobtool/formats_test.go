@@ -0,0 +1,123 @@
+package gobtool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testdata/formats/v<N> holds files of version N of the store reference,
+// delta and sharded formats, written by the release that introduced it:
+// base.gob and target.gob, target.delta from one to the other, and
+// target as a store reference and as a sharded file.
+
+func TestFormatFixturesCoverReadableVersions(t *testing.T) {
+	for _, f := range []Format{FormatStoreRef, FormatDelta, FormatShards} {
+		oldest, newest := ReadableVersions(f)
+		for v := oldest; v <= newest; v++ {
+			if _, err := os.Stat(filepath.Join("testdata", "formats", fmt.Sprintf("v%d", v))); err != nil {
+				t.Errorf("%s v%d is readable but has no fixtures", f, v)
+			}
+		}
+	}
+}
+
+func TestFormatFixtures(t *testing.T) {
+	versions, err := filepath.Glob(filepath.Join("testdata", "formats", "v*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, vdir := range versions {
+		t.Run(filepath.Base(vdir), func(t *testing.T) {
+			version, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(vdir), "v"))
+			if err != nil {
+				t.Fatalf("fixture directory %s: want v<N>", filepath.Base(vdir))
+			}
+			target, err := decodeAnyFile(filepath.Join(vdir, "target.gob"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := CanonicalHash(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sameAsTarget := func(what string, v interface{}) {
+				t.Helper()
+				if h, err := CanonicalHash(v); err != nil || h != want {
+					t.Errorf("%s: different data", what)
+				}
+			}
+
+			ref := filepath.Join(vdir, "target.ref")
+			rf, err := os.Open(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sr, err := readStoreRef(rf)
+			rf.Close()
+			if err != nil {
+				t.Errorf("store reference: %v", err)
+			} else if sr.Version != version {
+				t.Errorf("store reference: read as version %d", sr.Version)
+			}
+			if viaRef, err := decodeAnyFile(ref); err != nil {
+				t.Errorf("store reference: %v", err)
+			} else {
+				sameAsTarget("store reference", viaRef)
+			}
+
+			d, err := readDelta(filepath.Join(vdir, "target.delta"))
+			if err != nil {
+				t.Fatalf("delta: %v", err)
+			}
+			if d.Version != version {
+				t.Errorf("delta: read as version %d", d.Version)
+			}
+			base, err := decodeAnyFile(filepath.Join(vdir, "base.gob"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result, err := applyDelta(base, d); err != nil {
+				t.Errorf("delta: %v", err)
+			} else {
+				sameAsTarget("delta applied", result)
+			}
+
+			if sharded, err := decodeAnyFile(filepath.Join(vdir, "target.shards")); err != nil {
+				t.Errorf("sharded file: %v", err)
+			} else {
+				sameAsTarget("sharded file", sharded)
+			}
+		})
+	}
+}
+
+func TestNewerFormatVersions(t *testing.T) {
+	future := fmt.Sprintf("%s%d\nhash %s\n", storeRefPrefix, StoreRefVersion+1, strings.Repeat("0", 64))
+	_, err := readStoreRef(strings.NewReader(future))
+	var ve *FormatVersionError
+	if !errors.As(err, &ve) || ve.Version != StoreRefVersion+1 ||
+		!strings.Contains(err.Error(), fmt.Sprintf("please upgrade to a release that reads store-ref v%d", StoreRefVersion+1)) {
+		t.Errorf("store reference: got %v, want a please-upgrade error naming v%d", err, StoreRefVersion+1)
+	}
+	if !isStoreRef(bufio.NewReader(strings.NewReader(future))) {
+		t.Error("store reference: not recognised as one")
+	}
+	if _, err := applyDelta(createSampleData(), &Delta{Version: DeltaVersion + 1}); !errors.Is(err, ErrFormatVersion) {
+		t.Errorf("delta: got %v, want ErrFormatVersion", err)
+	}
+}
+
+func TestCanRead(t *testing.T) {
+	if oldest, _ := ReadableVersions(FormatDelta); CanRead(FormatDelta, oldest-1) == nil {
+		t.Errorf("delta v%d: older than readable, but CanRead accepts it", oldest-1)
+	}
+	if err := CanRead("archive", 1); !errors.Is(err, ErrFormatVersion) {
+		t.Errorf("unknown format: got %v", err)
+	}
+}
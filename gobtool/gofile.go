@@ -0,0 +1,322 @@
+package gobtool
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// -format gofile writes the decoded value as a complete Go source file
+// declaring it, for use as a test fixture:
+//
+//	package fixtures
+//
+//	var Fixture = map[interface{}]interface{}{...}
+//
+// with imports for every package whose types it names. Values inside
+// interfaces keep their dynamic type, so int64(3) stays int64(3) and
+// not 3. time.Time becomes a time.Date call and a *big.Int that fits an
+// int64 a big.NewInt call. Types without a literal, such as those
+// defined in this tool's own package, channels and functions, and
+// structs with unexported fields set, are errors.
+
+// goFileVar is the name of the declared variable.
+const goFileVar = "Fixture"
+
+// goWriter builds the literal and collects the imports it needs.
+type goWriter struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+// writeGoFile writes data to w as a gofmt-clean Go file in package pkg.
+func writeGoFile(w io.Writer, data interface{}, pkg string) error {
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("-package %q is not a Go identifier", pkg)
+	}
+	g := &goWriter{imports: make(map[string]bool)}
+	v := reflect.ValueOf(data)
+	if !v.IsValid() {
+		g.buf.WriteString("interface{}(nil)")
+	} else if err := g.value(v, nil, ""); err != nil {
+		return err
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by decode -format gofile. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(g.imports) > 0 {
+		paths := make([]string, 0, len(g.imports))
+		for p := range g.imports {
+			paths = append(paths, strconv.Quote(p))
+		}
+		sort.Strings(paths)
+		fmt.Fprintf(&src, "import (\n%s\n)\n\n", strings.Join(paths, "\n"))
+	}
+	fmt.Fprintf(&src, "var %s = %s\n", goFileVar, g.buf.Bytes())
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("generated Go does not parse: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func (g *goWriter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// value writes v as an expression assignable to a slot of type slot, nil
+// at the top level. Where the slot is an interface the expression must
+// carry v's own type.
+func (g *goWriter) value(v reflect.Value, slot reflect.Type, path string) error {
+	typed := slot == nil || slot.Kind() == reflect.Interface
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			g.buf.WriteString("nil")
+			return nil
+		}
+		return g.value(v.Elem(), slot, path)
+	}
+	t := v.Type()
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return g.time(x)
+	case *big.Int:
+		if x == nil {
+			break
+		}
+		if !x.IsInt64() {
+			return fmt.Errorf("%s: %s does not fit big.NewInt", pathOrRoot(path), x)
+		}
+		g.imports["math/big"] = true
+		g.printf("big.NewInt(%d)", x.Int64())
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			if !typed {
+				g.buf.WriteString("nil")
+				return nil
+			}
+			return g.conversion(t, "nil")
+		}
+		if t.Elem().Kind() == reflect.Struct && t.Elem() != reflect.TypeOf(time.Time{}) {
+			g.buf.WriteByte('&')
+			return g.value(v.Elem(), t.Elem(), path)
+		}
+		// no literal takes the address of a scalar, so use a function
+		name, err := g.typeName(t.Elem())
+		if err != nil {
+			return fmt.Errorf("%s: %w", pathOrRoot(path), err)
+		}
+		g.printf("func() *%s { v := ", name)
+		if err := g.value(v.Elem(), t.Elem(), path); err != nil {
+			return err
+		}
+		g.buf.WriteString("; return &v }()")
+		return nil
+	case reflect.Map:
+		return g.composite(v, typed, path, func() error {
+			keys := v.MapKeys()
+			sort.Slice(keys, func(i, j int) bool { return sprint(keys[i]) < sprint(keys[j]) })
+			for _, k := range keys {
+				if err := g.value(k, t.Key(), path); err != nil {
+					return err
+				}
+				g.buf.WriteString(": ")
+				if err := g.value(v.MapIndex(k), t.Elem(), joinPath(path, pathKey(k))); err != nil {
+					return err
+				}
+				g.buf.WriteString(",\n")
+			}
+			return nil
+		})
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice && !v.IsNil() {
+			if b := v.Bytes(); utf8.Valid(b) {
+				return g.conversion(t, strconv.Quote(string(b)))
+			}
+		}
+		return g.composite(v, typed, path, func() error {
+			for i := 0; i < v.Len(); i++ {
+				if err := g.value(v.Index(i), t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+				g.buf.WriteString(",\n")
+			}
+			return nil
+		})
+	case reflect.Struct:
+		return g.composite(v, true, path, func() error {
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				if v.Field(i).IsZero() {
+					continue
+				}
+				if !f.IsExported() {
+					return fmt.Errorf("%s: unexported field %s of %s is set", pathOrRoot(path), f.Name, t)
+				}
+				g.printf("%s: ", f.Name)
+				if err := g.value(v.Field(i), f.Type, joinPath(path, f.Name)); err != nil {
+					return err
+				}
+				g.buf.WriteString(",\n")
+			}
+			return nil
+		})
+	case reflect.Bool:
+		return g.scalar(t, typed, strconv.FormatBool(v.Bool()), reflect.Bool)
+	case reflect.String:
+		return g.scalar(t, typed, strconv.Quote(v.String()), reflect.String)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return g.scalar(t, typed, strconv.FormatInt(v.Int(), 10), reflect.Int)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return g.scalar(t, typed, strconv.FormatUint(v.Uint(), 10), reflect.Invalid)
+	case reflect.Float32, reflect.Float64:
+		return g.scalar(t, typed, g.float(v.Float(), t.Bits()), reflect.Float64)
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		lit := fmt.Sprintf("complex(%s, %s)", g.float(real(c), 64), g.float(imag(c), 64))
+		return g.scalar(t, typed, lit, reflect.Complex128)
+	}
+	return fmt.Errorf("%s: no Go literal for %s", pathOrRoot(path), t)
+}
+
+// composite writes T{...}, or nil for a nil map or slice in a typed slot.
+func (g *goWriter) composite(v reflect.Value, typed bool, path string, elems func() error) error {
+	if (v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+		if !typed {
+			g.buf.WriteString("nil")
+			return nil
+		}
+		return g.conversion(v.Type(), "nil")
+	}
+	name, err := g.typeName(v.Type())
+	if err != nil {
+		return fmt.Errorf("%s: %w", pathOrRoot(path), err)
+	}
+	g.printf("%s{\n", name)
+	if err := elems(); err != nil {
+		return err
+	}
+	g.buf.WriteByte('}')
+	return nil
+}
+
+// scalar writes lit, converted to t unless the slot already has that
+// type or lit's default type, def, is t.
+func (g *goWriter) scalar(t reflect.Type, typed bool, lit string, def reflect.Kind) error {
+	if !typed || t.Kind() == def && t.PkgPath() == "" && t.Name() == def.String() {
+		g.buf.WriteString(lit)
+		return nil
+	}
+	return g.conversion(t, lit)
+}
+
+// conversion writes T(expr), parenthesizing types such as *T that need it.
+func (g *goWriter) conversion(t reflect.Type, expr string) error {
+	name, err := g.typeName(t)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(name, "*") || strings.HasPrefix(name, "func") {
+		name = "(" + name + ")"
+	}
+	g.printf("%s(%s)", name, expr)
+	return nil
+}
+
+// float writes f so it reads back as the same float of the given bits.
+func (g *goWriter) float(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		g.imports["math"] = true
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		g.imports["math"] = true
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		g.imports["math"] = true
+		return "math.Inf(-1)"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+// time writes t as a time.Date call in its own zone.
+func (g *goWriter) time(t time.Time) error {
+	g.imports["time"] = true
+	loc := "time.UTC"
+	if name, off := t.Zone(); t.Location() != time.UTC {
+		loc = fmt.Sprintf("time.FixedZone(%q, %d)", name, off)
+	}
+	g.printf("time.Date(%d, time.%s, %d, %d, %d, %d, %d, %s)",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+	return nil
+}
+
+// typeName spells t as Go source, noting the imports it needs.
+func (g *goWriter) typeName(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		switch t.PkgPath() {
+		case "":
+			return t.Name(), nil
+		case "main":
+			return "", fmt.Errorf("type %s is defined in this tool and cannot be named from another package", t)
+		}
+		g.imports[t.PkgPath()] = true
+		return t.String(), nil
+	}
+	elem := func(prefix string) (string, error) {
+		name, err := g.typeName(t.Elem())
+		return prefix + name, err
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return elem("*")
+	case reflect.Slice:
+		return elem("[]")
+	case reflect.Array:
+		return elem(fmt.Sprintf("[%d]", t.Len()))
+	case reflect.Map:
+		key, err := g.typeName(t.Key())
+		if err != nil {
+			return "", err
+		}
+		return elem("map[" + key + "]")
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", nil
+		}
+	case reflect.Struct:
+		fields := make([]string, t.NumField())
+		for i := range fields {
+			f := t.Field(i)
+			name, err := g.typeName(f.Type)
+			if err != nil {
+				return "", err
+			}
+			fields[i] = f.Name + " " + name
+			if f.Tag != "" {
+				fields[i] += " " + strconv.Quote(string(f.Tag))
+			}
+		}
+		return "struct{" + strings.Join(fields, "; ") + "}", nil
+	}
+	return "", fmt.Errorf("no Go name for type %s", t)
+}
@@ -0,0 +1,69 @@
+package gobtool
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteGoFile(t *testing.T) {
+	data := map[interface{}]interface{}{
+		"n":    int64(3),
+		"i":    7,
+		"f":    1.5,
+		"s":    []string{"a"},
+		"b":    []byte("hi"),
+		"p":    func() *int { v := 4; return &v }(),
+		"big":  big.NewInt(12),
+		"when": time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		"nil":  nil,
+	}
+	var b strings.Builder
+	if err := writeGoFile(&b, data, "fixtures"); err != nil {
+		t.Fatal(err)
+	}
+	want := `// Code generated by decode -format gofile. DO NOT EDIT.
+
+package fixtures
+
+import (
+	"math/big"
+	"time"
+)
+
+var Fixture = map[interface{}]interface{}{
+	"b":   []uint8("hi"),
+	"big": big.NewInt(12),
+	"f":   1.5,
+	"i":   7,
+	"n":   int64(3),
+	"nil": nil,
+	"p":   func() *int { v := 4; return &v }(),
+	"s": []string{
+		"a",
+	},
+	"when": time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC),
+}
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteGoFileErrors(t *testing.T) {
+	for _, c := range []struct {
+		data interface{}
+		pkg  string
+		want string
+	}{
+		{1, "not a name", `-package "not a name" is not a Go identifier`},
+		{map[string]interface{}{"c": make(chan int)}, "fixtures", "c: no Go literal for chan int"},
+		{map[string]interface{}{"big": new(big.Int).Lsh(big.NewInt(1), 64)}, "fixtures", "big: 18446744073709551616 does not fit big.NewInt"},
+	} {
+		err := writeGoFile(&strings.Builder{}, c.data, c.pkg)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%v: got %v, want %q", c.data, err, c.want)
+		}
+	}
+}
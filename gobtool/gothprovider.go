@@ -0,0 +1,174 @@
+package gobtool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// gothic keeps each provider's session in the session Values under the
+// provider's name, as the JSON its Session.Marshal produces. Providers
+// name and fill the fields differently, so each known provider has an
+// entry in gothProviders listing where to look for the common ones. A
+// missing field is reported as missing, never an error. Supporting
+// another provider is one more entry.
+
+// gothProviderFields lists, for each normalized field, the JSON fields
+// it is read from, in the order tried.
+type gothProviderFields struct {
+	AccessToken  []string
+	RefreshToken []string
+	Expiry       []string
+	IDToken      []string
+	// ExpiryNote explains the provider's expiry, shown when it is missing.
+	ExpiryNote string
+}
+
+var gothProviders = map[string]gothProviderFields{
+	"google": {
+		AccessToken:  []string{"AccessToken"},
+		RefreshToken: []string{"RefreshToken"},
+		Expiry:       []string{"ExpiresAt"},
+		IDToken:      []string{"IDToken"},
+	},
+	"github": {
+		AccessToken: []string{"AccessToken"},
+		ExpiryNote:  "GitHub OAuth app tokens do not expire",
+	},
+	"gitlab": {
+		AccessToken:  []string{"AccessToken"},
+		RefreshToken: []string{"RefreshToken"},
+		Expiry:       []string{"ExpiresAt"},
+	},
+	"azuread": {
+		AccessToken:  []string{"AccessToken"},
+		RefreshToken: []string{"RefreshToken"},
+		Expiry:       []string{"ExpiresAt", "ExpiresOn"},
+		IDToken:      []string{"IDToken", "id_token"},
+	},
+}
+
+// gothProviderSession is a provider session found in the data.
+type gothProviderSession struct {
+	Path     string
+	Provider string
+	Known    bool // the provider has an entry in gothProviders
+	Fields   map[string]interface{}
+}
+
+// findGothProviderSessions returns the provider sessions in data: JSON
+// object strings stored under a known provider's name, or under any key
+// when the object has goth's AuthURL field.
+func findGothProviderSessions(data interface{}) []gothProviderSession {
+	var found []gothProviderSession
+	walkPaths(reflect.ValueOf(data), func(path string, _ int, v reflect.Value) bool {
+		if !v.IsValid() || v.Kind() != reflect.Map {
+			return true
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			name, ok := indirect(iter.Key()).Interface().(string)
+			if !ok {
+				continue
+			}
+			val := indirect(iter.Value())
+			if !val.IsValid() || val.Kind() != reflect.String {
+				continue
+			}
+			s := strings.TrimSpace(val.String())
+			var fields map[string]interface{}
+			if !strings.HasPrefix(s, "{") || json.Unmarshal([]byte(s), &fields) != nil {
+				continue
+			}
+			_, known := gothProviders[name]
+			if _, authURL := fields["AuthURL"]; !known && !authURL {
+				continue
+			}
+			found = append(found, gothProviderSession{joinPath(path, pathKey(name)), name, known, fields})
+		}
+		return true
+	})
+	sort.Slice(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+	return found
+}
+
+// lookup returns the first of names present in the session's fields.
+func (s gothProviderSession) lookup(names []string) (string, interface{}, bool) {
+	for _, name := range names {
+		if v, ok := s.Fields[name]; ok && v != nil && v != "" {
+			return name, v, true
+		}
+	}
+	return "", nil, false
+}
+
+// print writes the normalized report of a known provider's session, or
+// the session's JSON for an unknown one.
+func (s gothProviderSession) print(w io.Writer, showTokens bool) {
+	fmt.Fprintf(w, "%s session at %s:\n", s.Provider, s.Path)
+	if !s.Known {
+		b, _ := json.MarshalIndent(s.redacted(showTokens), "  ", "  ")
+		fmt.Fprintf(w, "  (unknown provider, raw fields)\n  %s\n", b)
+		return
+	}
+	p := gothProviders[s.Provider]
+	row := func(label string, names []string, show func(interface{}) string, absent string) {
+		if len(names) == 0 {
+			if absent != "" {
+				fmt.Fprintf(w, "  %-15s %s\n", label+":", absent)
+			}
+			return
+		}
+		name, v, ok := s.lookup(names)
+		if !ok {
+			fmt.Fprintf(w, "  %-15s missing\n", label+":")
+			return
+		}
+		fmt.Fprintf(w, "  %-15s %s (%s)\n", label+":", show(v), name)
+		printJWTClaims(w, v, "    ")
+	}
+	token := func(v interface{}) string {
+		if showTokens {
+			return fmt.Sprint(v)
+		}
+		return redact(fmt.Sprint(v))
+	}
+	present := func(interface{}) string { return "present" }
+	row("access token", p.AccessToken, token, "")
+	row("refresh token", p.RefreshToken, token, "not used")
+	row("expiry", p.Expiry, formatExpiry, p.ExpiryNote)
+	row("id token", p.IDToken, present, "not used")
+
+	var found []string
+	for name := range s.Fields {
+		found = append(found, name)
+	}
+	sort.Strings(found)
+	fmt.Fprintf(w, "  %-15s %s\n", "fields found:", strings.Join(found, ", "))
+}
+
+// redacted returns the fields with secret-looking values redacted.
+func (s gothProviderSession) redacted(showTokens bool) map[string]interface{} {
+	out := make(map[string]interface{}, len(s.Fields))
+	for k, v := range s.Fields {
+		lk := strings.ToLower(k)
+		if !showTokens && (strings.Contains(lk, "token") || strings.Contains(lk, "secret")) {
+			v = redact(fmt.Sprint(v))
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// printGothProviderSessions prints every provider session in data and
+// returns how many there were.
+func printGothProviderSessions(w io.Writer, data interface{}, showTokens bool) int {
+	sessions := findGothProviderSessions(data)
+	for _, s := range sessions {
+		s.print(w, showTokens)
+	}
+	return len(sessions)
+}
@@ -0,0 +1,160 @@
+package gobtool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// goth keeps the signed-in user, or the provider session it is built
+// from, in the session as a map of goth.User's fields, either directly or
+// as a JSON string. These helpers find such maps and print them as a
+// readable block instead of a generic dump.
+
+// gothUserFields are printed in this order when present.
+var gothUserFields = []string{
+	"Provider", "Email", "Name", "NickName", "UserID",
+	"AccessToken", "AccessTokenSecret", "RefreshToken", "IDToken", "ExpiresAt",
+}
+
+// gothSecretFields are redacted unless tokens are shown.
+var gothSecretFields = map[string]bool{
+	"AccessToken":       true,
+	"AccessTokenSecret": true,
+	"RefreshToken":      true,
+	"IDToken":           true,
+}
+
+// gothUser returns v's fields by name if v looks like a goth.User: a map
+// or struct with an AccessToken and a Provider or ExpiresAt, or a JSON
+// object string of one.
+func gothUser(v reflect.Value) (map[string]interface{}, bool) {
+	fields := make(map[string]interface{})
+	switch v.Kind() {
+	case reflect.String:
+		s := strings.TrimSpace(v.String())
+		if !strings.HasPrefix(s, "{") || json.Unmarshal([]byte(s), &fields) != nil {
+			return nil, false
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if k, ok := iter.Key().Interface().(string); ok {
+				fields[k] = iter.Value().Interface()
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fields[v.Type().Field(i).Name] = v.Field(i).Interface()
+			}
+		}
+	default:
+		return nil, false
+	}
+	_, token := fields["AccessToken"]
+	_, provider := fields["Provider"]
+	_, expires := fields["ExpiresAt"]
+	// a provider session, see gothprovider.go, has an AuthURL instead
+	_, authURL := fields["AuthURL"]
+	return fields, token && (provider || expires) && !authURL
+}
+
+// printGothUsers prints a block for every goth user found in data and
+// returns how many there were.
+func printGothUsers(w io.Writer, data interface{}, showTokens bool) int {
+	n := 0
+	walkPaths(reflect.ValueOf(data), func(path string, _ int, v reflect.Value) bool {
+		if !v.IsValid() {
+			return false
+		}
+		fields, ok := gothUser(v)
+		if !ok {
+			return true
+		}
+		n++
+		fmt.Fprintf(w, "goth user at %s:\n", pathOrRoot(path))
+		for _, name := range gothUserFields {
+			val, ok := fields[name]
+			if !ok {
+				continue
+			}
+			var s string
+			switch {
+			case name == "ExpiresAt":
+				s = formatExpiry(val)
+			case gothSecretFields[name] && !showTokens:
+				s = redact(fmt.Sprint(val))
+			default:
+				s = fmt.Sprint(val)
+			}
+			fmt.Fprintf(w, "  %-18s %s\n", name+":", s)
+			printJWTClaims(w, val, "    ")
+		}
+		return false
+	})
+	return n
+}
+
+// printJWTClaims prints the claims of v under -expand-jwt if it is a
+// JWT, each line after indent.
+func printJWTClaims(w io.Writer, v interface{}, indent string) {
+	s, ok := v.(string)
+	if !ok || jwtExpand == nil {
+		return
+	}
+	lines, _ := jwtExpand.describe(s)
+	for _, l := range lines {
+		fmt.Fprintf(w, "%s%s\n", indent, l)
+	}
+}
+
+// redact keeps just enough of a token to tell tokens apart.
+func redact(s string) string {
+	if s == "" {
+		return "(empty)"
+	}
+	if len(s) <= 8 {
+		return fmt.Sprintf("(redacted, %d chars)", len(s))
+	}
+	return fmt.Sprintf("%s… (redacted, %d chars)", s[:4], len(s))
+}
+
+// formatExpiry renders an expiry held as a time.Time, an RFC 3339
+// string or Unix seconds, noting whether it has passed.
+func formatExpiry(v interface{}) string {
+	var t time.Time
+	switch x := v.(type) {
+	case time.Time:
+		t = x
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, x); err != nil {
+			if sec, err := strconv.ParseInt(x, 10, 64); err == nil {
+				t = time.Unix(sec, 0)
+			} else {
+				return x
+			}
+		}
+	case int, int64, float64, json.Number:
+		sec, err := strconv.ParseFloat(fmt.Sprint(x), 64)
+		if err != nil {
+			return fmt.Sprint(x)
+		}
+		t = time.Unix(int64(sec), 0)
+	default:
+		return fmt.Sprint(v)
+	}
+	if t.IsZero() {
+		return "(never)"
+	}
+	state := "valid"
+	if time.Now().After(t) {
+		state = "expired"
+	}
+	return fmt.Sprintf("%s (%s)", t.UTC().Format(time.RFC3339), state)
+}
@@ -0,0 +1,89 @@
+package gobtool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGothUser(t *testing.T) {
+	for _, c := range []struct {
+		what string
+		v    interface{}
+		ok   bool
+	}{
+		{"map", map[string]interface{}{"Provider": "google", "AccessToken": "t"}, true},
+		{"struct", struct{ AccessToken, Provider string }{"t", "github"}, true},
+		{"JSON string", `{"AccessToken": "t", "ExpiresAt": "2030-01-01T00:00:00Z"}`, true},
+		{"no token", map[string]interface{}{"Provider": "google"}, false},
+		{"token only", map[string]interface{}{"AccessToken": "t"}, false},
+		{"provider session", map[string]interface{}{"AccessToken": "t", "Provider": "x", "AuthURL": "https://x"}, false},
+		{"other string", "{not json", false},
+	} {
+		if _, ok := gothUser(reflect.ValueOf(c.v)); ok != c.ok {
+			t.Errorf("%s: got %v, want %v", c.what, ok, c.ok)
+		}
+	}
+}
+
+func TestPrintGothUsers(t *testing.T) {
+	data := map[interface{}]interface{}{
+		"user": map[string]interface{}{
+			"Provider":     "google",
+			"Email":        "ada@example.com",
+			"AccessToken":  "ya29.a0AfH6SMBx",
+			"RefreshToken": "short",
+			"ExpiresAt":    time.Time{},
+		},
+		"other": 1,
+	}
+	for _, c := range []struct {
+		show bool
+		want string
+	}{
+		{false, `goth user at user:
+  Provider:          google
+  Email:             ada@example.com
+  AccessToken:       ya29… (redacted, 15 chars)
+  RefreshToken:      (redacted, 5 chars)
+  ExpiresAt:         (never)
+`},
+		{true, `goth user at user:
+  Provider:          google
+  Email:             ada@example.com
+  AccessToken:       ya29.a0AfH6SMBx
+  RefreshToken:      short
+  ExpiresAt:         (never)
+`},
+	} {
+		var b strings.Builder
+		if n := printGothUsers(&b, data, c.show); n != 1 {
+			t.Errorf("found %d users, want 1", n)
+		}
+		if b.String() != c.want {
+			t.Errorf("show tokens %v: got\n%s\nwant\n%s", c.show, b.String(), c.want)
+		}
+	}
+}
+
+func TestFormatExpiry(t *testing.T) {
+	past := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	future := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	for _, c := range []struct {
+		v    interface{}
+		want string
+	}{
+		{past, "2001-02-03T04:05:06Z (expired)"},
+		{future, future.Format(time.RFC3339) + " (valid)"},
+		{"2001-02-03T04:05:06Z", "2001-02-03T04:05:06Z (expired)"},
+		{"981173106", "2001-02-03T04:05:06Z (expired)"},
+		{int64(981173106), "2001-02-03T04:05:06Z (expired)"},
+		{"soon", "soon"},
+		{time.Time{}, "(never)"},
+	} {
+		if got := formatExpiry(c.v); got != c.want {
+			t.Errorf("formatExpiry(%v) = %q, want %q", c.v, got, c.want)
+		}
+	}
+}
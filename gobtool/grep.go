@@ -0,0 +1,36 @@
+package gobtool
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+)
+
+// writeGrep prints, in the flat format, the string leaves of data that
+// re matches. With all set, every other scalar leaf is matched against
+// its printed form as well. It returns the number of matches.
+func writeGrep(w io.Writer, data interface{}, re *regexp.Regexp, all bool) (int, error) {
+	n := 0
+	var err error
+	walkPaths(reflect.ValueOf(data), func(path string, _ int, v reflect.Value) bool {
+		if err != nil {
+			return false
+		}
+		if !v.IsValid() || isContainer(v) {
+			return true
+		}
+		if v.Kind() != reflect.String && !all {
+			return false
+		}
+		if s := sprint(v); re.MatchString(s) {
+			if path == "" {
+				path = "(root)"
+			}
+			_, err = fmt.Fprintf(w, "%s = %s (%s)\n", path, cleanString(s), v.Type())
+			n++
+		}
+		return false
+	})
+	return n, err
+}
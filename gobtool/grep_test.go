@@ -0,0 +1,46 @@
+package gobtool
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteGrep(t *testing.T) {
+	data := map[string]interface{}{
+		"user":  map[string]interface{}{"email": "ada@example.com", "name": "Ada"},
+		"admin": "root@example.com",
+		"port":  8080,
+		"ports": []int{80, 8080},
+	}
+	for _, c := range []struct {
+		re   string
+		all  bool
+		want string
+	}{
+		{`@example\.com$`, false, "admin = root@example.com (string)\nuser.email = ada@example.com (string)\n"},
+		{`8080`, false, ""},
+		{`8080`, true, "port = 8080 (int)\nports[1] = 8080 (int)\n"},
+		{`^Ada$`, true, "user.name = Ada (string)\n"},
+	} {
+		var b strings.Builder
+		n, err := writeGrep(&b, data, regexp.MustCompile(c.re), c.all)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != c.want || n != strings.Count(c.want, "\n") {
+			t.Errorf("-grep %s (all %v): got %d matches\n%s\nwant\n%s", c.re, c.all, n, b.String(), c.want)
+		}
+	}
+}
+
+func TestDecodeGrepNoMatch(t *testing.T) {
+	name := writeRoot(t, map[string]interface{}{"a": "x"})
+	out, err := runCaptured(t, "decode", "-grep", "nothing", name)
+	if code, ok := err.(exitCode); !ok || code != 1 || out != "" {
+		t.Errorf("got %q, %v; want no output and exit status 1", out, err)
+	}
+	if _, err := runCaptured(t, "decode", "-grep", "(", name); err == nil || !strings.Contains(err.Error(), "-grep") {
+		t.Errorf("bad regexp: got %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package gobtool
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"test-gob/errclass"
+	"test-gob/gobrpc"
+)
+
+// grpc-serve offers decode, encode and inspect over gRPC, to services
+// that would otherwise shell out to the tool; the protocol is in
+// gobrpc. Every request is bounded the same way. -max-bytes caps the
+// request and response messages, and the gob bytes a payload holds once
+// decompressed. Reading a payload stops at -value-timeout or the
+// client's deadline, whichever comes first. With -allow-type, only
+// streams whose top-level type is on the list are decoded, inspected or
+// written. The payloads may be sensitive, so -tls-cert and -tls-key
+// should be given whenever the listen address is not loopback.
+
+// grpcConfig is what grpc-serve is started with.
+type grpcConfig struct {
+	certFile, keyFile string
+	maxBytes          int64
+	valueTimeout      time.Duration
+	allowed           []string // top-level types; none means any
+}
+
+// defaultGRPCMaxBytes is the default of -max-bytes.
+const defaultGRPCMaxBytes = 16 << 20
+
+// grpcServer implements gobrpc.GobToolServer.
+type grpcServer struct {
+	gobrpc.UnimplementedGobToolServer
+	cfg grpcConfig
+}
+
+// newGRPCServer returns a server for cfg, not yet serving.
+func newGRPCServer(cfg grpcConfig) (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(int(cfg.maxBytes)),
+		grpc.MaxSendMsgSize(int(cfg.maxBytes)),
+	}
+	if cfg.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.certFile, cfg.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+	}
+	registerKnownOnce.Do(registerKnownTypes)
+	registerInterchangeTypes()
+	s := grpc.NewServer(opts...)
+	gobrpc.RegisterGobToolServer(s, &grpcServer{cfg: cfg})
+	return s, nil
+}
+
+// read runs decode on the gob stream in data, gzip-compressed or not,
+// within the limits of the server and the deadline of ctx, once the
+// stream's top-level type has passed -allow-type.
+func (s *grpcServer) read(ctx context.Context, data []byte, decode func(io.Reader) (interface{}, error)) (interface{}, error) {
+	limits := Limits{MaxBytes: s.cfg.maxBytes, ValueTimeout: s.cfg.valueTimeout}
+	if d, ok := ctx.Deadline(); ok {
+		left := time.Until(d)
+		if left <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		if limits.ValueTimeout <= 0 || left < limits.ValueTimeout {
+			limits.ValueTimeout = left
+		}
+	}
+	opts := []DecodeOption{WithReaderMiddleware(gunzipMiddleware), WithLimits(limits)}
+	return decodeWith(bytes.NewReader(data), opts, func(r io.Reader) (interface{}, error) {
+		if len(s.cfg.allowed) > 0 {
+			var err error
+			if r, err = guardTopType(r, s.cfg.allowed); err != nil {
+				return nil, err
+			}
+		}
+		return decode(r)
+	})
+}
+
+func (s *grpcServer) Decode(ctx context.Context, req *gobrpc.DecodeRequest) (*gobrpc.DecodeResponse, error) {
+	v, err := s.read(ctx, req.GetData(), firstRecord)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	var ws Warnings
+	j, err := toJSONWarn(v, &ws)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	out, err := json.Marshal(j)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	resp := &gobrpc.DecodeResponse{Json: string(out), Warnings: rpcWarnings(&ws)}
+	walkPaths(reflect.ValueOf(v), func(path string, _ int, v reflect.Value) bool {
+		t := "nil"
+		if v.IsValid() {
+			t = v.Type().String()
+		}
+		resp.Types = append(resp.Types, &gobrpc.TypeEntry{Path: path, Type: t})
+		return true
+	})
+	return resp, nil
+}
+
+func (s *grpcServer) Encode(ctx context.Context, req *gobrpc.EncodeRequest) (*gobrpc.EncodeResponse, error) {
+	var ws Warnings
+	data, err := decodeJSONMap(strings.NewReader(req.GetJson()), &ws)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "json: %v", err)
+	}
+	o := req.GetOptions()
+	if o.GetDeep() && len(o.GetDrop()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "deep needs drop")
+	}
+	if o.GetInterchange() {
+		if _, err := fromInterchange(data, "", &ws); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	if len(o.GetDrop()) > 0 {
+		data = dropKeys(data, o.GetDrop(), o.GetDeep())
+	}
+	if o.GetSortSlices() {
+		sortSlices(data, &ws)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, data); err != nil {
+		return nil, rpcError(err)
+	}
+	if n := int64(buf.Len()); n > s.cfg.maxBytes {
+		return nil, status.Errorf(codes.ResourceExhausted, "encoded value is %d bytes, over the %d-byte limit", n, s.cfg.maxBytes)
+	}
+	if len(s.cfg.allowed) > 0 {
+		if _, err := guardTopType(bytes.NewReader(buf.Bytes()), s.cfg.allowed); err != nil {
+			return nil, rpcError(err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, rpcError(err)
+	}
+	return &gobrpc.EncodeResponse{Data: buf.Bytes(), Warnings: rpcWarnings(&ws)}, nil
+}
+
+// inspection is what Inspect reads from a stream.
+type inspection struct {
+	types  map[typeID]*wireType
+	values []typeID
+}
+
+func (s *grpcServer) Inspect(ctx context.Context, req *gobrpc.InspectRequest) (*gobrpc.InspectResponse, error) {
+	v, err := s.read(ctx, req.GetData(), func(r io.Reader) (interface{}, error) {
+		types, values, err := readWireTypes(r)
+		return inspection{types, values}, err
+	})
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	in := v.(inspection)
+	var report bytes.Buffer
+	if err := writeWireTypes(&report, in.types); err != nil {
+		return nil, rpcError(err)
+	}
+	resp := &gobrpc.InspectResponse{Report: report.String()}
+	for _, id := range in.values {
+		resp.Values = append(resp.Values, typeString(in.types, id))
+	}
+	return resp, nil
+}
+
+// rpcWarnings converts ws for a response.
+func rpcWarnings(ws *Warnings) []*gobrpc.Warning {
+	var out []*gobrpc.Warning
+	for _, w := range ws.List {
+		out = append(out, &gobrpc.Warning{Code: w.Code, Name: w.Name, Path: w.Path, Message: w.Message})
+	}
+	return out
+}
+
+// rpcError gives err the status code its class calls for, with the
+// class, as errclass names it, at the start of the message.
+func rpcError(err error) error {
+	var notAllowed *TypeNotAllowedError
+	var deadline *ValueDeadlineError
+	switch {
+	case errors.As(err, &notAllowed):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.As(err, &deadline), errors.Is(err, context.DeadlineExceeded):
+		return status.Errorf(codes.DeadlineExceeded, "%s: %v", errclass.LimitExceeded, err)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	class, _ := ClassifyError(err)
+	code := codes.InvalidArgument
+	switch class {
+	case errclass.LimitExceeded:
+		code = codes.ResourceExhausted
+	case errclass.IOError, errclass.Other:
+		code = codes.Internal
+	}
+	return status.Errorf(code, "%s: %v", class, err)
+}
+
+// grpcServeFlags registers the flags of grpc-serve; the command returned
+// serves until it is interrupted.
+func grpcServeFlags(fs *flag.FlagSet) func(args []string) error {
+	cfg := grpcConfig{}
+	listen := fs.String("listen", "localhost:50051", "address to listen on; port 0 picks a free one")
+	fs.StringVar(&cfg.certFile, "tls-cert", "", "serve TLS with this PEM certificate (needs -tls-key)")
+	fs.StringVar(&cfg.keyFile, "tls-key", "", "PEM private key of -tls-cert")
+	fs.Int64Var(&cfg.maxBytes, "max-bytes", defaultGRPCMaxBytes, "largest request or response message, and most gob bytes a payload may hold once decompressed")
+	fs.DurationVar(&cfg.valueTimeout, "value-timeout", 10*time.Second, "give up reading a payload after this long, or sooner at the client's deadline; 0 for the client's deadline only")
+	fs.Func("allow-type", "only read and write streams of this top-level type, as ls names it (repeatable)", func(s string) error {
+		cfg.allowed = append(cfg.allowed, s)
+		return nil
+	})
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errors.New("usage: grpc-serve [-listen addr] [-tls-cert file -tls-key file] [-max-bytes n] [-value-timeout d] [-allow-type name]...")
+		}
+		if (cfg.certFile == "") != (cfg.keyFile == "") {
+			return errors.New("-tls-cert and -tls-key go together")
+		}
+		if cfg.maxBytes <= 0 || cfg.maxBytes > 1<<31-1 {
+			return fmt.Errorf("-max-bytes %d: want 1 to %d", cfg.maxBytes, 1<<31-1)
+		}
+		srv, err := newGRPCServer(cfg)
+		if err != nil {
+			return err
+		}
+		lis, err := net.Listen("tcp", *listen)
+		if err != nil {
+			return err
+		}
+		if cfg.certFile == "" {
+			if host, _, _ := net.SplitHostPort(*listen); host != "localhost" && !net.ParseIP(host).IsLoopback() {
+				fmt.Fprintln(os.Stderr, "warning: serving without TLS on a non-loopback address")
+			}
+		}
+		ctx, stop := signal.NotifyContext(runContext, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			srv.GracefulStop()
+		}()
+		fmt.Printf("serving on %s\n", lis.Addr())
+		return srv.Serve(lis)
+	}
+}
@@ -0,0 +1,178 @@
+package gobtool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"test-gob/gobrpc"
+)
+
+// startGRPC serves cfg on a free loopback port until the test ends and
+// returns a client of it, dialled with creds.
+func startGRPC(t *testing.T, cfg grpcConfig, creds credentials.TransportCredentials) gobrpc.GobToolClient {
+	t.Helper()
+	srv, err := newGRPCServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return gobrpc.NewGobToolClient(conn)
+}
+
+func TestGRPCServe(t *testing.T) {
+	c := startGRPC(t, grpcConfig{maxBytes: defaultGRPCMaxBytes, valueTimeout: time.Second}, insecure.NewCredentials())
+	ctx := context.Background()
+
+	enc, err := c.Encode(ctx, &gobrpc.EncodeRequest{
+		Json:    `{"user": "ada", "tags": ["b", "a"], "secret": "x"}`,
+		Options: &gobrpc.EncodeOptions{SortSlices: true, Drop: []string{"secret"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := c.Decode(ctx, &gobrpc.DecodeRequest{Data: enc.Data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"tags":["a","b"],"user":"ada"}`; dec.Json != want {
+		t.Errorf("Decode = %s, want %s", dec.Json, want)
+	}
+	types := map[string]string{}
+	for _, e := range dec.Types {
+		types[e.Path] = e.Type
+	}
+	if types["user"] != "string" || types["tags"] != "[]interface {}" {
+		t.Errorf("Decode types = %v", types)
+	}
+
+	in, err := c.Inspect(ctx, &gobrpc.InspectRequest{Data: enc.Data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(in.Values) != 1 || !strings.HasPrefix(in.Values[0], "map[") {
+		t.Errorf("Inspect values = %q", in.Values)
+	}
+
+	_, err = c.Decode(ctx, &gobrpc.DecodeRequest{Data: []byte("not gob")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Decode of junk: got %v", err)
+	}
+}
+
+func TestGRPCServeLimits(t *testing.T) {
+	ctx := context.Background()
+	data := encodeMap(t, map[interface{}]interface{}{"a": 1})
+	guarded := startGRPC(t, grpcConfig{maxBytes: 1 << 10, allowed: []string{"Session"}}, insecure.NewCredentials())
+	_, err := guarded.Decode(ctx, &gobrpc.DecodeRequest{Data: data})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Decode of a type not allowed: got %v", err)
+	}
+	_, err = guarded.Encode(ctx, &gobrpc.EncodeRequest{Json: `{"a": 1}`})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Encode of a type not allowed: got %v", err)
+	}
+
+	// Well under the message size compressed, far over it once not.
+	c := startGRPC(t, grpcConfig{maxBytes: 1 << 10}, insecure.NewCredentials())
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(encodeMap(t, map[interface{}]interface{}{"a": strings.Repeat("x", 1<<16)}))
+	w.Close()
+	_, err = c.Decode(ctx, &gobrpc.DecodeRequest{Data: gz.Bytes()})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Decode over -max-bytes once decompressed: got %v", err)
+	}
+
+	expired, cancel := context.WithTimeout(ctx, time.Nanosecond)
+	defer cancel()
+	_, err = c.Inspect(expired, &gobrpc.InspectRequest{Data: data})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Inspect past the deadline: got %v", err)
+	}
+}
+
+// encodeMap returns m as encode writes it.
+func encodeMap(t *testing.T, m map[interface{}]interface{}) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "m.gob")
+	if err := encodeAndWriteToFile(m, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestGRPCServeTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	cfg := grpcConfig{certFile: certFile, keyFile: keyFile, maxBytes: defaultGRPCMaxBytes}
+	c := startGRPC(t, cfg, credentials.NewTLS(&tls.Config{RootCAs: pool}))
+	if _, err := c.Encode(context.Background(), &gobrpc.EncodeRequest{Json: `{"a": 1}`}); err != nil {
+		t.Errorf("Encode over TLS: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// TypeNotAllowedError is returned by DecodeTypeGuarded for a stream whose
+// top-level type is not on the allowlist.
+type TypeNotAllowedError struct {
+	Type    string
+	Allowed []string
+}
+
+func (e *TypeNotAllowedError) Error() string {
+	return fmt.Sprintf("gob: top-level type %s is not allowed (allowed: %v)", e.Type, e.Allowed)
+}
+
+// DecodeTypeGuarded decodes one value from r into v, but only if the
+// stream's top-level type is one of allowed. The type definitions are
+// read and checked first, so a disallowed stream is rejected before any
+// of its values is decoded. Type names are as ls prints them: the wire
+// name of a named type ("Session"), a Go-like spelling for unnamed ones
+// ("map[string]interface {}"), and for a value sent as an interface the
+// name its concrete type was registered under ("*sessions.Session").
+func DecodeTypeGuarded(r io.Reader, allowed []string, v interface{}) error {
+	r, err := guardTopType(r, allowed)
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(r).Decode(v)
+}
+
+// guardTopType checks the top-level type of the gob stream r against
+// allowed, as DecodeTypeGuarded does, and returns the whole stream again,
+// the bytes it read to check included.
+func guardTopType(r io.Reader, allowed []string) (io.Reader, error) {
+	var seen bytes.Buffer
+	w := newWireReader(r, 0)
+	w.recs = append(w.recs, &seen)
+	id, err := w.nextMessage()
+	if err != nil {
+		return nil, err
+	}
+	name := typeString(w.types, id)
+	if id == tInterface {
+		if delta, err := w.readUint(); err != nil {
+			return nil, err
+		} else if delta != 0 {
+			return nil, formatErrorf("gob: corrupted data: non-zero delta for singleton at offset %d", w.off)
+		}
+		if name, err = w.readString(); err != nil {
+			return nil, err
+		}
+		if name == "" {
+			name = "nil"
+		}
+	}
+	ok := false
+	for _, a := range allowed {
+		ok = ok || a == name
+	}
+	if !ok {
+		return nil, &TypeNotAllowedError{name, allowed}
+	}
+	// The stream from the start: the bytes checked, then the rest.
+	return io.MultiReader(&seen, w.r), nil
+}
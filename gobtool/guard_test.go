@@ -0,0 +1,48 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestDecodeTypeGuarded(t *testing.T) {
+	registerKnownTypes()
+	var plain bytes.Buffer
+	if err := gob.NewEncoder(&plain).Encode(map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]interface{}
+	if err := DecodeTypeGuarded(bytes.NewReader(plain.Bytes()), []string{"map[string]interface {}"}, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["a"] != 1 {
+		t.Errorf("got %v", v)
+	}
+
+	var te *TypeNotAllowedError
+	err := DecodeTypeGuarded(bytes.NewReader(plain.Bytes()), []string{"Session"}, &v)
+	if !errors.As(err, &te) || te.Type != "map[string]interface {}" {
+		t.Errorf("disallowed map: got %v", err)
+	}
+
+	var iface bytes.Buffer
+	var s interface{} = &sessions.Session{ID: "x"}
+	if err := gob.NewEncoder(&iface).Encode(&s); err != nil {
+		t.Fatal(err)
+	}
+	var got interface{}
+	if err := DecodeTypeGuarded(bytes.NewReader(iface.Bytes()), []string{"*sessions.Session"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if sess, ok := got.(*sessions.Session); !ok || sess.ID != "x" {
+		t.Errorf("interface value: got %#v", got)
+	}
+	err = DecodeTypeGuarded(bytes.NewReader(iface.Bytes()), []string{"map[string]interface {}"}, &got)
+	if !errors.As(err, &te) || te.Type != "*sessions.Session" {
+		t.Errorf("disallowed interface value: got %v", err)
+	}
+}
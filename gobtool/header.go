@@ -0,0 +1,45 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// headerFlags describes a fixed-size header that precedes the gob stream
+// in some in-house formats, such as a magic number and a version byte.
+type headerFlags struct {
+	skip  int
+	magic string
+}
+
+func (h *headerFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&h.skip, "skip-header", 0, "skip this many bytes before the gob stream")
+	fs.StringVar(&h.magic, "expect-magic", "", "hex bytes the header must start with")
+}
+
+// strip consumes the header from r, checking it against the expected
+// magic. With only -expect-magic, the header is the magic itself.
+func (h *headerFlags) strip(r io.Reader) error {
+	magic, err := hex.DecodeString(h.magic)
+	if err != nil {
+		return fmt.Errorf("-expect-magic: %w", err)
+	}
+	n := h.skip
+	if n == 0 {
+		n = len(magic)
+	}
+	if n < len(magic) {
+		return fmt.Errorf("-expect-magic is %d bytes, longer than the %d-byte header", len(magic), n)
+	}
+	header := make([]byte, n)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("reading %d-byte header: %w", n, err)
+	}
+	if !bytes.Equal(header[:len(magic)], magic) {
+		return fmt.Errorf("bad magic: got %x, want %x", header[:len(magic)], magic)
+	}
+	return nil
+}
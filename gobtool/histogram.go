@@ -0,0 +1,54 @@
+package gobtool
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// typeCount is one row of a type histogram.
+type typeCount struct {
+	Type  string
+	Count int
+}
+
+// typeHistogram counts the concrete types of the values in data, the root
+// included, most frequent first. Pointers and interfaces are followed, so
+// a *T is counted as T; nil values count as "nil".
+func typeHistogram(data interface{}) []typeCount {
+	counts := make(map[string]int)
+	walkPaths(reflect.ValueOf(data), func(_ string, _ int, v reflect.Value) bool {
+		if !v.IsValid() {
+			counts["nil"]++
+		} else {
+			counts[v.Type().String()]++
+		}
+		return true
+	})
+	hist := make([]typeCount, 0, len(counts))
+	for t, n := range counts {
+		hist = append(hist, typeCount{t, n})
+	}
+	sort.Slice(hist, func(i, j int) bool {
+		if hist[i].Count != hist[j].Count {
+			return hist[i].Count > hist[j].Count
+		}
+		return hist[i].Type < hist[j].Type
+	})
+	return hist
+}
+
+func writeTypeHistogram(w io.Writer, data interface{}) error {
+	hist := typeHistogram(data)
+	width := 0
+	for _, h := range hist {
+		width = max(width, len(h.Type))
+	}
+	for _, h := range hist {
+		if _, err := fmt.Fprintf(w, "%-*s %d\n", width+1, h.Type+":", h.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package gobtool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTypeHistogram(t *testing.T) {
+	n := 3
+	data := map[string]interface{}{
+		"a": 1,
+		"b": 2,
+		"c": "x",
+		"d": []int{4, 5},
+		"e": &n,
+		"f": nil,
+	}
+	want := []typeCount{
+		{"int", 5},
+		{"[]int", 1},
+		{"map[string]interface {}", 1},
+		{"nil", 1},
+		{"string", 1},
+	}
+	if got := typeHistogram(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	var b strings.Builder
+	if err := writeTypeHistogram(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "int:                     5\n") {
+		t.Errorf("got\n%s", b.String())
+	}
+}
@@ -0,0 +1,259 @@
+package gobtool
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Interchange JSON is JSON in which values JSON has no type for are
+// annotated, as the data science pipeline writes them:
+//
+//	{"__type": "datetime", "value": "2024-05-01T12:00:00+00:00"}
+//
+// An object is an annotation when it has exactly the keys __type and
+// value. encode -interchange turns annotations into Go values, and
+// -format interchange writes those values back as annotations, so data
+// can go from Python through gob and back. The types known are:
+//
+//	datetime  time.Time       ISO 8601 string; without a zone it is UTC
+//	bytes     []byte          standard base64 (URL-safe also read)
+//	bigint    *big.Int        decimal string, or a JSON integer
+//	decimal   *big.Float      decimal string, kept to 256 bits
+//	duration  time.Duration   seconds as a JSON number, or a Go duration string
+//
+// RegisterInterchangeType adds a type. An annotation of a type nobody
+// registered stays a map, with a warning.
+
+// InterchangeType is one annotation type. Decode turns an annotation's
+// value, as fromJSON leaves it, into a Go value; Encode turns a value of
+// Go type back into the annotation's value.
+type InterchangeType struct {
+	Name   string
+	GoType reflect.Type
+	Decode func(value interface{}) (interface{}, error)
+	Encode func(v interface{}) (interface{}, error)
+}
+
+var (
+	interchangeMu     sync.Mutex
+	interchangeByName = make(map[string]InterchangeType)
+	interchangeByType = make(map[reflect.Type]InterchangeType)
+)
+
+// RegisterInterchangeType adds t to the annotation vocabulary. Its Go
+// type is registered with gob by registerInterchangeTypes, along with the
+// others. It panics if the name or the Go type is already registered.
+func RegisterInterchangeType(t InterchangeType) {
+	interchangeMu.Lock()
+	defer interchangeMu.Unlock()
+	if _, dup := interchangeByName[t.Name]; dup {
+		panic("interchange type registered twice: " + t.Name)
+	}
+	if _, dup := interchangeByType[t.GoType]; dup {
+		panic("interchange Go type registered twice: " + t.GoType.String())
+	}
+	interchangeByName[t.Name] = t
+	interchangeByType[t.GoType] = t
+}
+
+// registerInterchangeTypes registers the interchange Go types with gob. A
+// type whose gob name is taken is left out; its values then fail to
+// decode with gob's own error naming the type.
+func registerInterchangeTypes() {
+	interchangeMu.Lock()
+	defer interchangeMu.Unlock()
+	for _, t := range interchangeByName {
+		safeRegister(reflect.Zero(t.GoType).Interface())
+	}
+}
+
+func init() {
+	RegisterInterchangeType(InterchangeType{
+		Name:   "datetime",
+		GoType: reflect.TypeOf(time.Time{}),
+		Decode: func(value interface{}) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("want a string, not %T", value)
+			}
+			return parseISOTime(s)
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(time.Time).Format(time.RFC3339Nano), nil
+		},
+	})
+	RegisterInterchangeType(InterchangeType{
+		Name:   "bytes",
+		GoType: reflect.TypeOf([]byte(nil)),
+		Decode: func(value interface{}) (interface{}, error) {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("want a base64 string, not %T", value)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				b, err = base64.URLEncoding.DecodeString(s)
+			}
+			return b, err
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return base64.StdEncoding.EncodeToString(v.([]byte)), nil
+		},
+	})
+	RegisterInterchangeType(InterchangeType{
+		Name:   "bigint",
+		GoType: reflect.TypeOf((*big.Int)(nil)),
+		Decode: func(value interface{}) (interface{}, error) {
+			n, ok := new(big.Int).SetString(fmt.Sprint(value), 10)
+			if !ok {
+				return nil, fmt.Errorf("%v is not an integer", value)
+			}
+			return n, nil
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(*big.Int).String(), nil
+		},
+	})
+	RegisterInterchangeType(InterchangeType{
+		Name:   "decimal",
+		GoType: reflect.TypeOf((*big.Float)(nil)),
+		Decode: func(value interface{}) (interface{}, error) {
+			f, ok := new(big.Float).SetPrec(256).SetString(fmt.Sprint(value))
+			if !ok {
+				return nil, fmt.Errorf("%v is not a decimal number", value)
+			}
+			return f, nil
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(*big.Float).Text('g', -1), nil
+		},
+	})
+	RegisterInterchangeType(InterchangeType{
+		Name:   "duration",
+		GoType: reflect.TypeOf(time.Duration(0)),
+		Decode: func(value interface{}) (interface{}, error) {
+			switch x := value.(type) {
+			case string:
+				return time.ParseDuration(x)
+			case int:
+				return time.Duration(x) * time.Second, nil
+			case float64:
+				return time.Duration(x * float64(time.Second)), nil
+			}
+			return nil, fmt.Errorf("want seconds or a duration string, not %T", value)
+		},
+		Encode: func(v interface{}) (interface{}, error) {
+			return v.(time.Duration).Seconds(), nil
+		},
+	})
+}
+
+// isoLayouts are the datetime forms read, Python's isoformat among them.
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func parseISOTime(s string) (time.Time, error) {
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not an ISO 8601 datetime", s)
+}
+
+// fromInterchange replaces the annotations below v, as fromJSON left it,
+// with the values they stand for. Errors name the path of the bad
+// annotation.
+func fromInterchange(v interface{}, path string, ws *Warnings) (interface{}, error) {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		for k, e := range x {
+			d, err := fromInterchange(e, joinPath(path, pathKey(k)), ws)
+			if err != nil {
+				return nil, err
+			}
+			x[k] = d
+		}
+	case map[string]interface{}:
+		if name, ok := x["__type"].(string); ok && len(x) == 2 {
+			if value, ok := x["value"]; ok {
+				interchangeMu.Lock()
+				t, known := interchangeByName[name]
+				interchangeMu.Unlock()
+				if !known {
+					ws.add(WarnUnknownType, path, "annotation of unknown type %q is kept as a map", name)
+					return x, nil
+				}
+				d, err := t.Decode(value)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s annotation: %w", pathOrRoot(path), name, err)
+				}
+				return d, nil
+			}
+		}
+		for k, e := range x {
+			d, err := fromInterchange(e, joinPath(path, pathKey(k)), ws)
+			if err != nil {
+				return nil, err
+			}
+			x[k] = d
+		}
+	case []interface{}:
+		for i, e := range x {
+			d, err := fromInterchange(e, fmt.Sprintf("%s[%d]", path, i), ws)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = d
+		}
+	}
+	return v, nil
+}
+
+// annotateInterchange returns the annotation for v if it holds a value
+// of an interchange type.
+func annotateInterchange(v reflect.Value) (interface{}, bool, error) {
+	for v.IsValid() && v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false, nil
+	}
+	interchangeMu.Lock()
+	t, ok := interchangeByType[v.Type()]
+	interchangeMu.Unlock()
+	if !ok || v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, false, nil
+	}
+	value, err := t.Encode(v.Interface())
+	return map[string]interface{}{"__type": t.Name, "value": value}, true, err
+}
+
+// toInterchange is toJSONWarn writing interchange types as annotations.
+func toInterchange(v interface{}, ws *Warnings) (interface{}, error) {
+	c := &jsonConverter{guard: make(cycleGuard), ws: ws, annotate: true}
+	return c.value(reflect.ValueOf(v), "")
+}
+
+// writeInterchange writes data as indented interchange JSON.
+func writeInterchange(w io.Writer, data interface{}, ws *Warnings) error {
+	v, err := toInterchange(data, ws)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
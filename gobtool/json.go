@@ -0,0 +1,239 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// fromJSON converts a value decoded by encoding/json (with UseNumber) into
+// the shapes the tool encodes: objects become map[string]interface{},
+// arrays []interface{}, integral numbers int and other numbers float64.
+// An integer too big for int64 becomes float64 as well, with a warning.
+func fromJSON(v interface{}, path string, ws *Warnings) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = fromJSON(e, joinPath(path, pathKey(k)), ws)
+		}
+		return x
+	case []interface{}:
+		for i, e := range x {
+			x[i] = fromJSON(e, fmt.Sprintf("%s[%d]", path, i), ws)
+		}
+		return x
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := x.Float64()
+		if !strings.ContainsAny(string(x), ".eE") {
+			ws.add(WarnNumberType, path, "integer %s does not fit in int64 and is stored as float64 %g", x, f)
+		}
+		return f
+	}
+	return v
+}
+
+// openInput opens name for reading, with "-" meaning stdin and "fd:N" an
+// inherited file descriptor.
+func openInput(name string) (io.Reader, func() error, error) {
+	if name == "-" {
+		return os.Stdin, func() error { return nil }, nil
+	}
+	f, err := openFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// readJSONMap reads a JSON object from name ("-" for stdin) as a top-level
+// map ready for encodeAndWriteToFile.
+func readJSONMap(name string, ws *Warnings) (map[interface{}]interface{}, error) {
+	r, closeIn, err := openInput(name)
+	if err != nil {
+		return nil, err
+	}
+	defer closeIn()
+	data, err := decodeJSONMap(r, ws)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return data, nil
+}
+
+// decodeJSONMap is readJSONMap for the JSON object r holds.
+func decodeJSONMap(r io.Reader, ws *Warnings) (map[interface{}]interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	data := make(map[interface{}]interface{}, len(obj))
+	for k, v := range obj {
+		data[k] = fromJSON(v, pathKey(k), ws)
+	}
+	return data, nil
+}
+
+// toJSON converts a decoded value into one encoding/json can marshal: map
+// keys of any type become their printed form, structs become objects of
+// their exported fields and pointers are followed. Byte slices are left
+// for json to base64-encode, and text marshalers to marshal themselves.
+// A string that is not valid UTF-8 becomes a bytes annotation, as
+// interchange JSON writes []byte, since json would replace its bad bytes.
+func toJSON(v interface{}) (interface{}, error) {
+	return toJSONWarn(v, nil)
+}
+
+var registerKnownOnce sync.Once
+
+// DecodeToJSON decodes the top-level value of data, a gob stream behind
+// any wrapping decodeAny recognizes, and returns it as JSON as toJSON
+// converts it. The types the tool knows are registered first. The C API
+// returns what this does.
+func DecodeToJSON(data []byte) ([]byte, error) {
+	registerKnownOnce.Do(registerKnownTypes)
+	v, err := decodeAny(bytes.NewReader(data), "")
+	if err != nil {
+		return nil, err
+	}
+	if v, err = toJSON(v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// toJSONWarn is toJSON, adding to ws what the conversion loses: keys
+// that collide or stop being typed, unexported fields, integers beyond
+// the 2^53 that JSON readers using doubles hold exactly, byte slices and
+// complex numbers that become strings, and strings that are not UTF-8.
+func toJSONWarn(v interface{}, ws *Warnings) (interface{}, error) {
+	c := &jsonConverter{guard: make(cycleGuard), ws: ws}
+	return c.value(reflect.ValueOf(v), "")
+}
+
+// maxExactInt is the largest integer every JSON reader holds exactly.
+const maxExactInt = 1 << 53
+
+// jsonConverter is the state of one toJSONWarn or toInterchange.
+type jsonConverter struct {
+	guard cycleGuard
+	ws    *Warnings
+	// annotate writes values of registered interchange types as
+	// annotations; see interchange.go.
+	annotate bool
+}
+
+func (c *jsonConverter) value(v reflect.Value, path string) (interface{}, error) {
+	if c.annotate {
+		if a, ok, err := annotateInterchange(v); ok {
+			return a, err
+		}
+	}
+	ws := c.ws
+	v, leave, ok := c.guard.descend(v)
+	if !ok {
+		return nil, errors.New("value contains itself")
+	}
+	defer leave()
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.CanInterface() {
+		if _, ok := v.Interface().(encoding.TextMarshaler); ok {
+			return v.Interface(), nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		obj := make(map[string]interface{}, v.Len())
+		var nonString reflect.Value
+		iter := v.MapRange()
+		for iter.Next() {
+			k := iter.Key()
+			kp := joinPath(path, pathKey(k.Interface()))
+			e, err := c.value(iter.Value(), kp)
+			if err != nil {
+				return nil, err
+			}
+			name := fmt.Sprint(k)
+			if _, dup := obj[name]; dup {
+				ws.add(WarnKeyCollision, kp, "more than one key prints as %q; only one of their values is kept", name)
+			}
+			// the example named is the first in printed order, so the
+			// message does not depend on map iteration order
+			if ik := indirect(k); ik.IsValid() && ik.Kind() != reflect.String && (!nonString.IsValid() || name < fmt.Sprint(nonString)) {
+				nonString = ik
+			}
+			obj[name] = e
+		}
+		if nonString.IsValid() {
+			ws.add(WarnKeyStringified, path, "non-string keys, such as %v (%s), are written as strings", nonString, nonString.Type())
+		}
+		return obj, nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice && v.Type() != reflect.TypeOf(json.RawMessage(nil)) {
+				ws.add(WarnTypeChange, path, "%s is written as a base64 string", v.Type())
+			}
+			return v.Interface(), nil
+		}
+		arr := make([]interface{}, v.Len())
+		for i := range arr {
+			e, err := c.value(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = e
+		}
+		return arr, nil
+	case reflect.Struct:
+		obj := make(map[string]interface{}, v.NumField())
+		var dropped []string
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				dropped = append(dropped, f.Name)
+				continue
+			}
+			e, err := c.value(v.Field(i), joinPath(path, f.Name))
+			if err != nil {
+				return nil, err
+			}
+			obj[f.Name] = e
+		}
+		if len(dropped) > 0 {
+			ws.add(WarnFieldDropped, path, "unexported fields of %s left out: %s", v.Type(), strings.Join(dropped, ", "))
+		}
+		return obj, nil
+	case reflect.String:
+		if s := v.String(); !utf8.ValidString(s) {
+			ws.add(WarnInvalidUTF8, path, "%s of %d bytes is not valid UTF-8; it is written as a bytes annotation", v.Type(), len(s))
+			return map[string]interface{}{"__type": "bytes", "value": base64.StdEncoding.EncodeToString([]byte(s))}, nil
+		}
+	case reflect.Complex64, reflect.Complex128:
+		ws.add(WarnTypeChange, path, "%s is written as a string", v.Type())
+		return fmt.Sprint(v), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n > maxExactInt || n < -maxExactInt {
+			ws.add(WarnNumberType, path, "integer %d is beyond 2^53; JSON readers that use doubles round it", n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n > maxExactInt {
+			ws.add(WarnNumberType, path, "integer %d is beyond 2^53; JSON readers that use doubles round it", n)
+		}
+	}
+	return v.Interface(), nil
+}
@@ -0,0 +1,125 @@
+package gobtool
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	type point struct {
+		X, Y int
+		tag  string
+	}
+	data := map[interface{}]interface{}{
+		1:       "int key",
+		"point": point{1, 2, "hidden"},
+		"raw":   json.RawMessage(`{"a": [1, 2]}`),
+		"bytes": []byte("hi"),
+		"list":  []interface{}{1.5, nil, true},
+	}
+	ws := &Warnings{}
+	v, err := toJSONWarn(data, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"1":"int key","bytes":"aGk=","list":[1.5,null,true],"point":{"X":1,"Y":2},"raw":{"a":[1,2]}}`
+	if string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+	var codes []string
+	for _, w := range ws.List {
+		codes = append(codes, w.Code+" "+w.Path)
+	}
+	sort.Strings(codes)
+	if want := []string{WarnKeyStringified + " ", WarnFieldDropped + " point", WarnTypeChange + " bytes"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("got warnings %v, want %v", codes, want)
+	}
+}
+
+func TestToJSONInvalidUTF8(t *testing.T) {
+	registerKnownTypes()
+	data, err := decodeAnyFile(filepath.Join("testdata", "invalid-utf8.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := &Warnings{}
+	v, err := toJSONWarn(data, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"name":{"__type":"bytes","value":"Y2Fm6Q=="},"ok":"fine"}`; string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+	if len(ws.List) != 1 || ws.List[0].Code != WarnInvalidUTF8 || ws.List[0].Path != "name" {
+		t.Errorf("got warnings %+v, want one %s at name", ws.List, WarnInvalidUTF8)
+	}
+
+	// The annotation holds the original bytes, which U+FFFD would not.
+	raw, err := base64.StdEncoding.DecodeString(v.(map[string]interface{})["name"].(map[string]interface{})["value"].(string))
+	if err != nil || string(raw) != "caf\xe9" {
+		t.Errorf("annotation decodes to %q, %v", raw, err)
+	}
+}
+
+func TestToJSONCycle(t *testing.T) {
+	m := map[string]interface{}{}
+	m["self"] = m
+	if _, err := toJSON(m); err == nil || !strings.Contains(err.Error(), "contains itself") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestJSONBytes(t *testing.T) {
+	for _, c := range []struct {
+		v      interface{}
+		guess  bool
+		indent string
+		want   string
+		ok     bool
+	}{
+		{json.RawMessage(`{"a": 1}`), false, "", `{"a":1}`, true},
+		{json.RawMessage(`{"a": 1}`), false, "  ", "{\n  \"a\": 1\n}", true},
+		{json.RawMessage(`not json`), false, "", "", false},
+		{json.RawMessage(` `), false, "", "", false},
+		{[]byte(`[1, 2]`), false, "", "", false},
+		{[]byte(`[1, 2]`), true, "", `[1,2]`, true},
+		{[]byte("\xff\xfe"), true, "", "", false},
+		{"[1]", true, "", "", false},
+	} {
+		got, ok := jsonBytes(reflect.ValueOf(c.v), c.guess, c.indent)
+		if got != c.want || ok != c.ok {
+			t.Errorf("jsonBytes(%T %q, %v, %q) = %q, %v; want %q, %v", c.v, c.v, c.guess, c.indent, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestWriteFlatRawMessage(t *testing.T) {
+	var buf strings.Builder
+	data := map[string]interface{}{"raw": json.RawMessage(`{"a": 1}`), "b": []byte(`{"b": 2}`)}
+	if err := (flatWriter{}).write(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if want := "b = [123 34 98 34 58 32 50 125] ([]uint8)\nraw = {\"a\":1} (" + rawMessageType.String() + ", JSON)\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	buf.Reset()
+	if err := (flatWriter{guessJSON: true}).write(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	if want := "b = {\"b\":2} ([]uint8, JSON)\nraw = {\"a\":1} (" + rawMessageType.String() + ", JSON)\n"; buf.String() != want {
+		t.Errorf("guessing: got %q, want %q", buf.String(), want)
+	}
+}
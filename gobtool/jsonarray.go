@@ -0,0 +1,68 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// decode -format json-array writes every value of a multi-record stream
+// as one JSON array, converting and writing each record as it is decoded,
+// so memory does not grow with the number of records. Each element is
+// flushed as soon as it is written. An empty stream is written as [].
+
+// writeJSONArray streams the records of the gob stream r to w as a JSON
+// array, applying transforms to each record first. With counted set the
+// stream must end in a record-count footer that matches.
+func writeJSONArray(w io.Writer, r io.Reader, transforms []TransformRule, counted bool, ws *Warnings) error {
+	bw := bufio.NewWriter(w)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("  ", "  ")
+	enc.SetEscapeHTML(false)
+	n := 0
+	err := decodeStream(counted)(r, func(rec interface{}) error {
+		if _, err := ApplyTransforms(rec, transforms, false); err != nil {
+			return err
+		}
+		sub := &Warnings{}
+		v, err := toJSONWarn(rec, sub)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		for _, warn := range sub.List {
+			ws.add(warn.Code, fmt.Sprintf("[%d]", n)+pathSuffix(warn.Path), "%s", warn.Message)
+		}
+		buf.Reset()
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		sep := ",\n  "
+		if n == 0 {
+			sep = "[\n  "
+		}
+		n++
+		bw.WriteString(sep)
+		bw.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+		return bw.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		bw.WriteString("[]\n")
+	} else {
+		bw.WriteString("\n]\n")
+	}
+	return bw.Flush()
+}
+
+// pathSuffix returns path ready to follow an index segment.
+func pathSuffix(path string) string {
+	if path == "" || path[0] == '[' {
+		return path
+	}
+	return "." + path
+}
@@ -0,0 +1,100 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipeRecords is a stream of n records of about 100 bytes each.
+func pipeRecords(t *testing.T, n int) []byte {
+	t.Helper()
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = map[string]interface{}{"i": i, "pad": strings.Repeat("x", 100)}
+	}
+	raw, err := encodeStreamValues(values, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestWriteJSONArray(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		values := make([]interface{}, n)
+		for i := range values {
+			values[i] = map[string]interface{}{"i": i}
+		}
+		var raw []byte
+		if n > 0 {
+			var err error
+			if raw, err = encodeStreamValues(values, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var out bytes.Buffer
+		if err := writeJSONArray(&out, bytes.NewReader(raw), nil, false, &Warnings{}); err != nil {
+			t.Fatalf("%d records: %v", n, err)
+		}
+		var got []map[string]int
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("%d records: %v\n%s", n, err, out.String())
+		}
+		if len(got) != n || got == nil {
+			t.Errorf("%d records: got %v", n, got)
+		}
+		for i, rec := range got {
+			if rec["i"] != i {
+				t.Errorf("%d records: element %d is %v", n, i, rec)
+			}
+		}
+	}
+}
+
+func TestWriteJSONArrayBrokenPipe(t *testing.T) {
+	raw := pipeRecords(t, 2000)
+	pr, pw := io.Pipe()
+	go func() {
+		io.CopyN(io.Discard, pr, 1000)
+		pr.Close()
+	}()
+	in := &countingReader{r: bytes.NewReader(raw)}
+	err := writeJSONArray(pw, in, nil, false, &Warnings{})
+	if !isBrokenPipe(err) {
+		t.Errorf("got %v, want a broken pipe", err)
+	}
+	if n := in.n.Load(); n >= int64(len(raw)) {
+		t.Errorf("read all %d bytes of input anyway", n)
+	}
+}
+
+// stalledWriter blocks every write until gate is closed.
+type stalledWriter struct{ gate chan struct{} }
+
+func (s stalledWriter) Write(p []byte) (int, error) {
+	<-s.gate
+	return len(p), nil
+}
+
+// TestWriteJSONArrayBackpressure checks that a stalled output holds the
+// input back instead of buffering it.
+func TestWriteJSONArrayBackpressure(t *testing.T) {
+	raw := pipeRecords(t, 2000)
+	gate := make(chan struct{})
+	in := &countingReader{r: bytes.NewReader(raw)}
+	done := make(chan error, 1)
+	go func() { done <- writeJSONArray(stalledWriter{gate}, in, nil, false, &Warnings{}) }()
+	time.Sleep(50 * time.Millisecond)
+	n := in.n.Load()
+	close(gate)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n >= int64(len(raw)) {
+		t.Errorf("read all %d bytes of input ahead of the output", n)
+	}
+}
@@ -0,0 +1,34 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// jsonBytes returns the JSON text held in v, indented by indent, if v is
+// a json.RawMessage or, with guess set, a []byte that is valid JSON.
+// Guessing is opt-in as short byte strings are often valid JSON by
+// accident: a single digit is a number.
+func jsonBytes(v reflect.Value, guess bool, indent string) (string, bool) {
+	if !v.IsValid() || v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Uint8 {
+		return "", false
+	}
+	b := v.Bytes()
+	if v.Type() != rawMessageType && !guess || len(bytes.TrimSpace(b)) == 0 {
+		return "", false
+	}
+	var buf bytes.Buffer
+	var err error
+	if indent == "" {
+		err = json.Compact(&buf, b)
+	} else {
+		err = json.Indent(&buf, b, "", indent)
+	}
+	if err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
@@ -0,0 +1,163 @@
+package gobtool
+
+import (
+	"encoding"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// -format jsonschema describes the shape of the decoded data as a JSON
+// Schema (draft-07) document, for validators in other languages. It is
+// inferred from the values as JSON output would write them: objects get
+// their properties, with the keys every instance has as required; arrays
+// get one items schema covering all their elements; a value seen with
+// more than one type gets a list of types. Integers seen together with
+// other numbers become "number". Reading a stream, decode merges the
+// schemas of all its records into one.
+//
+// The result reads back with decode -schema, as far as TypeFromSchema
+// goes.
+
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// shapeNode accumulates what was observed at one place in the data.
+type shapeNode struct {
+	types map[string]bool // JSON Schema type names
+	dates int             // strings that were times, of types["string"]
+	strs  int
+
+	objects int                   // objects seen, for required
+	props   map[string]*shapeNode // properties of the objects
+	seen    map[string]int        // objects each property was in
+	items   *shapeNode            // elements of the arrays
+}
+
+// add records one value, as converted by toJSON.
+func (n *shapeNode) add(v interface{}) {
+	if n.types == nil {
+		n.types = make(map[string]bool)
+	}
+	switch x := v.(type) {
+	case nil:
+		n.types["null"] = true
+		return
+	case map[string]interface{}:
+		n.types["object"] = true
+		n.objects++
+		if n.props == nil {
+			n.props, n.seen = make(map[string]*shapeNode), make(map[string]int)
+		}
+		for k, e := range x {
+			p := n.props[k]
+			if p == nil {
+				p = new(shapeNode)
+				n.props[k] = p
+			}
+			p.add(e)
+			n.seen[k]++
+		}
+		return
+	case []interface{}:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = new(shapeNode)
+		}
+		for _, e := range x {
+			n.items.add(e)
+		}
+		return
+	case time.Time:
+		n.types["string"] = true
+		n.strs++
+		n.dates++
+		return
+	case encoding.TextMarshaler, []byte, json.RawMessage:
+		n.types["string"] = true
+		n.strs++
+		return
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Bool:
+		n.types["boolean"] = true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n.types["integer"] = true
+	case reflect.Float32, reflect.Float64:
+		n.types["number"] = true
+	default:
+		n.types["string"] = true
+		n.strs++
+	}
+}
+
+// jsonSchemaDoc is the schema written for a shapeNode. Fields are in the
+// order JSON Schema documents usually list them.
+type jsonSchemaDoc struct {
+	Schema     string                    `json:"$schema,omitempty"`
+	Type       interface{}               `json:"type,omitempty"` // a name, or a list of them
+	Format     string                    `json:"format,omitempty"`
+	Properties map[string]*jsonSchemaDoc `json:"properties,omitempty"`
+	Required   []string                  `json:"required,omitempty"`
+	Items      *jsonSchemaDoc            `json:"items,omitempty"`
+}
+
+// schema returns the document for what n observed; an empty schema,
+// which allows anything, if it observed nothing.
+func (n *shapeNode) schema() *jsonSchemaDoc {
+	doc := new(jsonSchemaDoc)
+	if n == nil || len(n.types) == 0 {
+		return doc
+	}
+	if n.types["integer"] && n.types["number"] {
+		delete(n.types, "integer")
+	}
+	var types []string
+	for t := range n.types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	if len(types) == 1 {
+		doc.Type = types[0]
+	} else {
+		doc.Type = types
+	}
+	if n.dates > 0 && n.dates == n.strs {
+		doc.Format = "date-time"
+	}
+	if n.types["object"] {
+		doc.Properties = make(map[string]*jsonSchemaDoc, len(n.props))
+		for k, p := range n.props {
+			doc.Properties[k] = p.schema()
+			if n.seen[k] == n.objects {
+				doc.Required = append(doc.Required, k)
+			}
+		}
+		sort.Strings(doc.Required)
+	}
+	if n.types["array"] {
+		doc.Items = n.items.schema()
+	}
+	return doc
+}
+
+// writeJSONSchema writes the schema of records, merged, to w. Conversion
+// losses are added to ws as they are for -format json.
+func writeJSONSchema(w io.Writer, records []interface{}, ws *Warnings) error {
+	var root shapeNode
+	for _, rec := range records {
+		v, err := toJSONWarn(rec, ws)
+		if err != nil {
+			return err
+		}
+		root.add(v)
+	}
+	doc := root.schema()
+	doc.Schema = jsonSchemaDraft
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
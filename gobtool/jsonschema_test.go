@@ -0,0 +1,125 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// schemaDoc is the part of a JSON Schema these tests look at.
+type schemaDoc struct {
+	Schema     string                `json:"$schema"`
+	Type       interface{}           `json:"type"`
+	Properties map[string]*schemaDoc `json:"properties"`
+	Required   []string              `json:"required"`
+	Items      *schemaDoc            `json:"items"`
+}
+
+// typeAt returns the type of the schema at path, where "[]" steps into
+// the items.
+func (s *schemaDoc) typeAt(path ...string) interface{} {
+	for _, p := range path {
+		if s == nil {
+			return nil
+		}
+		if p == "[]" {
+			s = s.Items
+		} else {
+			s = s.Properties[p]
+		}
+	}
+	if s == nil {
+		return nil
+	}
+	return s.Type
+}
+
+func inferSchema(t *testing.T, records ...interface{}) (*schemaDoc, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeJSONSchema(&buf, records, nil); err != nil {
+		t.Fatal(err)
+	}
+	var s schemaDoc
+	if err := json.Unmarshal(buf.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	return &s, buf.Bytes()
+}
+
+func TestWriteJSONSchemaSample(t *testing.T) {
+	sample, err := decodeAnyFile(writeRoot(t, createSampleData()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := inferSchema(t, sample)
+	if got.Schema != jsonSchemaDraft || got.Type != "object" {
+		t.Errorf("root: got $schema %q, type %v", got.Schema, got.Type)
+	}
+	for _, c := range []struct {
+		path []string
+		want string
+	}{
+		{[]string{"name"}, "string"},
+		{[]string{"42"}, "string"},
+		{[]string{"user_info"}, "object"},
+		{[]string{"user_info", "age"}, "integer"},
+		{[]string{"user_info", "city"}, "string"},
+		{[]string{"user_info", "active"}, "boolean"},
+		{[]string{"scores"}, "array"},
+		{[]string{"scores", "[]"}, "integer"},
+		{[]string{"point"}, "object"},
+		{[]string{"point", "X"}, "integer"},
+		{[]string{"point", "Y"}, "integer"},
+	} {
+		if typ := got.typeAt(c.path...); typ != c.want {
+			t.Errorf("%s: got type %v, want %s", strings.Join(c.path, "."), typ, c.want)
+		}
+	}
+	if len(got.Required) != len(got.Properties) {
+		t.Errorf("got required %v, want every property", got.Required)
+	}
+}
+
+func TestWriteJSONSchemaMerge(t *testing.T) {
+	merged, _ := inferSchema(t,
+		map[string]interface{}{"id": 1, "score": 2},
+		map[string]interface{}{"id": 2, "score": 2.5, "tag": "x"})
+	if typ := merged.typeAt("score"); typ != "number" {
+		t.Errorf("score: got type %v, want number", typ)
+	}
+	if typ := merged.typeAt("tag"); typ != "string" {
+		t.Errorf("tag: got type %v, want string", typ)
+	}
+	if fmt.Sprint(merged.Required) != "[id score]" {
+		t.Errorf("got required %v, want [id score]", merged.Required)
+	}
+}
+
+// TestJSONSchemaReadsBack checks that the schema written for a record
+// builds a type the record decodes into.
+func TestJSONSchemaReadsBack(t *testing.T) {
+	rec := map[string]interface{}{"id": 7, "name": "x", "tags": []string{"a"}}
+	_, doc := inferSchema(t, rec)
+	typ, err := TypeFromSchema(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&buf), rec); err != nil {
+		t.Fatal(err)
+	}
+	v, dropped, err := DecodeAsType(&buf, typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dropped) != 0 {
+		t.Errorf("dropped %v", dropped)
+	}
+	if got, want := fmt.Sprintf("%+v", v), "{Id:7 Name:x Tags:[a]}"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,362 @@
+package gobtool
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// -expand-jwt shows what is inside strings that look like JWTs: three
+// base64url segments whose first decodes to a JSON header with an alg.
+// The header and claims are decoded without verification, and the
+// issuer, subject, audience and expiry are shown under the string, the
+// expiry flagged as the goth report flags OAuth expiries. With -jwt-key
+// or -jwks the signature is checked as well. The goth report still
+// redacts the token itself; only the claims are shown.
+
+// jwtExpand is set by -expand-jwt; nil leaves JWTs as plain strings.
+var jwtExpand *jwtExpander
+
+// jwtFlags are -expand-jwt, -jwt-key and -jwks.
+type jwtFlags struct {
+	expand  bool
+	keyFile string
+	jwksURL string
+}
+
+func (f *jwtFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.expand, "expand-jwt", false, "show the header and claims of strings that look like JWTs")
+	fs.StringVar(&f.keyFile, "jwt-key", "", "with -expand-jwt, check signatures with this key: a PEM public key or certificate, or else an HMAC secret")
+	fs.StringVar(&f.jwksURL, "jwks", "", "with -expand-jwt, check signatures with the keys of this JWKS URL")
+}
+
+// apply sets jwtExpand from the flags.
+func (f *jwtFlags) apply() error {
+	if !f.expand {
+		if f.keyFile != "" || f.jwksURL != "" {
+			return errors.New("-jwt-key and -jwks need -expand-jwt")
+		}
+		return nil
+	}
+	jwtExpand = &jwtExpander{keyFile: f.keyFile, jwksURL: f.jwksURL}
+	return nil
+}
+
+// jwtKey is a verification key: an *rsa.PublicKey, *ecdsa.PublicKey,
+// ed25519.PublicKey, or []byte for HMAC.
+type jwtKey struct {
+	kid string
+	key interface{}
+}
+
+// jwtExpander decodes JWTs and, when it has keys, checks them. Keys are
+// loaded the first time a token needs them.
+type jwtExpander struct {
+	keyFile, jwksURL string
+
+	once    sync.Once
+	keys    []jwtKey
+	keysErr error
+}
+
+// jwtToken is a JWT split into its parts.
+type jwtToken struct {
+	Header map[string]interface{}
+	Claims map[string]interface{}
+	signed string // header.payload, what the signature covers
+	sig    []byte
+}
+
+// parseJWT splits s and decodes its header and claims, reporting false
+// when s does not look like a JWT.
+func parseJWT(s string) (*jwtToken, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || len(parts[0]) < 4 {
+		return nil, false
+	}
+	dec := base64.RawURLEncoding
+	t := &jwtToken{signed: parts[0] + "." + parts[1]}
+	h, err := dec.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(h, &t.Header) != nil {
+		return nil, false
+	}
+	if _, ok := t.Header["alg"].(string); !ok {
+		return nil, false
+	}
+	p, err := dec.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(p, &t.Claims) != nil {
+		return nil, false
+	}
+	if t.sig, err = dec.DecodeString(parts[2]); err != nil {
+		return nil, false
+	}
+	return t, true
+}
+
+func (t *jwtToken) headerString(name string) string {
+	s, _ := t.Header[name].(string)
+	return s
+}
+
+// audience returns aud, which may be a string or a list of them.
+func (t *jwtToken) audience() string {
+	switch aud := t.Claims["aud"].(type) {
+	case string:
+		return aud
+	case []interface{}:
+		parts := make([]string, len(aud))
+		for i, a := range aud {
+			parts[i] = fmt.Sprint(a)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return ""
+}
+
+// describe returns the lines shown under a JWT, or false if s is not
+// one.
+func (e *jwtExpander) describe(s string) ([]string, bool) {
+	t, ok := parseJWT(s)
+	if !ok {
+		return nil, false
+	}
+	head := "JWT: alg " + t.headerString("alg")
+	if kid := t.headerString("kid"); kid != "" {
+		head += ", kid " + kid
+	}
+	lines := []string{head}
+	claim := func(label, name string) {
+		if v, ok := t.Claims[name]; ok {
+			lines = append(lines, fmt.Sprintf("  %-10s %v", label+":", v))
+		}
+	}
+	claim("issuer", "iss")
+	claim("subject", "sub")
+	if aud := t.audience(); aud != "" {
+		lines = append(lines, fmt.Sprintf("  %-10s %s", "audience:", aud))
+	}
+	if exp, ok := t.Claims["exp"]; ok {
+		lines = append(lines, fmt.Sprintf("  %-10s %s", "expiry:", formatExpiry(exp)))
+	}
+	lines = append(lines, fmt.Sprintf("  %-10s %s", "signature:", e.verify(t)))
+	return lines, true
+}
+
+// verify reports on t's signature: not checked when there is no key for
+// its alg, valid when a key verifies it, and invalid otherwise.
+func (e *jwtExpander) verify(t *jwtToken) string {
+	if e.keyFile == "" && e.jwksURL == "" {
+		return "not checked"
+	}
+	e.once.Do(func() { e.keys, e.keysErr = e.loadKeys() })
+	if e.keysErr != nil {
+		return "not checked: " + e.keysErr.Error()
+	}
+	alg, kid := t.headerString("alg"), t.headerString("kid")
+	var lastErr error
+	for _, k := range e.keys {
+		if kid != "" && k.kid != "" && k.kid != kid {
+			continue
+		}
+		switch err := verifyJWS(alg, k.key, t.signed, t.sig); err {
+		case nil:
+			return "valid"
+		case errJWTKeyType:
+		default:
+			lastErr = err
+		}
+	}
+	if lastErr == nil {
+		return "not checked: no " + alg + " key"
+	}
+	return "INVALID: " + lastErr.Error()
+}
+
+var errJWTKeyType = errors.New("key does not fit the algorithm")
+
+// jwsHashes maps the size suffix of a JWS alg to its hash.
+var jwsHashes = map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+// verifyJWS checks sig over signed with key, as alg prescribes.
+func verifyJWS(alg string, key interface{}, signed string, sig []byte) error {
+	if alg == "EdDSA" {
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errJWTKeyType
+		}
+		// ed25519.Verify panics on a key of the wrong size.
+		if len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("Ed25519 key is %d bytes, want %d", len(pub), ed25519.PublicKeySize)
+		}
+		if !ed25519.Verify(pub, []byte(signed), sig) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	h, ok := jwsHashes[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	d := h.New()
+	io.WriteString(d, signed)
+	sum := d.Sum(nil)
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return errJWTKeyType
+		}
+		m := hmac.New(h.New, secret)
+		io.WriteString(m, signed)
+		if !hmac.Equal(m.Sum(nil), sig) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errJWTKeyType
+		}
+		if alg[0] == 'P' {
+			return rsa.VerifyPSS(pub, h, sum, sig, nil)
+		}
+		return rsa.VerifyPKCS1v15(pub, h, sum, sig)
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errJWTKeyType
+		}
+		// RFC 7518 section 3.4: R and S, each padded to the size of
+		// the curve order.
+		n := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*n {
+			return fmt.Errorf("%s signature is %d bytes, want %d", alg, len(sig), 2*n)
+		}
+		r, s := new(big.Int).SetBytes(sig[:n]), new(big.Int).SetBytes(sig[n:])
+		if !ecdsa.Verify(pub, sum, r, s) {
+			return errors.New("signature mismatch")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported alg %q", alg)
+}
+
+func (e *jwtExpander) loadKeys() ([]jwtKey, error) {
+	var keys []jwtKey
+	if e.keyFile != "" {
+		b, err := os.ReadFile(e.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		k, err := parseKeyFile(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.keyFile, err)
+		}
+		keys = append(keys, jwtKey{key: k})
+	}
+	if e.jwksURL != "" {
+		ks, err := fetchJWKS(e.jwksURL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.jwksURL, err)
+		}
+		keys = append(keys, ks...)
+	}
+	return keys, nil
+}
+
+// parseKeyFile reads a PEM public key or certificate, or takes the
+// trimmed contents as an HMAC secret when there is no PEM block.
+func parseKeyFile(b []byte) (interface{}, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return []byte(strings.TrimSpace(string(b))), nil
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// fetchJWKS reads the keys of a JSON Web Key Set.
+func fetchJWKS(url string) ([]jwtKey, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching keys: %s", resp.Status)
+	}
+	var set struct {
+		Keys []struct {
+			Kty, Kid, Crv, N, E, X, Y, K string
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&set); err != nil {
+		return nil, err
+	}
+	b64 := func(s string) []byte {
+		b, _ := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		return b
+	}
+	curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+	var keys []jwtKey
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			keys = append(keys, jwtKey{k.Kid, &rsa.PublicKey{
+				N: new(big.Int).SetBytes(b64(k.N)),
+				E: int(new(big.Int).SetBytes(b64(k.E)).Int64()),
+			}})
+		case "EC":
+			if c := curves[k.Crv]; c != nil {
+				keys = append(keys, jwtKey{k.Kid, &ecdsa.PublicKey{
+					Curve: c,
+					X:     new(big.Int).SetBytes(b64(k.X)),
+					Y:     new(big.Int).SetBytes(b64(k.Y)),
+				}})
+			}
+		case "OKP":
+			if x := b64(k.X); k.Crv == "Ed25519" && len(x) == ed25519.PublicKeySize {
+				keys = append(keys, jwtKey{k.Kid, ed25519.PublicKey(x)})
+			}
+		case "oct":
+			keys = append(keys, jwtKey{k.Kid, b64(k.K)})
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable keys in the set")
+	}
+	return keys, nil
+}
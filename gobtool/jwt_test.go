@@ -0,0 +1,125 @@
+package gobtool
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// esSign signs signed as ES256 or ES384 do, R and S each padded to size
+// bytes.
+func esSign(t *testing.T, key *ecdsa.PrivateKey, sum []byte, size int) []byte {
+	t.Helper()
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+	return sig
+}
+
+func TestVerifyJWSES(t *testing.T) {
+	const signed = "header.payload"
+	k256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum256 := sha256.Sum256([]byte(signed))
+	sig := esSign(t, k256, sum256[:], 32)
+	if err := verifyJWS("ES256", &k256.PublicKey, signed, sig); err != nil {
+		t.Errorf("valid ES256 signature: %v", err)
+	}
+
+	for _, bad := range [][]byte{sig[:63], append(append([]byte{}, sig...), 0), nil} {
+		err := verifyJWS("ES256", &k256.PublicKey, signed, bad)
+		if err == nil || !strings.Contains(err.Error(), "want 64") {
+			t.Errorf("%d-byte ES256 signature: got %v", len(bad), err)
+		}
+	}
+
+	// P-521 has 66-byte coordinates, not 64.
+	k521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum512 := sha512.Sum512([]byte(signed))
+	sig = esSign(t, k521, sum512[:], 66)
+	if err := verifyJWS("ES512", &k521.PublicKey, signed, sig); err != nil {
+		t.Errorf("valid ES512 signature: %v", err)
+	}
+	if err := verifyJWS("ES512", &k521.PublicKey, signed, sig[:128]); err == nil {
+		t.Error("128-byte ES512 signature: no error")
+	}
+}
+
+func TestVerifyJWSHS(t *testing.T) {
+	secret := []byte("secret")
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte("a.b"))
+	if err := verifyJWS("HS256", secret, "a.b", m.Sum(nil)); err != nil {
+		t.Error(err)
+	}
+	if err := verifyJWS("HS256", secret, "a.c", m.Sum(nil)); err == nil {
+		t.Error("HS256 over other data: no error")
+	}
+	if err := verifyJWS("HS999", secret, "a.b", m.Sum(nil)); err == nil {
+		t.Error("HS999: no error")
+	}
+}
+
+// TestJWKSShortOKPKey serves an Ed25519 key with a truncated x next to a
+// good one: the short key is skipped rather than reaching ed25519.Verify,
+// which panics on it.
+func TestJWKSShortOKPKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"OKP","crv":"Ed25519","kid":"short","x":%q},{"kty":"OKP","crv":"Ed25519","kid":"good","x":%q}]}`,
+			b64(pub[:16]), b64(pub))
+	}))
+	defer srv.Close()
+
+	keys, err := fetchJWKS(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].kid != "good" {
+		t.Fatalf("fetchJWKS kept %v, want only the good key", keys)
+	}
+
+	token := func(kid string) *jwtToken {
+		tok, ok := parseJWT(b64([]byte(`{"alg":"EdDSA","kid":"`+kid+`"}`)) + "." + b64([]byte(`{"sub":"ada"}`)) + ".AA")
+		if !ok {
+			t.Fatal("test token does not parse")
+		}
+		tok.sig = ed25519.Sign(priv, []byte(tok.signed))
+		return tok
+	}
+	e := &jwtExpander{jwksURL: srv.URL}
+	if got := e.verify(token("good")); got != "valid" {
+		t.Errorf("token signed by the good key: %s", got)
+	}
+	if got := e.verify(token("short")); !strings.HasPrefix(got, "not checked") {
+		t.Errorf("token naming the short key: %s", got)
+	}
+
+	tok := token("")
+	if err := verifyJWS("EdDSA", pub[:16], tok.signed, tok.sig); err == nil || !strings.Contains(err.Error(), "want 32") {
+		t.Errorf("verifyJWS with a 16-byte key: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// KVPair is one key and value produced by ToKV.
+type KVPair struct {
+	Key, Value []byte
+}
+
+// ToKV flattens data into one pair per leaf, for bulk loading into a
+// key-value store such as BoltDB or Badger. The key is the leaf's flat
+// path, as decode -format flat prints it, with "(root)" for a root that
+// is not a container; the value is the leaf encoded as JSON, as
+// -format json writes it. Empty maps, slices and structs are leaves, so
+// they are not lost. Pairs are sorted by key, the order stores load
+// fastest in.
+func ToKV(data interface{}) ([]KVPair, error) {
+	var pairs []KVPair
+	var err error
+	walkPaths(reflect.ValueOf(data), func(path string, depth int, v reflect.Value) bool {
+		if err != nil {
+			return false
+		}
+		if v.IsValid() && isContainer(v) && containerLen(v) > 0 {
+			return true
+		}
+		if path == "" {
+			path = "(root)"
+		}
+		var leaf interface{}
+		if v.IsValid() && v.CanInterface() {
+			leaf = v.Interface()
+		}
+		var j interface{}
+		if j, err = toJSON(leaf); err != nil {
+			return false
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err = enc.Encode(j); err != nil {
+			return false
+		}
+		pairs = append(pairs, KVPair{Key: []byte(path), Value: bytes.TrimSuffix(buf.Bytes(), []byte("\n"))})
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pairs, func(i, j int) bool { return bytes.Compare(pairs[i].Key, pairs[j].Key) < 0 })
+	return pairs, nil
+}
@@ -0,0 +1,70 @@
+package gobtool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToKV(t *testing.T) {
+	pairs, err := ToKV(map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":  "张三",
+			"tags":  []string{"a", "<b>"},
+			"prefs": map[string]interface{}{},
+		},
+		"id":    7,
+		"ratio": 0.5,
+		"ok":    true,
+		"raw":   []byte("hi"),
+		"none":  nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range pairs {
+		got = append(got, string(p.Key)+"="+string(p.Value))
+	}
+	want := []string{
+		`id=7`,
+		`none=null`,
+		`ok=true`,
+		`ratio=0.5`,
+		`raw="aGk="`,
+		`user.name="张三"`,
+		`user.prefs={}`,
+		`user.tags[0]="a"`,
+		`user.tags[1]="<b>"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got pairs\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+	}
+}
+
+func TestToKVScalarRoot(t *testing.T) {
+	pairs, err := ToKV("scalar")
+	if err != nil || len(pairs) != 1 || string(pairs[0].Key) != "(root)" {
+		t.Errorf("got %v, %v", pairs, err)
+	}
+}
+
+// TestToKVLeaves checks that every leaf of generated data gets a pair.
+func TestToKVLeaves(t *testing.T) {
+	data := syntheticData(200, 1)
+	pairs, err := ToKV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves := 0
+	walkPaths(reflect.ValueOf(data), func(_ string, _ int, v reflect.Value) bool {
+		if v.IsValid() && isContainer(v) && containerLen(v) > 0 {
+			return true
+		}
+		leaves++
+		return false
+	})
+	if len(pairs) != leaves {
+		t.Errorf("%d pairs for %d leaves", len(pairs), leaves)
+	}
+}
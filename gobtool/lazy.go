@@ -0,0 +1,331 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// LazyMap is the top-level map of a gob stream with only its keys decoded.
+// Each value is decoded the first time it is asked for, by reading back at
+// the offset recorded while the keys were scanned, so the reader passed to
+// OpenLazy must support seeking and must stay open while the map is used.
+// Values may be decoded from several goroutines at once.
+type LazyMap struct {
+	Entries []*LazyEntry
+
+	r     io.ReaderAt
+	types map[typeID]*wireType
+	elem  typeID
+}
+
+// seekReaderAt is io.ReaderAt for a reader that only seeks, serializing
+// the Seek and Read pairs.
+type seekReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (s *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(s.rs, p)
+}
+
+// LazyEntry is one key of a LazyMap and a handle on its undecoded value.
+type LazyEntry struct {
+	Key    interface{}
+	Type   string // concrete type of the value
+	Offset int64  // absolute offset of the encoded value
+	Length int64  // encoded size of the value in bytes
+
+	m    *LazyMap
+	once sync.Once
+	val  interface{}
+	err  error
+}
+
+// notMapError is OpenLazy's error for a stream whose top-level value is
+// not a map, naming the type it is instead.
+type notMapError string
+
+func (e notMapError) Error() string { return "top-level value is " + string(e) + ", not a map" }
+
+// OpenLazy scans the top-level map at the current position of rs, decoding
+// keys only and skipping over the value payloads. Values are read back
+// through ReadAt if rs has it, and under a lock through Seek otherwise.
+func OpenLazy(rs io.ReadSeeker) (*LazyMap, error) {
+	base, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	w := newWireReader(rs, base)
+	id, err := w.nextMessage()
+	if err != nil {
+		return nil, err
+	}
+	wt := w.types[id]
+	if wt == nil || wt.Kind != wireMap {
+		return nil, notMapError(typeString(w.types, id))
+	}
+	if delta, err := w.readUint(); err != nil {
+		return nil, err
+	} else if delta != 0 {
+		return nil, formatErrorf("gob: corrupted data: non-zero delta for singleton")
+	}
+	n, err := w.readCount()
+	if err != nil {
+		return nil, err
+	}
+	ra, ok := rs.(io.ReaderAt)
+	if !ok {
+		ra = &seekReaderAt{rs: rs}
+	}
+	m := &LazyMap{r: ra, types: w.types, elem: wt.Elem}
+	for i := int64(0); i < n; i++ {
+		k, err := w.value(wt.Key, true)
+		if err != nil {
+			return nil, err
+		}
+		e := &LazyEntry{Key: k, Offset: w.off, m: m}
+		if wt.Elem == tInterface {
+			_, e.Type, err = w.iface(false)
+		} else {
+			_, err = w.value(wt.Elem, false)
+			e.Type = typeString(w.types, wt.Elem)
+		}
+		if err != nil {
+			return nil, err
+		}
+		e.Length = w.off - e.Offset
+		m.Entries = append(m.Entries, e)
+	}
+	return m, nil
+}
+
+// Lookup returns the entry for key, or nil if the map has no such key.
+// Keys are compared with reflect.DeepEqual, since a struct key comes back
+// as a map, which == cannot compare.
+func (m *LazyMap) Lookup(key interface{}) *LazyEntry {
+	for _, e := range m.Entries {
+		if reflect.DeepEqual(e.Key, key) {
+			return e
+		}
+	}
+	return nil
+}
+
+// Value decodes the entry's value on first use and caches the result.
+func (e *LazyEntry) Value() (interface{}, error) {
+	e.once.Do(func() {
+		e.val, e.err = e.m.DecodeRange(e.Offset, e.Length)
+	})
+	return e.val, e.err
+}
+
+// DecodeRange decodes the single map value stored in the n bytes at off.
+// Interface values are handed to encoding/gob, so their concrete types must
+// be registered as for a full decode; other values are decoded generically.
+func (m *LazyMap) DecodeRange(off, n int64) (interface{}, error) {
+	raw := make([]byte, n)
+	if _, err := io.ReadFull(io.NewSectionReader(m.r, off, n), raw); err != nil {
+		return nil, fmt.Errorf("reading value at offset %d: %w", off, err)
+	}
+	if m.elem != tInterface {
+		w := newWireReader(bytes.NewReader(raw), off)
+		w.types = m.types
+		w.remain = n
+		return w.value(m.elem, true)
+	}
+
+	var stream bytes.Buffer
+	writeTypeDefs(&stream, m.types, off, off+n)
+	body := appendInt(nil, int64(tInterface))
+	body = append(body, 0) // singleton field delta
+	body = append(body, raw...)
+	stream.Write(appendUint(nil, uint64(len(body))))
+	stream.Write(body)
+
+	var v interface{}
+	if err := gob.NewDecoder(&stream).Decode(&v); err != nil {
+		return nil, fmt.Errorf("decoding value at offset %d: %w", off, err)
+	}
+	return v, nil
+}
+
+// typeString renders a wire type id as a Go-like type name. Unnamed types
+// nested past defaultMaxTypeDepth, which only a crafted stream has, are
+// cut short with "...".
+func typeString(types map[typeID]*wireType, id typeID) string {
+	return typeStringDepth(types, id, defaultMaxTypeDepth)
+}
+
+func typeStringDepth(types map[typeID]*wireType, id typeID, depth int) string {
+	switch id {
+	case tBool:
+		return "bool"
+	case tInt:
+		return "int"
+	case tUint:
+		return "uint"
+	case tFloat:
+		return "float64"
+	case tBytes:
+		return "[]byte"
+	case tString:
+		return "string"
+	case tComplex:
+		return "complex128"
+	case tInterface:
+		return "interface {}"
+	}
+	wt := types[id]
+	if wt == nil {
+		return fmt.Sprintf("type#%d", id)
+	}
+	if wt.Name != "" {
+		return wt.Name
+	}
+	if depth == 0 {
+		return "..."
+	}
+	elem := func(id typeID) string { return typeStringDepth(types, id, depth-1) }
+	switch wt.Kind {
+	case wireArray:
+		return fmt.Sprintf("[%d]%s", wt.Len, elem(wt.Elem))
+	case wireSlice:
+		return "[]" + elem(wt.Elem)
+	case wireMap:
+		return "map[" + elem(wt.Key) + "]" + elem(wt.Elem)
+	}
+	return fmt.Sprintf("type#%d", id)
+}
+
+// openLazyFile opens name as openFile does and scans its top-level map. A
+// plain file is scanned in place and must be closed with closeFile once
+// the map is done with. A pipe, a data: URI or a payload in gzip, base64
+// or another wrapping cannot be read back by offset, so its gob stream is
+// read into memory first.
+func openLazyFile(name string) (m *LazyMap, closeFile func() error, err error) {
+	f, err := openFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	layers := 0
+	r, err := unwrapPayload(f, name, func(layer) { layers++ })
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	rs, seekable := f.(io.ReadSeeker)
+	if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() && seekable && layers == 0 {
+		if _, err := rs.Seek(0, io.SeekStart); err == nil {
+			if m, err = OpenLazy(rs); err != nil {
+				f.Close()
+				return nil, nil, err
+			}
+			return m, f.Close, nil
+		}
+	}
+	defer f.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, payloadError(r, err)
+	}
+	if m, err = OpenLazy(bytes.NewReader(b)); err != nil {
+		return nil, nil, err
+	}
+	return m, func() error { return nil }, nil
+}
+
+// lsFlags registers the flags of ls; the command returned lists the
+// top-level keys of a file without decoding their values.
+func lsFlags(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errors.New("usage: ls file.gob")
+		}
+		m, closeFile, err := openLazyFile(args[0])
+		if err != nil {
+			return err
+		}
+		defer closeFile()
+		for _, e := range m.Entries {
+			fmt.Printf("%v (%T)\t%s\t%d bytes\n", e.Key, e.Key, e.Type, e.Length)
+		}
+		return nil
+	}
+}
+
+// containsFlags registers the flags of contains; the command returned
+// answers whether a file holds a top-level key, scanning keys on the
+// wire rather than decoding values. A nested path falls back to a full
+// decode. The exit status is 0 when found, 1 when not and 2 on error.
+func containsFlags(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "usage: contains file.gob key-or-path")
+			return exitCode(2)
+		}
+		found, err := containsKey(args[0], args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "contains: %v\n", err)
+			return exitCode(2)
+		}
+		if !found {
+			fmt.Printf("not found: %s\n", args[1])
+			return exitCode(1)
+		}
+		return nil
+	}
+}
+
+func containsKey(filename, key string) (bool, error) {
+	m, closeFile, err := openLazyFile(filename)
+	if err == nil {
+		defer closeFile()
+	}
+	var notMap notMapError
+	switch {
+	case errors.As(err, &notMap):
+		// no keys to scan; the path is looked up in the decoded value
+	case err != nil:
+		return false, err
+	default:
+		for _, e := range m.Entries {
+			if fmt.Sprint(e.Key) == key {
+				fmt.Printf("found: %s (%s, %d bytes)\n", key, e.Type, e.Length)
+				return true, nil
+			}
+		}
+		if !strings.ContainsAny(key, ".[") {
+			return false, nil
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s is a nested path, falling back to a full decode\n", key)
+	}
+
+	registerKnownTypes()
+	data, err := decodeAnyFile(filename)
+	if err != nil {
+		return false, err
+	}
+	v, found := lookupPath(data, key)
+	if found {
+		typ := "nil"
+		if v.IsValid() {
+			typ = v.Type().String()
+		}
+		fmt.Printf("found: %s (%s)\n", key, typ)
+	}
+	return found, nil
+}
@@ -0,0 +1,112 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// seekOnly hides everything but Read and Seek, ReadAt included.
+type seekOnly struct{ io.ReadSeeker }
+
+func lazyStream(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestLazyValuesConcurrent is meant for go test -race.
+func TestLazyValuesConcurrent(t *testing.T) {
+	data := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		data[fmt.Sprint("k", i)] = fmt.Sprint("value ", i)
+	}
+	raw := lazyStream(t, data)
+	for name, rs := range map[string]io.ReadSeeker{
+		"ReaderAt": bytes.NewReader(raw),
+		"Seeker":   seekOnly{bytes.NewReader(raw)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := OpenLazy(rs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			for _, e := range m.Entries {
+				for j := 0; j < 4; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						v, err := m.DecodeRange(e.Offset, e.Length)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if want := data[e.Key.(string)]; v != want {
+							t.Errorf("%v: got %v, want %v", e.Key, v, want)
+						}
+					}()
+				}
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestLazyLookupUncomparableKey(t *testing.T) {
+	type point struct{ X, Y int }
+	m, err := OpenLazy(bytes.NewReader(lazyStream(t, map[point]string{{1, 2}: "a", {3, 4}: "b"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := m.Entries[0].Key
+	if e := m.Lookup(key); e != m.Entries[0] {
+		t.Errorf("Lookup(%#v) = %v", key, e)
+	}
+	if e := m.Lookup("absent"); e != nil {
+		t.Errorf("Lookup of an absent key = %v", e)
+	}
+}
+
+func TestContainsKeyInputs(t *testing.T) {
+	raw := lazyStream(t, map[string]int{"alpha": 1, "beta": 2})
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.gob")
+	if err := os.WriteFile(plain, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gz := filepath.Join(dir, "data.gob.gz")
+	if err := os.WriteFile(gz, gzipped(t, raw), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		plain,
+		gz,
+		"data:application/gob;base64," + base64.StdEncoding.EncodeToString(raw),
+	} {
+		found, err := containsKey(name, "beta")
+		if err != nil || !found {
+			t.Errorf("%.40s: found %v, %v", name, found, err)
+		}
+		m, closeFile, err := openLazyFile(name)
+		if err != nil {
+			t.Errorf("%.40s: %v", name, err)
+			continue
+		}
+		if e := m.Lookup("alpha"); e == nil {
+			t.Errorf("%.40s: no alpha", name)
+		} else if v, err := e.Value(); err != nil || v != int64(1) {
+			t.Errorf("%.40s: alpha = %v, %v", name, v, err)
+		}
+		closeFile()
+	}
+}
@@ -0,0 +1,118 @@
+package gobtool
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Main runs the tool on the arguments in os.Args: a command when there
+// are any, and otherwise the demonstration of writing and reading back a
+// sample file.
+func Main() {
+	// 0. 带参数时按子命令处理
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1:])
+		return
+	}
+
+	// 1. 创建一个复杂的 map[interface{}]interface{}
+	data := createSampleData()
+
+	// 2. 将数据编码并写入文件
+	filename := "data.gob"
+	err := encodeAndWriteToFile(data, filename)
+	if err != nil {
+		log.Fatalf("编码写入文件失败: %v", err)
+	}
+	fmt.Printf("数据已成功写入文件: %s\n", filename)
+
+	// 3. 从文件读取并解码数据
+	decoded, err := decodeAnyFile(filename)
+	if err != nil {
+		log.Fatalf("从文件解码失败: %v", err)
+	}
+	decodedData, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		log.Fatalf("顶层值是 %T，不是 map", decoded)
+	}
+
+	// 4. 打印解码后的数据
+	fmt.Println("\n解码后的数据:")
+	printDecodedData(decodedData)
+}
+
+// createSampleData 创建示例数据
+func createSampleData() map[interface{}]interface{} {
+	data := make(map[interface{}]interface{})
+
+	// 添加各种类型的键值对
+	data["name"] = "张三"
+	data[42] = "数字作为键"
+	data[3.14] = "浮点数作为键"
+	data[true] = "布尔值作为键"
+
+	// 嵌套结构
+	nestedMap := map[string]interface{}{
+		"age":    25,
+		"city":   "北京",
+		"active": true,
+	}
+	data["user_info"] = nestedMap
+
+	// 切片作为值
+	data["scores"] = []int{95, 87, 92}
+
+	// 结构体作为值
+	data["point"] = struct {
+		X int
+		Y int
+	}{X: 10, Y: 20}
+
+	return data
+}
+
+// encodeAndWriteToFile 编码数据并写入文件
+// 先写入同目录下的临时文件，成功后再重命名覆盖目标文件，
+// 编码中途失败时目标文件保持不变
+func encodeAndWriteToFile(data interface{}, filename string) error {
+	// 注册可能用到的接口类型（对于基本类型通常不需要，但自定义类型需要）
+	// 对于 interface{} 包含的具体类型，gob 需要知道如何编码
+	// 这里我们注册一些可能用到的具体类型
+	safeRegister(map[string]interface{}{})
+	safeRegister([]interface{}{})
+	safeRegister([]int{})
+	safeRegister(struct {
+		X int
+		Y int
+	}{})
+
+	return writeAtomic(filename, func(file *os.File) error {
+		return writeEncoded(file, data)
+	})
+}
+
+// writeEncoded 把 data 编码写入 file，供 writeAtomic 的回调使用
+func writeEncoded(file *os.File, data interface{}) error {
+	// 带缓冲写入，关闭文件前必须 Flush
+	w := newEncodeWriter(file)
+
+	// 编码并写入文件
+	if err := encodeRoot(gob.NewEncoder(w), data); err != nil {
+		return fmt.Errorf("编码失败: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	return nil
+}
+
+// printDecodedData 打印解码后的数据
+func printDecodedData(data map[interface{}]interface{}) {
+	for key, value := range data {
+		fmt.Printf("键: %v (%T)\n", key, key)
+		fmt.Printf("值: %v (%T)\n", value, value)
+		fmt.Println("---")
+	}
+}
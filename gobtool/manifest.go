@@ -0,0 +1,166 @@
+package gobtool
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestFlags registers the flags of manifest; the command returned
+// prints a sha256sum-style manifest of the content hashes of the gob
+// files under a directory, or checks files against one.
+func manifestFlags(fset *flag.FlagSet) func(args []string) error {
+	verify := fset.String("verify", "", "check the files listed in this manifest")
+	errorsOut := fset.String("errors-out", "", "also write the failures, grouped by class, to this file as JSON")
+	return func(args []string) error {
+		registerKnownTypes()
+		if *verify != "" {
+			if len(args) != 0 {
+				return errors.New("usage: manifest -verify manifest.txt [-errors-out file]")
+			}
+			return verifyManifest(*verify, *errorsOut)
+		}
+		if len(args) != 1 {
+			return errors.New("usage: manifest dir [-errors-out file]")
+		}
+		var failures errorSummary
+		total := 0
+		err := filepath.WalkDir(args[0], func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			total++
+			hash, err := fileContentHash(p)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "manifest: %s: %v\n", p, err)
+				failures.add(p, err)
+				return nil
+			}
+			fmt.Printf("%s  %s\n", hash, p)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return failures.finish(total, *errorsOut, "files could not be decoded")
+	}
+}
+
+// finish reports the failures of a manifest run: a summary by class on
+// stderr, and as JSON to errorsOut if set. It fails if any file did.
+func (s *errorSummary) finish(total int, errorsOut, what string) error {
+	if errorsOut != "" {
+		if err := s.writeFile(errorsOut); err != nil {
+			return err
+		}
+	}
+	if s.Failed == 0 {
+		return nil
+	}
+	if err := s.write(os.Stderr, total); err != nil {
+		return err
+	}
+	return fmt.Errorf("%d %s", s.Failed, what)
+}
+
+// fileContentHash decodes a gob file, whatever its root, and returns its
+// hex CanonicalHash.
+func fileContentHash(name string) (string, error) {
+	data, err := decodeAnyFile(name)
+	if err != nil {
+		return "", err
+	}
+	hash, err := CanonicalHash(data)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// verifyManifest re-decodes every file in the manifest and reports those
+// whose content no longer matches, in the manner of sha256sum -c.
+func verifyManifest(name, errorsOut string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var failures errorSummary
+	total := 0
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		want, file, ok := strings.Cut(sc.Text(), "  ")
+		if !ok {
+			return fmt.Errorf("%s:%d: malformed line", name, line)
+		}
+		total++
+		got, err := fileContentHash(file)
+		switch {
+		case err != nil:
+			fmt.Printf("%s: FAILED (%v)\n", file, err)
+			failures.add(file, err)
+		case got != want:
+			fmt.Printf("%s: FAILED\n", file)
+			failures.add(file, ErrChecksumMismatch)
+		default:
+			fmt.Printf("%s: OK\n", file)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return failures.finish(total, errorsOut, "files did not match")
+}
+
+// hashFlags registers the flags of hash; the command returned prints the
+// canonical hash of each file, optionally writing it to a .sha256
+// sidecar, or checks files against their sidecars.
+func hashFlags(fset *flag.FlagSet) func(args []string) error {
+	write := fset.Bool("w", false, "also write each hash to a file.sha256 sidecar")
+	verify := fset.Bool("verify", false, "check each file against its .sha256 sidecar")
+	return func(args []string) error {
+		if len(args) == 0 {
+			return errors.New("usage: hash [-w | -verify] file.gob...")
+		}
+		registerKnownTypes()
+		bad := 0
+		for _, name := range args {
+			hash, err := fileContentHash(name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			line := fmt.Sprintf("%s  %s\n", hash, filepath.Base(name))
+			switch {
+			case *verify:
+				side, err := os.ReadFile(name + ".sha256")
+				if err != nil {
+					return err
+				}
+				want, _, _ := strings.Cut(string(side), " ")
+				if want == hash {
+					fmt.Printf("%s: OK\n", name)
+				} else {
+					fmt.Printf("%s: FAILED\n", name)
+					bad++
+				}
+			case *write:
+				if err := writeFileAtomic(name+".sha256", []byte(line)); err != nil {
+					return err
+				}
+				fallthrough
+			default:
+				fmt.Printf("%s  %s\n", hash, name)
+			}
+		}
+		if bad > 0 {
+			return fmt.Errorf("%d files did not match", bad)
+		}
+		return nil
+	}
+}
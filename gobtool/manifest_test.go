@@ -0,0 +1,53 @@
+package gobtool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifestVerify(t *testing.T) {
+	registerKnownTypes()
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.gob"), filepath.Join(dir, "b.gob")
+	dataA := map[string]interface{}{"x": 1, "y": "two", "z": []int{3}}
+	for name, data := range map[string]interface{}{a: dataA, b: map[string]interface{}{"b": true}} {
+		if err := encodeAndWriteToFile(data, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := runCaptured(t, "manifest", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "  "+a) || !strings.HasSuffix(lines[1], "  "+b) {
+		t.Fatalf("manifest:\n%s", out)
+	}
+	manifest := filepath.Join(t.TempDir(), "manifest.txt")
+	if err := os.WriteFile(manifest, []byte(out), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writing the same content again, in whatever order gob picks for the
+	// map this time, still matches.
+	if err := encodeAndWriteToFile(dataA, a); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCaptured(t, "manifest", "-verify", manifest)
+	if want := a + ": OK\n" + b + ": OK\n"; err != nil || out != want {
+		t.Errorf("untouched files: got %q, %v; want %q", out, err, want)
+	}
+
+	if err := encodeAndWriteToFile(map[string]interface{}{"b": false}, b); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runCaptured(t, "manifest", "-verify", manifest)
+	if want := a + ": OK\n" + b + ": FAILED\n"; out != want {
+		t.Errorf("tampered file: got %q, want %q", out, want)
+	}
+	if err == nil || err.Error() != "1 files did not match" {
+		t.Errorf("tampered file: got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package gobtool
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// migrate is the upgrade path for old gob archives: it reads a file in
+// any form decode accepts (plain, gzip, store reference, behind a
+// header), optionally transforms it, and writes it out again in the form
+// chosen now. Every record is migrated, each as the Go type it was sent
+// as, a record-count footer is kept, and the output is checked before it
+// replaces anything.
+
+// migrateFlags registers the flags of migrate; the command returned
+// implements "migrate in.gob out.gob".
+func migrateFlags(fs *flag.FlagSet) func(args []string) error {
+	var header headerFlags
+	header.register(fs)
+	transformFile := fs.String("transform-file", "", "apply the transform rules in this file before writing")
+	gz := fs.Bool("gzip", false, "write the output gzip-compressed")
+	store := fs.String("store", "", "keep the payload in this content-addressed store and write a reference")
+	return func(args []string) error {
+		if len(args) != 2 {
+			return errors.New("usage: migrate [-skip-header N] [-expect-magic hex] [-transform-file rules.yaml] [-gzip | -store dir] in.gob out.gob")
+		}
+		if *gz && *store != "" {
+			return errors.New("-gzip cannot be combined with -store")
+		}
+		in, out := args[0], args[1]
+
+		var transforms []TransformRule
+		if *transformFile != "" {
+			var err error
+			if transforms, _, err = readTransformFile(*transformFile); err != nil {
+				return err
+			}
+		}
+
+		registerKnownTypes()
+		f, err := openFile(in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r, err := applyReaderMiddleware(f,
+			[]ReaderMiddleware{header.middleware, payloadMiddleware(in)})
+		if err != nil {
+			return fmt.Errorf("%s: %w", in, err)
+		}
+		values, types, counted, err := readTypedStream(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", in, payloadError(r, err))
+		}
+		transformed := 0
+		for _, v := range values {
+			paths, err := ApplyTransforms(v, transforms, false)
+			if err != nil {
+				return err
+			}
+			transformed += len(paths)
+		}
+		data, err := encodeStream(values, types, counted)
+		if err != nil {
+			return err
+		}
+		// Read the result back as a reader of the original types would, and
+		// check it holds what was meant to be written, before anything is
+		// replaced.
+		verify := func(r io.Reader) error {
+			if err := verifyTyped(r, values, types, counted); err != nil {
+				return fmt.Errorf("verify %s: %w", out, err)
+			}
+			return nil
+		}
+
+		switch {
+		case *store != "":
+			// A store reference holds one value, sent bare.
+			if len(values) != 1 {
+				return fmt.Errorf("-store keeps a single value, and %s holds %d records", in, len(values))
+			}
+			if types[0] == interfaceType {
+				return fmt.Errorf("-store keeps a value sent bare, and %s holds one sent as an interface", in)
+			}
+			if err := verify(bytes.NewReader(data)); err != nil {
+				return err
+			}
+			root, err := retype(types[0], values[0])
+			if err != nil {
+				return err
+			}
+			ref, err := EncodeToStore(*store, out, root)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s -> %s (%d bytes)\n", out, ref.Hash, ref.Size)
+		default:
+			err = writeAtomic(out, func(file *os.File) error {
+				if *gz {
+					zw, _ := gzipMiddleware(file)
+					if _, err := zw.Write(data); err != nil {
+						return err
+					}
+					if err := zw.Close(); err != nil {
+						return err
+					}
+				} else if _, err := file.Write(data); err != nil {
+					return err
+				}
+				back, err := os.Open(file.Name())
+				if err != nil {
+					return err
+				}
+				defer back.Close()
+				r, err := openPayload(back, file.Name())
+				if err != nil {
+					return fmt.Errorf("verify %s: %w", out, err)
+				}
+				return verify(r)
+			})
+		}
+		if err != nil {
+			return err
+		}
+		summary := rootSummary(values[0])
+		if len(values) > 1 {
+			summary = fmt.Sprintf("%d records", len(values))
+		}
+		fmt.Printf("%s -> %s: %s, %d values transformed\n", in, out, summary, transformed)
+		return nil
+	}
+}
@@ -0,0 +1,121 @@
+package gobtool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateToGzip(t *testing.T) {
+	registerKnownTypes()
+	in := writeRoot(t, syntheticData(50, 1))
+	out := filepath.Join(t.TempDir(), "out.gob.gz")
+	if _, err := runCaptured(t, "migrate", "-gzip", in, out); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(raw, []byte{0x1f, 0x8b}) {
+		t.Errorf("output is not gzip: % x", raw[:min(len(raw), 8)])
+	}
+	want, err := decodeAnyFile(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeAnyFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Error("the gzip output decodes to different data")
+	}
+}
+
+type migrateEvent struct {
+	Name string
+	At   int64
+}
+
+// Every record is migrated, each still decodable by encoding/gob as the
+// type it was sent as.
+func TestMigrateStreamKeepsTypes(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "ev.gob")
+	out := filepath.Join(dir, "out.gob.gz")
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for i := 0; i < 3; i++ {
+		if err := enc.Encode(migrateEvent{Name: fmt.Sprintf(" e%d ", i), At: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(in, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rules := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(rules, []byte("- path: Name\n  transform: trim\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCaptured(t, "migrate", "-gzip", "-transform-file", rules, in, out); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := gob.NewDecoder(zr)
+	for i := 0; i < 3; i++ {
+		var ev migrateEvent
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if want := (migrateEvent{Name: fmt.Sprintf("e%d", i), At: int64(i)}); ev != want {
+			t.Errorf("record %d = %+v, want %+v", i, ev, want)
+		}
+	}
+	if err := dec.Decode(new(migrateEvent)); err != io.EOF {
+		t.Errorf("after three records: %v, want EOF", err)
+	}
+}
+
+// A record that cannot be written back as its type fails the migration
+// and leaves the output as it was.
+func TestMigrateFailureKeepsOutput(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "ev.gob")
+	out := filepath.Join(dir, "out.gob")
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(migrateEvent{Name: "e", At: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(in, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// unix-time makes At a time.Time, which does not fit its int64 field
+	rules := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(rules, []byte("- path: At\n  transform: unix-time\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCaptured(t, "migrate", "-transform-file", rules, in, out); err == nil {
+		t.Fatal("no error")
+	}
+	if b, _ := os.ReadFile(out); string(b) != "old" {
+		t.Errorf("out.gob is now %q", b)
+	}
+}
@@ -0,0 +1,114 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Gob messages exchanged over sockets are sometimes framed as netstrings,
+// "<len>:<data>,", with each frame holding a complete gob stream, type
+// definitions included, so frames decode independently.
+
+// maxNetstring bounds the frame length a reader will allocate for.
+const maxNetstring = 64 << 20
+
+// NetstringDecoder reads netstring-framed gob values from a stream.
+type NetstringDecoder struct {
+	r   *bufio.Reader
+	off int64 // stream offset of the next frame, for errors
+}
+
+// NewNetstringDecoder returns a NetstringDecoder reading frames from r.
+func NewNetstringDecoder(r io.Reader) *NetstringDecoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &NetstringDecoder{r: br}
+}
+
+// Decode reads the next frame and decodes its gob stream into v, which
+// must be a pointer. It returns io.EOF when the stream ends cleanly
+// between frames.
+func (d *NetstringDecoder) Decode(v interface{}) error {
+	start := d.off
+	frame, err := d.frame()
+	if err != nil {
+		return err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(frame)).Decode(v); err != nil {
+		return fmt.Errorf("netstring at offset %d: %w", start, err)
+	}
+	return nil
+}
+
+// frame reads one netstring and returns its payload.
+func (d *NetstringDecoder) frame() ([]byte, error) {
+	start := d.off
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("netstring at offset %d: %s", start, fmt.Sprintf(format, args...))
+	}
+	head, err := d.r.ReadSlice(':')
+	switch {
+	case err == io.EOF && len(head) == 0:
+		return nil, io.EOF
+	case errors.Is(err, bufio.ErrBufferFull):
+		return nil, fail("length prefix too long")
+	case err == io.EOF:
+		return nil, fail("truncated length prefix %q", head)
+	case err != nil:
+		return nil, err
+	}
+	digits := head[:len(head)-1]
+	n, err := strconv.Atoi(string(digits))
+	switch {
+	case err != nil || n < 0 || len(digits) == 0 || digits[0] == '+':
+		return nil, fail("bad length prefix %q", digits)
+	case len(digits) > 1 && digits[0] == '0':
+		return nil, fail("length %q has leading zeros", digits)
+	case n > maxNetstring:
+		return nil, fail("length %d is over the %d-byte limit", n, maxNetstring)
+	}
+	frame := make([]byte, n+1)
+	if _, err := io.ReadFull(d.r, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fail("reading %d-byte payload: %v", n, err)
+	}
+	if frame[n] != ',' {
+		return nil, fail("payload of %d bytes is followed by %q, not ','", n, frame[n])
+	}
+	d.off += int64(len(head) + n + 1)
+	return frame[:n], nil
+}
+
+// DecodeNetstring reads a single netstring-framed gob value from r into
+// v. Unlike a NetstringDecoder it may read past the frame, so use one of
+// those to read several.
+func DecodeNetstring(r io.Reader, v interface{}) error {
+	err := NewNetstringDecoder(r).Decode(v)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// EncodeNetstring writes v to w as a gob stream framed as a netstring.
+func EncodeNetstring(w io.Writer, v interface{}) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%d:", buf.Len()); err != nil {
+		return err
+	}
+	buf.WriteByte(',')
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,74 @@
+package gobtool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNetstringRoundTrip(t *testing.T) {
+	values := []map[string]int{{"a": 1}, {"b": 2, "c": 3}, {}}
+	var buf bytes.Buffer
+	for _, v := range values {
+		if err := EncodeNetstring(&buf, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewNetstringDecoder(&buf)
+	for i, want := range values {
+		var got map[string]int
+		if err := d.Decode(&got); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if len(want) > 0 && !reflect.DeepEqual(got, want) {
+			t.Errorf("frame %d: got %v, want %v", i, got, want)
+		}
+	}
+	var v map[string]int
+	if err := d.Decode(&v); err != io.EOF {
+		t.Errorf("after the last frame: got %v, want io.EOF", err)
+	}
+}
+
+func TestNetstringErrors(t *testing.T) {
+	var frame bytes.Buffer
+	if err := EncodeNetstring(&frame, map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	good := frame.String()
+	for _, c := range []struct {
+		in   string
+		want string
+	}{
+		{"abc:x,", `bad length prefix "abc"`},
+		{":x,", `bad length prefix ""`},
+		{"+1:x,", `bad length prefix "+1"`},
+		{"01:x,", `leading zeros`},
+		{"99999999999:", "over the"},
+		{"5:ab", "reading 5-byte payload: unexpected EOF"},
+		{"1:ab", `followed by 'b', not ','`},
+		{"12", `truncated length prefix "12"`},
+		{good + "3:x", fmt.Sprintf("netstring at offset %d: reading 3-byte payload", len(good))},
+	} {
+		d := NewNetstringDecoder(strings.NewReader(c.in))
+		var err error
+		for err == nil {
+			var v map[string]int
+			err = d.Decode(&v)
+		}
+		if errors.Is(err, io.EOF) || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q: got %v, want an error containing %q", c.in, err, c.want)
+		}
+	}
+}
+
+func TestDecodeNetstringEmpty(t *testing.T) {
+	var v map[string]int
+	if err := DecodeNetstring(strings.NewReader(""), &v); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,249 @@
+package gobtool
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Which integer type a value comes out as follows gob's rules. gob sends
+// the concrete type's name with every value held in an interface, so
+// decoding into interface{} gives back the type that was sent: an int
+// stays int and an int64 stays int64. Typed fields and elements take
+// their Go type. A file written with both therefore shows both, and the
+// tree printer and type histogram report each value's type as decoded.
+// Only the untyped wire view (wireReader, as used by session head) turns
+// every signed integer into int64 and every unsigned one into uint64.
+// -normalize-ints makes the decoded output uniform.
+
+var int64Type = reflect.TypeOf(int64(0))
+
+// normalizeInts returns a copy of data with its integers turned into
+// int64: values held in interfaces, map keys, and the elements and struct
+// fields of typed containers, whose types change to match. A struct type
+// with an integer field in it is rebuilt as an unnamed struct of its
+// exported fields, which are all gob sends. Byte slices and arrays stay
+// as they are, and so do unsigned values too large for an int64 where an
+// interface can hold them as uint64. It is an error if two keys of a map
+// become equal, a typed unsigned value does not fit, or a type to rebuild
+// refers to itself, which reflect cannot construct.
+func normalizeInts(data interface{}) (interface{}, error) {
+	n := intNormalizer{guard: cycleGuard{}, types: make(map[reflect.Type]reflect.Type)}
+	v, err := n.value(reflect.ValueOf(data))
+	if err != nil || !v.IsValid() {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+type intNormalizer struct {
+	guard cycleGuard
+	types map[reflect.Type]reflect.Type // normalized types, nil while being built
+}
+
+// typ returns t with its integer types replaced by int64, or t itself if
+// it has none.
+func (n intNormalizer) typ(t reflect.Type) (reflect.Type, error) {
+	if nt, ok := n.types[t]; ok {
+		if nt == nil {
+			return nil, fmt.Errorf("cannot normalize the integers of recursive type %s", t)
+		}
+		return nt, nil
+	}
+	n.types[t] = nil
+	nt, err := n.buildType(t)
+	if err != nil {
+		delete(n.types, t)
+		return nil, err
+	}
+	n.types[t] = nt
+	return nt, nil
+}
+
+func (n intNormalizer) buildType(t reflect.Type) (reflect.Type, error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64Type, nil
+	case reflect.Pointer:
+		elem, err := n.typ(t.Elem())
+		if err != nil || elem == t.Elem() {
+			return t, err
+		}
+		return reflect.PointerTo(elem), nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return t, nil // bytes stay bytes
+		}
+		elem, err := n.typ(t.Elem())
+		if err != nil || elem == t.Elem() {
+			return t, err
+		}
+		if t.Kind() == reflect.Array {
+			return reflect.ArrayOf(t.Len(), elem), nil
+		}
+		return reflect.SliceOf(elem), nil
+	case reflect.Map:
+		key, err := n.typ(t.Key())
+		if err != nil {
+			return nil, err
+		}
+		elem, err := n.typ(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if key == t.Key() && elem == t.Elem() {
+			return t, nil
+		}
+		return reflect.MapOf(key, elem), nil
+	case reflect.Struct:
+		var fields []reflect.StructField
+		changed := false
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			ft, err := n.typ(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || ft != f.Type
+			fields = append(fields, reflect.StructField{Name: f.Name, Type: ft, Tag: f.Tag})
+		}
+		if !changed {
+			return t, nil
+		}
+		return reflect.StructOf(fields), nil
+	}
+	return t, nil
+}
+
+// value normalizes v, a value of any type, as held in an interface.
+func (n intNormalizer) value(v reflect.Value) (reflect.Value, error) {
+	if !v.IsValid() {
+		return v, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, nil
+		}
+		return n.value(v.Elem())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return v, nil
+		}
+	}
+	t, err := n.typ(v.Type())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return n.convert(v, t)
+}
+
+// convert returns v as a value of type to, its normalized type.
+func (n intNormalizer) convert(v reflect.Value, to reflect.Type) (reflect.Value, error) {
+	if k, ok := guardKeyOf(v); ok {
+		if n.guard[k] {
+			if v.Type() == to {
+				return v, nil
+			}
+			return reflect.Value{}, fmt.Errorf("cannot normalize the integers of a cyclic %s", v.Type())
+		}
+		n.guard[k] = true
+		defer delete(n.guard, k)
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		out := reflect.New(to).Elem()
+		if !v.IsNil() {
+			nv, err := n.value(v.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if !nv.Type().AssignableTo(to) {
+				nv = v.Elem() // the normalized type does not implement to
+			}
+			out.Set(nv)
+		}
+		return out, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.ValueOf(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return reflect.Value{}, fmt.Errorf("%s value %d does not fit in an int64", v.Type(), v.Uint())
+		}
+		return reflect.ValueOf(int64(v.Uint())), nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return reflect.Zero(to), nil
+		}
+		elem, err := n.convert(v.Elem(), to.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(to.Elem())
+		p.Elem().Set(elem)
+		return p, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(to), nil
+		}
+		m := reflect.MakeMapWithSize(to, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k, err := n.convert(iter.Key(), to.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if m.MapIndex(k).IsValid() {
+				return reflect.Value{}, fmt.Errorf("map keys %v and %v are the same once normalized; leave out -normalize-ints", k, iter.Key())
+			}
+			e, err := n.convert(iter.Value(), to.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			m.SetMapIndex(k, e)
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v, nil
+		}
+		var s reflect.Value
+		if v.Kind() == reflect.Array {
+			s = reflect.New(to).Elem()
+		} else if v.IsNil() {
+			return reflect.Zero(to), nil
+		} else {
+			s = reflect.MakeSlice(to, v.Len(), v.Len())
+		}
+		for i := 0; i < v.Len(); i++ {
+			e, err := n.convert(v.Index(i), to.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s.Index(i).Set(e)
+		}
+		return s, nil
+	case reflect.Struct:
+		s := reflect.New(to).Elem()
+		if to == v.Type() {
+			s.Set(v) // for the unexported fields
+		}
+		for i := 0; i < to.NumField(); i++ {
+			f := to.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			e, err := n.convert(v.FieldByName(f.Name), f.Type)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s.Field(i).Set(e)
+		}
+		return s, nil
+	}
+	return v, nil
+}
@@ -0,0 +1,139 @@
+package gobtool
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// intTypes collects the types of every integer in v.
+func intTypes(v reflect.Value, seen map[string]bool) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if !v.IsNil() {
+			intTypes(v.Elem(), seen)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			intTypes(iter.Key(), seen)
+			intTypes(iter.Value(), seen)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				intTypes(v.Index(i), seen)
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				intTypes(v.Field(i), seen)
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		seen[v.Type().String()] = true
+	}
+}
+
+type normalizeRecord struct {
+	Count  int
+	Sizes  []uint16
+	ByID   map[int32]string
+	Nested *normalizeInner
+	secret int
+}
+
+type normalizeInner struct{ Count int }
+
+type normalizeNode struct {
+	N    int
+	Next *normalizeNode
+}
+
+func TestNormalizeIntsUniform(t *testing.T) {
+	data := map[interface{}]interface{}{
+		int(1):    int8(2),
+		int64(2):  uint32(3),
+		"typed":   map[int]int{1: 2},
+		"slice":   []interface{}{int16(4), uint(5)},
+		"record":  normalizeRecord{Count: 6, Sizes: []uint16{7}, ByID: map[int32]string{8: "x"}, Nested: &normalizeInner{Count: 9}, secret: 10},
+		"ptr":     &normalizeRecord{Count: 11},
+		"array":   [2]int32{12, 13},
+		"bytes":   []byte{14},
+		"sha":     [4]byte{15},
+		"when":    time.Unix(16, 0),
+		uint8(17): "small key",
+	}
+	got, err := normalizeInts(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	intTypes(reflect.ValueOf(got), seen)
+	delete(seen, "int64")
+	if len(seen) > 0 {
+		t.Errorf("integers left as %v", seen)
+	}
+	m := got.(map[interface{}]interface{})
+	if m[int64(1)] != int64(2) || m[int64(2)] != int64(3) || m[int64(17)] != "small key" {
+		t.Errorf("keys or values not normalized: %#v", m)
+	}
+	rec := reflect.ValueOf(m["record"])
+	if rec.FieldByName("Count").Interface() != int64(6) || rec.FieldByName("Nested").Elem().FieldByName("Count").Interface() != int64(9) {
+		t.Errorf("record = %#v", m["record"])
+	}
+	if _, ok := rec.Type().FieldByName("secret"); ok {
+		t.Error("the rebuilt struct kept an unexported field")
+	}
+	if !reflect.DeepEqual(m["bytes"], []byte{14}) || m["sha"] != [4]byte{15} {
+		t.Error("bytes were changed")
+	}
+	if !m["when"].(time.Time).Equal(time.Unix(16, 0)) {
+		t.Error("time.Time was changed")
+	}
+}
+
+func TestNormalizeIntsLargeUnsigned(t *testing.T) {
+	got, err := normalizeInts([]interface{}{uint64(math.MaxUint64)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.([]interface{})[0] != uint64(math.MaxUint64) {
+		t.Errorf("a uint64 too large for int64 became %#v", got)
+	}
+	if _, err := normalizeInts([]uint64{math.MaxUint64}); err == nil {
+		t.Error("a typed uint64 too large for int64: no error")
+	}
+}
+
+func TestNormalizeIntsKeyCollision(t *testing.T) {
+	_, err := normalizeInts(map[interface{}]interface{}{int(1): "a", int64(1): "b"})
+	if err == nil || !strings.Contains(err.Error(), "same once normalized") {
+		t.Errorf("got %v, want a key collision error", err)
+	}
+}
+
+func TestNormalizeIntsCycle(t *testing.T) {
+	m := map[string]interface{}{"n": 1}
+	m["self"] = m
+	got, err := normalizeInts(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.(map[string]interface{})["n"] != int64(1) {
+		t.Errorf("got %#v", got)
+	}
+}
+
+// StructOf cannot build a recursive type, so a value of one is refused
+// rather than left with its integer types.
+func TestNormalizeIntsRecursiveType(t *testing.T) {
+	_, err := normalizeInts(map[string]interface{}{"list": &normalizeNode{N: 1}})
+	if err == nil || !strings.Contains(err.Error(), "recursive type") {
+		t.Errorf("got %v, want a recursive type error", err)
+	}
+}
@@ -0,0 +1,246 @@
+package gobtool
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// optimize gathers the values of many small gob streams, such as one
+// file per snapshot or a netstring-framed log whose every frame repeats
+// its type definitions, and writes them again as one record stream with a
+// single encoder, so each type is described once. Every value goes out as
+// the type it was sent as, bare or as an interface, as typed.go describes.
+// It reports how the bytes split between type definitions and data before
+// and after, and reads the output back to check every value has its
+// original type and is deeply equal to the original before writing it.
+// Output that would be larger than the input is refused: it defeats the
+// point and usually means the inputs were already one stream.
+
+// layoutStats is the byte breakdown of a set of gob streams.
+type layoutStats struct {
+	Values    int
+	Streams   int
+	FileBytes int64 // as stored: compressed, framed
+	GobBytes  int64 // of the gob streams themselves
+	TypeBytes int64 // of the type definitions within them
+}
+
+func (s layoutStats) String() string {
+	pct := 0.0
+	if s.GobBytes > 0 {
+		pct = 100 * float64(s.TypeBytes) / float64(s.GobBytes)
+	}
+	return fmt.Sprintf("%d values in %d %s, %d bytes (gob %d: descriptors %d, %.1f%%; data %d)",
+		s.Values, s.Streams, plural(int64(s.Streams), "stream", "streams"), s.FileBytes,
+		s.GobBytes, s.TypeBytes, pct, s.GobBytes-s.TypeBytes)
+}
+
+func optimizeFlags(fs *flag.FlagSet) func(args []string) error {
+	out := fs.String("out", "", "write the optimized stream to this file")
+	gz := fs.Bool("gzip", false, "gzip the output")
+	framed := fs.Bool("framed", false, "wrap the output in a netstring frame, as a framed log holds it")
+	return func(args []string) error {
+		if len(args) == 0 || *out == "" {
+			return errors.New("usage: optimize [-gzip] [-framed] -out out.gob file-or-dir...")
+		}
+
+		registerKnownTypes()
+		files, err := optimizeInputs(args)
+		if err != nil {
+			return err
+		}
+		var values []interface{}
+		var types []reflect.Type
+		var before layoutStats
+		for _, name := range files {
+			if err := readLayout(name, &before, func(rec interface{}, t reflect.Type) {
+				values = append(values, rec)
+				types = append(types, t)
+			}); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if len(values) == 0 {
+			return errors.New("no values to optimize")
+		}
+
+		var buf bytes.Buffer
+		if err := encodeShared(&buf, values, types); err != nil {
+			return err
+		}
+		data := buf.Bytes()
+		if *framed {
+			data = append(fmt.Appendf(nil, "%d:", len(data)), append(data, ',')...)
+		}
+		if *gz {
+			var zbuf bytes.Buffer
+			zw := gzip.NewWriter(&zbuf)
+			zw.Write(data)
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			data = zbuf.Bytes()
+		}
+
+		// Read the output back as any reader would before replacing anything.
+		var after layoutStats
+		if err := verifyLayout(data, values, types, &after); err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+		if after.FileBytes > before.FileBytes {
+			return fmt.Errorf("output would be %d bytes, larger than the %d of the input; not written", after.FileBytes, before.FileBytes)
+		}
+		if err := writeFileAtomic(*out, data); err != nil {
+			return err
+		}
+
+		fmt.Printf("before: %s\n", before)
+		fmt.Printf("after:  %s\n", after)
+		saved := before.FileBytes - after.FileBytes
+		fmt.Printf("saved %d bytes (%.1f%%)\n", saved, 100*float64(saved)/float64(max(before.FileBytes, 1)))
+		fmt.Printf("verified: %d values read back deeply equal, as their original types\n", len(values))
+		return nil
+	}
+}
+
+// encodeShared encodes values onto w with one encoder, each as the type
+// readLayout gave it: bare like the originals, or as an interface for
+// values that were sent as one.
+func encodeShared(w io.Writer, values []interface{}, types []reflect.Type) error {
+	enc := gob.NewEncoder(w)
+	for i, v := range values {
+		if err := encodeTyped(enc, types[i], v); err != nil {
+			return fmt.Errorf("value %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// verifyLayout reads data back with readLayoutBytes, adding its layout to
+// st, and checks it holds values and nothing else, each deeply equal to
+// the original and of the same type. The types compare as rebuilt from
+// the wire, so a value written as any other wire type than it was read
+// as, such as a struct written as a map, is an error.
+func verifyLayout(data []byte, values []interface{}, types []reflect.Type, st *layoutStats) error {
+	var err error
+	i := 0
+	rerr := readLayoutBytes(data, st, func(rec interface{}, t reflect.Type) {
+		switch {
+		case err != nil || i >= len(values):
+		case t != types[i]:
+			err = fmt.Errorf("value %d reads back as %s, not %s", i, t, types[i])
+		case !reflect.DeepEqual(rec, values[i]):
+			err = fmt.Errorf("value %d differs after re-encoding", i)
+		}
+		i++
+	})
+	if rerr != nil {
+		return rerr
+	}
+	if err != nil {
+		return err
+	}
+	if i != len(values) {
+		return fmt.Errorf("%d values read back, want %d", i, len(values))
+	}
+	return nil
+}
+
+// optimizeInputs expands directories among args into the regular files
+// they hold, in name order, leaving out hidden ones.
+func optimizeInputs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, e := range entries {
+			if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+				names = append(names, filepath.Join(arg, e.Name()))
+			}
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	return files, nil
+}
+
+// readLayout reads every value of the named file into fn, with the type
+// nextWithType gives it, adding the file's layout to st.
+func readLayout(name string, st *layoutStats, fn func(rec interface{}, t reflect.Type)) error {
+	f, err := openFile(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return readLayoutBytes(raw, st, fn)
+}
+
+// readLayoutBytes is readLayout for a file's contents: a gob stream, or a
+// netstring-framed log of them, possibly compressed or behind any other
+// layer openPayload removes.
+func readLayoutBytes(raw []byte, st *layoutStats, fn func(rec interface{}, t reflect.Type)) error {
+	st.FileBytes += int64(len(raw))
+	r, err := openPayload(bytes.NewReader(raw), "")
+	if err != nil {
+		return err
+	}
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return payloadError(r, err)
+	}
+	streams := [][]byte{payload}
+	if spans := netstringSpans(payload); spans != nil {
+		streams = streams[:0]
+		d := NewNetstringDecoder(bytes.NewReader(payload))
+		for range spans {
+			frame, err := d.frame()
+			if err != nil {
+				return err
+			}
+			streams = append(streams, frame)
+		}
+	}
+	for _, s := range streams {
+		rr := newRecordReader(bytes.NewReader(s))
+		for {
+			rec, t, err := rr.nextWithType()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("value %d: %w", st.Values, err)
+			}
+			fn(rec, t)
+			st.Values++
+		}
+		st.Streams++
+		st.GobBytes += rr.w.off
+		st.TypeBytes += rr.w.typeBytes
+	}
+	return nil
+}
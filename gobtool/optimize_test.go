@@ -0,0 +1,121 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type optimizeEvent struct {
+	ID   int
+	Tags []string
+}
+
+// writeOptimizeInputs writes each event to a stream of its own in dir and
+// returns their names.
+func writeOptimizeInputs(t *testing.T, dir string, events []optimizeEvent) []string {
+	t.Helper()
+	var names []string
+	for i, e := range events {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Join(dir, fmt.Sprintf("%02d.gob", i))
+		if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// TestOptimizeKeepsTypes checks that the values of many streams go out
+// as their original type, which a reader of that type decodes.
+func TestOptimizeKeepsTypes(t *testing.T) {
+	events := []optimizeEvent{{1, []string{"a"}}, {2, nil}, {3, []string{"b", "c"}}}
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	if err := os.Mkdir(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeOptimizeInputs(t, in, events)
+	out := filepath.Join(dir, "out.gob")
+	if _, err := runCaptured(t, "optimize", "-out", out, in); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(f)
+	for i, want := range events {
+		var got optimizeEvent
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("value %d = %#v, want %#v", i, got, want)
+		}
+	}
+	var extra optimizeEvent
+	if err := dec.Decode(&extra); !errors.Is(err, io.EOF) {
+		t.Errorf("after the values: %v, want EOF", err)
+	}
+}
+
+// TestOptimizeRefusesLarger checks that output larger than its input, as
+// gzip makes a stream this small, is not written.
+func TestOptimizeRefusesLarger(t *testing.T) {
+	dir := t.TempDir()
+	in := writeOptimizeInputs(t, dir, []optimizeEvent{{1, []string{"a"}}})
+	out := filepath.Join(dir, "out.gob")
+	_, err := runCaptured(t, "optimize", "-gzip", "-out", out, in[0])
+	if err == nil || !strings.Contains(err.Error(), "larger") {
+		t.Fatalf("err = %v, want the output refused as larger", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("output was written: %v", err)
+	}
+}
+
+// TestOptimizeRefusesRetyped checks that output holding a value as
+// another wire type than it was read as fails verification, even though
+// the value reads back deeply equal: here a struct written as the map it
+// reads as.
+func TestOptimizeRefusesRetyped(t *testing.T) {
+	var src bytes.Buffer
+	if err := gob.NewEncoder(&src).Encode(optimizeEvent{1, []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	var values []interface{}
+	var types []reflect.Type
+	var st layoutStats
+	if err := readLayoutBytes(src.Bytes(), &st, func(rec interface{}, t reflect.Type) {
+		values = append(values, rec)
+		types = append(types, t)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var retyped bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&retyped), values[0]); err != nil {
+		t.Fatal(err)
+	}
+	err := verifyLayout(retyped.Bytes(), values, types, &layoutStats{})
+	if err == nil || !strings.Contains(err.Error(), "reads back as") {
+		t.Fatalf("err = %v, want the value reported as retyped", err)
+	}
+	if err := verifyLayout(src.Bytes(), values, types, &layoutStats{}); err != nil {
+		t.Errorf("the original stream: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package gobtool
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Decode and Encode are the library entry points; everything else about
+// how bytes reach the gob codec is an option. Middleware runs in the
+// order given, on both sides, with the first entry nearest the file: on
+// decode the first reader middleware reads the raw input and each later
+// one reads the output of the one before, and on encode the first writer
+// middleware writes to the raw output and each later one writes into the
+// one before. So the same list, such as [decrypt, gunzip] and
+// [encrypt, gzip], describes both directions of one format.
+//
+// On decode, limits apply to the bytes the codec sees, after all
+// middleware; types are registered before decoding starts; transforms
+// run on the decoded result in the order given.
+
+// ReaderMiddleware wraps the reader a decode reads from.
+type ReaderMiddleware func(io.Reader) (io.Reader, error)
+
+// WriterMiddleware wraps the writer an encode writes to. Close flushes
+// whatever the middleware holds back; it must not close the writer it
+// was given.
+type WriterMiddleware func(io.Writer) (io.WriteCloser, error)
+
+// Limits bounds the resources a decode may use. Zero fields are
+// unlimited.
+type Limits struct {
+	MaxBytes int64 // gob bytes read, after middleware
+	// ValueTimeout is the wall-clock time the value may take to decode,
+	// however long the caller's own deadline; see decodeWithDeadline.
+	ValueTimeout time.Duration
+}
+
+// errInputLimit is returned when a decode reads past Limits.MaxBytes.
+var errInputLimit = errors.New("input exceeds the size limit")
+
+type decodeConfig struct {
+	middleware []ReaderMiddleware
+	limits     Limits
+	types      []interface{}
+	transforms []TransformRule
+}
+
+// DecodeOption configures Decode and DecodeValue.
+type DecodeOption func(*decodeConfig)
+
+// WithReaderMiddleware adds middleware after any added before it.
+func WithReaderMiddleware(m ...ReaderMiddleware) DecodeOption {
+	return func(c *decodeConfig) { c.middleware = append(c.middleware, m...) }
+}
+
+// WithLimits sets the decode limits, replacing any set before.
+func WithLimits(l Limits) DecodeOption {
+	return func(c *decodeConfig) { c.limits = l }
+}
+
+// WithRegistry registers the concrete types of values before decoding,
+// as gob.Register does, skipping any already registered. A type whose
+// gob name another type has is an error.
+func WithRegistry(values ...interface{}) DecodeOption {
+	return func(c *decodeConfig) { c.types = append(c.types, values...) }
+}
+
+// WithTransforms applies rules to the decoded data, as ApplyTransforms
+// does.
+func WithTransforms(rules ...TransformRule) DecodeOption {
+	return func(c *decodeConfig) { c.transforms = append(c.transforms, rules...) }
+}
+
+// Decode decodes one gob map from r, configured by opts.
+func Decode(r io.Reader, opts ...DecodeOption) (map[interface{}]interface{}, error) {
+	var data map[interface{}]interface{}
+	_, err := decodeWith(r, opts, func(src io.Reader) (interface{}, error) {
+		err := gob.NewDecoder(src).Decode(&data)
+		return data, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DecodeValue is Decode for a top-level value of any type, which comes
+// back in the generic form decodeAny gives it.
+func DecodeValue(r io.Reader, opts ...DecodeOption) (interface{}, error) {
+	return decodeWith(r, opts, firstRecord)
+}
+
+func decodeWith(r io.Reader, opts []DecodeOption, decode func(io.Reader) (interface{}, error)) (interface{}, error) {
+	var c decodeConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	for _, v := range c.types {
+		if err := safeRegister(v); err != nil {
+			return nil, err
+		}
+	}
+	r, err := applyReaderMiddleware(r, c.middleware)
+	if err != nil {
+		return nil, err
+	}
+	src := r
+	if c.limits.MaxBytes > 0 {
+		src = &limitReader{r: r, n: c.limits.MaxBytes}
+	}
+	data, err := decodeWithDeadline(src, c.limits.ValueTimeout, decode)
+	var deadline *ValueDeadlineError
+	if errors.As(err, &deadline) {
+		return nil, err
+	}
+	// The decoder may have what it needs from a read that also went
+	// over the limit, and drop the error that came with it.
+	if l, ok := src.(*limitReader); ok && l.n < 0 {
+		return nil, fmt.Errorf("%w (%d bytes)", errInputLimit, c.limits.MaxBytes)
+	}
+	if err != nil {
+		return nil, payloadError(r, err)
+	}
+	if _, err := ApplyTransforms(data, c.transforms, false); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func applyReaderMiddleware(r io.Reader, ms []ReaderMiddleware) (io.Reader, error) {
+	for _, m := range ms {
+		var err error
+		if r, err = m(r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// limitReader fails once more than n bytes are read, unlike
+// io.LimitReader, which would end the stream quietly and leave the
+// decoder to report a truncated input.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errInputLimit
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errInputLimit
+	}
+	return n, err
+}
+
+type encodeConfig struct {
+	middleware []WriterMiddleware
+}
+
+// EncodeOption configures Encode.
+type EncodeOption func(*encodeConfig)
+
+// WithWriterMiddleware adds middleware after any added before it.
+func WithWriterMiddleware(m ...WriterMiddleware) EncodeOption {
+	return func(c *encodeConfig) { c.middleware = append(c.middleware, m...) }
+}
+
+// Encode encodes v onto w through the configured middleware, closing
+// the middleware last-added first once the encoding is complete.
+func Encode(w io.Writer, v interface{}, opts ...EncodeOption) error {
+	var c encodeConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	var closers []io.Closer
+	closeAll := func() error {
+		var first error
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i].Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+		closers = nil
+		return first
+	}
+	for _, m := range c.middleware {
+		wc, err := m(w)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		closers = append(closers, wc)
+		w = wc
+	}
+	bw := newEncodeWriter(w)
+	if err := encodeRoot(gob.NewEncoder(bw), v); err != nil {
+		closeAll()
+		return fmt.Errorf("encode: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		closeAll()
+		return err
+	}
+	return closeAll()
+}
+
+// storeRefMiddleware resolves a content-addressed store reference, with
+// relative blob paths taken from refPath.
+func storeRefMiddleware(refPath string) ReaderMiddleware {
+	return func(r io.Reader) (io.Reader, error) { return resolveStoreRef(r, refPath) }
+}
+
+// gunzipMiddleware decompresses gzip data and passes anything else
+// through.
+func gunzipMiddleware(r io.Reader) (io.Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	if head, _ := br.Peek(len(gzipMagic)); string(head) != string(gzipMagic) {
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, &corruptPayloadError{"gzip data is corrupt", err}
+	}
+	// Rotated logs are often gzip members concatenated into one file, each
+	// holding a gob stream of its own. gzip.Reader reads them all as one
+	// stream by default; the record readers take each member's type
+	// definitions as they come.
+	return &gunzipReader{zr: zr}, nil
+}
+
+// base64Middleware decodes base64 text, in either alphabet and padded or
+// not, and passes anything else through.
+func base64Middleware(r io.Reader) (io.Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	enc, ok := base64Encoding(peekAll(br))
+	if !ok {
+		return br, nil
+	}
+	return base64.NewDecoder(enc, &trimPadding{r: br}), nil
+}
+
+// payloadMiddleware removes every layer unwrapPayload recognizes around
+// the gob stream, with relative store blob paths taken from refPath.
+func payloadMiddleware(refPath string) ReaderMiddleware {
+	return func(r io.Reader) (io.Reader, error) { return unwrapPayload(r, refPath, nil) }
+}
+
+// gzipMiddleware compresses the encoding.
+func gzipMiddleware(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// middleware strips the header as a reader middleware.
+func (h *headerFlags) middleware(r io.Reader) (io.Reader, error) {
+	if err := h.strip(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
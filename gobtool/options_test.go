@@ -0,0 +1,179 @@
+package gobtool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xorWriter and xorReader are a toy cipher standing in for encryption:
+// each flips every byte.
+type xorWriter struct{ w io.Writer }
+
+func (x xorWriter) Write(p []byte) (int, error) {
+	q := make([]byte, len(p))
+	for i, b := range p {
+		q[i] = b ^ 0xff
+	}
+	return x.w.Write(q)
+}
+
+func (xorWriter) Close() error { return nil }
+
+type xorReader struct{ r io.Reader }
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= 0xff
+	}
+	return n, err
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	data := map[interface{}]interface{}{"a": strings.Repeat("x", 1000), "b": 2}
+	var buf bytes.Buffer
+	err := Encode(&buf, data, WithWriterMiddleware(
+		func(w io.Writer) (io.WriteCloser, error) { return xorWriter{w}, nil },
+		gzipMiddleware))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 500 {
+		t.Errorf("%d bytes: not compressed", buf.Len())
+	}
+	var read int
+	got, err := Decode(bytes.NewReader(buf.Bytes()), WithReaderMiddleware(
+		func(r io.Reader) (io.Reader, error) { return xorReader{r}, nil },
+		gunzipMiddleware,
+		func(r io.Reader) (io.Reader, error) {
+			// After both layers, the codec's reads can be counted.
+			return readFunc(func(p []byte) (int, error) {
+				n, err := r.Read(p)
+				read += n
+				return n, err
+			}), nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("got %v", got)
+	}
+	if read <= buf.Len() {
+		t.Errorf("counted %d bytes, no more than the %d compressed ones", read, buf.Len())
+	}
+
+	_, err = Decode(bytes.NewReader(buf.Bytes()), WithReaderMiddleware(gunzipMiddleware))
+	if err == nil {
+		t.Error("without the cipher layer: no error")
+	}
+}
+
+type readFunc func(p []byte) (int, error)
+
+func (f readFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestMiddlewareError(t *testing.T) {
+	refused := errors.New("refused")
+	_, err := Decode(strings.NewReader(""), WithReaderMiddleware(
+		func(io.Reader) (io.Reader, error) { return nil, refused }))
+	if !errors.Is(err, refused) {
+		t.Errorf("got %v, want the middleware's error", err)
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	registerKnownTypes()
+	data := syntheticData(100, 1)
+	var buf bytes.Buffer
+	if err := Encode(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(buf.Len())
+	if _, err := Decode(bytes.NewReader(buf.Bytes()), WithLimits(Limits{MaxBytes: size - 1})); !errors.Is(err, errInputLimit) {
+		t.Errorf("one byte short: got %v, want the size limit error", err)
+	}
+	if _, err := Decode(bytes.NewReader(buf.Bytes()), WithLimits(Limits{MaxBytes: size})); err != nil {
+		t.Errorf("exact size: %v", err)
+	}
+	slow := slowReader{bytes.NewReader(buf.Bytes()), time.Millisecond}
+	var de *ValueDeadlineError
+	if _, err := Decode(slow, WithLimits(Limits{ValueTimeout: 10 * time.Millisecond})); !errors.As(err, &de) {
+		t.Errorf("slow value: got %v, want a ValueDeadlineError", err)
+	}
+}
+
+func TestHeaderMiddleware(t *testing.T) {
+	var payload bytes.Buffer
+	data := map[interface{}]interface{}{"a": 1}
+	if err := Encode(&payload, data); err != nil {
+		t.Fatal(err)
+	}
+	withHeader := append([]byte("GOB\x01\x02"), payload.Bytes()...)
+	for _, c := range []struct {
+		h    headerFlags
+		in   []byte
+		want string
+	}{
+		{headerFlags{skip: 5}, withHeader, ""},
+		{headerFlags{skip: 5, magic: "474f42"}, withHeader, ""},
+		{headerFlags{magic: "474f4201"}, append([]byte("GOB\x01"), payload.Bytes()...), ""},
+		{headerFlags{skip: 5, magic: "585858"}, withHeader, "bad magic: got 474f42, want 585858"},
+		{headerFlags{skip: 2, magic: "474f42"}, withHeader, "longer than the 2-byte header"},
+		{headerFlags{magic: "zz"}, withHeader, "-expect-magic"},
+		{headerFlags{skip: 50}, withHeader[:10], "reading 50-byte header"},
+	} {
+		h := c.h
+		got, err := Decode(bytes.NewReader(c.in), WithReaderMiddleware(h.middleware))
+		if c.want == "" {
+			if err != nil || !reflect.DeepEqual(got, data) {
+				t.Errorf("%+v: got %v, %v", c.h, got, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%+v: got %v, want an error containing %q", c.h, err, c.want)
+		}
+	}
+}
+
+// TestDecodeHeaderFile runs header-prefixed files through the decode
+// command, which strips the header before finding the gob stream behind
+// any other wrapping.
+func TestDecodeHeaderFile(t *testing.T) {
+	var payload bytes.Buffer
+	if err := Encode(&payload, map[interface{}]interface{}{"greeting": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		name = filepath.Join(dir, name)
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return name
+	}
+	plain := write("plain.gob", append([]byte("GOB\x01\x02"), payload.Bytes()...))
+	zipped := write("zipped.gob", append([]byte("GOB\x01\x02"), gzipped(t, payload.Bytes())...))
+
+	for _, name := range []string{plain, zipped} {
+		out, err := runCaptured(t, "decode", "-skip-header", "5", "-expect-magic", "474f4201", name)
+		if err != nil {
+			t.Errorf("%s: %v", filepath.Base(name), err)
+		} else if !strings.Contains(out, "Key: greeting (string)") || !strings.Contains(out, "hello (string)") {
+			t.Errorf("%s: printed\n%s", filepath.Base(name), out)
+		}
+	}
+	_, err := runCaptured(t, "decode", "-skip-header", "5", "-expect-magic", "585858", plain)
+	if err == nil || !strings.Contains(err.Error(), plain+": bad magic") {
+		t.Errorf("wrong magic: got %v", err)
+	}
+	if _, err := runCaptured(t, "decode", plain); err == nil {
+		t.Error("without -skip-header, the header decoded as gob")
+	}
+}
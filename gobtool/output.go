@@ -0,0 +1,65 @@
+package gobtool
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// errOutputCap is returned by a capWriter once its cap is reached; the
+// writers above it stop at their first write error.
+var errOutputCap = errors.New("output cap reached")
+
+// capWriter passes at most limit bytes on to w.
+type capWriter struct {
+	w     io.Writer
+	limit int64
+	n     int64
+	nl    bool // the last byte written was a newline
+}
+
+func (c *capWriter) Write(p []byte) (int, error) {
+	room := c.limit - c.n
+	var capped bool
+	if int64(len(p)) > room {
+		p, capped = p[:room], true
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if n > 0 {
+		c.nl = p[n-1] == '\n'
+	}
+	if err == nil && capped {
+		err = errOutputCap
+	}
+	return n, err
+}
+
+// outputCapFlag is -max-total-output.
+type outputCapFlag int64
+
+func (o *outputCapFlag) register(fs *flag.FlagSet) {
+	fs.Int64Var((*int64)(o), "max-total-output", 0, "stop after writing this many bytes of output (0 = no limit)")
+}
+
+// wrap returns w capped at the flag's limit, and a function that turns
+// the output's error into the final one: hitting the cap is not an
+// error, it ends the output with a truncation note and a warning in ws.
+func (o outputCapFlag) wrap(w io.Writer, ws *Warnings) (io.Writer, func(error) error) {
+	if o <= 0 {
+		return w, func(err error) error { return err }
+	}
+	c := &capWriter{w: w, limit: int64(o), nl: true}
+	return c, func(err error) error {
+		if !errors.Is(err, errOutputCap) {
+			return err
+		}
+		ws.add(WarnTruncated, "", "output stopped at the %d bytes of -max-total-output", c.limit)
+		if !c.nl {
+			fmt.Fprintln(w)
+		}
+		_, err = fmt.Fprintf(w, "...(output truncated at %d bytes)\n", c.limit)
+		return err
+	}
+}
@@ -0,0 +1,46 @@
+package gobtool
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestOutputCap(t *testing.T) {
+	for _, c := range []struct {
+		limit outputCapFlag
+		write string
+		want  string
+		warn  bool
+	}{
+		{0, "line one\nline two\n", "line one\nline two\n", false},
+		{100, "line one\nline two\n", "line one\nline two\n", false},
+		{9, "line one\nline two\n", "line one\n...(output truncated at 9 bytes)\n", true},
+		{6, "line one\nline two\n", "line o\n...(output truncated at 6 bytes)\n", true},
+	} {
+		var b strings.Builder
+		ws := &Warnings{}
+		w, finish := c.limit.wrap(&b, ws)
+		_, err := fmt.Fprint(w, c.write)
+		if err := finish(err); err != nil {
+			t.Errorf("cap %d: %v", c.limit, err)
+		}
+		if b.String() != c.want {
+			t.Errorf("cap %d: got %q, want %q", c.limit, b.String(), c.want)
+		}
+		if warned := len(ws.List) == 1 && ws.List[0].Code == WarnTruncated; warned != c.warn {
+			t.Errorf("cap %d: warnings %v", c.limit, ws.List)
+		}
+	}
+}
+
+func TestDecodeMaxTotalOutput(t *testing.T) {
+	name := writeRoot(t, syntheticData(100, 1))
+	out, err := runCaptured(t, "decode", "-max-total-output", "200", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out, "...(output truncated at 200 bytes)\n") || len(out) > 250 {
+		t.Errorf("got %d bytes:\n%s", len(out), out)
+	}
+}
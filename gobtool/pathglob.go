@@ -0,0 +1,123 @@
+package gobtool
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// pathGlob matches the paths walkPaths produces. It is split into
+// segments like a path and matched segment by segment against the end of
+// a path, so "Values.*token*" matches at any depth. Name segments use
+// path.Match syntax; slice elements are addressed as "scores[1]", or
+// "scores[*]" for all of them.
+type pathGlob []string
+
+func parsePathGlob(glob string) (pathGlob, error) {
+	if glob == "" {
+		return nil, errors.New("empty path")
+	}
+	var g pathGlob
+	for _, seg := range strings.Split(glob, ".") {
+		name, index, _ := strings.Cut(seg, "[")
+		if name != "" {
+			if _, err := path.Match(name, ""); err != nil {
+				return nil, err
+			}
+			g = append(g, name)
+		}
+		if index != "" {
+			for _, i := range strings.SplitAfter(index, "]") {
+				if i != "" {
+					g = append(g, "["+i)
+				}
+			}
+		}
+	}
+	return g, nil
+}
+
+// match reports whether g matches the last segments of segs.
+func (g pathGlob) match(segs []string) bool {
+	if len(segs) < len(g) {
+		return false
+	}
+	tail := segs[len(segs)-len(g):]
+	for i, p := range g {
+		if strings.HasPrefix(p, "[") {
+			// indexes match literally, or any index with [*]
+			if p != "[*]" && p != tail[i] {
+				return false
+			}
+		} else if ok, _ := path.Match(p, tail[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// splitPath splits a path from walkPaths into its segments, unquoting
+// quoted keys. Each index is a segment of its own, such as "[2]".
+func splitPath(p string) []string {
+	var segs []string
+	for p != "" {
+		switch {
+		case p[0] == '.':
+			p = p[1:]
+		case p[0] == '[':
+			end := strings.IndexByte(p, ']') + 1
+			if end == 0 {
+				end = len(p)
+			}
+			segs = append(segs, p[:end])
+			p = p[end:]
+		case p[0] == '"':
+			if q, err := strconv.QuotedPrefix(p); err == nil {
+				s, _ := strconv.Unquote(q)
+				segs = append(segs, s)
+				p = p[len(q):]
+				continue
+			}
+			fallthrough
+		default:
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			segs = append(segs, p[:end])
+			p = p[end:]
+		}
+	}
+	return segs
+}
+
+// ignoreGlobs is a flag value collecting repeated -ignore path globs.
+type ignoreGlobs []pathGlob
+
+func (ig *ignoreGlobs) String() string { return "" }
+
+func (ig *ignoreGlobs) Set(s string) error {
+	g, err := parsePathGlob(s)
+	if err != nil {
+		return err
+	}
+	*ig = append(*ig, g)
+	return nil
+}
+
+// ignored reports whether any of the globs matches p or a path above it.
+func (ig ignoreGlobs) ignored(p string) bool {
+	if len(ig) == 0 {
+		return false
+	}
+	segs := splitPath(p)
+	for _, g := range ig {
+		for n := len(g); n <= len(segs); n++ {
+			if g.match(segs[:n]) {
+				return true
+			}
+		}
+	}
+	return false
+}
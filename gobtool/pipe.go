@@ -0,0 +1,36 @@
+package gobtool
+
+import (
+	"errors"
+	"io"
+	"os/signal"
+	"syscall"
+)
+
+// Output is often piped into head or a pager that exits before reading
+// it all. By default that kills a Go program with SIGPIPE on its next
+// write to stdout, exit status 141, which scripts under set -o pipefail
+// report as a failure. Instead SIGPIPE is ignored, so the write returns
+// EPIPE; the writers stop at their first write error, which ends the
+// decode loop before it reads further input, and runCommand exits with
+// -broken-pipe-exit (0 by default) without a message.
+//
+// Nothing on the output side buffers more than a record or a bufio
+// buffer ahead of the consumer: every writer writes through to the next
+// as it goes, so a slow reader of the output slows the decode down
+// rather than letting memory grow.
+
+// brokenPipeExit is the exit status when the output is closed early.
+var brokenPipeExit int
+
+// handleBrokenPipes turns SIGPIPE into EPIPE errors for the rest of the
+// run.
+func handleBrokenPipes() {
+	signal.Ignore(syscall.SIGPIPE)
+}
+
+// isBrokenPipe reports whether err comes from writing to an output whose
+// reader has gone away.
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrClosedPipe)
+}
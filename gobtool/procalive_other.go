@@ -0,0 +1,6 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package gobtool
+
+// processAlive cannot check processes on this platform.
+func processAlive(int) (alive, known bool) { return false, false }
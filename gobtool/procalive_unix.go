@@ -0,0 +1,12 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package gobtool
+
+import "syscall"
+
+// processAlive reports whether a process pid is running on this host;
+// known is false where that cannot be told.
+func processAlive(pid int) (alive, known bool) {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM, true
+}
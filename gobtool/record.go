@@ -0,0 +1,278 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"test-gob/errclass"
+)
+
+// record and replay pin down how a corpus of files decodes, so a Go
+// upgrade or a change to the decode pipeline can be checked against it:
+//
+//	record -out baseline.jsonl testdata/
+//	replay baseline.jsonl testdata/
+//
+// For every file under the directory, record stores the outcome of
+// decoding all its records: their CanonicalHash, the warning codes JSON
+// output raises for them, or, if it fails, the error class. Messages are
+// not stored, since they change with Go versions while the classes do
+// not. replay decodes the corpus again and lists every file whose
+// outcome changed, was added or is gone, failing if there is any, so it
+// can gate CI.
+//
+// The baseline has one JSON object per line, sorted by path, with paths
+// relative to the directory and slash-separated, so it diffs well and
+// does not depend on where the corpus is checked out.
+
+// decodeOutcome is one line of a baseline.
+type decodeOutcome struct {
+	File       string         `json:"file"`
+	Records    int            `json:"records,omitempty"`
+	Hash       string         `json:"hash,omitempty"`
+	Warnings   []string       `json:"warnings,omitempty"` // codes, sorted
+	ErrorClass errclass.Class `json:"error_class,omitempty"`
+}
+
+// key is the outcome without the file, for comparing.
+func (o decodeOutcome) key() string {
+	if o.ErrorClass != "" {
+		return "error " + string(o.ErrorClass)
+	}
+	return fmt.Sprintf("%d records, hash %s, warnings [%s]", o.Records, o.Hash, strings.Join(o.Warnings, " "))
+}
+
+// recordFile decodes every record of a file and returns its outcome.
+func recordFile(name string) decodeOutcome {
+	var o decodeOutcome
+	records, err := decodeFileRecords(name)
+	if err == nil {
+		var v interface{} = records
+		if len(records) == 1 {
+			v = records[0]
+		}
+		var h [32]byte
+		if h, err = CanonicalHash(v); err == nil {
+			var ws Warnings
+			if _, err = toJSONWarn(v, &ws); err == nil {
+				o.Records, o.Hash = len(records), hex.EncodeToString(h[:])
+				o.Warnings = warningCodes(&ws)
+			}
+		}
+	}
+	if err != nil {
+		o.ErrorClass, _ = ClassifyError(err)
+	}
+	return o
+}
+
+// decodeFileRecords decodes all records of a file, as decode
+// -format json-array reads them.
+func decodeFileRecords(name string) ([]interface{}, error) {
+	f, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := openPayload(f, name)
+	if err != nil {
+		return nil, err
+	}
+	records, err := decodeRecords(r, false)
+	if err == nil && len(records) == 0 {
+		err = errEmptyStream
+	}
+	return records, payloadError(r, err)
+}
+
+// warningCodes returns the distinct codes in ws, sorted.
+func warningCodes(ws *Warnings) []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, w := range ws.List {
+		if !seen[w.Code] {
+			seen[w.Code] = true
+			codes = append(codes, w.Code)
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// recordCorpus returns the outcome of every file under dir, sorted by
+// path, leaving out hidden files and skip.
+func recordCorpus(dir, skip string) ([]decodeOutcome, error) {
+	skipAbs, _ := filepath.Abs(skip)
+	var outcomes []decodeOutcome
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); skip != "" && abs == skipAbs {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		o := recordFile(p)
+		o.File = filepath.ToSlash(rel)
+		outcomes = append(outcomes, o)
+		return nil
+	})
+	sort.Slice(outcomes, func(i, j int) bool { return outcomes[i].File < outcomes[j].File })
+	return outcomes, err
+}
+
+// writeBaseline writes outcomes as JSON lines.
+func writeBaseline(w io.Writer, outcomes []decodeOutcome) error {
+	for _, o := range outcomes {
+		b, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBaseline reads a baseline written by record.
+func readBaseline(name string) ([]decodeOutcome, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var outcomes []decodeOutcome
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var o decodeOutcome
+		if err := json.Unmarshal(sc.Bytes(), &o); err != nil || o.File == "" {
+			return nil, fmt.Errorf("%s:%d: malformed line", name, line)
+		}
+		outcomes = append(outcomes, o)
+	}
+	return outcomes, sc.Err()
+}
+
+// outcomeChange is a file whose outcome differs from the baseline. Old
+// or New is nil for a file added or gone.
+type outcomeChange struct {
+	File     string
+	Old, New *decodeOutcome
+}
+
+func (c outcomeChange) String() string {
+	switch {
+	case c.Old == nil:
+		return fmt.Sprintf("added\t%s\t%s", c.File, c.New.key())
+	case c.New == nil:
+		return fmt.Sprintf("removed\t%s\t%s", c.File, c.Old.key())
+	}
+	return fmt.Sprintf("changed\t%s\t%s -> %s", c.File, c.Old.key(), c.New.key())
+}
+
+// compareOutcomes returns the changes from old to new, by path.
+func compareOutcomes(old, new []decodeOutcome) []outcomeChange {
+	byFile := make(map[string]*decodeOutcome, len(old))
+	for i := range old {
+		byFile[old[i].File] = &old[i]
+	}
+	var changes []outcomeChange
+	for i := range new {
+		n := &new[i]
+		o := byFile[n.File]
+		delete(byFile, n.File)
+		if o == nil || o.key() != n.key() {
+			changes = append(changes, outcomeChange{File: n.File, Old: o, New: n})
+		}
+	}
+	for file, o := range byFile {
+		changes = append(changes, outcomeChange{File: file, Old: o})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].File < changes[j].File })
+	return changes
+}
+
+func recordFlags(fs *flag.FlagSet) func(args []string) error {
+	out := fs.String("out", "", "write the baseline to this file (default stdout)")
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errors.New("usage: record [-out baseline.jsonl] dir")
+		}
+		registerKnownTypes()
+		outcomes, err := recordCorpus(args[0], *out)
+		if err != nil {
+			return err
+		}
+		if *out == "" {
+			return writeBaseline(os.Stdout, outcomes)
+		}
+		var buf bytes.Buffer
+		if err := writeBaseline(&buf, outcomes); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(*out, buf.Bytes()); err != nil {
+			return err
+		}
+		failed := 0
+		for _, o := range outcomes {
+			if o.ErrorClass != "" {
+				failed++
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d files recorded, %d of them failing\n", *out, len(outcomes), failed)
+		return nil
+	}
+}
+
+func replayFlags(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 2 {
+			return errors.New("usage: replay baseline.jsonl dir")
+		}
+		old, err := readBaseline(args[0])
+		if err != nil {
+			return err
+		}
+		registerKnownTypes()
+		outcomes, err := recordCorpus(args[1], args[0])
+		if err != nil {
+			return err
+		}
+		changes := compareOutcomes(old, outcomes)
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		fmt.Printf("%d files replayed, %d changed\n", len(outcomes), len(changes))
+		if len(changes) > 0 {
+			return exitCode(1)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,86 @@
+package gobtool
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"test-gob/errclass"
+)
+
+// TestRecordReplay records a small corpus, checks that replaying it finds
+// nothing, then changes, breaks, adds and removes files and checks that
+// replay reports each of them.
+func TestRecordReplay(t *testing.T) {
+	corpus := t.TempDir()
+	for name, v := range map[string]interface{}{"data.gob": syntheticData(50, 1), "sample.gob": createSampleData(), "gone.gob": "soon removed"} {
+		if err := encodeAndWriteToFile(v, filepath.Join(corpus, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	baseline := filepath.Join(corpus, "baseline.jsonl")
+	outcomes, err := recordCorpus(corpus, baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeBaseline(&buf, outcomes); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(baseline, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old, err := readBaseline(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(old, outcomes) {
+		t.Fatal("baseline reads back differently")
+	}
+	again, err := recordCorpus(corpus, baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := compareOutcomes(old, again); len(changes) > 0 {
+		t.Errorf("unchanged corpus: replay reports %v", changes)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(corpus, "data.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(corpus, "data.gob"), raw[:len(raw)-1], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sample := createSampleData()
+	sample["name"] = "李四"
+	if err := encodeAndWriteToFile(sample, filepath.Join(corpus, "sample.gob")); err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeAndWriteToFile("new", filepath.Join(corpus, "new.gob")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(corpus, "gone.gob")); err != nil {
+		t.Fatal(err)
+	}
+	if again, err = recordCorpus(corpus, baseline); err != nil {
+		t.Fatal(err)
+	}
+	changes := compareOutcomes(old, again)
+	var got []string
+	for _, c := range changes {
+		kind, _, _ := strings.Cut(c.String(), "\t")
+		got = append(got, kind+" "+c.File)
+	}
+	want := "[changed data.gob removed gone.gob added new.gob changed sample.gob]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("replay reports %v, want %s", got, want)
+	}
+	if class := changes[0].New.ErrorClass; class != errclass.Truncated {
+		t.Errorf("truncated data.gob: got error class %q", class)
+	}
+}
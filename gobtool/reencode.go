@@ -0,0 +1,494 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reencode rewrites a gob file with some subtrees dropped or transformed,
+// typically to sanitize production data for lower environments. Rules
+// name their targets by path globs (see parsePathGlob), so
+// "Values.*token*" matches at any depth.
+
+// reencodeRule is one -drop or -transform flag.
+type reencodeRule struct {
+	spec    string
+	pattern pathGlob
+	drop    bool
+	fn      string // transform: hash, truncate, zero, constant, trim, lower, upper or unix-time
+	arg     string
+	custom  func(v interface{}) (interface{}, error) // a library TransformRule
+}
+
+func parseReencodeRule(spec string, drop bool) (*reencodeRule, error) {
+	r := &reencodeRule{spec: spec, drop: drop}
+	glob := spec
+	if !drop {
+		var fn string
+		var ok bool
+		glob, fn, ok = strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("-transform %q: want path=transform", spec)
+		}
+		r.fn, r.arg, _ = strings.Cut(fn, ":")
+		switch r.fn {
+		case "hash", "zero", "trim", "lower", "upper", "unix-time":
+		case "truncate":
+			if r.arg == "" {
+				r.arg = "8"
+			}
+			if n, err := strconv.Atoi(r.arg); err != nil || n < 0 {
+				return nil, fmt.Errorf("-transform %q: bad truncate length %q", spec, r.arg)
+			}
+		case "constant":
+		default:
+			return nil, fmt.Errorf("-transform %q: unknown transform %q (want hash, truncate[:N], zero, constant:V, trim, lower, upper or unix-time)", spec, r.fn)
+		}
+	}
+	var err error
+	if r.pattern, err = parsePathGlob(glob); err != nil {
+		return nil, fmt.Errorf("%q: %w", spec, err)
+	}
+	return r, nil
+}
+
+// apply transforms v; the result must still fit slot.
+func (r *reencodeRule) apply(v reflect.Value, slot reflect.Type) (reflect.Value, error) {
+	v = indirect(v)
+	var out reflect.Value
+	if r.custom != nil {
+		var in interface{}
+		if v.IsValid() {
+			in = v.Interface()
+		}
+		res, err := r.custom(in)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if res == nil {
+			return reflect.Zero(slot), nil
+		}
+		out = reflect.ValueOf(res)
+	}
+	switch r.fn {
+	case "zero":
+		if !v.IsValid() {
+			return reflect.Zero(slot), nil
+		}
+		out = reflect.Zero(v.Type())
+	case "hash":
+		var data interface{}
+		if v.IsValid() {
+			data = v.Interface()
+		}
+		h, err := CanonicalHash(data)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out = reflect.ValueOf(hex.EncodeToString(h[:]))
+	case "truncate":
+		if !v.IsValid() || v.Kind() != reflect.String {
+			return reflect.Value{}, errors.New("truncate applies to strings only")
+		}
+		n, _ := strconv.Atoi(r.arg)
+		s := v.String()
+		for i := range s {
+			if n == 0 {
+				s = s[:i]
+				break
+			}
+			n--
+		}
+		out = reflect.ValueOf(s).Convert(v.Type())
+	case "trim", "lower", "upper":
+		if !v.IsValid() || v.Kind() != reflect.String {
+			return reflect.Value{}, fmt.Errorf("%s applies to strings only", r.fn)
+		}
+		f := map[string]func(string) string{"trim": strings.TrimSpace, "lower": strings.ToLower, "upper": strings.ToUpper}[r.fn]
+		out = reflect.ValueOf(f(v.String())).Convert(v.Type())
+	case "unix-time":
+		switch {
+		case v.CanInt():
+			out = reflect.ValueOf(time.Unix(v.Int(), 0).UTC())
+		case v.CanUint():
+			out = reflect.ValueOf(time.Unix(int64(v.Uint()), 0).UTC())
+		case v.CanFloat():
+			sec, frac := math.Modf(v.Float())
+			out = reflect.ValueOf(time.Unix(int64(sec), int64(frac*1e9)).UTC())
+		default:
+			return reflect.Value{}, errors.New("unix-time applies to numbers only")
+		}
+	case "constant":
+		c, err := parseConstant(r.arg, v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out = c
+	}
+	if out.Type().AssignableTo(slot) {
+		return out, nil
+	}
+	if out.Type().ConvertibleTo(slot) && out.Kind() == slot.Kind() {
+		return out.Convert(slot), nil
+	}
+	return reflect.Value{}, fmt.Errorf("result %s does not fit %s", out.Type(), slot)
+}
+
+// parseConstant reads s as a value of the same kind as old, or as a
+// string if old has no kind to follow.
+func parseConstant(s string, old reflect.Value) (reflect.Value, error) {
+	if !old.IsValid() {
+		return reflect.ValueOf(s), nil
+	}
+	out := reflect.New(old.Type()).Elem()
+	switch old.Kind() {
+	case reflect.String:
+		out.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, old.Type().Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, old.Type().Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, old.Type().Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out.SetFloat(f)
+	default:
+		return reflect.ValueOf(s), nil
+	}
+	return out, nil
+}
+
+// touched records a path changed by a rule.
+type touched struct {
+	path string
+	rule *reencodeRule
+}
+
+type rewriter struct {
+	rules   []*reencodeRule
+	chain   bool // apply every matching transform in order, not just the first
+	dryRun  bool // only record what the rules would touch
+	touched []touched
+	guard   cycleGuard
+}
+
+// rulesFor returns the rules to apply at segs: the first matching drop if
+// there is one, otherwise the matching transforms in order, or only the
+// first of them unless chaining.
+func (rw *rewriter) rulesFor(segs []string) []*reencodeRule {
+	var found []*reencodeRule
+	for _, r := range rw.rules {
+		if !r.pattern.match(segs) {
+			continue
+		}
+		if r.drop {
+			return []*reencodeRule{r}
+		}
+		if len(found) == 0 || rw.chain {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// rewrite applies the rules below v and returns the value to store in
+// its place; maps are changed in place, structs and arrays are copied.
+func (rw *rewriter) rewrite(v reflect.Value, segs []string, display string) (reflect.Value, error) {
+	if k, ok := guardKeyOf(v); ok {
+		if rw.guard[k] {
+			return v, nil
+		}
+		rw.guard[k] = true
+		defer delete(rw.guard, k)
+	}
+	if !v.IsValid() {
+		return v, nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, nil
+		}
+		inner, err := rw.rewrite(v.Elem(), segs, display)
+		if err != nil {
+			return v, err
+		}
+		return inner, nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v, nil
+		}
+		inner, err := rw.rewrite(v.Elem(), segs, display)
+		if err != nil {
+			return v, err
+		}
+		v.Elem().Set(inner)
+		return v, nil
+	case reflect.Map:
+		for _, k := range sortedMapKeys(v) {
+			sub := append(segs[:len(segs):len(segs)], fmt.Sprint(k))
+			nv, err := rw.child(v.MapIndex(k), v.Type().Elem(), sub, joinPath(display, pathKey(k.Interface())))
+			if err != nil {
+				return v, err
+			}
+			v.SetMapIndex(k, nv)
+		}
+		return v, nil
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			sub := append(segs[:len(segs):len(segs)], f.Name)
+			nv, err := rw.child(cp.Field(i), f.Type, sub, joinPath(display, f.Name))
+			if err != nil {
+				return v, err
+			}
+			if !nv.IsValid() {
+				// struct fields cannot be dropped, only zeroed
+				nv = reflect.Zero(f.Type)
+			}
+			cp.Field(i).Set(nv)
+		}
+		return cp, nil
+	case reflect.Slice, reflect.Array:
+		if !isContainer(v) {
+			return v, nil
+		}
+		cp := v
+		if v.Kind() == reflect.Array {
+			cp = reflect.New(v.Type()).Elem()
+			cp.Set(v)
+		}
+		for i := 0; i < cp.Len(); i++ {
+			seg := fmt.Sprintf("[%d]", i)
+			nv, err := rw.child(cp.Index(i), v.Type().Elem(), append(segs[:len(segs):len(segs)], seg), display+seg)
+			if err != nil {
+				return v, err
+			}
+			if !nv.IsValid() {
+				nv = reflect.Zero(v.Type().Elem())
+			}
+			cp.Index(i).Set(nv)
+		}
+		return cp, nil
+	}
+	return v, nil
+}
+
+// child rewrites a value stored in a slot of type slot. An invalid result
+// means the value is dropped.
+//
+// A transformed value is not descended into. In a dry run the rules are
+// only recorded and the walk carries on below them.
+func (rw *rewriter) child(v reflect.Value, slot reflect.Type, segs []string, display string) (reflect.Value, error) {
+	rules := rw.rulesFor(segs)
+	for _, r := range rules {
+		rw.touched = append(rw.touched, touched{display, r})
+		if rw.dryRun {
+			continue
+		}
+		if r.drop {
+			return reflect.Value{}, nil
+		}
+		nv, err := r.apply(v, slot)
+		if err != nil {
+			return v, fmt.Errorf("%s: %s: %w", display, r.spec, err)
+		}
+		v = nv
+	}
+	if len(rules) > 0 && !rw.dryRun {
+		return v, nil
+	}
+	return rw.rewrite(v, segs, display)
+}
+
+// streamRoot is the value that stands for the records of a stream in
+// paths: the record itself when there is one, and otherwise all of them
+// as a slice, so that the paths of record i start with [i].
+func streamRoot(values []interface{}) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
+
+// flatLines maps each flat-format path of data to its line.
+func flatLines(data interface{}) (map[string]string, error) {
+	var buf bytes.Buffer
+	if err := writeFlat(&buf, data, 0); err != nil {
+		return nil, err
+	}
+	lines := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		p, _, _ := strings.Cut(line, " = ")
+		lines[p] = line
+	}
+	return lines, nil
+}
+
+// underAny reports whether p is one of paths or lies below one of them.
+func underAny(p string, paths []touched) bool {
+	for _, t := range paths {
+		if p == t.path || strings.HasPrefix(p, t.path+".") || strings.HasPrefix(p, t.path+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// aboveAny reports whether p holds one of paths: a map or slice that a
+// drop below it can leave with fewer entries, or empty. The whole value
+// is (root) when it prints as a single line.
+func aboveAny(p string, paths []touched) bool {
+	for _, t := range paths {
+		if p == "(root)" || strings.HasPrefix(t.path, p+".") || strings.HasPrefix(t.path, p+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// reencodeFlags registers the flags of reencode; the command returned
+// rewrites a gob file with drops and transforms applied, then checks
+// that nothing outside the touched paths changed.
+func reencodeFlags(fs *flag.FlagSet) func(args []string) error {
+	var rules []*reencodeRule
+	addRule := func(drop bool) func(string) error {
+		return func(s string) error {
+			r, err := parseReencodeRule(s, drop)
+			if err == nil {
+				rules = append(rules, r)
+			}
+			return err
+		}
+	}
+	fs.Func("drop", "drop the values at this glob path (repeatable)", addRule(true))
+	fs.Func("transform", "path=hash|truncate[:N]|zero|constant:V|trim|lower|upper|unix-time (repeatable)", addRule(false))
+	fs.Func("root-type", rootTypeUsage, setRootType)
+	fs.BoolVar(&trimLeading, "trim-leading", false, trimLeadingUsage)
+	return func(args []string) error {
+		if len(args) != 2 {
+			return errors.New("usage: reencode [-drop glob]... [-transform glob=fn]... [-root-type t] [-trim-leading] in.gob out.gob")
+		}
+
+		registerKnownTypes()
+		orig, _, err := readTypedFile(args[0])
+		if err != nil {
+			return err
+		}
+		values, types, err := readTypedFile(args[0])
+		if err != nil {
+			return err
+		}
+		if override := rootTypeOverride(); override != nil {
+			for i := range types {
+				types[i] = override
+			}
+		}
+		rw := &rewriter{rules: rules, guard: make(cycleGuard)}
+		for i, v := range values {
+			var segs []string
+			display := ""
+			if len(values) > 1 {
+				display = fmt.Sprintf("[%d]", i)
+				segs = []string{display}
+			}
+			nv, err := rw.rewrite(reflect.ValueOf(v), segs, display)
+			if err != nil {
+				return err
+			}
+			if nv.IsValid() {
+				values[i] = nv.Interface()
+			}
+		}
+		shared := findAliases(streamRoot(values))
+		before, err := flatLines(streamRoot(orig))
+		if err != nil {
+			return err
+		}
+		data, err := encodeStreamValues(values, types)
+		if err != nil {
+			return err
+		}
+		// The round trip is checked on the temporary file, before it replaces
+		// out.gob, so a failed check leaves out.gob as it was.
+		return writeAtomic(args[1], func(f *os.File) error {
+			if _, err := f.Write(data); err != nil {
+				return err
+			}
+			// Every record must decode as the type it was read as.
+			if err := verifyTyped(bytes.NewReader(data), values, types, false); err != nil {
+				return fmt.Errorf("verify: %w", err)
+			}
+			// Round trip: everything the rules did not touch must read back as it was.
+			back, _, err := readTypedFile(f.Name())
+			if err != nil {
+				return fmt.Errorf("verify: %w", err)
+			}
+			after, err := flatLines(streamRoot(back))
+			if err != nil {
+				return err
+			}
+			var changed []string
+			for p, line := range before {
+				if after[p] != line && !underAny(p, rw.touched) && !aboveAny(p, rw.touched) {
+					changed = append(changed, p)
+				}
+			}
+			for p := range after {
+				if _, ok := before[p]; !ok && !underAny(p, rw.touched) && !aboveAny(p, rw.touched) {
+					changed = append(changed, p)
+				}
+			}
+
+			for _, t := range rw.touched {
+				action := "drop"
+				if !t.rule.drop {
+					action = t.rule.fn
+				}
+				fmt.Printf("%-8s %s\t(%s)\n", action, t.path, t.rule.spec)
+			}
+			fmt.Printf("%d paths affected\n", len(rw.touched))
+			if len(shared) > 0 {
+				// gob never keeps sharing, so this only fires for data that gained
+				// some after decoding; say how much the round trip undid
+				fmt.Println(sharingNote(shared, findAliases(streamRoot(back))))
+			}
+			if len(changed) > 0 {
+				sort.Strings(changed)
+				return fmt.Errorf("verify: %d untouched paths changed, first %s; %s left unchanged", len(changed), changed[0], args[1])
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReencodeReplacesOutputAfterVerifying(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.gob")
+	out := filepath.Join(dir, "out.gob")
+	if err := encodeAndWriteToFile(map[string]interface{}{"name": "ada", "keep": 1}, in); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := lookupCommand("reencode").run([]string{"-transform", "name=upper", in, out}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeAnyFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[interface{}]interface{}{"name": "ADA", "keep": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestReencodeFailureKeepsOutput(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.gob")
+	out := filepath.Join(dir, "out.gob")
+	if err := os.WriteFile(in, []byte("not gob"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := lookupCommand("reencode").run([]string{in, out}); err == nil {
+		t.Fatal("no error")
+	}
+	if b, _ := os.ReadFile(out); string(b) != "old" {
+		t.Errorf("out.gob is now %q", b)
+	}
+}
+
+type reencodeEvent struct {
+	Name  string
+	Tags  []string
+	Count int
+}
+
+// A stream of bare records of several types must come out with every
+// record, each still decodable by encoding/gob as the type it was sent as.
+func TestReencodeStreamKeepsTypes(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.gob")
+	out := filepath.Join(dir, "out.gob")
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for _, v := range []interface{}{
+		reencodeEvent{Name: "ada", Tags: []string{"a", "b"}, Count: 3},
+		[]string{"x", "y"},
+		map[string]interface{}{"name": "bob", "n": 2},
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(in, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCaptured(t, "reencode", "-transform", "name=upper", "-transform", "Name=upper", in, out); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(f)
+	var ev reencodeEvent
+	var strs []string
+	var m map[string]interface{}
+	for _, p := range []interface{}{&ev, &strs, &m} {
+		if err := dec.Decode(p); err != nil {
+			t.Fatalf("decoding into %T: %v", p, err)
+		}
+	}
+	if err := dec.Decode(new(interface{})); err != io.EOF {
+		t.Errorf("after three records: %v, want EOF", err)
+	}
+	if want := (reencodeEvent{Name: "ADA", Tags: []string{"a", "b"}, Count: 3}); !reflect.DeepEqual(ev, want) {
+		t.Errorf("record 0 = %#v, want %#v", ev, want)
+	}
+	if want := []string{"x", "y"}; !reflect.DeepEqual(strs, want) {
+		t.Errorf("record 1 = %#v, want %#v", strs, want)
+	}
+	if want := map[string]interface{}{"name": "BOB", "n": 2}; !reflect.DeepEqual(m, want) {
+		t.Errorf("record 2 = %#v, want %#v", m, want)
+	}
+}
+
+func TestReencodeRefusesRetyping(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.gob")
+	out := filepath.Join(dir, "out.gob")
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(reencodeEvent{Name: "ada", Count: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(in, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// a hash is a string, which does not fit the int field Count
+	_, err := runCaptured(t, "reencode", "-transform", "Count=hash", in, out)
+	if err == nil || !strings.Contains(err.Error(), "value 0") {
+		t.Fatalf("got %v, want an error re-encoding value 0", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("out.gob was written: %v", err)
+	}
+}
+
+// Dropping every entry of a map leaves it empty, which is a line of its
+// own in the flat form; the map holds the dropped paths, so that is not
+// an untouched path changing.
+func TestReencodeDropsWholeMap(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.gob")
+	out := filepath.Join(dir, "out.gob")
+	data := map[string]interface{}{"a": 1, "b": map[string]interface{}{"x": 1, "y": 2}}
+	if err := encodeAndWriteToFile(data, in); err != nil {
+		t.Fatal(err)
+	}
+	got, err := runCaptured(t, "reencode", "-drop", "b.*", in, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "2 paths affected") {
+		t.Errorf("output:\n%s", got)
+	}
+	back, err := decodeAnyFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[interface{}]interface{}{"a": 1, "b": map[string]interface{}{}}
+	if !reflect.DeepEqual(back, want) {
+		t.Errorf("got %#v, want %#v", back, want)
+	}
+}
@@ -0,0 +1,61 @@
+package gobtool
+
+import (
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	registerMu      sync.Mutex
+	registered      = make(map[reflect.Type]bool)
+	registeredNames = make(map[string]reflect.Type)
+)
+
+// safeRegister is gob.Register for use from any goroutine. Each type is
+// registered once; repeating a registration is a no-op rather than a
+// second trip through gob's global tables. A type whose name gob already
+// gives another type is refused with an error where gob.Register would
+// panic.
+func safeRegister(v interface{}) (err error) {
+	t := reflect.TypeOf(v)
+	name := gobName(t)
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	if registered[t] {
+		return nil
+	}
+	if other, taken := registeredNames[name]; taken {
+		return fmt.Errorf("cannot register %s: gob name %q is taken by another type %s", t, name, other)
+	}
+	// gob knows names registered without safeRegister too.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot register %s: %v", t, r)
+		}
+	}()
+	gob.Register(v)
+	registered[t] = true
+	registeredNames[name] = t
+	return nil
+}
+
+// registeredType returns the type registered under a gob interface name.
+func registeredType(name string) reflect.Type {
+	registerMu.Lock()
+	defer registerMu.Unlock()
+	return registeredNames[name]
+}
+
+// gobName is the name gob.Register gives t: the package path qualified
+// name for named types and t.String() for the rest, pointers included.
+func gobName(t reflect.Type) string {
+	if t.Name() == "" {
+		return t.String()
+	}
+	if t.PkgPath() == "" {
+		return t.Name()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
@@ -0,0 +1,91 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type registerA struct{ N int }
+type registerB struct{ S string }
+
+// TestSafeRegisterConcurrent is meant for go test -race: gob.Register
+// from many goroutines at once must neither race nor panic on repeats.
+func TestSafeRegisterConcurrent(t *testing.T) {
+	values := []interface{}{registerA{}, registerB{}, map[string]registerB{}, []registerA{}}
+	var wg sync.WaitGroup
+	errs := make(chan error, 64*len(values))
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, v := range values {
+				if err := safeRegister(v); err != nil {
+					errs <- err
+				}
+			}
+			registerKnownTypes()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var buf bytes.Buffer
+	in := map[string]interface{}{"a": registerA{1}, "b": []registerA{{2}}}
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out["a"] != (registerA{1}) || out["b"].([]registerA)[0].N != 2 {
+		t.Errorf("round trip gave %#v", out)
+	}
+}
+
+func TestSafeRegisterNameClash(t *testing.T) {
+	// Two types local to different functions share a gob name.
+	first := func() interface{} {
+		type clash struct{ A int }
+		return clash{}
+	}()
+	second := func() interface{} {
+		type clash struct{ B string }
+		return clash{}
+	}()
+	if err := safeRegister(first); err != nil {
+		t.Fatal(err)
+	}
+	err := safeRegister(second)
+	if err == nil || !strings.Contains(err.Error(), "is taken by") {
+		t.Errorf("got %v, want a name clash error", err)
+	}
+	if err := safeRegister(first); err != nil {
+		t.Errorf("registering the first type again: %v", err)
+	}
+}
+
+// Clashes gob itself detects, for names registered without safeRegister
+// or for a type and its pointer, are errors too rather than panics.
+func TestSafeRegisterClashInGob(t *testing.T) {
+	type outside struct{ A int }
+	type inside struct{ B int }
+	gob.RegisterName(gobName(reflect.TypeOf(inside{})), outside{})
+	if err := safeRegister(inside{}); err == nil {
+		t.Error("registering a name gob gives another type succeeded")
+	}
+
+	if err := safeRegister(registerB{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := safeRegister(&registerB{}); err == nil {
+		t.Error("registering both a type and its pointer succeeded")
+	}
+}
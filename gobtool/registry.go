@@ -0,0 +1,268 @@
+package gobtool
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The registry generator writes the gob.Register calls a package needs so
+// that forgetting one is caught when the code is written instead of when a
+// decode fails. It works on syntax alone: a type is registered when its
+// declaration carries a //gob:register comment, or when a composite
+// literal or conversion of it is stored as a map element, either through
+// an index assignment (m[k] = T{...}) or inside a map literal whose
+// element type is interface{}.
+
+const registryGenFile = "gob_registrations_gen.go"
+
+// regType is a type the generator has decided to register.
+type regType struct {
+	name string
+	ptr  bool // seen as &T{...}, so register *T
+	lit  bool // T{} is a valid zero value, otherwise use *new(T)
+}
+
+// registryGenFlags registers the flags of registry gen; the command
+// returned implements "registry gen patterns...".
+func registryGenFlags(fset *flag.FlagSet) func(args []string) error {
+	dryRun := fset.Bool("n", false, "print the files instead of writing them")
+	return func(patterns []string) error {
+		if len(patterns) == 0 {
+			patterns = []string{"."}
+		}
+		dirs, err := expandPatterns(patterns)
+		if err != nil {
+			return err
+		}
+		for _, dir := range dirs {
+			if err := genRegistrations(dir, *dryRun); err != nil {
+				return fmt.Errorf("%s: %w", dir, err)
+			}
+		}
+		return nil
+	}
+}
+
+// expandPatterns turns "dir" and "dir/..." arguments into directories.
+func expandPatterns(patterns []string) ([]string, error) {
+	var dirs []string
+	for _, p := range patterns {
+		root, recursive := strings.CutSuffix(p, "...")
+		root = filepath.Clean(root)
+		if !recursive {
+			dirs = append(dirs, root)
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			name := d.Name()
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dirs, nil
+}
+
+// genRegistrations scans the package in dir and writes its registration
+// file, or removes a stale one when nothing needs registering.
+func genRegistrations(dir string, dryRun bool) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != registryGenFile
+	}, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	for name, pkg := range pkgs {
+		types, skipped := scanRegistrations(pkg)
+		for _, s := range skipped {
+			fmt.Fprintf(os.Stderr, "%s: skipped %s\n", dir, s)
+		}
+		out := filepath.Join(dir, registryGenFile)
+		if len(types) == 0 {
+			if !dryRun {
+				if err := removeFile(out); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			continue
+		}
+		src, err := registrationSource(name, types)
+		if err != nil {
+			return err
+		}
+		if dryRun {
+			fmt.Printf("// %s\n%s", out, src)
+			continue
+		}
+		if old, err := os.ReadFile(out); err == nil && bytes.Equal(old, src) {
+			continue
+		}
+		if err := writeFileAtomic(out, src); err != nil {
+			return err
+		}
+		fmt.Printf("wrote %s (%d types)\n", out, len(types))
+	}
+	return nil
+}
+
+// scanRegistrations finds the types of pkg to register, and describes the
+// candidates it had to skip.
+func scanRegistrations(pkg *ast.Package) ([]regType, []string) {
+	decls := make(map[string]*ast.TypeSpec)
+	tagged := make(map[string]bool)
+	for _, f := range pkg.Files {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				decls[ts.Name.Name] = ts
+				doc := ts.Doc
+				if doc == nil && len(gd.Specs) == 1 {
+					doc = gd.Doc
+				}
+				if hasRegisterTag(doc) {
+					tagged[ts.Name.Name] = true
+				}
+			}
+		}
+	}
+
+	used := make(map[string]bool) // name -> seen as a pointer
+	for name := range tagged {
+		used[name] = false
+	}
+	note := func(e ast.Expr) {
+		ptr := false
+		if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			e, ptr = u.X, true
+		}
+		var typ ast.Expr
+		switch x := e.(type) {
+		case *ast.CompositeLit:
+			typ = x.Type
+		case *ast.CallExpr:
+			// a conversion such as Count(1)
+			if len(x.Args) == 1 {
+				typ = x.Fun
+			}
+		}
+		id, ok := typ.(*ast.Ident)
+		if !ok || decls[id.Name] == nil {
+			return
+		}
+		used[id.Name] = used[id.Name] || ptr
+	}
+	for _, f := range pkg.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if _, ok := lhs.(*ast.IndexExpr); ok && i < len(n.Rhs) {
+						note(n.Rhs[i])
+					}
+				}
+			case *ast.CompositeLit:
+				if mt, ok := n.Type.(*ast.MapType); ok && isEmptyInterface(mt.Value) {
+					for _, elt := range n.Elts {
+						if kv, ok := elt.(*ast.KeyValueExpr); ok {
+							note(kv.Value)
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	var types []regType
+	var skipped []string
+	for name, ptr := range used {
+		ts := decls[name]
+		switch {
+		case !ast.IsExported(name):
+			skipped = append(skipped, name+": unexported")
+		case ts.TypeParams != nil:
+			skipped = append(skipped, name+": generic")
+		case !encodableTypeExpr(ts.Type):
+			skipped = append(skipped, name+": gob cannot encode its kind")
+		default:
+			_, basic := ts.Type.(*ast.Ident)
+			types = append(types, regType{name, ptr, !basic})
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].name < types[j].name })
+	sort.Strings(skipped)
+	return types, skipped
+}
+
+func hasRegisterTag(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == "//gob:register" {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyInterface(e ast.Expr) bool {
+	if it, ok := e.(*ast.InterfaceType); ok {
+		return len(it.Methods.List) == 0
+	}
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "any"
+}
+
+// encodableTypeExpr rules out the kinds gob refuses outright. Named types
+// from elsewhere are assumed encodable.
+func encodableTypeExpr(e ast.Expr) bool {
+	switch e.(type) {
+	case *ast.FuncType, *ast.ChanType, *ast.InterfaceType:
+		return false
+	}
+	return true
+}
+
+// registrationSource renders the gofmt-clean registration file.
+func registrationSource(pkg string, types []regType) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by \"registry gen\"; DO NOT EDIT.\n\npackage %s\n\nimport \"encoding/gob\"\n\nfunc init() {\n", pkg)
+	for _, t := range types {
+		switch {
+		case t.ptr && t.lit:
+			fmt.Fprintf(&buf, "\tgob.Register(&%s{})\n", t.name)
+		case t.ptr:
+			fmt.Fprintf(&buf, "\tgob.Register(new(%s))\n", t.name)
+		case t.lit:
+			fmt.Fprintf(&buf, "\tgob.Register(%s{})\n", t.name)
+		default:
+			fmt.Fprintf(&buf, "\tgob.Register(*new(%s))\n", t.name)
+		}
+	}
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,402 @@
+package gobtool
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Renamed fields are decoded in two steps: the stream is first read into
+// the generic form wireReader produces, then copied into the typed target
+// by reflection, looking each wire field up under its renamed Go name.
+// gob itself matches fields by name only, so without this a field renamed
+// by the writer is silently dropped.
+
+// RenameRules maps, per Go struct type name, wire field names to the Go
+// field names they are stored in.
+type RenameRules map[string]map[string]string
+
+// parseRenameRules parses rules of the form "Type.WireField=GoField". A
+// wire field renamed twice, or two wire fields renamed into the same Go
+// field, is an error.
+func parseRenameRules(specs []string) (RenameRules, error) {
+	rules := make(RenameRules)
+	for _, spec := range specs {
+		lhs, goName, ok := strings.Cut(spec, "=")
+		typ, wireName, ok2 := strings.Cut(lhs, ".")
+		typ, wireName, goName = strings.TrimSpace(typ), strings.TrimSpace(wireName), strings.TrimSpace(goName)
+		if !ok || !ok2 || typ == "" || wireName == "" || goName == "" {
+			return nil, fmt.Errorf("rename %q: want Type.WireField=GoField", spec)
+		}
+		fields := rules[typ]
+		if fields == nil {
+			fields = make(map[string]string)
+			rules[typ] = fields
+		}
+		if prev, ok := fields[wireName]; ok && prev != goName {
+			return nil, fmt.Errorf("rename %q: %s.%s is already renamed to %s", spec, typ, wireName, prev)
+		}
+		for w, g := range fields {
+			if g == goName && w != wireName {
+				return nil, fmt.Errorf("rename %q: %s.%s is already the target of %s", spec, typ, goName, w)
+			}
+		}
+		fields[wireName] = goName
+	}
+	return rules, nil
+}
+
+// readRenameFile reads one rule per line; blank lines and lines starting
+// with # are ignored.
+func readRenameFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var specs []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			specs = append(specs, line)
+		}
+	}
+	return specs, sc.Err()
+}
+
+// DecodeRenamed decodes one value from r into target, which must be a
+// non-nil pointer, applying rules to struct fields. It returns the paths
+// of the wire fields that had no Go field to go to; renamed fields count
+// as matched.
+func DecodeRenamed(r io.Reader, target interface{}, rules RenameRules) (dropped []string, err error) {
+	dst := reflect.ValueOf(target)
+	if dst.Kind() != reflect.Pointer || dst.IsNil() {
+		return nil, fmt.Errorf("decode target must be a non-nil pointer, not %T", target)
+	}
+	w := newWireReader(r, 0)
+	w.named = true
+	id, err := w.nextMessage()
+	if err != nil {
+		return nil, err
+	}
+	v, err := w.topValue(id, true)
+	if err != nil {
+		return nil, err
+	}
+	a := &assigner{rules: rules}
+	if err := a.assign(dst.Elem(), v, ""); err != nil {
+		return nil, err
+	}
+	return a.dropped, nil
+}
+
+type assigner struct {
+	rules   RenameRules
+	dropped []string
+}
+
+// assign stores the generically decoded src into dst.
+func (a *assigner) assign(dst reflect.Value, src interface{}, path string) error {
+	if named, ok := src.(wireNamed); ok {
+		return a.assignNamed(dst, named, path)
+	}
+	if src == nil {
+		return nil
+	}
+	t := dst.Type()
+	if b, ok := src.([]byte); ok && t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return unmarshalInto(dst, b, path)
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		p := reflect.New(t.Elem())
+		if err := a.assign(p.Elem(), src, path); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	case reflect.Interface:
+		// No Go type to aim at: keep the generic form, but rebuild its
+		// containers so that values held in interfaces below find theirs.
+		st := reflect.TypeOf(src)
+		switch src.(type) {
+		case map[interface{}]interface{}, map[string]interface{}, []interface{}:
+			v := reflect.New(st).Elem()
+			if err := a.assign(v, src, path); err != nil {
+				return err
+			}
+			src = v.Interface()
+		}
+		if !st.AssignableTo(t) {
+			return fmt.Errorf("%s: %T does not fit %s", pathOrRoot(path), src, t)
+		}
+		dst.Set(reflect.ValueOf(src))
+		return nil
+	case reflect.Struct:
+		fields, ok := structFields(src)
+		if !ok {
+			return fmt.Errorf("%s: cannot store %T in %s", pathOrRoot(path), src, t)
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			goName := name
+			if renamed, ok := a.rules[t.Name()][name]; ok {
+				goName = renamed
+			}
+			sf, ok := t.FieldByName(goName)
+			if !ok {
+				sf, ok = fieldByJSONName(t, goName)
+			}
+			if !ok || !sf.IsExported() {
+				a.dropped = append(a.dropped, joinPath(path, name))
+				continue
+			}
+			if err := a.assign(dst.FieldByIndex(sf.Index), fields[name], joinPath(path, goName)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m := reflect.MakeMap(t)
+		err := eachGenericEntry(src, func(k, v interface{}) error {
+			kv := reflect.New(t.Key()).Elem()
+			if err := a.assign(kv, k, path); err != nil {
+				return err
+			}
+			ev := reflect.New(t.Elem()).Elem()
+			if err := a.assign(ev, v, joinPath(path, pathKey(unwrapNamed(k)))); err != nil {
+				return err
+			}
+			m.SetMapIndex(kv, ev)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", pathOrRoot(path), err)
+		}
+		dst.Set(m)
+		return nil
+	case reflect.Slice, reflect.Array:
+		if b, ok := src.([]byte); ok && t.Elem().Kind() == reflect.Uint8 {
+			if t.Kind() == reflect.Slice {
+				dst.SetBytes(append([]byte(nil), b...))
+			} else {
+				reflect.Copy(dst, reflect.ValueOf(b))
+			}
+			return nil
+		}
+		elems, ok := src.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: cannot store %T in %s", pathOrRoot(path), src, t)
+		}
+		if t.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(t, len(elems), len(elems)))
+		} else if len(elems) > t.Len() {
+			return fmt.Errorf("%s: %d elements do not fit %s", pathOrRoot(path), len(elems), t)
+		}
+		for i, e := range elems {
+			if err := a.assign(dst.Index(i), e, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return assignScalar(dst, src, path)
+}
+
+// assignNamed handles an interface value, decoding it as the Go type its
+// wire name was registered for when the destination is an interface.
+func (a *assigner) assignNamed(dst reflect.Value, named wireNamed, path string) error {
+	if dst.Kind() != reflect.Interface {
+		return a.assign(dst, named.Value, path)
+	}
+	rt := registeredType(named.Name)
+	if rt == nil || !rt.AssignableTo(dst.Type()) {
+		return a.assign(dst, named.Value, path)
+	}
+	v := reflect.New(rt).Elem()
+	if err := a.assign(v, named.Value, path); err != nil {
+		return err
+	}
+	dst.Set(v)
+	return nil
+}
+
+// unmarshalInto hands the payload of a GobEncoder, BinaryMarshaler or
+// TextMarshaler back to the matching method on dst.
+func unmarshalInto(dst reflect.Value, b []byte, path string) error {
+	if dst.Kind() == reflect.Pointer {
+		p := reflect.New(dst.Type().Elem())
+		if err := unmarshalInto(p.Elem(), b, path); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	}
+	var err error
+	switch u := dst.Addr().Interface().(type) {
+	case gob.GobDecoder:
+		err = u.GobDecode(b)
+	case encoding.BinaryUnmarshaler:
+		err = u.UnmarshalBinary(b)
+	case encoding.TextUnmarshaler:
+		err = u.UnmarshalText(b)
+	default:
+		return fmt.Errorf("%s: cannot store encoded bytes in %s", pathOrRoot(path), dst.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", pathOrRoot(path), err)
+	}
+	return nil
+}
+
+// structFields returns the fields of a generic struct, or the entries of
+// a generic map, which can fill a struct too. Map keys that are not
+// strings match no field and end up among the dropped.
+func structFields(src interface{}) (map[string]interface{}, bool) {
+	switch m := src.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		fields := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			fields[pathKey(unwrapNamed(k))] = v
+		}
+		return fields, true
+	}
+	return nil, false
+}
+
+// fieldByJSONName finds the field of t whose json tag names it name.
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func eachGenericEntry(src interface{}, fn func(k, v interface{}) error) error {
+	switch m := src.(type) {
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for k, v := range m {
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot store %T in a map", src)
+	}
+	return nil
+}
+
+// assignScalar converts a generic int64, uint64, float64, complex128,
+// bool or string into dst, refusing values that would overflow.
+func assignScalar(dst reflect.Value, src interface{}, path string) error {
+	v := reflect.ValueOf(src)
+	ok := false
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.CanInt() && !dst.OverflowInt(v.Int()) {
+			dst.SetInt(v.Int())
+			ok = true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.CanUint() && !dst.OverflowUint(v.Uint()) {
+			dst.SetUint(v.Uint())
+			ok = true
+		}
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case v.CanFloat() && !dst.OverflowFloat(v.Float()):
+			dst.SetFloat(v.Float())
+			ok = true
+		case v.CanInt() && int64(float64(v.Int())) == v.Int():
+			// a JSON Schema "number" holds integers too; take them when exact
+			dst.SetFloat(float64(v.Int()))
+			ok = true
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if v.CanComplex() {
+			dst.SetComplex(v.Complex())
+			ok = true
+		}
+	case reflect.Bool:
+		if v.Kind() == reflect.Bool {
+			dst.SetBool(v.Bool())
+			ok = true
+		}
+	case reflect.String:
+		if v.Kind() == reflect.String {
+			dst.SetString(v.String())
+			ok = true
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%s: cannot store %v (%T) in %s", pathOrRoot(path), src, src, dst.Type())
+	}
+	return nil
+}
+
+// unwrapNamed strips the wireNamed wrappers from a generic value.
+func unwrapNamed(v interface{}) interface{} {
+	for {
+		n, ok := v.(wireNamed)
+		if !ok {
+			return v
+		}
+		v = n.Value
+	}
+}
+
+// unwrapNamedAll strips the wireNamed wrappers from a generic value and
+// everything in it, for walking with walkPaths.
+func unwrapNamedAll(v interface{}) interface{} {
+	switch v := unwrapNamed(v).(type) {
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			m[unwrapNamed(k)] = unwrapNamedAll(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = unwrapNamedAll(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = unwrapNamedAll(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
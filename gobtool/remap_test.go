@@ -0,0 +1,101 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// remapV1 is a record as an old writer sent it; remapV2 is the reader's
+// version, with FullName renamed to Name and Legacy removed.
+type remapV1 struct {
+	FullName string
+	Age      int
+	Legacy   string
+}
+
+type remapV2 struct {
+	Name string
+	Age  int
+}
+
+func remapStream(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(remapV1{"Ada Lovelace", 36, "x"}); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestDecodeRenamed(t *testing.T) {
+	rules, err := parseRenameRules([]string{"remapV2.FullName=Name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got remapV2
+	dropped, err := DecodeRenamed(remapStream(t), &got, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (remapV2{"Ada Lovelace", 36}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if want := []string{"Legacy"}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("dropped %v, want %v", dropped, want)
+	}
+
+	// Without the rule gob's own matching drops the renamed field.
+	got = remapV2{}
+	dropped, err = DecodeRenamed(remapStream(t), &got, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "" || len(dropped) != 2 {
+		t.Errorf("without rules: got %+v, dropped %v", got, dropped)
+	}
+}
+
+func TestDecodeRenamedTarget(t *testing.T) {
+	var v remapV2
+	if _, err := DecodeRenamed(remapStream(t), v, nil); err == nil {
+		t.Error("non-pointer target: no error")
+	}
+}
+
+func TestParseRenameRules(t *testing.T) {
+	for _, c := range []struct {
+		specs []string
+		want  string
+	}{
+		{[]string{"T.A=B", "T.A=B", "U.A=C"}, ""},
+		{[]string{"T.A"}, "want Type.WireField=GoField"},
+		{[]string{"A=B"}, "want Type.WireField=GoField"},
+		{[]string{"T.=B"}, "want Type.WireField=GoField"},
+		{[]string{"T.A=B", "T.A=C"}, "already renamed to B"},
+		{[]string{"T.A=C", "T.B=C"}, "already the target of A"},
+	} {
+		_, err := parseRenameRules(c.specs)
+		if c.want == "" && err != nil || c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)) {
+			t.Errorf("%q: got %v, want %q", c.specs, err, c.want)
+		}
+	}
+}
+
+func TestReadRenameFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "renames")
+	if err := os.WriteFile(name, []byte("# renames\nT.A=B\n\n  U.C=D  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	specs, err := readRenameFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"T.A=B", "U.C=D"}; !reflect.DeepEqual(specs, want) {
+		t.Errorf("got %q, want %q", specs, want)
+	}
+}
@@ -0,0 +1,232 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// rename-key renames map keys across every value of a stream or every
+// file of a directory, such as a key a buggy release misspelled in all
+// the sessions it wrote. Keys are matched with a path glob, so they are
+// found at any depth; values and all other keys are kept. Like session
+// gc it only reports what it would do until it is given -yes. Files are
+// rewritten atomically, in their original layout and types: records sent
+// as interfaces are sent as interfaces again, and the rest as the types
+// they were sent as.
+
+// renameConflict says what to do when the new name is already a key of
+// the same map.
+type renameConflict string
+
+const (
+	conflictSkip      renameConflict = "skip"      // leave both keys as they are
+	conflictOverwrite renameConflict = "overwrite" // replace the existing value
+	conflictError     renameConflict = "error"     // fail before writing anything
+)
+
+// keyRenamer renames the keys from matches to the name to.
+type keyRenamer struct {
+	from       pathGlob
+	to         string
+	onConflict renameConflict
+
+	renamed   []string // paths of the keys renamed
+	conflicts []string // paths of the keys left alone for a conflict
+}
+
+// rename renames the matching keys in v, the value at segs.
+func (kr *keyRenamer) rename(v reflect.Value, segs []string) {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return sprint(keys[i]) < sprint(keys[j]) })
+		for _, k := range keys {
+			name, ok := indirect(k).Interface().(string)
+			if !ok {
+				kr.rename(v.MapIndex(k), append(segs, sprint(k)))
+				continue
+			}
+			p := append(segs[:len(segs):len(segs)], name)
+			kr.rename(v.MapIndex(k), p)
+			if name == kr.to || !kr.from.match(p) {
+				continue
+			}
+			nk := reflect.ValueOf(kr.to)
+			if k.Kind() != reflect.Interface {
+				nk = nk.Convert(k.Type())
+			}
+			if v.MapIndex(nk).IsValid() && kr.onConflict != conflictOverwrite {
+				kr.conflicts = append(kr.conflicts, joinSegs(p))
+				continue
+			}
+			v.SetMapIndex(nk, v.MapIndex(k))
+			v.SetMapIndex(k, reflect.Value{})
+			kr.renamed = append(kr.renamed, joinSegs(p))
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			kr.rename(v.Index(i), append(segs, fmt.Sprintf("[%d]", i)))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				kr.rename(v.Field(i), append(segs, v.Type().Field(i).Name))
+			}
+		}
+	}
+}
+
+// joinSegs renders segments from rename as a path.
+func joinSegs(segs []string) string {
+	var b strings.Builder
+	for _, s := range segs {
+		if !strings.HasPrefix(s, "[") && b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// renameTarget is one input file and what renaming did to it.
+type renameTarget struct {
+	name      string
+	values    []interface{}
+	types     []reflect.Type // the type each value goes back out as
+	renamed   []string
+	conflicts []string
+}
+
+func renameKeyFlags(fs *flag.FlagSet) func(args []string) error {
+	from := fs.String("from", "", "path glob of the keys to rename, such as user_infp or *.user_infp")
+	to := fs.String("to", "", "the new name of the keys, which stay in the same map")
+	onConflict := fs.String("on-conflict", "skip", "when the new name is already a key: skip, overwrite or error")
+	yes := fs.Bool("yes", false, "rewrite the files; without it nothing is written")
+	return func(args []string) error {
+		if len(args) == 0 || *from == "" || *to == "" {
+			return errors.New("usage: rename-key -from glob -to name [-on-conflict skip|overwrite|error] [-yes] file-or-dir...")
+		}
+		glob, err := parsePathGlob(*from)
+		if err != nil {
+			return fmt.Errorf("-from: %w", err)
+		}
+		if strings.ContainsAny(*to, ".[") {
+			return fmt.Errorf("-to %q: want a key name, not a path", *to)
+		}
+		policy := renameConflict(*onConflict)
+		switch policy {
+		case conflictSkip, conflictOverwrite, conflictError:
+		default:
+			return fmt.Errorf("-on-conflict %q: want skip, overwrite or error", *onConflict)
+		}
+
+		registerKnownTypes()
+		names, err := optimizeInputs(args)
+		if err != nil {
+			return err
+		}
+		// Rename everything in memory first, so -on-conflict error fails
+		// before any file is written.
+		var files []*renameTarget
+		var failed int
+		for _, name := range names {
+			rf, err := readRenameTarget(name)
+			if err != nil {
+				failed++
+				fmt.Printf("failed\t%s\t%v\n", name, err)
+				continue
+			}
+			kr := &keyRenamer{from: glob, to: *to, onConflict: policy}
+			for i, v := range rf.values {
+				seg := []string{}
+				if len(rf.values) > 1 {
+					seg = append(seg, fmt.Sprintf("[%d]", i))
+				}
+				kr.rename(reflect.ValueOf(v), seg)
+			}
+			rf.renamed, rf.conflicts = kr.renamed, kr.conflicts
+			if policy == conflictError && len(rf.conflicts) > 0 {
+				return fmt.Errorf("%s: %s is already a key at %s; nothing written", name, *to, rf.conflicts[0])
+			}
+			files = append(files, rf)
+		}
+
+		action := "would rename"
+		if *yes {
+			action = "renamed"
+		}
+		var touched, occurrences, conflicts int
+		for _, rf := range files {
+			for _, p := range rf.conflicts {
+				fmt.Printf("conflict\t%s\t%s: %s already exists, skipped\n", rf.name, p, *to)
+			}
+			conflicts += len(rf.conflicts)
+			if len(rf.renamed) == 0 {
+				continue
+			}
+			if *yes {
+				if err := writeRenameTarget(rf); err != nil {
+					failed++
+					fmt.Printf("failed\t%s\t%v\n", rf.name, err)
+					continue
+				}
+			}
+			touched++
+			occurrences += len(rf.renamed)
+			for _, p := range rf.renamed {
+				fmt.Printf("%s\t%s\t%s -> %s\n", action, rf.name, p, *to)
+			}
+		}
+		fmt.Printf("%d files scanned, %d touched, %d keys %s, %d conflicts skipped, %d failed\n",
+			len(names), touched, occurrences, action, conflicts, failed)
+		if !*yes && occurrences > 0 {
+			fmt.Println("dry run: rerun with -yes to write")
+		}
+		if failed > 0 {
+			return exitCode(1)
+		}
+		return nil
+	}
+}
+
+// readRenameTarget reads every value of a plain gob stream. Wrapped data,
+// such as gzip, is refused, since it could not be written back the same.
+func readRenameTarget(name string) (*renameTarget, error) {
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if l, ok := sniffLayer(bufio.NewReader(bytes.NewReader(raw))); ok {
+		return nil, fmt.Errorf("input is %s (%s); only plain gob streams can be rewritten", l.Name, l.Evidence)
+	}
+	values, types, err := readStreamValues(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &renameTarget{name: name, values: values, types: types}, nil
+}
+
+// writeRenameTarget writes rf back with each value as the type it was
+// sent as, and decodes the result into those types, as a reader of the
+// file would, to check every value came out as renamed.
+func writeRenameTarget(rf *renameTarget) error {
+	data, err := encodeStreamValues(rf.values, rf.types)
+	if err != nil {
+		return err
+	}
+	if err := verifyTyped(bytes.NewReader(data), rf.values, rf.types, false); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	return writeFileAtomic(rf.name, data)
+}
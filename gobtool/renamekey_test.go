@@ -0,0 +1,57 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type renameKeyRecord struct {
+	ID   int
+	Meta map[string]string
+}
+
+// Renamed files must still decode as the types they were written with.
+func TestRenameKeyKeepsTypes(t *testing.T) {
+	registerKnownTypes()
+	name := filepath.Join(t.TempDir(), "s.gob")
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for _, v := range []interface{}{
+		map[string]interface{}{"user_infp": map[string]interface{}{"id": 7}, "keep": "x"},
+		renameKeyRecord{ID: 1, Meta: map[string]string{"user_infp": "ada"}},
+	} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCaptured(t, "rename-key", "-from", "*user_infp", "-to", "user_info", "-yes", name); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	dec := gob.NewDecoder(f)
+	var m map[string]interface{}
+	var rec renameKeyRecord
+	if err := dec.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.Decode(&rec); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"user_info": map[string]interface{}{"id": 7}, "keep": "x"}; !reflect.DeepEqual(m, want) {
+		t.Errorf("record 0 = %#v, want %#v", m, want)
+	}
+	if want := (renameKeyRecord{ID: 1, Meta: map[string]string{"user_info": "ada"}}); !reflect.DeepEqual(rec, want) {
+		t.Errorf("record 1 = %#v, want %#v", rec, want)
+	}
+}
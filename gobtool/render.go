@@ -0,0 +1,133 @@
+package gobtool
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A renderer writes decoded data in one output format. Formats are
+// registered once in renderers and are then available both as decode's
+// -format and as its -out-<format> file outputs.
+type renderer interface {
+	render(w io.Writer, data interface{}) error
+}
+
+type rendererFunc func(w io.Writer, data interface{}) error
+
+func (f rendererFunc) render(w io.Writer, data interface{}) error { return f(w, data) }
+
+// renderOptions are the format options decode passes on.
+type renderOptions struct {
+	flattenDepth   int
+	showUnexported bool
+	collapse       bool // tree: -collapse-singletons
+	guessJSON      bool
+	goPackage      string    // for -format gofile
+	warnings       *Warnings // what lossy formats lose is added here
+}
+
+var renderers = map[string]func(o renderOptions) renderer{
+	"tree": func(o renderOptions) renderer {
+		return rendererFunc(func(w io.Writer, data interface{}) error {
+			t := &treeWriter{w: w, showUnexported: o.showUnexported, guessJSON: o.guessJSON, collapse: o.collapse}
+			t.value(data, 0, cycleGuard{})
+			return t.err
+		})
+	},
+	"flat": func(o renderOptions) renderer {
+		return rendererFunc(func(w io.Writer, data interface{}) error {
+			return flatWriter{maxDepth: o.flattenDepth, guessJSON: o.guessJSON}.write(w, data)
+		})
+	},
+	"dot": func(renderOptions) renderer { return rendererFunc(writeDot) },
+	"json": func(o renderOptions) renderer {
+		return rendererFunc(func(w io.Writer, data interface{}) error { return writeJSON(w, data, o.warnings) })
+	},
+	"interchange": func(o renderOptions) renderer {
+		return rendererFunc(func(w io.Writer, data interface{}) error { return writeInterchange(w, data, o.warnings) })
+	},
+	"gofile": func(o renderOptions) renderer {
+		return rendererFunc(func(w io.Writer, data interface{}) error { return writeGoFile(w, data, o.goPackage) })
+	},
+	"jsonschema": func(o renderOptions) renderer {
+		return rendererFunc(func(w io.Writer, data interface{}) error {
+			return writeJSONSchema(w, []interface{}{data}, o.warnings)
+		})
+	},
+	"toml": func(o renderOptions) renderer {
+		return rendererFunc(func(w io.Writer, data interface{}) error { return writeTOML(w, data, o.warnings) })
+	},
+}
+
+func rendererNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeJSON writes data as indented JSON, converted by toJSONWarn.
+func writeJSON(w io.Writer, data interface{}, ws *Warnings) error {
+	v, err := toJSONWarn(data, ws)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// outputTarget is a file to render a format into.
+type outputTarget struct {
+	format string
+	file   string
+}
+
+// outputFlags are decode's -out-<format> and -tee flags.
+type outputFlags struct {
+	targets []outputTarget
+	tee     string
+}
+
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	for _, name := range rendererNames() {
+		fs.Func("out-"+name, "also write the "+name+" rendering to this file (repeatable)", func(file string) error {
+			o.targets = append(o.targets, outputTarget{name, file})
+			return nil
+		})
+	}
+	fs.StringVar(&o.tee, "tee", "", "also write the -format output to this file")
+}
+
+// write renders data into every target file, each written atomically.
+// A failed target is reported on stderr and does not stop the others;
+// the error returned says how many failed.
+func (o *outputFlags) write(data interface{}, primary string, opts renderOptions) error {
+	targets := o.targets
+	if o.tee != "" {
+		targets = append(targets[:len(targets):len(targets)], outputTarget{primary, o.tee})
+	}
+	var failed []string
+	for _, t := range targets {
+		r := renderers[t.format](opts)
+		err := writeAtomic(t.file, func(f *os.File) error {
+			return r.render(f, data)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s output %s: %v\n", t.format, t.file, err)
+			failed = append(failed, t.file)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d outputs failed: %s", len(failed), len(targets), strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package gobtool
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"test-gob/errclass"
+)
+
+// -retries N retries a decode that failed reading its input, such as a
+// file on a network mount that returned EIO, up to N more times. Each
+// attempt opens the source again and starts the decode over; the waits
+// between them start at -retry-backoff and double, up to
+// maxRetryBackoff. Only errors ClassifyError puts in io-error are
+// retried, and not a file that is missing or forbidden: a format error
+// would fail the same way every time.
+
+const maxRetryBackoff = 30 * time.Second
+
+// errNoRetry wraps an error that cannot be retried, so do stops at it.
+var errNoRetry = errors.New("cannot retry")
+
+// retryPolicy holds the -retries flags.
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+	sleep   func(time.Duration) // time.Sleep, unless a test waits less
+	log     io.Writer           // where retries are reported, os.Stderr if nil
+}
+
+func (p *retryPolicy) register(fs *flag.FlagSet) {
+	fs.IntVar(&p.retries, "retries", 0, "retry a decode that fails reading its input up to this many times, opening it again each time")
+	fs.DurationVar(&p.backoff, "retry-backoff", 200*time.Millisecond, "with -retries, wait this long before the first retry, doubling after each")
+}
+
+// retryable reports whether err is a read failure worth trying again.
+func retryable(err error) bool {
+	if errors.Is(err, errNoRetry) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+	class, _ := ClassifyError(err)
+	return class == errclass.IOError
+}
+
+// do calls attempt, numbered from 0, until it succeeds, fails with an
+// error that is not retryable, or has been retried p.retries times.
+func (p *retryPolicy) do(name string, attempt func(n int) error) error {
+	sleep, log := p.sleep, p.log
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	if log == nil {
+		log = os.Stderr
+	}
+	wait := p.backoff
+	for n := 0; ; n++ {
+		err := attempt(n)
+		if err == nil || n >= p.retries || !retryable(err) {
+			return err
+		}
+		fmt.Fprintf(log, "%s: attempt %d of %d failed: %v; retrying in %s\n", name, n+1, p.retries+1, err, wait)
+		sleep(wait)
+		wait = min(2*wait, maxRetryBackoff)
+	}
+}
@@ -0,0 +1,94 @@
+package gobtool
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyReader fails with a read error, as a file on a bad mount would,
+// once it has given failAfter bytes.
+type flakyReader struct {
+	r         io.Reader
+	failAfter int
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.failAfter <= 0 {
+		return 0, &fs.PathError{Op: "read", Path: "flaky", Err: syscall.EIO}
+	}
+	n, err := f.r.Read(p[:min(len(p), f.failAfter)])
+	f.failAfter -= n
+	return n, err
+}
+
+// testRetryPolicy returns a policy of three retries that records its
+// waits in *waits instead of sleeping.
+func testRetryPolicy(waits *[]time.Duration) *retryPolicy {
+	return &retryPolicy{retries: 3, backoff: time.Millisecond, sleep: func(d time.Duration) { *waits = append(*waits, d) }, log: io.Discard}
+}
+
+func TestRetryReadError(t *testing.T) {
+	data := syntheticData(50, 1)
+	name := writeRoot(t, data)
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var waits []time.Duration
+	attempts := 0
+	var got interface{}
+	err = testRetryPolicy(&waits).do(name, func(n int) error {
+		attempts++
+		var r io.Reader = bytes.NewReader(raw)
+		if n == 0 {
+			r = &flakyReader{r: r, failAfter: len(raw) / 2}
+		}
+		var err error
+		got, err = decodeAny(r, name)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || fmt.Sprint(waits) != "[1ms]" {
+		t.Errorf("%d attempts, waits %v; want 2 attempts, waits [1ms]", attempts, waits)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Error("decodes to different data")
+	}
+}
+
+func TestRetryFormatError(t *testing.T) {
+	var waits []time.Duration
+	attempts := 0
+	err := testRetryPolicy(&waits).do("x.gob", func(int) error {
+		attempts++
+		_, err := decodeAny(bytes.NewReader([]byte("not a gob stream at all")), "x.gob")
+		return err
+	})
+	if err == nil || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want the error after 1", err, attempts)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var waits []time.Duration
+	attempts := 0
+	err := testRetryPolicy(&waits).do("x.gob", func(int) error {
+		attempts++
+		return &fs.PathError{Op: "read", Path: "flaky", Err: syscall.EIO}
+	})
+	if err == nil {
+		t.Error("lasting read error: no error")
+	}
+	if attempts != 4 || fmt.Sprint(waits) != "[1ms 2ms 4ms]" {
+		t.Errorf("%d attempts, waits %v; want 4 attempts, waits [1ms 2ms 4ms]", attempts, waits)
+	}
+}
@@ -0,0 +1,140 @@
+package gobtool
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// A gob file may hold any value at the top level: a map, a slice, a
+// struct, a bare string, even a nil interface. The tool used to decode
+// only maps, into map[interface{}]interface{}. Commands that show, search
+// or compare a file decode through decodeAny, which by default reads the
+// value in its generic form, so it works whatever the type. Values sent
+// behind interfaces come back as their registered Go types, but the rest
+// does not keep its own: a struct reads as map[string]interface{}, a
+// []string as []interface{} and a map[string]int as
+// map[interface{}]interface{}. That is fine to show and useless to write
+// back, so commands that rewrite a file read it with readTypedFile
+// instead, which keeps the Go type of every record; see typed.go.
+// -root-type overrides the choice when the generic form is not what is
+// wanted.
+
+// rootType is -root-type: "auto" for the generic decode, "map" for the
+// legacy map[interface{}]interface{} decode, or the name a type was
+// registered under, such as *sessions.Session.
+var rootType = "auto"
+
+// setRootType implements -root-type.
+func setRootType(s string) error {
+	if s != "auto" && s != "map" {
+		registerKnownTypes()
+		if registeredType(s) == nil {
+			return fmt.Errorf("%q is not auto, map or a registered type name", s)
+		}
+	}
+	rootType = s
+	return nil
+}
+
+const rootTypeUsage = "decode the top-level value as: auto (whatever it is), map (map[interface{}]interface{}) or a registered type name"
+
+// rootTypeOverride is the type -root-type has commands that rewrite a
+// file write every record back as, or nil under auto, when each record
+// keeps the type it was sent as.
+func rootTypeOverride() reflect.Type {
+	switch rootType {
+	case "auto":
+		return nil
+	case "map":
+		return reflect.TypeOf(map[interface{}]interface{}(nil))
+	}
+	return registeredType(rootType)
+}
+
+// decodeAny decodes the top-level value of r as rootType selects.
+// filename resolves store references relative to the file. Every
+// command and API that decodes a whole file for display comes through
+// here, so none of them is limited to map roots.
+func decodeAny(r io.Reader, filename string) (interface{}, error) {
+	br := bufio.NewReader(r)
+	if isSharded(br) {
+		// Reassemble the shards directly rather than as one stream.
+		registerKnownTypes()
+		return DecodeSharded(br, shardWorkers)
+	}
+	r = br
+	switch rootType {
+	case "auto":
+		registerKnownTypes()
+		r, err := openPayload(r, filename)
+		if err != nil {
+			return nil, err
+		}
+		rec, err := firstRecord(r)
+		return rec, payloadError(r, err)
+	case "map":
+		registerKnownTypes()
+		r, err := openPayload(r, filename)
+		if err != nil {
+			return nil, err
+		}
+		var m map[interface{}]interface{}
+		if err := gob.NewDecoder(r).Decode(&m); err != nil {
+			return nil, fmt.Errorf("decode: %w", payloadError(r, err))
+		}
+		return m, nil
+	}
+	return decodeSchema(r, filename, registeredType(rootType), false)
+}
+
+// decodeAnyFile is decodeAny for a named file.
+func decodeAnyFile(name string) (interface{}, error) {
+	f, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decodeAny(f, name)
+}
+
+// errEmptyStream is returned for a stream that ends before its first
+// value.
+var errEmptyStream = errors.New("empty gob stream")
+
+// firstRecord decodes the first value of a gob stream in its generic
+// form.
+func firstRecord(r io.Reader) (interface{}, error) {
+	rec, err := newRecordReader(r).next(true)
+	if errors.Is(err, io.EOF) {
+		return nil, errEmptyStream
+	}
+	return rec, err
+}
+
+// encodeRoot encodes v as the top-level value of a stream. gob cannot
+// encode a nil value, so a nil root is sent as a nil interface, which
+// decodes back to nil.
+func encodeRoot(enc *gob.Encoder, v interface{}) error {
+	if v == nil {
+		return enc.Encode(&v)
+	}
+	return enc.Encode(v)
+}
+
+// rootSummary describes the size of a top-level value for status lines.
+func rootSummary(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		return fmt.Sprintf("%d entries", rv.Len())
+	case reflect.Slice, reflect.Array:
+		return fmt.Sprintf("%d elements", rv.Len())
+	case reflect.Invalid:
+		return "nil"
+	}
+	return "a " + rv.Type().String()
+}
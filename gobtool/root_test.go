@@ -0,0 +1,195 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// nonMapRoots are top-level values other than maps, with the generic form
+// decodeAny gives them back in.
+var nonMapRoots = []struct {
+	name string
+	root interface{}
+	want interface{}
+}{
+	{"slice", []string{"a", "b"}, []interface{}{"a", "b"}},
+	{"string", "just a string", "just a string"},
+	{"struct", struct{ Name string }{"x"}, map[string]interface{}{"Name": "x"}},
+}
+
+func writeRoot(t *testing.T, root interface{}) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "root.gob")
+	if err := encodeAndWriteToFile(root, name); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestDecodeAnyNonMapRoots(t *testing.T) {
+	for _, c := range nonMapRoots {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := decodeAnyFile(writeRoot(t, c.root))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %#v, want %#v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestFileContentHashNonMapRoots(t *testing.T) {
+	for _, c := range nonMapRoots {
+		t.Run(c.name, func(t *testing.T) {
+			name := writeRoot(t, c.root)
+			if _, err := fileContentHash(name); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDecodeRangeNonMapRoot(t *testing.T) {
+	name := writeRoot(t, []string{"a", "b"})
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded := filepath.Join(t.TempDir(), "embedded.bin")
+	if err := os.WriteFile(embedded, append([]byte("HEADER"), raw...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeRange(embedded, ByteRange{Offset: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestDecodeTransformedNonMapRoot(t *testing.T) {
+	raw, err := os.ReadFile(writeRoot(t, []string{"a", "b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := builtinTransform("[*]", "upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeTransformed(bytes.NewReader(raw), "", []TransformRule{rule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"A", "B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestDeltaNonMapRoots(t *testing.T) {
+	base, err := decodeAnyFile(writeRoot(t, []string{"a", "b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := decodeAnyFile(writeRoot(t, "now a string"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := diffDelta(base, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := applyDelta(base, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "now a string" {
+		t.Errorf("got %#v", got)
+	}
+}
+
+// apply-delta writes its result back as the type of the base.
+func TestApplyDeltaKeepsRootType(t *testing.T) {
+	type point struct{ X, Y int }
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.gob")
+	target := filepath.Join(dir, "target.gob")
+	for name, p := range map[string]point{base: {1, 2}, target: {1, 5}} {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	delta := filepath.Join(dir, "d.delta")
+	out := filepath.Join(dir, "out.gob")
+	if _, err := runCaptured(t, "delta", base, target, "-out", delta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCaptured(t, "apply-delta", base, delta, "-out", out); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got point
+	if err := gob.NewDecoder(f).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != (point{1, 5}) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// rootTypeProbe is registered for TestDecodeRootTypeFlag.
+type rootTypeProbe struct {
+	Name string
+	Tags []string
+}
+
+// TestDecodeRootTypeFlag checks that decode -root-type decodes the root
+// as the registered type named, and refuses a name nothing is registered
+// under.
+func TestDecodeRootTypeFlag(t *testing.T) {
+	t.Cleanup(func() { rootType = "auto" })
+	if err := safeRegister(rootTypeProbe{}); err != nil {
+		t.Fatal(err)
+	}
+	name := writeRoot(t, rootTypeProbe{"x", []string{"a"}})
+	registered := gobName(reflect.TypeOf(rootTypeProbe{}))
+
+	out, err := runCaptured(t, "decode", "-root-type", registered, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Struct rootTypeProbe:\n  Field Name (string):\n    x (string)\n  Field Tags ([]string):\n"
+	if !strings.HasPrefix(out, want) {
+		t.Errorf("-root-type %s printed:\n%s\nwant it to start:\n%s", registered, out, want)
+	}
+
+	// decode exits on a flag error, so parse its flags here instead.
+	rootType = "auto"
+	fs := flag.NewFlagSet("decode", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	decodeFlags(fs)
+	err = fs.Parse([]string{"-root-type", registered + "Missing", name})
+	if err == nil || !strings.Contains(err.Error(), "not auto, map or a registered type name") {
+		t.Errorf("unregistered name: got %v", err)
+	}
+	if rootType != "auto" {
+		t.Errorf("a refused -root-type left rootType %q", rootType)
+	}
+}
@@ -0,0 +1,156 @@
+package gobtool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Two global flags, given before the command, make the tool safe to hand
+// to someone who should only look:
+//
+//	-read-only        refuse to replace or delete any existing file
+//	-audit-file file  append a JSON line for every file written or deleted
+//
+// GOB_READ_ONLY=1 and GOB_AUDIT_FILE set the defaults. Both are enforced
+// where files are changed, in writeAtomic and removeFile, rather than by
+// each command, so a command cannot forget them: rename-key -yes, gc,
+// session gc -yes, registry gen, hash -w and an encode over an existing
+// file are all refused the same way. Creating a new file, such as the
+// output of optimize, is still allowed and audited.
+
+// safety holds the global flags for the command being run.
+var safety struct {
+	readOnly bool
+	audit    *os.File
+	command  string
+}
+
+// errReadOnly is returned for a change refused by -read-only.
+var errReadOnly = errors.New("read-only mode")
+
+// parseGlobalFlags parses the flags before the command name and returns
+// the rest of args.
+func parseGlobalFlags(args []string) ([]string, error) {
+	fs := flag.NewFlagSet("global", flag.ExitOnError)
+	fs.BoolVar(&safety.readOnly, "read-only", os.Getenv("GOB_READ_ONLY") == "1", "refuse to replace or delete existing files (default from GOB_READ_ONLY=1)")
+	fs.IntVar(&brokenPipeExit, "broken-pipe-exit", 0, "exit status when the output is closed early, as by head")
+	fs.DurationVar(&runDeadline, "deadline", 0, "stop the whole run after this long and exit with status 124")
+	audit := fs.String("audit-file", os.Getenv("GOB_AUDIT_FILE"), "append a JSON line for every file written or deleted (default from GOB_AUDIT_FILE)")
+	fs.Usage = func() {
+		printUsage()
+		fmt.Fprintf(os.Stderr, "\nGlobal flags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *audit != "" {
+		f, err := os.OpenFile(*audit, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("-audit-file: %w", err)
+		}
+		safety.audit = f
+	}
+	return fs.Args(), nil
+}
+
+// checkMutable refuses, in read-only mode, to change an existing file.
+func checkMutable(name, what string) error {
+	if !safety.readOnly {
+		return nil
+	}
+	if _, err := os.Lstat(name); err != nil {
+		return nil
+	}
+	return fmt.Errorf("%w: refusing to %s %s", errReadOnly, what, name)
+}
+
+// removeFile deletes name, as os.Remove does, subject to -read-only and
+// -audit-file.
+func removeFile(name string) error {
+	if _, err := os.Lstat(name); err != nil {
+		return err
+	}
+	if err := checkMutable(name, "delete"); err != nil {
+		return err
+	}
+	before := auditHash(name)
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+	return auditChange("delete", name, before, "")
+}
+
+// auditRecord is one line of the -audit-file log.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Command string    `json:"command"`
+	Op      string    `json:"op"` // write or delete
+	Target  string    `json:"target"`
+	Before  string    `json:"before,omitempty"` // hash of the old file, if any
+	After   string    `json:"after,omitempty"`  // hash of the new file, if any
+}
+
+// auditHash returns the hash recorded for a file: for a gob stream its
+// CanonicalHash, as hash prints it, so a change can be checked against
+// the data itself; for anything else the SHA-256 of its bytes. A file
+// that does not exist, or audit logging that is off, gives "".
+func auditHash(name string) string {
+	if safety.audit == nil {
+		return ""
+	}
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	var values []interface{}
+	err = DecodeStream(bytes.NewReader(raw), func(rec interface{}) error {
+		values = append(values, rec)
+		return nil
+	})
+	if err == nil && len(values) > 0 {
+		var v interface{} = values
+		if len(values) == 1 {
+			v = values[0]
+		}
+		if h, err := CanonicalHash(v); err == nil {
+			return "canonical:" + hex.EncodeToString(h[:])
+		}
+	}
+	sum := sha256.Sum256(raw)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// auditChange appends a record of a change already made.
+func auditChange(op, name, before, after string) error {
+	if safety.audit == nil {
+		return nil
+	}
+	target := name
+	if abs, err := filepath.Abs(name); err == nil {
+		target = abs
+	}
+	b, err := json.Marshal(auditRecord{
+		Time:    time.Now().UTC(),
+		User:    os.Getenv("USER"),
+		Command: safety.command,
+		Op:      op,
+		Target:  target,
+		Before:  before,
+		After:   after,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := safety.audit.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("%s was changed, but the audit record failed: %w", name, err)
+	}
+	return nil
+}
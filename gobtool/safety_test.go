@@ -0,0 +1,111 @@
+package gobtool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"test-gob/sessionsource"
+)
+
+// TestReadOnly checks that -read-only refuses every way the tool changes
+// an existing file, from the primitives through the commands built on
+// them, and still lets new files be written.
+func TestReadOnly(t *testing.T) {
+	data := syntheticData(20, 1)
+	name := writeRoot(t, data)
+	dir := filepath.Dir(name)
+	storeDir := filepath.Join(dir, "store")
+	if _, err := EncodeToStore(storeDir, filepath.Join(dir, "ref"), data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "ref")); err != nil {
+		t.Fatal(err)
+	}
+	target := &renameTarget{name: name, values: []interface{}{"renamed"}}
+	defer func(old bool) { safety.readOnly = old }(safety.readOnly)
+	safety.readOnly = true
+
+	for _, c := range []struct {
+		what string
+		fn   func() error
+	}{
+		{"writeAtomic", func() error {
+			return writeAtomic(name, func(f *os.File) error { return nil })
+		}},
+		{"writeFileAtomic", func() error { return writeFileAtomic(name, nil) }},
+		{"removeFile", func() error { return removeFile(name) }},
+		{"encode", func() error { return encodeAndWriteToFile(data, name) }},
+		{"rename-key", func() error { return writeRenameTarget(target) }},
+		{"gc", func() error {
+			_, err := gcStore(storeDir, []string{dir}, false)
+			return err
+		}},
+		{"session write", func() error { return dirSource{dir}.Write(context.Background(), "root.gob", nil) }},
+		{"session delete", func() error { return dirSource{dir}.Delete(context.Background(), sessionsource.Ref{Name: name}) }},
+	} {
+		if err := c.fn(); !errors.Is(err, errReadOnly) {
+			t.Errorf("%s: got %v, want it refused", c.what, err)
+		}
+	}
+	if err := checkDecodesTo(name, data); err != nil {
+		t.Errorf("after refused changes: %v", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "new.gob"), nil); err != nil {
+		t.Errorf("new file: %v", err)
+	}
+}
+
+func TestAuditTrail(t *testing.T) {
+	dir := t.TempDir()
+	log, err := os.Create(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	defer func(old *os.File) { safety.audit = old }(safety.audit)
+	safety.audit = log
+
+	name := filepath.Join(dir, "data.gob")
+	for _, v := range []string{"first", "second"} {
+		if err := encodeAndWriteToFile(v, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := removeFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(log.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []auditRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\n") {
+		var r auditRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3:\n%s", len(records), raw)
+	}
+	first, second, deleted := records[0], records[1], records[2]
+	if first.Op != "write" || first.Before != "" || !strings.HasPrefix(first.After, "canonical:") {
+		t.Errorf("new file: %+v", first)
+	}
+	if second.Op != "write" || second.Before != first.After || second.After == first.After {
+		t.Errorf("replaced file: %+v", second)
+	}
+	if deleted.Op != "delete" || deleted.Before != second.After || deleted.After != "" {
+		t.Errorf("deleted file: %+v", deleted)
+	}
+	if !filepath.IsAbs(first.Target) {
+		t.Errorf("target %s is not absolute", first.Target)
+	}
+}
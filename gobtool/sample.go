@@ -0,0 +1,244 @@
+package gobtool
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// -sample N[:first|stride|random] keeps N elements of every slice and map
+// longer than -sample-threshold (by default N), so a snapshot holding
+// millions of similar records can be looked at without printing them
+// all. Maps are sampled in sorted key order. The elements kept are
+// renumbered in the output; the notes printed after it say which
+// containers were cut, how far and how. Sampling is only ever applied
+// where it is asked for: decode and stats take -sample, re-encoding and
+// diffing never sample.
+
+type sampleStrategy string
+
+const (
+	sampleFirst  sampleStrategy = "first"  // the first N
+	sampleStride sampleStrategy = "stride" // N spread evenly from first to last
+	sampleRandom sampleStrategy = "random" // N chosen at random, in order, by -sample-seed
+)
+
+// sampler picks the elements to keep and records what it cut.
+type sampler struct {
+	n         int
+	strategy  sampleStrategy
+	threshold int
+	seed      int64
+	rng       *rand.Rand
+
+	notes []sampleNote
+}
+
+// sampleNote records one container that was sampled.
+type sampleNote struct {
+	Path  string
+	Kind  string // elements or keys
+	Total int
+	Kept  int
+}
+
+func (s *sampler) register(fs *flag.FlagSet) {
+	fs.Func("sample", "show only N elements of large slices and maps, as N[:first|stride|random] (default first)", s.set)
+	fs.IntVar(&s.threshold, "sample-threshold", 0, "with -sample, sample only slices and maps longer than this (default N)")
+	fs.Int64Var(&s.seed, "sample-seed", 1, "with -sample N:random, seed for the elements chosen")
+}
+
+func (s *sampler) set(v string) error {
+	n, strategy, _ := strings.Cut(v, ":")
+	count, err := strconv.Atoi(n)
+	if err != nil || count < 1 {
+		return fmt.Errorf("%q: want a count of at least 1, as N[:first|stride|random]", v)
+	}
+	s.n, s.strategy = count, sampleFirst
+	switch st := sampleStrategy(strategy); st {
+	case "":
+	case sampleFirst, sampleStride, sampleRandom:
+		s.strategy = st
+	default:
+		return fmt.Errorf("unknown sample strategy %q: want first, stride or random", strategy)
+	}
+	return nil
+}
+
+// enabled reports whether -sample was given; a nil sampler is disabled.
+func (s *sampler) enabled() bool { return s != nil && s.n > 0 }
+
+// pick returns the indexes to keep, in order, of a container of total
+// elements, or nil when it is not long enough to sample.
+func (s *sampler) pick(total int) []int {
+	if !s.enabled() || total <= max(s.threshold, s.n) {
+		return nil
+	}
+	keep := make([]int, 0, s.n)
+	switch s.strategy {
+	case sampleStride:
+		for i := 0; i < s.n; i++ {
+			keep = append(keep, i*total/s.n)
+		}
+	case sampleRandom:
+		if s.rng == nil {
+			s.rng = rand.New(rand.NewSource(s.seed))
+		}
+		// Floyd's algorithm: n distinct indexes without a permutation of
+		// all of them.
+		chosen := make(map[int]bool, s.n)
+		for j := total - s.n; j < total; j++ {
+			if t := s.rng.Intn(j + 1); chosen[t] {
+				chosen[j] = true
+			} else {
+				chosen[t] = true
+			}
+		}
+		for i := range chosen {
+			keep = append(keep, i)
+		}
+		sort.Ints(keep)
+	default:
+		for i := 0; i < s.n; i++ {
+			keep = append(keep, i)
+		}
+	}
+	return keep
+}
+
+func (s *sampler) note(path, kind string, total int) {
+	s.notes = append(s.notes, sampleNote{Path: path, Kind: kind, Total: total, Kept: s.n})
+}
+
+// apply samples data in place where it can and returns the result.
+func (s *sampler) apply(data interface{}) interface{} {
+	v := reflect.ValueOf(data)
+	if !s.enabled() || !v.IsValid() {
+		return data
+	}
+	if nv, changed := s.sampleIn(v, ""); changed {
+		return nv.Interface()
+	}
+	return data
+}
+
+// sampleIn samples the containers in v, the value at path. Maps and
+// values behind pointers are cut in place; where a slice must be
+// replaced, or a value that cannot be set holds one, it returns the
+// value to put in v's place and true.
+func (s *sampler) sampleIn(v reflect.Value, path string) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		nv, changed := s.sampleIn(v.Elem(), path)
+		if !changed {
+			return v, false
+		}
+		iv := reflect.New(v.Type()).Elem()
+		iv.Set(nv)
+		return iv, true
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v, false
+		}
+		if nv, changed := s.sampleIn(v.Elem(), path); changed {
+			v.Elem().Set(nv)
+		}
+		return v, false
+	case reflect.Map:
+		keys := sortedMapKeys(v)
+		if keep := s.pick(len(keys)); keep != nil {
+			s.note(path, "keys", len(keys))
+			kept := make([]reflect.Value, 0, len(keep))
+			for i, k := range keys {
+				if len(kept) < len(keep) && keep[len(kept)] == i {
+					kept = append(kept, k)
+				} else {
+					v.SetMapIndex(k, reflect.Value{})
+				}
+			}
+			keys = kept
+		}
+		for _, k := range keys {
+			if nv, changed := s.sampleIn(v.MapIndex(k), joinPath(path, pathKey(k.Interface()))); changed {
+				v.SetMapIndex(k, nv)
+			}
+		}
+		return v, false
+	case reflect.Slice:
+		if !isContainer(v) || v.IsNil() {
+			return v, false
+		}
+		index := func(i int) int { return i }
+		changed := false
+		if keep := s.pick(v.Len()); keep != nil {
+			s.note(path, "elements", v.Len())
+			nv := reflect.MakeSlice(v.Type(), len(keep), len(keep))
+			for i, j := range keep {
+				nv.Index(i).Set(v.Index(j))
+			}
+			v, changed = nv, true
+			index = func(i int) int { return keep[i] }
+		}
+		for i := 0; i < v.Len(); i++ {
+			if nv, ch := s.sampleIn(v.Index(i), fmt.Sprintf("%s[%d]", path, index(i))); ch {
+				v.Index(i).Set(nv)
+			}
+		}
+		return v, changed
+	case reflect.Array, reflect.Struct:
+		if !isContainer(v) {
+			return v, false
+		}
+		// Elements of an array or struct can only be set in a copy.
+		var cp reflect.Value
+		n := v.Len
+		elem, name := v.Index, func(i int) string { return fmt.Sprintf("%s[%d]", path, i) }
+		if v.Kind() == reflect.Struct {
+			n = v.NumField
+			elem, name = v.Field, func(i int) string { return joinPath(path, v.Type().Field(i).Name) }
+		}
+		for i := 0; i < n(); i++ {
+			if v.Kind() == reflect.Struct && !v.Type().Field(i).IsExported() {
+				continue
+			}
+			nv, changed := s.sampleIn(elem(i), name(i))
+			if !changed {
+				continue
+			}
+			if !cp.IsValid() {
+				cp = reflect.New(v.Type()).Elem()
+				cp.Set(v)
+			}
+			if v.Kind() == reflect.Struct {
+				cp.Field(i).Set(nv)
+			} else {
+				cp.Index(i).Set(nv)
+			}
+		}
+		if cp.IsValid() {
+			return cp, true
+		}
+	}
+	return v, false
+}
+
+// writeNotes says which containers were sampled, so what was printed is
+// not taken for all of it.
+func (s *sampler) writeNotes(w io.Writer) error {
+	for _, n := range s.notes {
+		_, err := fmt.Fprintf(w, "sampled %s: %d of %d %s shown (%s), %d omitted\n",
+			pathOrRoot(n.Path), n.Kept, n.Total, n.Kind, s.strategy, n.Total-n.Kept)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
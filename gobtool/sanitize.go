@@ -0,0 +1,69 @@
+package gobtool
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Decoded strings may hold anything, binary data included. Printed as
+// is, control characters garble the terminal and invalid UTF-8 turns into
+// mojibake, so the text renderers pass strings through cleanString
+// unless -raw-strings is given. JSON output is handled apart:
+// encoding/json escapes control characters itself, and toJSON writes a
+// string that is not valid UTF-8 as a bytes annotation with a warning,
+// where encoding/json would silently replace the bad bytes with U+FFFD.
+
+// rawStrings turns cleanString off; set by -raw-strings.
+var rawStrings bool
+
+// cleanString escapes the control characters in s Go-style (\n, \x1b,
+// \u0085) and replaces invalid UTF-8 with U+FFFD, noting how many bytes
+// were replaced. A -string-encoding transcoding is applied first, even
+// with -raw-strings.
+func cleanString(s string) string {
+	if stringDecoder != nil {
+		s = stringDecoder(s)
+	}
+	if rawStrings || isCleanString(s) {
+		return s
+	}
+	var b strings.Builder
+	invalid := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			invalid++
+			b.WriteRune(utf8.RuneError)
+		case unicode.IsControl(r):
+			q := strconv.QuoteRune(r)
+			b.WriteString(q[1 : len(q)-1])
+		default:
+			b.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	switch invalid {
+	case 0:
+	case 1:
+		b.WriteString(" [1 invalid UTF-8 byte replaced]")
+	default:
+		fmt.Fprintf(&b, " [%d invalid UTF-8 bytes replaced]", invalid)
+	}
+	return b.String()
+}
+
+func isCleanString(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
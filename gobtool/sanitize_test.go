@@ -0,0 +1,26 @@
+package gobtool
+
+import "testing"
+
+func TestCleanString(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"plain 值", "plain 值"},
+		{"a\nb\tc", `a\nb\tc`},
+		{"\x1b[31mred\x1b[0m", `\x1b[31mred\x1b[0m`},
+		{"next\u0085line", `next\u0085line`},
+		{"bad\xffbyte", "bad�byte [1 invalid UTF-8 byte replaced]"},
+		{"\xfe\xff", "�� [2 invalid UTF-8 bytes replaced]"},
+	} {
+		if got := cleanString(c.in); got != c.want {
+			t.Errorf("cleanString(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestCleanStringRaw(t *testing.T) {
+	defer func(old bool) { rawStrings = old }(rawStrings)
+	rawStrings = true
+	if s := "a\n\xff"; cleanString(s) != s {
+		t.Errorf("-raw-strings: got %q", cleanString(s))
+	}
+}
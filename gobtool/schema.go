@@ -0,0 +1,160 @@
+package gobtool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Schema-first users describe their data with JSON Schema; TypeFromSchema
+// builds a Go type from such a schema so a gob file can be decoded into
+// it without writing the type out. Only a subset of JSON Schema maps onto
+// Go types:
+//
+//   - "object" with "properties" becomes a struct. Each property becomes
+//     an exported field named after it with its first letter upper-cased
+//     and characters not valid in a Go name removed; the original name is
+//     kept in a json tag. Fields are ordered by name.
+//   - "object" with only "additionalProperties" becomes map[string]T.
+//   - "array" with "items" becomes []T.
+//   - "string", "integer", "number" and "boolean" become string, int64,
+//     float64 and bool.
+//   - a schema with no "type", or with a list of types, becomes interface{}.
+//
+// Everything else ("required", "$ref", "enum", formats, bounds...) is
+// ignored.
+
+// jsonSchema is the part of a JSON Schema TypeFromSchema reads.
+type jsonSchema struct {
+	Type                 interface{}            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Items                *jsonSchema            `json:"items"`
+	AdditionalProperties interface{}            `json:"additionalProperties"`
+}
+
+var anyType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// TypeFromSchema builds a reflect.Type from a JSON Schema document.
+func TypeFromSchema(schema []byte) (reflect.Type, error) {
+	var s jsonSchema
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	return s.goType("")
+}
+
+func (s *jsonSchema) goType(path string) (reflect.Type, error) {
+	if s == nil {
+		return anyType, nil
+	}
+	typ, _ := s.Type.(string)
+	switch typ {
+	case "":
+		return anyType, nil
+	case "string":
+		return reflect.TypeOf(""), nil
+	case "integer":
+		return reflect.TypeOf(int64(0)), nil
+	case "number":
+		return reflect.TypeOf(float64(0)), nil
+	case "boolean":
+		return reflect.TypeOf(false), nil
+	case "array":
+		elem, err := s.Items.goType(path + "[*]")
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+	case "object":
+		if len(s.Properties) == 0 {
+			var elem reflect.Type = anyType
+			if m, ok := s.AdditionalProperties.(map[string]interface{}); ok {
+				raw, _ := json.Marshal(m)
+				var sub jsonSchema
+				if err := json.Unmarshal(raw, &sub); err != nil {
+					return nil, fmt.Errorf("schema %s: %w", pathOrRoot(path), err)
+				}
+				var err error
+				if elem, err = sub.goType(joinPath(path, "*")); err != nil {
+					return nil, err
+				}
+			}
+			return reflect.MapOf(reflect.TypeOf(""), elem), nil
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]reflect.StructField, 0, len(names))
+		seen := make(map[string]string)
+		for _, name := range names {
+			goName := schemaFieldName(name)
+			if goName == "" {
+				return nil, fmt.Errorf("schema %s: property %q has no usable Go name", pathOrRoot(path), name)
+			}
+			if prev, ok := seen[goName]; ok {
+				return nil, fmt.Errorf("schema %s: properties %q and %q both become field %s", pathOrRoot(path), prev, name, goName)
+			}
+			seen[goName] = name
+			ft, err := s.Properties[name].goType(joinPath(path, name))
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, reflect.StructField{
+				Name: goName,
+				Type: ft,
+				Tag:  reflect.StructTag(fmt.Sprintf("json:%q", name)),
+			})
+		}
+		return reflect.StructOf(fields), nil
+	}
+	return nil, fmt.Errorf("schema %s: unsupported type %q", pathOrRoot(path), typ)
+}
+
+// schemaFieldName turns a property name into an exported Go field name.
+func schemaFieldName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || b.Len() > 0 && (r == '_' || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+		}
+	}
+	s := []rune(b.String())
+	if len(s) == 0 {
+		return ""
+	}
+	s[0] = unicode.ToUpper(s[0])
+	return string(s)
+}
+
+// readSchemaType reads a schema file and builds its type.
+func readSchemaType(name string) (reflect.Type, error) {
+	schema, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := TypeFromSchema(schema)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// DecodeAsType decodes one value from r into a new value of type t, such
+// as one built by TypeFromSchema. Struct fields are matched to wire
+// fields or string map keys by field name, then by json tag. It returns
+// the paths of the wire values that had nowhere to go.
+func DecodeAsType(r io.Reader, t reflect.Type) (v interface{}, dropped []string, err error) {
+	p := reflect.New(t)
+	dropped, err = DecodeRenamed(r, p.Interface(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.Elem().Interface(), dropped, nil
+}
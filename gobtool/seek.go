@@ -0,0 +1,101 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// SeekDecoder reads single records at known byte offsets of a file, for
+// index-based access without decoding what comes before them.
+//
+// This only works for records written independently, each by a fresh
+// gob.Encoder, as EncodeIndependent does, so each carries the type
+// definitions it needs. A gob.Encoder sends each type once per stream:
+// in a file written by a single encoder, such as encode -stream, a
+// record after the first refers to definitions sent earlier and cannot
+// be decoded on its own. SeekDecoder reports that as
+// ErrNotIndependent. Netstring frames are independent records too, but
+// their offsets are those of the frame payloads, after the length prefix.
+type SeekDecoder struct {
+	r io.ReaderAt
+}
+
+// NewSeekDecoder returns a SeekDecoder reading from r.
+func NewSeekDecoder(r io.ReaderAt) *SeekDecoder {
+	return &SeekDecoder{r: r}
+}
+
+// ErrNotIndependent is returned for a record that uses type definitions
+// it does not carry.
+var ErrNotIndependent = errors.New("record uses types defined before it; write each record with a fresh gob.Encoder to read it by offset")
+
+// Extent returns the offset just past the record at off, which is where
+// the next record starts, by scanning its messages without decoding them.
+func (d *SeekDecoder) Extent(off int64) (end int64, err error) {
+	w := newWireReader(io.NewSectionReader(d.r, off, math.MaxInt64-off), off)
+	id, err := w.nextMessage()
+	if err == io.EOF {
+		return 0, fmt.Errorf("record at offset %d: %w", off, io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("record at offset %d: %w", off, err)
+	}
+	if !definesAll(w.types, id) {
+		return 0, fmt.Errorf("record at offset %d: %w", off, ErrNotIndependent)
+	}
+	if _, err := w.topValue(id, false); err != nil {
+		return 0, fmt.Errorf("record at offset %d: %w", off, err)
+	}
+	return w.off, nil
+}
+
+// definesAll reports whether types defines id and every type it refers
+// to, directly or not.
+func definesAll(types map[typeID]*wireType, id typeID) bool {
+	seen := make(map[typeID]bool)
+	var walk func(id typeID) bool
+	walk = func(id typeID) bool {
+		if id < firstUserID || seen[id] {
+			return true
+		}
+		seen[id] = true
+		wt := types[id]
+		if wt == nil {
+			return false
+		}
+		for _, f := range wt.Fields {
+			if !walk(f.ID) {
+				return false
+			}
+		}
+		return walk(wt.Key) && walk(wt.Elem)
+	}
+	return walk(id)
+}
+
+// DecodeAt decodes the record at off into v, which must be a pointer,
+// and returns the offset just past it.
+func (d *SeekDecoder) DecodeAt(off int64, v interface{}) (next int64, err error) {
+	end, err := d.Extent(off)
+	if err != nil {
+		return 0, err
+	}
+	if err := gob.NewDecoder(io.NewSectionReader(d.r, off, end-off)).Decode(v); err != nil {
+		return 0, fmt.Errorf("record at offset %d: %w", off, err)
+	}
+	return end, nil
+}
+
+// EncodeIndependent writes v to w as a record SeekDecoder can read on its
+// own, and returns its size, for the caller's index.
+func EncodeIndependent(w io.Writer, v interface{}) (int64, error) {
+	var buf bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&buf), v); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
@@ -0,0 +1,71 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// TestSeekDecoder checks that independently written records decode by
+// offset in any order.
+func TestSeekDecoder(t *testing.T) {
+	registerKnownTypes()
+	data := syntheticData(50, 1)
+	records := []interface{}{
+		data,
+		map[string]int{"a": 1, "b": 2},
+		[]string{"x", "y"},
+		"plain string",
+		struct{ Name string }{"point"},
+		data,
+	}
+	var file bytes.Buffer
+	offsets := make([]int64, len(records)+1)
+	for i, rec := range records {
+		n, err := EncodeIndependent(&file, rec)
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		offsets[i+1] = offsets[i] + n
+	}
+
+	d := NewSeekDecoder(bytes.NewReader(file.Bytes()))
+	for i := len(records) - 1; i >= 0; i-- {
+		got := reflect.New(reflect.TypeOf(records[i]))
+		next, err := d.DecodeAt(offsets[i], got.Interface())
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if next != offsets[i+1] {
+			t.Errorf("record %d: next offset %d, want %d", i, next, offsets[i+1])
+		}
+		if !reflect.DeepEqual(got.Elem().Interface(), records[i]) {
+			t.Errorf("record %d at offset %d: decodes to different data", i, offsets[i])
+		}
+	}
+	if _, err := d.DecodeAt(offsets[2]+1, new([]string)); err == nil {
+		t.Error("offset inside a record: no error")
+	}
+	if _, err := d.Extent(offsets[len(records)]); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("offset at the end of the file: got %v", err)
+	}
+}
+
+func TestSeekDecoderNotIndependent(t *testing.T) {
+	var stream bytes.Buffer
+	enc := gob.NewEncoder(&stream)
+	if err := enc.Encode(map[string]int{"first": 1}); err != nil {
+		t.Fatal(err)
+	}
+	second := int64(stream.Len())
+	if err := enc.Encode(map[string]int{"second": 2}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewSeekDecoder(bytes.NewReader(stream.Bytes())).DecodeAt(second, new(map[string]int))
+	if !errors.Is(err, ErrNotIndependent) {
+		t.Errorf("second record of one encoder's stream: got %v, want ErrNotIndependent", err)
+	}
+}
@@ -0,0 +1,323 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// selftest is a smoke test of a built binary: it generates data of a few
+// sizes and runs it through each way the tool writes and reads gob,
+// checking the result against what went in. When a stage fails, the
+// files it worked on are kept for a bug report.
+
+// syntheticData returns a map of n entries in the shapes createSampleData
+// uses, with keys and values drawn from seed.
+func syntheticData(n int, seed int64) map[interface{}]interface{} {
+	rng := rand.New(rand.NewSource(seed))
+	data := make(map[interface{}]interface{}, n)
+	for i := 0; i < n; i++ {
+		var key interface{} = "key" + strconv.Itoa(i)
+		switch i % 4 {
+		case 1:
+			key = i
+		case 2:
+			key = float64(i) + 0.5
+		}
+		switch rng.Intn(6) {
+		case 0:
+			data[key] = strings.Repeat("值", rng.Intn(8)) + strconv.Itoa(rng.Int())
+		case 1:
+			data[key] = rng.Intn(1 << 20)
+		case 2:
+			data[key] = rng.Float64()
+		case 3:
+			data[key] = []int{rng.Intn(100), rng.Intn(100), rng.Intn(100)}
+		case 4:
+			data[key] = map[string]interface{}{"age": rng.Intn(100), "city": "北京", "active": rng.Intn(2) == 0}
+		default:
+			data[key] = struct {
+				X int
+				Y int
+			}{rng.Intn(100), rng.Intn(100)}
+		}
+	}
+	return data
+}
+
+// selftestStage is one check, run against data with its files in dir.
+type selftestStage struct {
+	name string
+	run  func(dir string, data map[interface{}]interface{}) error
+}
+
+var selftestStages = []selftestStage{
+	{"roundtrip", selftestRoundtrip},
+	{"deterministic", selftestDeterministic},
+	{"gzip", selftestGzip},
+	{"store", selftestStore},
+	{"securecookie", selftestCookie},
+	{"json", selftestJSON},
+	{"limits", selftestLimits},
+	{"deadline", selftestDeadline},
+}
+
+func selftestRoundtrip(dir string, data map[interface{}]interface{}) error {
+	name := filepath.Join(dir, "data.gob")
+	if err := encodeAndWriteToFile(data, name); err != nil {
+		return err
+	}
+	return checkDecodesTo(name, data)
+}
+
+func checkDecodesTo(name string, want map[interface{}]interface{}) error {
+	got, err := decodeAnyFile(name)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("%s decodes to different data", filepath.Base(name))
+	}
+	return nil
+}
+
+// selftestDeterministic checks that the canonical hash survives an
+// encoding, though gob writes map entries in no particular order.
+func selftestDeterministic(dir string, data map[interface{}]interface{}) error {
+	want, err := CanonicalHash(data)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, "data.gob")
+	if err := encodeAndWriteToFile(data, name); err != nil {
+		return err
+	}
+	back, err := decodeAnyFile(name)
+	if err != nil {
+		return err
+	}
+	got, err := CanonicalHash(back)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("canonical hash changed: %x, want %x", got, want)
+	}
+	return nil
+}
+
+func selftestGzip(dir string, data map[interface{}]interface{}) error {
+	name := filepath.Join(dir, "data.gob.gz")
+	err := writeAtomic(name, func(f *os.File) error {
+		return Encode(f, data, WithWriterMiddleware(gzipMiddleware))
+	})
+	if err != nil {
+		return err
+	}
+	id, err := identify(name)
+	if err != nil {
+		return err
+	}
+	if id.Format != "gzip+gob" {
+		return fmt.Errorf("identified as %s, want gzip+gob", id.Format)
+	}
+	return checkDecodesTo(name, data)
+}
+
+func selftestStore(dir string, data map[interface{}]interface{}) error {
+	name := filepath.Join(dir, "data.ref")
+	if _, err := EncodeToStore(filepath.Join(dir, "store"), name, data); err != nil {
+		return err
+	}
+	return checkDecodesTo(name, data)
+}
+
+// selftestCookie round-trips data through a signed and encrypted
+// securecookie, as a session cookie store does.
+func selftestCookie(dir string, data map[interface{}]interface{}) error {
+	sc := securecookie.New(securecookie.GenerateRandomKey(32), securecookie.GenerateRandomKey(32)).MaxLength(0)
+	value, err := sc.Encode("selftest", data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cookie.txt"), []byte(value), 0o644); err != nil {
+		return err
+	}
+	var got map[interface{}]interface{}
+	if err := sc.Decode("selftest", value, &got); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(got, data) {
+		return errors.New("cookie decodes to different data")
+	}
+	return nil
+}
+
+// selftestJSON checks that data converted to JSON, encoded from that JSON
+// and converted again gives the same JSON.
+func selftestJSON(dir string, data map[interface{}]interface{}) error {
+	marshal := func(v interface{}) ([]byte, error) {
+		j, err := toJSON(v)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(j, "", "  ")
+	}
+	want, err := marshal(data)
+	if err != nil {
+		return err
+	}
+	jsonName := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(jsonName, want, 0o644); err != nil {
+		return err
+	}
+	fromJSON, err := readJSONMap(jsonName, nil)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, "data.gob")
+	if err := encodeAndWriteToFile(fromJSON, name); err != nil {
+		return err
+	}
+	back, err := decodeAnyFile(name)
+	if err != nil {
+		return err
+	}
+	got, err := marshal(back)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, want) {
+		return errors.New("JSON differs after a round trip through gob")
+	}
+	return nil
+}
+
+// selftestLimits checks that the size limits are enforced.
+func selftestLimits(dir string, data map[interface{}]interface{}) error {
+	name := filepath.Join(dir, "data.gob")
+	if err := encodeAndWriteToFile(data, name); err != nil {
+		return err
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = Decode(f, WithLimits(Limits{MaxBytes: fi.Size() - 1}))
+	if !errors.Is(err, errInputLimit) {
+		return fmt.Errorf("decode with a limit one byte short: got %v, want the size limit error", err)
+	}
+	// Map order changes the encoded size by a few bytes from one
+	// encoding to the next, so the budgets keep well clear of it.
+	var budget *BudgetExceededError
+	if err := CheckEncodedSize(data, int(fi.Size())/2); !errors.As(err, &budget) {
+		return fmt.Errorf("budget of half the size: got %v, want *BudgetExceededError", err)
+	}
+	if err := CheckEncodedSize(data, int(fi.Size())*2); err != nil {
+		return fmt.Errorf("budget of twice the size: %w", err)
+	}
+	return nil
+}
+
+// selftestFlags registers the flags of selftest; the command returned
+// implements "selftest".
+func selftestFlags(fs *flag.FlagSet) func(args []string) error {
+	sizesFlag := fs.String("sizes", "1,100,10000", "comma-separated entry counts to generate")
+	seed := fs.Int64("seed", 1, "seed for the generated data")
+	keep := fs.Bool("keep", false, "keep the work directory even if every stage passes")
+	return func(args []string) error {
+		if len(args) != 0 {
+			return errors.New("usage: selftest [-sizes n,n,...] [-seed n] [-keep]")
+		}
+		var sizes []int
+		for _, s := range strings.Split(*sizesFlag, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || n < 1 {
+				return fmt.Errorf("-sizes: bad size %q", s)
+			}
+			sizes = append(sizes, n)
+		}
+
+		root, err := os.MkdirTemp("", "gob-selftest-")
+		if err != nil {
+			return err
+		}
+		registerKnownTypes()
+		failed := 0
+		for _, n := range sizes {
+			data := syntheticData(n, *seed)
+			for _, st := range selftestStages {
+				dir := filepath.Join(root, fmt.Sprintf("%s-%d", st.name, n))
+				if err := os.Mkdir(dir, 0o755); err != nil {
+					return err
+				}
+				start := time.Now()
+				err := st.run(dir, data)
+				elapsed := time.Since(start).Round(time.Microsecond)
+				if err != nil {
+					failed++
+					fmt.Printf("FAIL  %-14s %7d  %10s  %v\n", st.name, n, elapsed, err)
+					continue
+				}
+				fmt.Printf("ok    %-14s %7d  %10s\n", st.name, n, elapsed)
+				if !*keep {
+					os.RemoveAll(dir)
+				}
+			}
+		}
+		if failed == 0 && !*keep {
+			return os.RemoveAll(root)
+		}
+		fmt.Printf("work files are in %s\n", root)
+		if failed > 0 {
+			fmt.Printf("%d stages failed\n", failed)
+			return exitCode(1)
+		}
+		return nil
+	}
+}
+
+// selftestDeadline checks that a run blocked on input it never gets
+// exits with status 124 once -deadline passes.
+func selftestDeadline(dir string, data map[interface{}]interface{}) error {
+	const d = 50 * time.Millisecond
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pw.Close()
+	var stderr bytes.Buffer
+	cmd := exec.Command(exe, "-deadline", d.String(), "decode", "fd:0")
+	cmd.Stdin, cmd.Stderr = pr, &stderr
+	err = cmd.Run()
+	pr.Close()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != deadlineExit {
+		return fmt.Errorf("blocked run: got %v, want exit status %d", err, deadlineExit)
+	}
+	if !strings.Contains(stderr.String(), "-deadline") {
+		return fmt.Errorf("blocked run: stderr %q does not mention -deadline", stderr.String())
+	}
+	return nil
+}
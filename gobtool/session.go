@@ -0,0 +1,221 @@
+package gobtool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"test-gob/sessionsource"
+)
+
+// sessionHead is what "session head" reports about a session file. It is
+// read with the generic wire reader, which needs no registered types, so
+// it works on files the full decode rejects.
+type sessionHead struct {
+	File    string
+	Size    int64
+	ModTime time.Time
+	Decodes error // result of the full decode
+
+	// Session reports that the file holds a session's Values, a
+	// map[interface{}]interface{}, as the gorilla stores write them.
+	Session bool
+	// Nested reports that a *sessions.Session was found among the
+	// values, as goth keeps one; Key, ID and IsNew are its.
+	Nested bool
+	Key    string
+	ID     string
+	IsNew  bool
+	Values int            // top-level values in the file
+	Types  map[string]int // wire type names of the values
+
+	values map[interface{}]interface{} // in generic form
+}
+
+// sessionTypeName is the name a *sessions.Session goes by in a gob stream.
+var sessionTypeName = gobName(reflect.TypeOf(&sessions.Session{}))
+
+// readSessionHead reads the outline of a session in src, and with full
+// set also tries the full decode.
+func readSessionHead(ctx context.Context, src sessionsource.Source, ref sessionsource.Ref, full bool) (*sessionHead, error) {
+	data, err := src.Read(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	h := &sessionHead{File: ref.Name, Size: int64(len(data)), ModTime: ref.ModTime, Types: make(map[string]int)}
+
+	r, err := openPayload(bytes.NewReader(data), ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	w := newWireReader(r, 0)
+	w.named = true
+	id, err := w.nextMessage()
+	if err != nil {
+		return nil, payloadError(r, err)
+	}
+	top, err := w.topValue(id, true)
+	if err != nil {
+		return nil, payloadError(r, err)
+	}
+	entries, ok := top.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("top-level value is %T, not a map", top)
+	}
+	wt := w.types[id]
+	h.Session = wt != nil && wt.Kind == wireMap && wt.Key == tInterface && wt.Elem == tInterface
+
+	// A goth session file keeps its values at the top level, next to a
+	// nested sessions.Session that carries the ID. That is a session of
+	// its own, so its options say nothing about this file.
+	for k, v := range entries {
+		n, ok := v.(wireNamed)
+		if !ok || n.Name != sessionTypeName {
+			continue
+		}
+		s, ok := n.Value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		h.Nested = true
+		h.Key = fmt.Sprint(unwrapNamed(k))
+		h.ID, _ = s["ID"].(string)
+		h.IsNew, _ = s["IsNew"].(bool)
+		break
+	}
+	h.values = entries
+	h.Values = len(entries)
+	for _, v := range entries {
+		name := "nil"
+		if n, ok := v.(wireNamed); ok {
+			name = n.Name
+		}
+		h.Types[name]++
+	}
+
+	if full {
+		registerKnownTypes()
+		_, h.Decodes = decodeAny(bytes.NewReader(data), ref.Name)
+	}
+	return h, nil
+}
+
+// typeSummary renders Types as "string×2, int64".
+func (h *sessionHead) typeSummary() string {
+	names := make([]string, 0, len(h.Types))
+	for name := range h.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if n := h.Types[name]; n > 1 {
+			names[i] = fmt.Sprintf("%s×%d", name, n)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func (h *sessionHead) decodeStatus() string {
+	if h.Decodes != nil {
+		return "error: " + h.Decodes.Error()
+	}
+	return "ok"
+}
+
+// record is the session as -column expressions see it, of type
+// "session".
+func (h *sessionHead) record() filterRecord {
+	return filterRecord{"session", map[string]interface{}{
+		"File":   h.File,
+		"Size":   h.Size,
+		"ID":     h.ID,
+		"IsNew":  h.IsNew,
+		"Values": unwrapNamedAll(h.values),
+	}}
+}
+
+func (h *sessionHead) print() {
+	fmt.Printf("file:     %s\nsize:     %d\nage:      %s\ndecodes:  %s\n",
+		h.File, h.Size, time.Since(h.ModTime).Round(time.Second), h.decodeStatus())
+	switch {
+	case h.Nested:
+		fmt.Printf("session:  %s\nid:       %s\nis new:   %v\n", h.Key, h.ID, h.IsNew)
+	case h.Session:
+		fmt.Println("session:  (none, file holds values only)")
+	default:
+		fmt.Println("session:  (none, not a session's values)")
+	}
+	fmt.Printf("values:   %d entries, types: %s\n", h.Values, h.typeSummary())
+}
+
+func (h *sessionHead) line() string {
+	id := h.ID
+	if !h.Nested {
+		id = "-"
+	}
+	return fmt.Sprintf("%s\t%d\t%s\t%s\tid=%s\tvalues=%d (%s)",
+		h.File, h.Size, time.Since(h.ModTime).Round(time.Second), h.decodeStatus(), id, h.Values, h.typeSummary())
+}
+
+// sessionHeadFlags registers the flags of session head; the command
+// returned implements "session head source...", where each source is a
+// file, a directory or a session source URI. A single file is described
+// in full, anything else one line per session.
+func sessionHeadFlags(fset *flag.FlagSet) func(args []string) error {
+	var columns exprColumns
+	columns.register(fset)
+	return func(args []string) error {
+		if len(args) == 0 {
+			return errors.New("usage: session head [-column name=expr]... source...")
+		}
+		ctx := runContext
+		failed := false
+		for _, uri := range args {
+			src, err := sessionsource.Open(uri)
+			if err != nil {
+				return err
+			}
+			refs, err := src.List(ctx)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 && len(refs) == 1 && refs[0].Name == uri {
+				h, err := readSessionHead(ctx, src, refs[0], true)
+				if err != nil {
+					return err
+				}
+				h.print()
+				cells, bad := columns.cells(h.File, h.record())
+				for _, c := range cells {
+					name, value, _ := strings.Cut(c, "=")
+					fmt.Printf("%-9s %s\n", name+":", value)
+				}
+				if bad {
+					return exitCode(1)
+				}
+				return nil
+			}
+			for _, ref := range refs {
+				h, err := readSessionHead(ctx, src, ref, true)
+				if err != nil {
+					fmt.Printf("%s\tunreadable: %v\n", ref.Name, err)
+					continue
+				}
+				cells, bad := columns.cells(h.File, h.record())
+				failed = failed || bad
+				fmt.Println(strings.Join(append([]string{h.line()}, cells...), "\t"))
+			}
+		}
+		if failed {
+			return exitCode(1)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,193 @@
+package gobtool
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"test-gob/sessionsource"
+)
+
+// session gc deletes expired session files. It reads each file only as
+// far as session head does, so it works without the application's types,
+// and a file it cannot place in time is kept, as is any file that does
+// not hold a session's Values map: a directory of sessions may hold other
+// gob files too. Deleting is opt-in: without -yes it only reports what
+// it would do.
+
+type sessionGC struct {
+	maxAge     time.Duration
+	expiryPath string
+	keep       map[string]bool
+	now        time.Time
+}
+
+// expiry returns when the session in h expires and what that is based
+// on: the -expiry-path value, or else -max-age from the file's mtime. ok
+// is false if neither applies. The stores keep a session's options out
+// of its file, so there is no MaxAge of its own to go by.
+func (g *sessionGC) expiry(h *sessionHead) (at time.Time, basis string, ok bool, err error) {
+	if g.expiryPath != "" {
+		if v, found := lookupPath(unwrapNamedAll(h.values), g.expiryPath); found {
+			at, err := parseExpiry(v)
+			if err != nil {
+				return time.Time{}, "", false, fmt.Errorf("%s: %w", g.expiryPath, err)
+			}
+			return at, g.expiryPath, true, nil
+		}
+	}
+	if g.maxAge > 0 {
+		return h.ModTime.Add(g.maxAge), "-max-age", true, nil
+	}
+	return time.Time{}, "", false, nil
+}
+
+// parseExpiry reads an expiry time stored as Unix seconds, an RFC 3339
+// string or a gob-encoded time.Time.
+func parseExpiry(v reflect.Value) (time.Time, error) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return time.Time{}, errors.New("expiry is nil")
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t, nil
+	}
+	switch {
+	case v.CanInt():
+		return time.Unix(v.Int(), 0), nil
+	case v.CanUint():
+		return time.Unix(int64(v.Uint()), 0), nil
+	case v.CanFloat():
+		return time.Unix(int64(v.Float()), 0), nil
+	case v.Kind() == reflect.String:
+		if n, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return time.Unix(n, 0), nil
+		}
+		return time.Parse(time.RFC3339, v.String())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		var t time.Time
+		if err := t.GobDecode(v.Bytes()); err != nil {
+			return time.Time{}, fmt.Errorf("not an encoded time: %w", err)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot read a time from %s", v.Type())
+}
+
+// readKeepList reads session IDs, one per line; blank lines and lines
+// starting with # are ignored.
+func readKeepList(name string) (map[string]bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	keep := make(map[string]bool)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			keep[line] = true
+		}
+	}
+	return keep, sc.Err()
+}
+
+// sessionGCFlags registers the flags of session gc; the command returned
+// implements "session gc -dir source [flags]".
+func sessionGCFlags(fset *flag.FlagSet) func(args []string) error {
+	dir := fset.String("dir", "", "session directory, or session source URI, to sweep")
+	maxAge := fset.Duration("max-age", 0, "expire sessions this long after their last write")
+	expiryPath := fset.String("expiry-path", "", "path in the session values holding an explicit expiry time")
+	keepList := fset.String("keep-list", "", "file of session IDs, or file names, never to delete")
+	yes := fset.Bool("yes", false, "delete the expired files; without it nothing is deleted")
+	return func(args []string) error {
+		if len(args) != 0 || *dir == "" {
+			return errors.New("usage: session gc -dir source [-max-age d] [-expiry-path path] [-keep-list file] [-yes]")
+		}
+		ctx := runContext
+		src, err := sessionsource.Open(*dir)
+		if err != nil {
+			return err
+		}
+		deleter, canDelete := src.(sessionsource.Deleter)
+		if *yes && !canDelete {
+			return fmt.Errorf("%s: %w", *dir, errNoDelete)
+		}
+		refs, err := src.List(ctx)
+		if err != nil {
+			return err
+		}
+		g := &sessionGC{maxAge: *maxAge, expiryPath: *expiryPath, now: time.Now()}
+		if *keepList != "" {
+			var err error
+			if g.keep, err = readKeepList(*keepList); err != nil {
+				return err
+			}
+		}
+
+		var scanned, deleted, kept, failed int
+		action := "would delete"
+		if *yes {
+			action = "deleted"
+		}
+		for _, ref := range refs {
+			p := ref.Name
+			scanned++
+			h, err := readSessionHead(ctx, src, ref, false)
+			if err != nil {
+				failed++
+				fmt.Printf("failed\t%s\t%v\n", p, err)
+				continue
+			}
+			if !h.Session {
+				kept++
+				fmt.Printf("keep\t%s\tnot a session\n", p)
+				continue
+			}
+			if g.keep[h.ID] && h.ID != "" || g.keep[filepath.Base(p)] {
+				kept++
+				fmt.Printf("keep\t%s\ton the keep list\n", p)
+				continue
+			}
+			at, basis, ok, err := g.expiry(h)
+			switch {
+			case err != nil:
+				failed++
+				fmt.Printf("failed\t%s\t%v\n", p, err)
+				continue
+			case !ok:
+				kept++
+				fmt.Printf("keep\t%s\tno expiry known\n", p)
+				continue
+			case at.After(g.now):
+				kept++
+				continue
+			}
+			if *yes {
+				if err := deleter.Delete(ctx, ref); err != nil {
+					failed++
+					fmt.Printf("failed\t%s\t%v\n", p, err)
+					continue
+				}
+			}
+			deleted++
+			fmt.Printf("%s\t%s\texpired %s ago (%s)\n", action, p, g.now.Sub(at).Round(time.Second), basis)
+		}
+		fmt.Printf("%d scanned, %d %s, %d kept, %d failed\n", scanned, deleted, action, kept, failed)
+		if !*yes && deleted > 0 {
+			fmt.Println("dry run: rerun with -yes to delete")
+		}
+		if failed > 0 {
+			return exitCode(1)
+		}
+		return nil
+	}
+}
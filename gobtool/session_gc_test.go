@@ -0,0 +1,96 @@
+package gobtool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// writeAged writes v to dir/name and backdates it by age.
+func writeAged(t *testing.T, dir, name string, v interface{}, age time.Duration) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := encodeAndWriteToFile(v, p); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-age)
+	if err := os.Chtimes(p, old, old); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// copyAged copies the file src to dir and backdates the copy by age.
+func copyAged(t *testing.T, dir, src string, age time.Duration) string {
+	t.Helper()
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := filepath.Join(dir, filepath.Base(src))
+	if err := os.WriteFile(p, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-age)
+	if err := os.Chtimes(p, old, old); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestSessionGCDeletesOnlySessions sweeps the Values maps the gorilla
+// stores write, goth's among them, next to gob files that are not
+// sessions. The MaxAge of the session goth nests in its values is not
+// that of the file.
+func TestSessionGCDeletesOnlySessions(t *testing.T) {
+	registerKnownTypes()
+	dir := t.TempDir()
+	expired := copyAged(t, dir, filepath.Join("..", "normal-session.bin"), 2*time.Hour)
+	fresh := copyAged(t, dir, filepath.Join("..", "normal-session-2.bin"), 0)
+	goth := copyAged(t, dir, filepath.Join("..", "goth-session.bin"), 0)
+	gothExpired := writeAged(t, dir, "goth-old.bin", map[interface{}]interface{}{
+		"_gothic_session": &sessions.Session{ID: "a", Options: &sessions.Options{MaxAge: 3600}},
+	}, 2*time.Hour)
+	explicit := writeAged(t, dir, "explicit.bin", map[interface{}]interface{}{
+		"expires": time.Now().Add(time.Hour).Unix(),
+	}, 2*time.Hour)
+	other := writeAged(t, dir, "config.gob", map[string]interface{}{"MaxAge": 1}, 2*time.Hour)
+	hidden := writeAged(t, dir, ".git/objects/old.gob", map[interface{}]interface{}{"user": "ada"}, 2*time.Hour)
+
+	if _, err := runCaptured(t, "session", "gc", "-dir", dir, "-max-age", "1m", "-expiry-path", "expires", "-yes"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{expired, gothExpired} {
+		if exists(p) {
+			t.Errorf("the expired session %s was kept", filepath.Base(p))
+		}
+	}
+	for _, p := range []string{fresh, goth, explicit, other, hidden} {
+		if !exists(p) {
+			t.Errorf("%s was deleted", filepath.Base(p))
+		}
+	}
+}
+
+func TestSessionGCDryRunByDefault(t *testing.T) {
+	registerKnownTypes()
+	dir := t.TempDir()
+	p := writeAged(t, dir, "s.gob", map[interface{}]interface{}{"user": "ada"}, 2*time.Hour)
+	if err := lookupCommand("session").run([]string{"gc", "-dir", dir, "-max-age", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+	if !exists(p) {
+		t.Error("session gc deleted a file without -yes")
+	}
+}
@@ -0,0 +1,39 @@
+package gobtool
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"test-gob/sessionsource"
+)
+
+// TestReadSessionHeadGothFile reads the head of ../goth-session.bin, whose
+// five values sit at the top level next to the nested session that
+// holds only the ID and options.
+func TestReadSessionHeadGothFile(t *testing.T) {
+	ctx := context.Background()
+	src, err := sessionsource.Open(filepath.Join("..", "goth-session.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := src.List(ctx)
+	if err != nil || len(refs) != 1 {
+		t.Fatalf("List = %+v, %v", refs, err)
+	}
+	h, err := readSessionHead(ctx, src, refs[0], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Session || !h.Nested || h.Key != "_gothic_session" || h.ID != "17634d7885249bfc" || h.Decodes != nil {
+		t.Errorf("head = %+v", h)
+	}
+	if h.Values != 5 {
+		t.Errorf("%d values, want the file's 5", h.Values)
+	}
+	want := map[string]int{"*sessions.Session": 1, "bool": 1, "int64": 1, "string": 2}
+	if !reflect.DeepEqual(h.Types, want) {
+		t.Errorf("types %v, want %v", h.Types, want)
+	}
+}
@@ -0,0 +1,66 @@
+package gobtool
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"test-gob/sessionsource"
+)
+
+// Session commands read sessions through package sessionsource. The
+// program registers scheme file, which a name without a scheme also
+// opens: a session file, or a directory of them.
+
+func init() {
+	sessionsource.Register("file", func(rest string) (sessionsource.Source, error) {
+		return dirSource{strings.TrimPrefix(rest, "//")}, nil
+	})
+}
+
+// dirSource is a session file, or a directory tree of them. Hidden
+// directories below the root, such as .git, are not part of the tree.
+type dirSource struct{ root string }
+
+func (s dirSource) List(ctx context.Context) ([]sessionsource.Ref, error) {
+	var refs []sessionsource.Ref
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != s.root && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		refs = append(refs, sessionsource.Ref{Name: p, Size: fi.Size(), ModTime: fi.ModTime()})
+		return nil
+	})
+	return refs, err
+}
+
+func (s dirSource) Read(_ context.Context, ref sessionsource.Ref) ([]byte, error) {
+	return os.ReadFile(ref.Name)
+}
+
+func (s dirSource) Write(_ context.Context, name string, data []byte) error {
+	return writeFileAtomic(filepath.Join(s.root, name), data)
+}
+
+func (s dirSource) Delete(_ context.Context, ref sessionsource.Ref) error {
+	return removeFile(ref.Name)
+}
+
+// errNoDelete is returned for a source that cannot delete sessions.
+var errNoDelete = errors.New("session source cannot delete sessions")
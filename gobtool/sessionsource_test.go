@@ -0,0 +1,76 @@
+package gobtool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"test-gob/sessionsource"
+)
+
+func TestSessionHeadFromMemSource(t *testing.T) {
+	registerKnownTypes()
+	name := filepath.Join(t.TempDir(), "s.gob")
+	if err := encodeAndWriteToFile(map[string]interface{}{"session": &sessions.Session{
+		ID:     "abc",
+		Values: map[interface{}]interface{}{"user": "ada"},
+	}}, name); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := sessionsource.Mem(t.Name())
+	if err := src.Write(context.Background(), "s", raw); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := src.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := readSessionHead(context.Background(), src, refs[0], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Nested || h.ID != "abc" || h.Values != 1 {
+		t.Errorf("head = %+v", h)
+	}
+	if err := lookupCommand("session").run([]string{"head", "mem:" + t.Name()}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDirSourceSkipsHiddenDirectories(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.gob", "sub/b.gob", ".git/objects/c", "sub/.cache/d.gob"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refs, err := dirSource{dir}.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range refs {
+		rel, _ := filepath.Rel(dir, r.Name)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if want := []string{"a.gob", "sub/b.gob"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+
+	// A hidden directory named as the root is still listed.
+	refs, err = dirSource{filepath.Join(dir, ".git")}.List(context.Background())
+	if err != nil || len(refs) != 1 {
+		t.Errorf("List of a hidden root = %+v, %v", refs, err)
+	}
+}
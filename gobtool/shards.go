@@ -0,0 +1,271 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A single gob encoder runs on one core, which makes a map of several
+// gigabytes slow to write. encode -shards N splits the top-level map into
+// N shards instead, each key going to the shard a hash of its canonical
+// form picks, so a key always lands in the same shard. The shards are encoded
+// concurrently and written as one sharded file:
+//
+//	gob-shards v1
+//	<gob stream: a ShardManifest, then one []byte per shard>
+//
+// Each []byte is the gob stream of one shard's map. The manifest records
+// the shard count and every shard's key count, size and SHA-256, so a
+// truncated file, a shard from another file or a shard out of place is
+// refused rather than reassembled into the wrong map. Every command that
+// reads gob reads a sharded file too, decoding the shards in parallel
+// (decode -shard-workers bounds how many at once); the version on the
+// first line is checked with CanRead.
+
+// shardsPrefix starts the first line of a sharded file.
+const shardsPrefix = "gob-shards v"
+
+// maxShards bounds the shard count, in the manifest as well as the flag.
+const maxShards = 1 << 12
+
+// shardWorkers is decode -shard-workers: how many shards to decode at
+// once, 0 for one per CPU.
+var shardWorkers int
+
+// ShardManifest is the first value of a sharded file.
+type ShardManifest struct {
+	Keys    int // in the whole map
+	Entries []ShardEntry
+}
+
+// ShardEntry describes one shard.
+type ShardEntry struct {
+	Keys int
+	Size int
+	Sum  [32]byte // SHA-256 of the shard's gob stream
+}
+
+// shardOf returns the shard key belongs in, out of n, from the FNV-1a
+// hash of its canonical form. buf is scratch space.
+func shardOf(buf *bytes.Buffer, key interface{}, n int) (int, error) {
+	buf.Reset()
+	if err := writeCanonical(buf, key); err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	h.Write(buf.Bytes())
+	return int(h.Sum64() % uint64(n)), nil
+}
+
+// EncodeSharded writes data to path as a sharded file of n shards.
+func EncodeSharded(path string, data map[interface{}]interface{}, n int) error {
+	if n < 1 || n > maxShards {
+		return fmt.Errorf("shard count %d: want 1 to %d", n, maxShards)
+	}
+	shards := make([]map[interface{}]interface{}, n)
+	for i := range shards {
+		shards[i] = make(map[interface{}]interface{}, len(data)/n+1)
+	}
+	var buf bytes.Buffer
+	for k, v := range data {
+		i, err := shardOf(&buf, k, n)
+		if err != nil {
+			return fmt.Errorf("key %v: %w", k, err)
+		}
+		shards[i][k] = v
+	}
+
+	blobs := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	for i := range shards {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			var buf bytes.Buffer
+			if err := encodeRoot(gob.NewEncoder(&buf), shards[i]); err != nil {
+				errs[i] = fmt.Errorf("shard %d: %w", i, err)
+				return
+			}
+			blobs[i] = buf.Bytes()
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	m := ShardManifest{Keys: len(data), Entries: make([]ShardEntry, n)}
+	for i, b := range blobs {
+		m.Entries[i] = ShardEntry{Keys: len(shards[i]), Size: len(b), Sum: sha256.Sum256(b)}
+	}
+	return writeAtomic(path, func(f *os.File) error {
+		w := newEncodeWriter(f)
+		if _, err := fmt.Fprintf(w, "%s%d\n", shardsPrefix, ShardsVersion); err != nil {
+			return err
+		}
+		enc := gob.NewEncoder(w)
+		if err := enc.Encode(&m); err != nil {
+			return err
+		}
+		for _, b := range blobs {
+			if err := enc.Encode(b); err != nil {
+				return err
+			}
+		}
+		return w.Flush()
+	})
+}
+
+// isSharded reports whether br starts with a sharded file header.
+func isSharded(br *bufio.Reader) bool {
+	head, _ := br.Peek(len(shardsPrefix))
+	return string(head) == shardsPrefix
+}
+
+// readShardManifest reads the header and manifest of a sharded file,
+// leaving dec at the first shard.
+func readShardManifest(br *bufio.Reader) (version int, m *ShardManifest, dec *gob.Decoder, err error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("sharded file header: %w", err)
+	}
+	s, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), shardsPrefix)
+	if !ok {
+		return 0, nil, nil, errors.New("not a sharded file")
+	}
+	if version, err = strconv.Atoi(s); err != nil {
+		return 0, nil, nil, fmt.Errorf("sharded file header: bad version %q", s)
+	}
+	if err := CanRead(FormatShards, version); err != nil {
+		return version, nil, nil, err
+	}
+	dec = gob.NewDecoder(br)
+	m = new(ShardManifest)
+	if err := dec.Decode(m); err != nil {
+		return version, nil, nil, fmt.Errorf("sharded file manifest: %w", err)
+	}
+	if n := len(m.Entries); n < 1 || n > maxShards {
+		return version, nil, nil, fmt.Errorf("sharded file manifest lists %d shards, want 1 to %d", n, maxShards)
+	}
+	return version, m, dec, nil
+}
+
+// DecodeSharded reads a sharded file from r and reassembles its map,
+// decoding up to workers shards at once, or one per CPU if workers is 0.
+func DecodeSharded(r io.Reader, workers int) (map[interface{}]interface{}, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	_, m, dec, err := readShardManifest(br)
+	if err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	n := len(m.Entries)
+	shards := make([]map[interface{}]interface{}, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	var readErr error
+	for i, e := range m.Entries {
+		var blob []byte
+		if err := dec.Decode(&blob); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = fmt.Errorf("shard %d of %d is missing: the file is truncated", i, n)
+			} else {
+				err = fmt.Errorf("shard %d: %w", i, err)
+			}
+			readErr = err
+			break
+		}
+		if len(blob) != e.Size || sha256.Sum256(blob) != e.Sum {
+			readErr = fmt.Errorf("shard %d does not match the manifest's size and SHA-256", i)
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			shards[i], errs[i] = decodeShard(blob, i, n, e.Keys)
+		}()
+	}
+	if readErr == nil {
+		var extra []byte
+		if err := dec.Decode(&extra); !errors.Is(err, io.EOF) {
+			readErr = fmt.Errorf("data after the %d shards the manifest lists", n)
+		}
+	}
+	wg.Wait()
+	if readErr != nil {
+		return nil, readErr
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	out := make(map[interface{}]interface{}, m.Keys)
+	for _, s := range shards {
+		for k, v := range s {
+			out[k] = v
+		}
+	}
+	if len(out) != m.Keys {
+		return nil, fmt.Errorf("shards hold %d keys, the manifest says %d", len(out), m.Keys)
+	}
+	return out, nil
+}
+
+// decodeShard decodes shard i of n and checks it holds keys keys, each
+// one belonging there.
+func decodeShard(blob []byte, i, n, keys int) (map[interface{}]interface{}, error) {
+	var s map[interface{}]interface{}
+	if err := gob.NewDecoder(bytes.NewReader(blob)).Decode(&s); err != nil {
+		return nil, fmt.Errorf("shard %d: %w", i, err)
+	}
+	if len(s) != keys {
+		return nil, fmt.Errorf("shard %d holds %d keys, the manifest says %d", i, len(s), keys)
+	}
+	var buf bytes.Buffer
+	for k := range s {
+		j, err := shardOf(&buf, k, n)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: key %v: %w", i, k, err)
+		}
+		if j != i {
+			return nil, fmt.Errorf("shard %d holds key %v, which belongs in shard %d", i, k, j)
+		}
+	}
+	return s, nil
+}
+
+// openSharded reassembles the sharded file in br and returns it as one
+// gob stream, for commands that read streams.
+func openSharded(br *bufio.Reader) (io.Reader, error) {
+	registerKnownTypes()
+	m, err := DecodeSharded(br, shardWorkers)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&buf), m); err != nil {
+		return nil, fmt.Errorf("reassembled shards: %w", err)
+	}
+	return &buf, nil
+}
@@ -0,0 +1,207 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeSharded(t *testing.T, data map[interface{}]interface{}, shards int) string {
+	t.Helper()
+	registerKnownTypes()
+	name := filepath.Join(t.TempDir(), "data.shards")
+	if err := EncodeSharded(name, data, shards); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestShardsRoundTrip(t *testing.T) {
+	data := syntheticData(100, 1)
+	name := writeSharded(t, data, 4)
+	if err := checkDecodesTo(name, data); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 3} {
+		got, err := DecodeSharded(bytes.NewReader(raw), workers)
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		if !reflect.DeepEqual(got, data) {
+			t.Errorf("%d workers: decodes to different data", workers)
+		}
+	}
+
+	// Read as a plain stream, a sharded file is the map in one value.
+	r, err := openPayload(bytes.NewReader(raw), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back map[interface{}]interface{}
+	if err := gob.NewDecoder(r).Decode(&back); err != nil {
+		t.Fatalf("as a stream: %v", err)
+	}
+	if !reflect.DeepEqual(back, data) {
+		t.Error("as a stream: decodes to different data")
+	}
+}
+
+// TestShardsRefused checks that truncated, extra, foreign and misplaced
+// shards are refused.
+func TestShardsRefused(t *testing.T) {
+	raw, err := os.ReadFile(writeSharded(t, syntheticData(100, 1), 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, m, dec, err := readShardManifest(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs := make([][]byte, len(m.Entries))
+	for i := range blobs {
+		if err := dec.Decode(&blobs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.Entries[0].Keys == 0 || m.Entries[1].Keys == 0 {
+		t.Fatal("the first two shards are empty and look alike")
+	}
+	write := func(m ShardManifest, blobs [][]byte) []byte {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "%s%d\n", shardsPrefix, ShardsVersion)
+		enc := gob.NewEncoder(&buf)
+		if err := enc.Encode(&m); err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range blobs {
+			if err := enc.Encode(b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return buf.Bytes()
+	}
+	swapped := *m
+	swapped.Entries = slices.Clone(m.Entries)
+	swapped.Entries[0], swapped.Entries[1] = m.Entries[1], m.Entries[0]
+	foreign := slices.Clone(blobs)
+	var other bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&other), map[interface{}]interface{}{"other": 1}); err != nil {
+		t.Fatal(err)
+	}
+	foreign[2] = other.Bytes()
+	fewer := *m
+	fewer.Entries = m.Entries[:3]
+	for _, c := range []struct {
+		what string
+		file []byte
+		want string
+	}{
+		{"truncated", raw[:len(raw)-len(blobs[3])/2], "shard 3 of 4 is missing"},
+		{"missing a shard", write(*m, blobs[:3]), "shard 3 of 4 is missing"},
+		{"one shard too many", write(fewer, blobs), "data after the 3 shards"},
+		{"shard from another file", write(*m, foreign), "shard 2 does not match"},
+		{"shards swapped", write(swapped, append([][]byte{blobs[1], blobs[0]}, blobs[2:]...)), "which belongs in shard"},
+		{"newer version", []byte(fmt.Sprintf("%s%d\n", shardsPrefix, ShardsVersion+1)), "please upgrade"},
+	} {
+		_, err := DecodeSharded(bytes.NewReader(c.file), 0)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want an error containing %q", c.what, err, c.want)
+		}
+	}
+}
+
+// TestShardsMatchSerial checks that generator data written as shards,
+// encoded and decoded concurrently, reassembles to what one encoder and
+// decoder make of it.
+func TestShardsMatchSerial(t *testing.T) {
+	registerKnownTypes()
+	data := syntheticData(5000, 7)
+	var buf bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&buf), data); err != nil {
+		t.Fatal(err)
+	}
+	var serial map[interface{}]interface{}
+	if err := gob.NewDecoder(&buf).Decode(&serial); err != nil {
+		t.Fatal(err)
+	}
+	want, err := CanonicalHash(serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, shards := range []int{1, 7, 64} {
+		raw, err := os.ReadFile(writeSharded(t, data, shards))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{1, 0} {
+			got, err := DecodeSharded(bytes.NewReader(raw), workers)
+			if err != nil {
+				t.Fatalf("%d shards, %d workers: %v", shards, workers, err)
+			}
+			if !reflect.DeepEqual(got, serial) {
+				t.Errorf("%d shards, %d workers: differs from the serial decode", shards, workers)
+			}
+			if h, err := CanonicalHash(got); err != nil || h != want {
+				t.Errorf("%d shards, %d workers: hash %x, %v; serial %x", shards, workers, h, err, want)
+			}
+		}
+	}
+}
+
+// BenchmarkEncodeSharded writes 100000 generator keys with one encoder,
+// as encode does without -shards, and as 16 shards encoded concurrently.
+// The speedup follows the CPUs: compare with go test -bench Sharded -cpu 1,4.
+func BenchmarkEncodeSharded(b *testing.B) {
+	registerKnownTypes()
+	data := syntheticData(100000, 1)
+	name := filepath.Join(b.TempDir(), "data")
+	b.Run("serial", func(b *testing.B) {
+		for b.Loop() {
+			if err := writeAtomic(name, func(f *os.File) error { return encodeRoot(gob.NewEncoder(f), data) }); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("shards=16", func(b *testing.B) {
+		for b.Loop() {
+			if err := EncodeSharded(name, data, 16); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkDecodeSharded reassembles 100000 keys from 16 shards, one at
+// a time and with a worker per CPU.
+func BenchmarkDecodeSharded(b *testing.B) {
+	registerKnownTypes()
+	name := filepath.Join(b.TempDir(), "data.shards")
+	if err := EncodeSharded(name, syntheticData(100000, 1), 16); err != nil {
+		b.Fatal(err)
+	}
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, workers := range []int{1, runtime.GOMAXPROCS(0)} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := DecodeSharded(bytes.NewReader(raw), workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// shrink turns a large input that shows a failure into a small one that
+// still shows it, for attaching to a bug report. The failure is a
+// predicate on a candidate file: a decode error containing -fails-with,
+// or an external command exiting with -exit-code (any non-zero status by
+// default), optionally with -fails-with matched against its output.
+//
+// When the input decodes, shrink works on the values: it removes map
+// keys, slice elements and whole records, largest chunks first, and cuts
+// strings and byte slices, re-encoding each candidate in the input's
+// layout. When it does not, it removes byte ranges, tail first, halving
+// the range size as long as the failure holds. Every accepted step is
+// strictly smaller and is logged, and -seed fixes the order candidates
+// are tried in, so a run can be repeated exactly.
+
+// shrinkPredicate decides whether a candidate still fails.
+type shrinkPredicate struct {
+	failsWith string
+	cmd       string
+	exitCode  int    // with cmd: the status wanted, or -1 for any failure
+	tmp       string // with cmd: where candidates are written
+}
+
+func (p *shrinkPredicate) holds(data []byte) bool {
+	if p.cmd == "" {
+		err := decodeCandidate(data)
+		return err != nil && strings.Contains(err.Error(), p.failsWith)
+	}
+	if err := os.WriteFile(p.tmp, data, 0o644); err != nil {
+		return false
+	}
+	cmd := p.cmd
+	if strings.Contains(cmd, "{}") {
+		cmd = strings.ReplaceAll(cmd, "{}", shellQuote(p.tmp))
+	} else {
+		cmd += " " + shellQuote(p.tmp)
+	}
+	out, err := exec.Command("sh", "-c", cmd).CombinedOutput()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		return false
+	}
+	if p.exitCode < 0 && code == 0 || p.exitCode >= 0 && code != p.exitCode {
+		return false
+	}
+	return strings.Contains(string(out), p.failsWith)
+}
+
+// decodeCandidate decodes data as decode does, turning a panic into an
+// error so a crashing input can be shrunk too.
+func decodeCandidate(data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	_, err = decodeAny(bytes.NewReader(data), "")
+	return err
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shrinker holds the smallest failing input found so far.
+type shrinker struct {
+	pred     *shrinkPredicate
+	rng      *rand.Rand
+	best     []byte
+	steps    int
+	tests    int
+	maxTests int
+}
+
+// try tests data, and keeps it when it is smaller and still fails.
+func (s *shrinker) try(data []byte, what string) bool {
+	if len(data) >= len(s.best) || s.tests >= s.maxTests {
+		return false
+	}
+	s.tests++
+	if !s.pred.holds(data) {
+		return false
+	}
+	s.steps++
+	fmt.Printf("step %d: %s: %d -> %d bytes\n", s.steps, what, len(s.best), len(data))
+	s.best = data
+	return true
+}
+
+// chunks calls fn with the ranges of a sequence of n elements to try
+// removing: all of it first, then halves, quarters and so on down to
+// single elements, each size from the end backwards. It stops at the
+// first range fn accepts and reports whether there was one.
+func chunks(n int, fn func(start, end int) bool) bool {
+	if n == 0 {
+		return false
+	}
+	for size := n; ; size = (size + 1) / 2 {
+		for end := n; end > 0; end -= size {
+			if fn(max(end-size, 0), end) {
+				return true
+			}
+		}
+		if size == 1 {
+			return false
+		}
+	}
+}
+
+// shrinkBytes removes byte ranges while the failure holds.
+func (s *shrinker) shrinkBytes() {
+	for s.tests < s.maxTests {
+		data := s.best
+		if !chunks(len(data), func(start, end int) bool {
+			cand := append(data[:start:start], data[end:]...)
+			return s.try(cand, fmt.Sprintf("removed bytes %d to %d", start, end))
+		}) {
+			return
+		}
+	}
+}
+
+// valueShrinker removes parts of decoded values while the failure holds.
+type valueShrinker struct {
+	*shrinker
+	values []interface{}
+	types  []reflect.Type // the type each value goes back out as
+}
+
+// test re-encodes the values as they now are and tries the result.
+func (vs *valueShrinker) test(what string) bool {
+	data, err := encodeStreamValues(vs.values, vs.types)
+	return err == nil && vs.try(data, what)
+}
+
+// shrinkValues makes passes over the values, each stopping at the first
+// reduction that keeps the failure, until a pass finds none.
+func (vs *valueShrinker) shrinkValues() {
+	for vs.tests < vs.maxTests {
+		if len(vs.values) > 1 && chunks(len(vs.values), func(start, end int) bool {
+			old, oldTypes := vs.values, vs.types
+			vs.values = append(old[:start:start], old[end:]...)
+			vs.types = append(oldTypes[:start:start], oldTypes[end:]...)
+			if vs.test(fmt.Sprintf("removed records [%d:%d]", start, end)) {
+				return true
+			}
+			vs.values, vs.types = old, oldTypes
+			return false
+		}) {
+			continue
+		}
+		found := false
+		for i := range vs.values {
+			slot := reflect.ValueOf(vs.values).Index(i)
+			path := ""
+			if len(vs.values) > 1 {
+				path = fmt.Sprintf("[%d]", i)
+			}
+			if vs.node(slot.Elem(), path, slot.Set) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+	}
+}
+
+// node tries the reductions of v, the value at path, and then those
+// below it. set replaces v where it is held, and is nil when it cannot
+// be replaced.
+func (vs *valueShrinker) node(v reflect.Value, path string, set func(reflect.Value)) bool {
+	if vs.tests >= vs.maxTests {
+		return false
+	}
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return false
+		}
+		var elemSet func(reflect.Value)
+		if v.Elem().CanSet() {
+			elemSet = v.Elem().Set
+		}
+		return vs.node(v.Elem(), path, elemSet)
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return sprint(keys[i]) < sprint(keys[j]) })
+		vs.rng.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		if chunks(len(keys), func(start, end int) bool {
+			removed := make([]reflect.Value, 0, end-start)
+			for _, k := range keys[start:end] {
+				removed = append(removed, v.MapIndex(k))
+				v.SetMapIndex(k, reflect.Value{})
+			}
+			what := fmt.Sprintf("removed %d keys of %s", end-start, pathOrRoot(path))
+			if end-start == 1 {
+				what = "removed " + pathOrRoot(joinPath(path, pathKey(keys[start])))
+			}
+			if vs.test(what) {
+				return true
+			}
+			for i, k := range keys[start:end] {
+				v.SetMapIndex(k, removed[i])
+			}
+			return false
+		}) {
+			return true
+		}
+		for _, k := range keys {
+			k := k
+			if vs.node(v.MapIndex(k), joinPath(path, pathKey(k)), func(nv reflect.Value) { v.SetMapIndex(k, nv) }) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		isBytes := v.Type().Elem().Kind() == reflect.Uint8
+		if v.Kind() == reflect.Slice && set != nil && v.Len() > 0 {
+			if isBytes {
+				return vs.cut(v, path, set)
+			}
+			if chunks(v.Len(), func(start, end int) bool {
+				nv := reflect.AppendSlice(reflect.MakeSlice(v.Type(), 0, v.Len()-(end-start)), v.Slice(0, start))
+				nv = reflect.AppendSlice(nv, v.Slice(end, v.Len()))
+				set(nv)
+				if vs.test(fmt.Sprintf("removed %s[%d:%d]", pathOrRoot(path), start, end)) {
+					return true
+				}
+				set(v)
+				return false
+			}) {
+				return true
+			}
+		}
+		if isBytes {
+			return false
+		}
+		for i := 0; i < v.Len(); i++ {
+			var elemSet func(reflect.Value)
+			if v.Index(i).CanSet() {
+				elemSet = v.Index(i).Set
+			}
+			if vs.node(v.Index(i), fmt.Sprintf("%s[%d]", path, i), elemSet) {
+				return true
+			}
+		}
+	case reflect.String:
+		if set != nil && v.Len() > 0 {
+			return vs.cut(v, path, set)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			var fieldSet func(reflect.Value)
+			if v.Field(i).CanSet() {
+				fieldSet = v.Field(i).Set
+			}
+			if vs.node(v.Field(i), joinPath(path, v.Type().Field(i).Name), fieldSet) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cut tries a string or byte slice empty, then cut to half its length.
+func (vs *valueShrinker) cut(v reflect.Value, path string, set func(reflect.Value)) bool {
+	for i, n := range []int{0, v.Len() / 2} {
+		if i > 0 && n == 0 {
+			break
+		}
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(v.Slice(0, n))
+		set(nv)
+		if vs.test(fmt.Sprintf("cut %s to %d bytes", pathOrRoot(path), n)) {
+			return true
+		}
+		set(v)
+	}
+	return false
+}
+
+func shrinkFlags(fs *flag.FlagSet) func(args []string) error {
+	var p shrinkPredicate
+	fs.StringVar(&p.failsWith, "fails-with", "", "the failure: decoding fails with an error containing this, or with -cmd, the command's output contains it")
+	fs.StringVar(&p.cmd, "cmd", "", "the failure: this shell command fails on the candidate file, given as {} or appended")
+	fs.IntVar(&p.exitCode, "exit-code", -1, "with -cmd, the exit status that counts as failing (default any non-zero)")
+	seed := fs.Int64("seed", 1, "seed for the order candidates are tried in")
+	maxTests := fs.Int("max-tests", 10000, "stop after testing this many candidates")
+	out := fs.String("out", "", "write the result here (default: in.min.ext next to the input)")
+	return func(args []string) error {
+		if len(args) != 1 || p.failsWith == "" && p.cmd == "" {
+			return errors.New("usage: shrink (-fails-with text | -cmd command [-exit-code n] [-fails-with text]) [-seed n] [-max-tests n] [-out file] in.gob")
+		}
+		in := args[0]
+		raw, err := os.ReadFile(in)
+		if err != nil {
+			return err
+		}
+		if *out == "" {
+			ext := filepath.Ext(in)
+			*out = strings.TrimSuffix(in, ext) + ".min" + ext
+		}
+		if p.cmd != "" {
+			dir, err := os.MkdirTemp("", "shrink")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dir)
+			p.tmp = filepath.Join(dir, "candidate"+filepath.Ext(in))
+		}
+
+		registerKnownTypes()
+		if !p.holds(raw) {
+			return fmt.Errorf("%s does not show the failure to begin with", in)
+		}
+		s := &shrinker{pred: &p, rng: rand.New(rand.NewSource(*seed)), best: raw, maxTests: *maxTests}
+		values, types, err := readStreamValues(raw)
+		if _, layered := sniffLayer(bufio.NewReader(bytes.NewReader(raw))); err == nil && !layered {
+			vs := &valueShrinker{shrinker: s, values: values, types: types}
+			if data, err := encodeStreamValues(values, types); err == nil && p.holds(data) {
+				if len(data) < len(s.best) {
+					s.best = data
+				}
+				fmt.Println("input decodes: shrinking values")
+				vs.shrinkValues()
+			} else {
+				fmt.Println("input decodes, but re-encoded it no longer fails: shrinking bytes")
+				s.shrinkBytes()
+			}
+		} else {
+			fmt.Println("input does not decode: shrinking bytes")
+			s.shrinkBytes()
+		}
+		if s.tests >= s.maxTests {
+			fmt.Printf("stopped after %d tests (-max-tests)\n", s.tests)
+		}
+		if err := writeFileAtomic(*out, s.best); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d -> %d bytes in %d steps, %d tests\n", *out, len(raw), len(s.best), s.steps, s.tests)
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package gobtool
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// encode -sort-slices sorts the elements of every slice of orderable
+// scalars (integers, floats, strings or bools, all of one type) before
+// encoding, so archives of data whose element order means nothing come
+// out byte for byte the same. It changes the data, which is why it is
+// opt-in: it is only right when no reader cares about the order. Slices
+// of anything else, such as maps, structs or a mix of types, are left as
+// they are with a warning. Byte slices are values rather than lists and
+// are never sorted.
+
+// sortSlices sorts the slices in v in place, adding a WarnSliceUnsorted
+// to ws for each slice it leaves alone.
+func sortSlices(v interface{}, ws *Warnings) {
+	sortSlicesIn(reflect.ValueOf(v), "", make(cycleGuard), ws)
+}
+
+func sortSlicesIn(v reflect.Value, path string, guard cycleGuard, ws *Warnings) {
+	v, leave, ok := guard.descend(v)
+	if !ok || !v.IsValid() {
+		return
+	}
+	defer leave()
+	switch v.Kind() {
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			sortSlicesIn(iter.Value(), joinPath(path, pathKey(iter.Key().Interface())), guard, ws)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				sortSlicesIn(v.Field(i), joinPath(path, v.Type().Field(i).Name), guard, ws)
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			sortSlicesIn(v.Index(i), fmt.Sprintf("%s[%d]", path, i), guard, ws)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			sortSlicesIn(v.Index(i), fmt.Sprintf("%s[%d]", path, i), guard, ws)
+		}
+		if v.Len() < 2 {
+			return
+		}
+		if t, ok := scalarElemType(v); ok {
+			sort.SliceStable(v.Interface(), func(i, j int) bool {
+				return lessScalar(indirect(v.Index(i)), indirect(v.Index(j)), t.Kind())
+			})
+			return
+		}
+		ws.add(WarnSliceUnsorted, path, "%s holds elements that are not all one orderable scalar type; left unsorted", v.Type())
+	}
+}
+
+// scalarElemType returns the type all elements of slice v share, when it
+// is an orderable scalar.
+func scalarElemType(v reflect.Value) (reflect.Type, bool) {
+	var t reflect.Type
+	for i := 0; i < v.Len(); i++ {
+		e := indirect(v.Index(i))
+		if !e.IsValid() || t != nil && e.Type() != t {
+			return nil, false
+		}
+		t = e.Type()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return t, true
+	}
+	return nil, false
+}
+
+// lessScalar orders two scalars of kind k. NaN sorts before every other
+// float, so the order is total.
+func lessScalar(a, b reflect.Value, k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		x, y := a.Float(), b.Float()
+		return x < y || math.IsNaN(x) && !math.IsNaN(y)
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package gobtool
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSortSlices(t *testing.T) {
+	type holder struct{ Names []string }
+	nan := math.NaN()
+	data := map[interface{}]interface{}{
+		"ints":   []int{3, 1, 2},
+		"floats": []float64{2, nan, 1},
+		"mixed":  []interface{}{2, "a", 1},
+		"boxed":  []interface{}{"b", "c", "a"},
+		"bytes":  []byte{3, 1, 2},
+		"nested": []interface{}{[]int{2, 1}},
+		"struct": holder{[]string{"y", "x"}},
+		"maps":   []map[string]int{{"b": 1}, {"a": 2}},
+	}
+	var ws Warnings
+	sortSlices(data, &ws)
+	for k, want := range map[string]interface{}{
+		"ints":   []int{1, 2, 3},
+		"mixed":  []interface{}{2, "a", 1},
+		"boxed":  []interface{}{"a", "b", "c"},
+		"bytes":  []byte{3, 1, 2},
+		"nested": []interface{}{[]int{1, 2}},
+		"struct": holder{[]string{"x", "y"}},
+	} {
+		if !reflect.DeepEqual(data[k], want) {
+			t.Errorf("%s: got %v, want %v", k, data[k], want)
+		}
+	}
+	if f := data["floats"].([]float64); !math.IsNaN(f[0]) || f[1] != 1 || f[2] != 2 {
+		t.Errorf("floats: got %v, want [NaN 1 2]", f)
+	}
+	var paths []string
+	for _, w := range ws.List {
+		if w.Code != WarnSliceUnsorted {
+			t.Errorf("warning %s at %s", w.Code, w.Path)
+		}
+		paths = append(paths, w.Path)
+	}
+	sort.Strings(paths)
+	if want := []string{"maps", "mixed"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("warned at %v, want %v", paths, want)
+	}
+}
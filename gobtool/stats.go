@@ -0,0 +1,350 @@
+package gobtool
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// stats gathers distributions per path across every value of one or more
+// streams: string lengths, container sizes and numeric values, counted
+// into buckets. Records are walked as they are decoded and then dropped,
+// so memory depends on the number of distinct paths, not on the input.
+// Slice indexes are folded together, so scores[0] and scores[1] are both
+// counted under scores[].
+
+// maxTrackedStrings bounds the distinct strings -top remembers per path.
+const maxTrackedStrings = 10000
+
+var indexSegment = regexp.MustCompile(`\[\d+\]`)
+
+// bucketCounts counts values into the buckets split by Bounds: below
+// Bounds[0], from each bound up to the next, and from the last bound up.
+type bucketCounts struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []int     `json:"counts"`
+	Min    float64   `json:"min"`
+	Max    float64   `json:"max"`
+	N      int       `json:"n"`
+}
+
+func newBucketCounts(bounds []float64) *bucketCounts {
+	return &bucketCounts{Bounds: bounds, Counts: make([]int, len(bounds)+1)}
+}
+
+func (b *bucketCounts) add(x float64) {
+	if b.N == 0 || x < b.Min {
+		b.Min = x
+	}
+	if b.N == 0 || x > b.Max {
+		b.Max = x
+	}
+	b.N++
+	b.Counts[sort.Search(len(b.Bounds), func(i int) bool { return x < b.Bounds[i] })]++
+}
+
+// labels names the buckets: "<1", "[1,10)", ..., ">=1000".
+func (b *bucketCounts) labels() []string {
+	f := func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
+	labels := make([]string, len(b.Counts))
+	for i := range labels {
+		switch {
+		case i == 0:
+			labels[i] = "<" + f(b.Bounds[0])
+		case i == len(b.Bounds):
+			labels[i] = ">=" + f(b.Bounds[i-1])
+		default:
+			labels[i] = "[" + f(b.Bounds[i-1]) + "," + f(b.Bounds[i]) + ")"
+		}
+	}
+	return labels
+}
+
+// valueCount is a string value and how often it was seen.
+type valueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// pathStats is what stats knows about one path.
+type pathStats struct {
+	Path      string        `json:"path"`
+	Count     int           `json:"count"`
+	StringLen *bucketCounts `json:"string_len,omitempty"`
+	Size      *bucketCounts `json:"size,omitempty"` // slice, array and map lengths
+	Value     *bucketCounts `json:"value,omitempty"`
+	Top       []valueCount  `json:"top,omitempty"`
+	// TopPartial is set when the path had more distinct strings than
+	// could be tracked, so Top may miss some.
+	TopPartial bool `json:"top_partial,omitempty"`
+
+	strings map[string]int
+}
+
+// statsCollector accumulates pathStats over any number of records.
+type statsCollector struct {
+	sizeBounds, valueBounds []float64
+	top                     int
+	paths                   map[string]*pathStats
+	records                 int
+	// sample, when enabled, limits the elements walked below large
+	// containers; sampled counts the containers it cut.
+	sample  *sampler
+	sampled int
+}
+
+func (c *statsCollector) add(rec interface{}) {
+	c.records++
+	var visit func(path string, depth int, v reflect.Value) bool
+	visit = func(path string, depth int, v reflect.Value) bool {
+		at := path
+		if path == "" {
+			path = "(root)"
+		}
+		path = indexSegment.ReplaceAllString(path, "[]")
+		ps := c.paths[path]
+		if ps == nil {
+			ps = &pathStats{Path: path}
+			c.paths[path] = ps
+		}
+		ps.Count++
+		if !v.IsValid() {
+			return false
+		}
+		switch v.Kind() {
+		case reflect.String:
+			if ps.StringLen == nil {
+				ps.StringLen = newBucketCounts(c.sizeBounds)
+			}
+			ps.StringLen.add(float64(v.Len()))
+			c.countString(ps, v.String())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			if ps.Size == nil {
+				ps.Size = newBucketCounts(c.sizeBounds)
+			}
+			ps.Size.add(float64(v.Len()))
+			// the size is exact; only what is below it is sampled
+			if keep := c.sample.pick(v.Len()); keep != nil && isContainer(v) {
+				c.sampled++
+				if v.Kind() == reflect.Map {
+					keys := sortedMapKeys(v)
+					for _, i := range keep {
+						walkPathsFrom(v.MapIndex(keys[i]), joinPath(at, pathKey(keys[i].Interface())), depth+1, cycleGuard{}, visit)
+					}
+					return false
+				}
+				for _, i := range keep {
+					walkPathsFrom(v.Index(i), fmt.Sprintf("%s[%d]", at, i), depth+1, cycleGuard{}, visit)
+				}
+				return false
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			c.addValue(ps, float64(v.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			c.addValue(ps, float64(v.Uint()))
+		case reflect.Float32, reflect.Float64:
+			c.addValue(ps, v.Float())
+		}
+		return true
+	}
+	walkPaths(reflect.ValueOf(rec), visit)
+}
+
+func (c *statsCollector) addValue(ps *pathStats, x float64) {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return
+	}
+	if ps.Value == nil {
+		ps.Value = newBucketCounts(c.valueBounds)
+	}
+	ps.Value.add(x)
+}
+
+func (c *statsCollector) countString(ps *pathStats, s string) {
+	if c.top <= 0 {
+		return
+	}
+	if ps.strings == nil {
+		ps.strings = make(map[string]int)
+	}
+	if _, ok := ps.strings[s]; !ok && len(ps.strings) >= maxTrackedStrings {
+		ps.TopPartial = true
+		return
+	}
+	ps.strings[s]++
+}
+
+// result returns the statistics sorted by path, with Top filled in.
+func (c *statsCollector) result() []*pathStats {
+	out := make([]*pathStats, 0, len(c.paths))
+	for _, ps := range c.paths {
+		for s, n := range ps.strings {
+			ps.Top = append(ps.Top, valueCount{s, n})
+		}
+		sort.Slice(ps.Top, func(i, j int) bool {
+			if ps.Top[i].Count != ps.Top[j].Count {
+				return ps.Top[i].Count > ps.Top[j].Count
+			}
+			return ps.Top[i].Value < ps.Top[j].Value
+		})
+		if len(ps.Top) > c.top {
+			ps.Top = ps.Top[:c.top]
+		}
+		out = append(out, ps)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// addFile streams every record of name into c.
+func (c *statsCollector) addFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := openPayload(f, name)
+	if err != nil {
+		return err
+	}
+	err = DecodeStream(r, func(rec interface{}) error {
+		c.add(rec)
+		return nil
+	})
+	return payloadError(r, err)
+}
+
+func writeStatsTable(w io.Writer, stats []*pathStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(path, what string, b *bucketCounts) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%g\t%g", path, what, b.N, b.Min, b.Max)
+		for i, label := range b.labels() {
+			fmt.Fprintf(tw, "\t%s:%d", label, b.Counts[i])
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintln(tw, "PATH\tWHAT\tN\tMIN\tMAX\tBUCKETS")
+	for _, ps := range stats {
+		if ps.StringLen != nil {
+			row(ps.Path, "string len", ps.StringLen)
+		}
+		if ps.Size != nil {
+			row(ps.Path, "size", ps.Size)
+		}
+		if ps.Value != nil {
+			row(ps.Path, "value", ps.Value)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, ps := range stats {
+		if len(ps.Top) == 0 {
+			continue
+		}
+		parts := make([]string, len(ps.Top))
+		for i, t := range ps.Top {
+			parts[i] = fmt.Sprintf("%s×%d", strconv.Quote(t.Value), t.Count)
+		}
+		more := ""
+		if ps.TopPartial {
+			more = fmt.Sprintf(" (over %d distinct values; counts may be incomplete)", maxTrackedStrings)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s%s\n", ps.Path, strings.Join(parts, ", "), more); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseBounds parses a comma-separated, increasing list of bucket bounds.
+func parseBounds(s string) ([]float64, error) {
+	var bounds []float64
+	for _, f := range strings.Split(s, ",") {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad bucket bound %q", f)
+		}
+		if len(bounds) > 0 && x <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("bucket bounds must increase: %q", s)
+		}
+		bounds = append(bounds, x)
+	}
+	return bounds, nil
+}
+
+// statsFlags registers the flags of stats; the command returned
+// implements "stats file-or-dir...".
+func statsFlags(fset *flag.FlagSet) func(args []string) error {
+	format := fset.String("format", "table", "output format: table or json")
+	sizeBuckets := fset.String("buckets", "1,10,100,1000,10000", "bucket bounds for string lengths and container sizes")
+	valueBuckets := fset.String("value-buckets", "0,1,10,100,1000,10000", "bucket bounds for numeric values")
+	top := fset.Int("top", 0, "also list the N most common string values per path")
+	var sample sampler
+	sample.register(fset)
+	return func(args []string) error {
+		if len(args) == 0 {
+			return errors.New("usage: stats [-format table|json] [-buckets b,b,...] [-value-buckets b,b,...] [-top n] [-sample N[:first|stride|random]] file-or-dir...")
+		}
+		if *format != "table" && *format != "json" {
+			return fmt.Errorf("unknown format %q", *format)
+		}
+		c := &statsCollector{top: *top, paths: make(map[string]*pathStats), sample: &sample}
+		var err error
+		if c.sizeBounds, err = parseBounds(*sizeBuckets); err != nil {
+			return fmt.Errorf("-buckets: %w", err)
+		}
+		if c.valueBounds, err = parseBounds(*valueBuckets); err != nil {
+			return fmt.Errorf("-value-buckets: %w", err)
+		}
+
+		registerKnownTypes()
+		for _, root := range args {
+			err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				if err := c.addFile(p); err != nil {
+					return fmt.Errorf("%s: %w", p, err)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		stats := c.result()
+		if *format == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			return enc.Encode(struct {
+				Records int          `json:"records"`
+				Sampled int          `json:"sampled_containers,omitempty"`
+				Paths   []*pathStats `json:"paths"`
+			}{c.records, c.sampled, stats})
+		}
+		if err := writeStatsTable(os.Stdout, stats); err != nil {
+			return err
+		}
+		fmt.Printf("%d records, %d paths\n", c.records, len(stats))
+		if c.sampled > 0 {
+			fmt.Printf("sampled: below %d %s longer than %d, only %d elements each were counted (%s); counts there are of the sample, not exact\n",
+				c.sampled, plural(int64(c.sampled), "container", "containers"), max(sample.threshold, sample.n), sample.n, sample.strategy)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,472 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A store keeps gob payloads in a content-addressed directory and leaves
+// a small text reference where the snapshot file would otherwise be.
+// Blobs are named by the hash of their canonical content rather than of
+// their bytes, because gob writes maps in random order: two encodings of
+// the same map differ byte for byte but share one blob, so writing the
+// same map every minute costs a few hundred bytes of reference each time.
+
+// storeRefPrefix starts every reference file, followed by its format
+// version; storeRefMagic is the whole first line this build writes.
+const storeRefPrefix = "gob-store-ref v"
+
+var storeRefMagic = storeRefPrefix + strconv.Itoa(StoreRefVersion) + "\n"
+
+// StoreRef is the content of a reference file.
+type StoreRef struct {
+	Version int       // format version of the reference file
+	Hash    string    // hex content hash, which is also the blob name
+	Sum     string    // hex SHA-256 of the blob bytes
+	Size    int64     // blob size in bytes
+	Created time.Time // when the reference was written
+	Store   string    // blob directory, relative to the reference if not absolute
+}
+
+// EncodeToStore encodes data into the store at dir and writes a reference
+// to it at refPath. As with gob.Encoder, concrete types held in interfaces
+// must already be registered.
+func EncodeToStore(dir, refPath string, data interface{}) (*StoreRef, error) {
+	hash, err := CanonicalHash(data)
+	if err != nil {
+		return nil, err
+	}
+	ref := &StoreRef{
+		Hash:    hex.EncodeToString(hash[:]),
+		Created: time.Now().UTC().Truncate(time.Second),
+		Store:   dir,
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		if rel, err := filepath.Rel(filepath.Dir(refPathAbs(refPath)), abs); err == nil {
+			ref.Store = rel
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	unlock, err := lockStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	blob := filepath.Join(dir, ref.Hash)
+	payload, err := os.ReadFile(blob)
+	if os.IsNotExist(err) {
+		var buf bytes.Buffer
+		if err := encodeRoot(gob.NewEncoder(&buf), data); err != nil {
+			return nil, fmt.Errorf("encode: %w", err)
+		}
+		payload = buf.Bytes()
+		if err := writeFileAtomic(blob, payload); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(payload)
+	ref.Sum = hex.EncodeToString(sum[:])
+	ref.Size = int64(len(payload))
+	if err := writeFileAtomic(refPath, []byte(ref.String())); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+func refPathAbs(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+func (ref *StoreRef) String() string {
+	return fmt.Sprintf("%shash %s\nsha256 %s\nsize %d\ncreated %s\nstore %s\n",
+		storeRefMagic, ref.Hash, ref.Sum, ref.Size, ref.Created.Format(time.RFC3339), ref.Store)
+}
+
+// blobPath locates the blob, resolving a relative store against the
+// directory of the reference file.
+func (ref *StoreRef) blobPath(refPath string) string {
+	dir := ref.Store
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(refPath), dir)
+	}
+	return filepath.Join(dir, ref.Hash)
+}
+
+// isStoreRef reports whether r starts with a reference file header.
+func isStoreRef(r *bufio.Reader) bool {
+	head, _ := r.Peek(len(storeRefPrefix))
+	return string(head) == storeRefPrefix
+}
+
+func readStoreRef(r io.Reader) (*StoreRef, error) {
+	sc := bufio.NewScanner(r)
+	if !sc.Scan() || !strings.HasPrefix(sc.Text(), storeRefPrefix) {
+		return nil, errors.New("not a store reference")
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(sc.Text(), storeRefPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("store reference: bad version in %q", sc.Text())
+	}
+	if err := CanRead(FormatStoreRef, version); err != nil {
+		return nil, err
+	}
+	ref := &StoreRef{Version: version}
+	for sc.Scan() {
+		key, val, _ := strings.Cut(sc.Text(), " ")
+		var err error
+		switch key {
+		case "hash":
+			ref.Hash = val
+		case "sha256":
+			ref.Sum = val
+		case "size":
+			ref.Size, err = strconv.ParseInt(val, 10, 64)
+		case "created":
+			ref.Created, err = time.Parse(time.RFC3339, val)
+		case "store":
+			ref.Store = val
+		}
+		if err != nil {
+			return nil, fmt.Errorf("store reference %s: %w", key, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ref.Hash) != sha256.Size*2 || len(ref.Sum) != sha256.Size*2 {
+		return nil, errors.New("store reference is missing its hashes")
+	}
+	return ref, nil
+}
+
+// openStoreBlob reads and verifies the blob a reference file points at.
+func openStoreBlob(r io.Reader, refPath string) (io.Reader, error) {
+	ref, err := readStoreRef(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(ref.blobPath(refPath))
+	if err != nil {
+		return nil, fmt.Errorf("store blob: %w", err)
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != ref.Sum {
+		return nil, fmt.Errorf("store blob %s: content does not match its checksum", ref.Hash)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// resolveStoreRef returns r itself, or the verified blob if r holds a
+// reference file.
+func resolveStoreRef(r io.Reader, refPath string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if !isStoreRef(br) {
+		return br, nil
+	}
+	return openStoreBlob(br, refPath)
+}
+
+// lockStore serializes writers and gc on a store directory with a lock
+// file naming the owner by PID and hostname. The owner refreshes the
+// lock's mtime while it holds it. A lock is broken when its owner is on
+// this host and no longer running, or when it has gone unrefreshed for
+// staleLockAge, which covers owners on other hosts and platforms where a
+// process cannot be checked.
+func lockStore(dir string) (unlock func(), err error) {
+	name := filepath.Join(dir, ".lock")
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%d %s\n", os.Getpid(), host)
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(name)
+				return nil, err
+			}
+			return holdLock(name), nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if held, err := os.ReadFile(name); err == nil && lockIsStale(held, host, name) {
+			// Another waiter may have broken the lock and taken it in
+			// the meantime; only remove the lock that was judged.
+			if again, err := os.ReadFile(name); err == nil && bytes.Equal(again, held) {
+				os.Remove(name)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("store %s is locked", dir)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+const (
+	// staleLockAge is how long a lock may go unrefreshed before it is
+	// taken to be left over from a crashed process.
+	staleLockAge = time.Minute
+	// lockRefresh is how often a held lock's mtime is refreshed.
+	lockRefresh = staleLockAge / 6
+)
+
+// holdLock refreshes the lock file name until the returned unlock is
+// called, which removes it.
+func holdLock(name string) (unlock func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(lockRefresh)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				os.Chtimes(name, now, now)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		os.Remove(name)
+	}
+}
+
+// lockIsStale reports whether the lock file name, holding held, was left
+// by a process that is gone.
+func lockIsStale(held []byte, host, name string) bool {
+	var pid int
+	var owner string
+	if _, err := fmt.Sscanf(string(held), "%d %s", &pid, &owner); err == nil && owner == host {
+		if alive, known := processAlive(pid); known {
+			return !alive
+		}
+	}
+	fi, err := os.Stat(name)
+	return err == nil && time.Since(fi.ModTime()) > staleLockAge
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames
+// it into place, so readers never see a partial file.
+func writeFileAtomic(name string, data []byte) error {
+	return writeAtomic(name, func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
+
+// writeAtomic calls write on a temporary file next to name and, if it
+// succeeds, renames the file over name. On failure the temporary file is
+// removed and name is left as it was. The new file keeps the mode of the
+// one it replaces. Both are subject to -read-only and -audit-file.
+func writeAtomic(name string, write func(f *os.File) error) error {
+	if err := checkMutable(name, "replace"); err != nil {
+		return err
+	}
+	before := auditHash(name)
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := write(tmp); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return auditChange("write", name, before, auditHash(name))
+}
+
+// gcStore deletes the blobs in dir that none of the reference files under
+// roots points at, returning the names of the blobs removed.
+func gcStore(dir string, roots []string, dryRun bool) ([]string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	live := make(map[string]bool)
+	for _, root := range roots {
+		err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			br := bufio.NewReader(f)
+			if !isStoreRef(br) {
+				return nil
+			}
+			ref, err := readStoreRef(br)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			if blobDir, err := filepath.Abs(filepath.Dir(ref.blobPath(p))); err == nil && blobDir == absDir {
+				live[ref.Hash] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, e := range entries {
+		name := e.Name()
+		if _, err := hex.DecodeString(name); err != nil || len(name) != sha256.Size*2 || live[name] {
+			continue
+		}
+		if !dryRun {
+			if err := removeFile(filepath.Join(dir, name)); err != nil {
+				return removed, err
+			}
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// gcFlags registers the flags of gc; the command returned removes
+// unreferenced blobs from a store. Without -yes it only lists them, as
+// rename-key only reports what it would rename.
+func gcFlags(fs *flag.FlagSet) func(args []string) error {
+	dir := fs.String("store", "", "store directory to collect")
+	yes := fs.Bool("yes", false, "remove the unreferenced blobs; without it nothing is removed")
+	return func(args []string) error {
+		if *dir == "" || len(args) == 0 {
+			return errors.New("usage: gc -store dir [-yes] ref-file-or-dir...")
+		}
+		action := "would remove"
+		if *yes {
+			action = "removed"
+		}
+		// On an error, the blobs listed are those already removed.
+		removed, err := gcStore(*dir, args, !*yes)
+		for _, name := range removed {
+			fmt.Println(action, name)
+		}
+		if *yes {
+			fmt.Printf("%d unreferenced blobs removed\n", len(removed))
+		} else {
+			fmt.Printf("%d unreferenced blobs; rerun with -yes to remove them\n", len(removed))
+		}
+		return err
+	}
+}
+
+// infoFlags registers the flags of info; the command returned describes
+// a file: a store reference is listed field by field.
+func infoFlags(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errors.New("usage: info file")
+		}
+		f, err := openFile(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		br := bufio.NewReader(f)
+		if isSharded(br) {
+			version, m, _, err := readShardManifest(br)
+			if version > 0 {
+				fmt.Printf("file:    %s\nkind:    sharded\nformat:  %s\n", args[0], formatLine(FormatShards, version))
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("shards:  %d\nkeys:    %d\nsize:    %d\n", len(m.Entries), m.Keys, fi.Size())
+			return nil
+		}
+		if !isStoreRef(br) {
+			registerKnownTypes()
+			if d, err := readDelta(args[0]); err == nil {
+				fmt.Printf("file:    %s\nkind:    delta\nformat:  %s\nops:     %d\nsize:    %d\n",
+					args[0], formatLine(FormatDelta, d.Version), len(d.Ops), fi.Size())
+				return nil
+			}
+			fmt.Printf("file:    %s\nkind:    gob\nformat:  gob stream (unversioned)\nsize:    %d\n", args[0], fi.Size())
+			return nil
+		}
+		ref, err := readStoreRef(br)
+		var ve *FormatVersionError
+		if errors.As(err, &ve) {
+			fmt.Printf("file:    %s\nkind:    store reference\nformat:  %s\n", args[0], formatLine(FormatStoreRef, ve.Version))
+		}
+		if err != nil {
+			return err
+		}
+		blob := ref.blobPath(args[0])
+		present := "present"
+		if _, err := os.Stat(blob); err != nil {
+			present = "missing"
+		}
+		fmt.Printf("file:    %s\nkind:    store reference\nformat:  %s\nhash:    %s\nsha256:  %s\nsize:    %d\ncreated: %s\nblob:    %s (%s)\n",
+			args[0], formatLine(FormatStoreRef, ref.Version), ref.Hash, ref.Sum, ref.Size, ref.Created.Format(time.RFC3339), blob, present)
+		return nil
+	}
+}
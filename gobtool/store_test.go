@@ -0,0 +1,169 @@
+package gobtool
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deadPID returns the PID of a process that has exited.
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestLockIsStale(t *testing.T) {
+	host, _ := os.Hostname()
+	if _, known := processAlive(os.Getpid()); !known {
+		t.Skip("processes cannot be checked on this platform")
+	}
+	name := filepath.Join(t.TempDir(), ".lock")
+	if err := os.WriteFile(name, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	for _, c := range []struct {
+		what  string
+		held  string
+		mtime time.Time
+		stale bool
+	}{
+		{"live owner", fmt.Sprintf("%d %s\n", os.Getpid(), host), time.Now(), false},
+		// Held for longer than staleLockAge, by a process still running.
+		{"live owner, old mtime", fmt.Sprintf("%d %s\n", os.Getpid(), host), old, false},
+		{"dead owner", fmt.Sprintf("%d %s\n", deadPID(t), host), time.Now(), true},
+		{"other host", "1 elsewhere.example\n", time.Now(), false},
+		{"other host, old mtime", "1 elsewhere.example\n", old, true},
+		{"no owner", "", time.Now(), false},
+		{"no owner, old mtime", "", old, true},
+	} {
+		if err := os.Chtimes(name, c.mtime, c.mtime); err != nil {
+			t.Fatal(err)
+		}
+		if got := lockIsStale([]byte(c.held), host, name); got != c.stale {
+			t.Errorf("%s: stale = %v, want %v", c.what, got, c.stale)
+		}
+	}
+}
+
+func TestLockStoreBreaksDeadOwnersLock(t *testing.T) {
+	if _, known := processAlive(os.Getpid()); !known {
+		t.Skip("processes cannot be checked on this platform")
+	}
+	dir := t.TempDir()
+	host, _ := os.Hostname()
+	name := filepath.Join(dir, ".lock")
+	if err := os.WriteFile(name, []byte(fmt.Sprintf("%d %s\n", deadPID(t), host)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%d %s\n", os.Getpid(), host); string(held) != want {
+		t.Errorf("lock holds %q, want %q", held, want)
+	}
+	unlock()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("lock still there after unlock: %v", err)
+	}
+}
+
+func TestWriteAtomicFailureKeepsFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.gob")
+	if err := encodeAndWriteToFile("old", name); err != nil {
+		t.Fatal(err)
+	}
+	// gob cannot encode a channel; the encoding fails partway.
+	err := encodeAndWriteToFile(map[string]interface{}{"a": 1, "ch": make(chan int)}, name)
+	if err == nil {
+		t.Fatal("no error")
+	}
+	got, err := decodeAnyFile(name)
+	if err != nil || got != "old" {
+		t.Errorf("after a failed write: got %v, %v", got, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestWriteAtomicKeepsMode(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(name, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(name, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("mode %v, want 0600", fi.Mode().Perm())
+	}
+	if b, _ := os.ReadFile(name); string(b) != "new" {
+		t.Errorf("holds %q", b)
+	}
+}
+
+// TestGCDryRunByDefault checks that gc only lists unreferenced blobs
+// until -yes is given, and then removes them and nothing else.
+func TestGCDryRunByDefault(t *testing.T) {
+	dir := t.TempDir()
+	store, refs := filepath.Join(dir, "store"), filepath.Join(dir, "refs")
+	if err := os.Mkdir(refs, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	live, err := EncodeToStore(store, filepath.Join(refs, "live.ref"), map[string]int{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead, err := EncodeToStore(store, filepath.Join(refs, "dead.ref"), map[string]int{"b": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(refs, "dead.ref")); err != nil {
+		t.Fatal(err)
+	}
+	exists := func(ref *StoreRef) bool {
+		_, err := os.Stat(filepath.Join(store, ref.Hash))
+		return err == nil
+	}
+
+	out, err := runCaptured(t, "gc", "-store", store, refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "would remove "+dead.Hash) || strings.Contains(out, "removed") {
+		t.Errorf("dry run printed:\n%s", out)
+	}
+	if !exists(dead) || !exists(live) {
+		t.Fatal("dry run removed a blob")
+	}
+
+	out, err = runCaptured(t, "gc", "-store", store, "-yes", refs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "removed "+dead.Hash) || strings.Contains(out, live.Hash) {
+		t.Errorf("gc -yes printed:\n%s", out)
+	}
+	if exists(dead) || !exists(live) {
+		t.Errorf("after gc -yes: unreferenced blob kept %v, referenced blob kept %v", exists(dead), exists(live))
+	}
+}
@@ -0,0 +1,442 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// encodeBufferSize is the write buffer used when encoding to files; 0
+// writes straight through to the file.
+var encodeBufferSize = 64 << 10
+
+// flushEvery is how many records EncodeChannel encodes between flushes,
+// so a reader tailing the file is never more than that far behind.
+const flushEvery = 1000
+
+// flushWriter is what encoders write to: a bufio.Writer, or the file
+// itself when buffering is off.
+type flushWriter interface {
+	io.Writer
+	Flush() error
+}
+
+type unbuffered struct{ io.Writer }
+
+func (unbuffered) Flush() error { return nil }
+
+// newEncodeWriter wraps w in a buffer of encodeBufferSize bytes. Flush
+// must be called before w is closed.
+func newEncodeWriter(w io.Writer) flushWriter {
+	if encodeBufferSize <= 0 {
+		return unbuffered{w}
+	}
+	return bufio.NewWriterSize(w, encodeBufferSize)
+}
+
+// EncodeChannel encodes each record received from records onto w with a
+// single encoder, so type definitions are sent only once, until records
+// is closed. Output is buffered and flushed every flushEvery records and
+// at the end.
+func EncodeChannel(w io.Writer, records <-chan interface{}) (n int, err error) {
+	return encodeChannel(w, records, false)
+}
+
+// EncodeChannelCounted is EncodeChannel followed by a record-count
+// footer holding the number of records, which DecodeStreamCounted
+// checks. Every reader of streams in this package knows the footer and
+// stops there, so it is never taken for a record.
+func EncodeChannelCounted(w io.Writer, records <-chan interface{}) (n int, err error) {
+	return encodeChannel(w, records, true)
+}
+
+func encodeChannel(w io.Writer, records <-chan interface{}, footer bool) (n int, err error) {
+	bw := newEncodeWriter(w)
+	enc := gob.NewEncoder(bw)
+	for rec := range records {
+		if err := enc.Encode(&rec); err != nil {
+			return n, fmt.Errorf("record %d: %w", n, err)
+		}
+		n++
+		if n%flushEvery == 0 {
+			if err := bw.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if footer {
+		if err := enc.Encode(recordCountFooter{int64(n)}); err != nil {
+			return n, fmt.Errorf("record-count footer: %w", err)
+		}
+	}
+	return n, bw.Flush()
+}
+
+// recordCountFooter is the record-count footer. It is sent bare, where
+// EncodeChannel sends records as interfaces, and is known on the wire by
+// its type name and single field; see isCountFooter.
+type recordCountFooter struct {
+	Records int64
+}
+
+// isCountFooter reports whether id, in types, is recordCountFooter.
+func isCountFooter(types map[typeID]*wireType, id typeID) bool {
+	wt := types[id]
+	return wt != nil && wt.Kind == wireStruct && wt.Name == "recordCountFooter" &&
+		len(wt.Fields) == 1 && wt.Fields[0].Name == "Records" && wt.Fields[0].ID == tInt
+}
+
+// encodeJSONStream encodes each JSON value read from r as its own record
+// in out, with its slices sorted when sorted is set, its interchange
+// annotations decoded when interchange is and a record-count footer
+// after the last record when footer is. Warnings name record i as [i].
+func encodeJSONStream(r io.Reader, out string, sorted, interchange, footer bool, ws *Warnings) (n int, err error) {
+	err = writeAtomic(out, func(file *os.File) error {
+		records := make(chan interface{})
+		done := make(chan error, 1)
+		go func() {
+			defer close(records)
+			dec := json.NewDecoder(r)
+			dec.UseNumber()
+			for i := 0; ; i++ {
+				var v interface{}
+				err := dec.Decode(&v)
+				if errors.Is(err, io.EOF) {
+					done <- nil
+					return
+				}
+				if err != nil {
+					done <- err
+					return
+				}
+				rec := fromJSON(v, fmt.Sprintf("[%d]", i), ws)
+				if interchange {
+					if rec, err = fromInterchange(rec, fmt.Sprintf("[%d]", i), ws); err != nil {
+						done <- err
+						return
+					}
+				}
+				if sorted {
+					sortSlicesIn(reflect.ValueOf(rec), fmt.Sprintf("[%d]", i), make(cycleGuard), ws)
+				}
+				records <- rec
+			}
+		}()
+		var err error
+		n, err = encodeChannel(file, records, footer)
+		if err != nil {
+			// drain so the reader goroutine can finish
+			for range records {
+			}
+			return err
+		}
+		return <-done
+	})
+	return n, err
+}
+
+// maxNDJSONLine bounds the length of one line of -from-ndjson input.
+const maxNDJSONLine = 64 << 20
+
+// encodeNDJSON encodes each line of r, a JSON object, as a record of out,
+// a map[string]interface{} sent bare with a single encoder. Blank lines
+// are skipped; errors and warnings name the line, counting from 1.
+// sorted and interchange are as for encodeJSONStream.
+func encodeNDJSON(r io.Reader, out string, sorted, interchange bool, ws *Warnings) (n int, err error) {
+	err = writeAtomic(out, func(file *os.File) error {
+		bw := newEncodeWriter(file)
+		enc := gob.NewEncoder(bw)
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, maxNDJSONLine)
+		for line := 1; sc.Scan(); line++ {
+			text := bytes.TrimSpace(sc.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+			dec := json.NewDecoder(bytes.NewReader(text))
+			dec.UseNumber()
+			var obj map[string]interface{}
+			if err := dec.Decode(&obj); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if dec.More() {
+				return fmt.Errorf("line %d: more than one JSON value", line)
+			}
+			if obj == nil {
+				return fmt.Errorf("line %d: want a JSON object, not null", line)
+			}
+			path := fmt.Sprintf("line %d", line)
+			rec := fromJSON(obj, path, ws)
+			if interchange {
+				var err error
+				if rec, err = fromInterchange(rec, path, ws); err != nil {
+					return fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+			if sorted {
+				sortSlicesIn(reflect.ValueOf(rec), path, make(cycleGuard), ws)
+			}
+			if err := encodeRoot(enc, rec); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			n++
+			if n%flushEvery == 0 {
+				if err := bw.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+	return n, err
+}
+
+// recordReader reads the values of a gob stream one at a time.
+type recordReader struct {
+	w *wireReader
+	a *assigner
+	g *goTypes // for nextWithType, built on first use
+	n int      // records read so far
+
+	counted bool // the stream ended in a record-count footer
+}
+
+func newRecordReader(r io.Reader) *recordReader {
+	w := newWireReader(r, 0)
+	w.named = true
+	return &recordReader{w: w, a: &assigner{}}
+}
+
+// next reads the next record, or only skips over it when keep is false.
+// It returns io.EOF at the end of the stream. Values sent as interfaces
+// come back as the Go type registered for them; anything else keeps the
+// generic form.
+func (rr *recordReader) next(keep bool) (interface{}, error) {
+	id, err := rr.nextID()
+	if err != nil {
+		return nil, err
+	}
+	return rr.record(id, keep)
+}
+
+// nextID starts the next message holding a record and returns its type.
+// A record-count footer ends the stream: nextID reads it, checks it
+// against the records read and that nothing follows it, and returns
+// io.EOF.
+func (rr *recordReader) nextID() (typeID, error) {
+	if rr.counted {
+		return 0, io.EOF
+	}
+	id, err := rr.w.nextMessage()
+	if err != nil || !isCountFooter(rr.w.types, id) {
+		return id, err
+	}
+	v, err := rr.w.topValue(id, true)
+	if err != nil {
+		return 0, fmt.Errorf("record-count footer: %w", err)
+	}
+	want, _ := v.(map[string]interface{})["Records"].(int64)
+	if want != int64(rr.n) {
+		return 0, fmt.Errorf("record-count footer declares %d records, the stream has %d", want, rr.n)
+	}
+	if _, err := rr.w.nextMessage(); !errors.Is(err, io.EOF) {
+		if err == nil {
+			err = fmt.Errorf("data after the record-count footer at offset %d", rr.w.msg)
+		}
+		return 0, err
+	}
+	rr.counted = true
+	return 0, io.EOF
+}
+
+// checkCounted fails unless the stream, read to its end, had a
+// record-count footer.
+func (rr *recordReader) checkCounted() error {
+	if !rr.counted {
+		return fmt.Errorf("no record-count footer after %d records: the stream is truncated or was written without one", rr.n)
+	}
+	return nil
+}
+
+// record reads the value of the message of type id just started.
+func (rr *recordReader) record(id typeID, keep bool) (interface{}, error) {
+	v, err := rr.w.topValue(id, keep)
+	if err != nil {
+		return nil, err
+	}
+	rr.n++
+	if !keep {
+		return nil, nil
+	}
+	return rr.build(v)
+}
+
+// build turns the generic value of the record just read into the form
+// next returns.
+func (rr *recordReader) build(v interface{}) (interface{}, error) {
+	var rec interface{}
+	if err := rr.a.assign(reflect.ValueOf(&rec).Elem(), v, ""); err != nil {
+		return nil, fmt.Errorf("record %d: %w", rr.n-1, err)
+	}
+	return rec, nil
+}
+
+// DecodeStream calls fn with each value of a gob stream in turn, such as
+// one written by EncodeChannel, holding only one value in memory at a
+// time. An error from fn stops the stream and is returned. A record-count
+// footer, if there is one, is checked but not required.
+func DecodeStream(r io.Reader, fn func(rec interface{}) error) error {
+	_, err := decodeEach(r, fn)
+	return err
+}
+
+// DecodeStreamCounted is DecodeStream for a stream written by
+// EncodeChannelCounted: it fails if the record-count footer is missing,
+// disagrees with the number of records read or is followed by anything.
+// A stream cut short between two records, which DecodeStream takes for a
+// complete one, fails here.
+func DecodeStreamCounted(r io.Reader, fn func(rec interface{}) error) error {
+	rr, err := decodeEach(r, fn)
+	if err != nil {
+		return err
+	}
+	return rr.checkCounted()
+}
+
+// decodeEach calls fn with each record of r and returns the reader, to
+// tell whether the stream had a footer.
+func decodeEach(r io.Reader, fn func(rec interface{}) error) (*recordReader, error) {
+	rr := newRecordReader(r)
+	for {
+		rec, err := rr.next(true)
+		if errors.Is(err, io.EOF) {
+			return rr, nil
+		}
+		if err != nil {
+			return rr, err
+		}
+		if err := fn(rec); err != nil {
+			return rr, err
+		}
+	}
+}
+
+// decodeStream returns DecodeStreamCounted when counted is set and
+// DecodeStream otherwise.
+func decodeStream(counted bool) func(io.Reader, func(interface{}) error) error {
+	if counted {
+		return DecodeStreamCounted
+	}
+	return DecodeStream
+}
+
+// DecodeRecordAt decodes only the record at index in a gob stream,
+// skipping over the ones before it without building them. A negative
+// index counts from the end, -1 being the last record; that needs the
+// whole stream decoded, with the last -index records kept in memory.
+func DecodeRecordAt(r io.Reader, index int) (interface{}, error) {
+	return decodeRecordAt(r, index, false)
+}
+
+// decodeRecordAt is DecodeRecordAt, which with counted set also skips
+// the records after index and checks the record-count footer, as
+// DecodeStreamCounted does.
+func decodeRecordAt(r io.Reader, index int, counted bool) (interface{}, error) {
+	rr := newRecordReader(r)
+	if index >= 0 {
+		for {
+			rec, err := rr.next(rr.n == index)
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("record %d out of range: the stream has %d records", index, rr.n)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if rr.n == index+1 {
+				if counted {
+					err = rr.skipRest()
+				}
+				return rec, err
+			}
+		}
+	}
+	ring := make([]interface{}, -index)
+	for {
+		rec, err := rr.next(true)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ring[(rr.n-1)%len(ring)] = rec
+	}
+	if rr.n < len(ring) {
+		return nil, fmt.Errorf("record %d out of range: the stream has %d records", index, rr.n)
+	}
+	if counted {
+		if err := rr.checkCounted(); err != nil {
+			return nil, err
+		}
+	}
+	return ring[(rr.n+index)%len(ring)], nil
+}
+
+// skipRest skips the records left in the stream and checks its
+// record-count footer.
+func (rr *recordReader) skipRest() error {
+	for {
+		_, err := rr.next(false)
+		if errors.Is(err, io.EOF) {
+			return rr.checkCounted()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readStreamValues reads every value of a gob stream in data, in their
+// generic form, with the type each goes back out as, for
+// encodeStreamValues to write them back as they were sent.
+func readStreamValues(data []byte) (values []interface{}, types []reflect.Type, err error) {
+	return readTypedValues(bytes.NewReader(data))
+}
+
+// encodeStreamValues encodes values with one encoder, each as the type
+// of the same index in types, as encodeTyped does. With types nil each
+// value is sent bare as its own Go type.
+func encodeStreamValues(values []interface{}, types []reflect.Type) ([]byte, error) {
+	return encodeStream(values, types, false)
+}
+
+// encodeStream is encodeStreamValues, followed by a record-count footer
+// when footer is set.
+func encodeStream(values []interface{}, types []reflect.Type, footer bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for i, v := range values {
+		var t reflect.Type
+		if types != nil {
+			t = types[i]
+		}
+		if err := encodeTyped(enc, t, v); err != nil {
+			return nil, fmt.Errorf("value %d: %w", i, err)
+		}
+	}
+	if footer {
+		if err := enc.Encode(recordCountFooter{int64(len(values))}); err != nil {
+			return nil, fmt.Errorf("record-count footer: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,250 @@
+package gobtool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// channelStream encodes values with EncodeChannel, or with
+// EncodeChannelCounted when counted is set.
+func channelStream(t *testing.T, values []interface{}, counted bool) []byte {
+	t.Helper()
+	records := make(chan interface{}, len(values))
+	for _, v := range values {
+		records <- v
+	}
+	close(records)
+	encode := EncodeChannel
+	if counted {
+		encode = EncodeChannelCounted
+	}
+	var buf bytes.Buffer
+	n, err := encode(&buf, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(values) {
+		t.Fatalf("encoded %d records, want %d", n, len(values))
+	}
+	return buf.Bytes()
+}
+
+func streamRecords(t testing.TB, n int) []interface{} {
+	t.Helper()
+	registerKnownTypes()
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = map[string]interface{}{"i": i}
+	}
+	return values
+}
+
+func TestEncodeChannelBuffering(t *testing.T) {
+	values := streamRecords(t, flushEvery+10)
+	buffered := channelStream(t, values, false)
+	defer func(old int) { encodeBufferSize = old }(encodeBufferSize)
+	encodeBufferSize = 0
+	if unbuffered := channelStream(t, values, false); !bytes.Equal(buffered, unbuffered) {
+		t.Error("buffered and unbuffered output differ")
+	}
+	var got []interface{}
+	err := DecodeStream(bytes.NewReader(buffered), func(rec interface{}) error {
+		got = append(got, rec)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("got %d records back, different from the %d encoded", len(got), len(values))
+	}
+}
+
+// BenchmarkEncodeChannel encodes 10000 small records to a file, through
+// the write buffer and straight to the file.
+func BenchmarkEncodeChannel(b *testing.B) {
+	values := streamRecords(b, 10000)
+	for _, c := range []struct {
+		name string
+		size int
+	}{{"buffered", encodeBufferSize}, {"unbuffered", 0}} {
+		b.Run(c.name, func(b *testing.B) {
+			defer func(old int) { encodeBufferSize = old }(encodeBufferSize)
+			encodeBufferSize = c.size
+			f, err := os.Create(filepath.Join(b.TempDir(), "out.gob"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer f.Close()
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := f.Seek(0, io.SeekStart); err != nil {
+					b.Fatal(err)
+				}
+				records := make(chan interface{}, len(values))
+				for _, v := range values {
+					records <- v
+				}
+				close(records)
+				if _, err := EncodeChannel(f, records); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeRecordAt(t *testing.T) {
+	values := streamRecords(t, 5)
+	raw := channelStream(t, values, false)
+	for _, c := range []struct{ index, want int }{{0, 0}, {3, 3}, {4, 4}, {-1, 4}, {-5, 0}} {
+		got, err := DecodeRecordAt(bytes.NewReader(raw), c.index)
+		if err != nil {
+			t.Errorf("index %d: %v", c.index, err)
+		} else if !reflect.DeepEqual(got, values[c.want]) {
+			t.Errorf("index %d: got %v, want %v", c.index, got, values[c.want])
+		}
+	}
+	for _, index := range []int{5, -6} {
+		_, err := DecodeRecordAt(bytes.NewReader(raw), index)
+		if err == nil || !strings.Contains(err.Error(), "out of range: the stream has 5 records") {
+			t.Errorf("index %d: got %v", index, err)
+		}
+	}
+}
+
+func TestDecodeStreamCounted(t *testing.T) {
+	values := streamRecords(t, 3)
+	counted := channelStream(t, values, true)
+	n := 0
+	count := func(interface{}) error { n++; return nil }
+	if err := DecodeStreamCounted(bytes.NewReader(counted), count); err != nil || n != 3 {
+		t.Fatalf("got %d records, %v", n, err)
+	}
+
+	// A stream cut between two records reads as complete without the
+	// footer check.
+	cut := channelStream(t, values[:2], false)
+	if err := DecodeStream(bytes.NewReader(cut), count); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		what   string
+		stream []byte
+		want   string
+	}{
+		{"no footer", cut, "no record-count footer after 2 records"},
+		{"data after footer", append(append([]byte{}, counted...), counted[len(counted)-3:]...), "data after the record-count footer"},
+	} {
+		err := DecodeStreamCounted(bytes.NewReader(c.stream), count)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want an error containing %q", c.what, err, c.want)
+		}
+	}
+}
+
+func TestEncodeNDJSON(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.gob")
+	in := "{\"id\": 1, \"name\": \"a\"}\n\n   \n{\"id\": 2}\n"
+	n, err := encodeNDJSON(strings.NewReader(in), out, false, false, &Warnings{})
+	if err != nil || n != 2 {
+		t.Fatalf("got %d records, %v", n, err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []interface{}
+	err = DecodeStream(f, func(rec interface{}) error {
+		got = append(got, rec)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[interface{}]interface{}{"id": 1, "name": "a"},
+		map[interface{}]interface{}{"id": 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	for _, c := range []struct{ in, want string }{
+		{"{\"id\": 1}\n[1]\n", "line 2"},
+		{"{\"id\": 1} {\"id\": 2}\n", "line 1: more than one JSON value"},
+		{"\nnull\n", "line 2: want a JSON object"},
+	} {
+		_, err := encodeNDJSON(strings.NewReader(c.in), out, false, false, &Warnings{})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%q: got %v, want an error containing %q", c.in, err, c.want)
+		}
+	}
+}
+
+// TestCountFooterNotARecord checks that every reader of streams stops at
+// the record-count footer rather than taking it for one more record.
+func TestCountFooterNotARecord(t *testing.T) {
+	values := streamRecords(t, 3)
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.gob")
+	if err := os.WriteFile(in, channelStream(t, values, true), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain.gob")
+	if err := os.WriteFile(plain, channelStream(t, values, false), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		cmd  string
+		args []string
+		want string
+	}{
+		{"decode", []string{"-format", "json", "-index", "-1", in}, `{"i":2}`},
+		{"decode", []string{"-format", "json", "-index", "-1", "-count-footer", in}, `{"i":2}`},
+		{"decode", []string{"-format", "json", "-index", "0", "-count-footer", in}, `{"i":0}`},
+		{"decode", []string{"-format", "json-array", in}, `{"i":2}]`},
+		{"stats", []string{in}, "3records"},
+	} {
+		out, err := runCaptured(t, c.cmd, c.args...)
+		// compared without white space, which the formats lay out differently
+		if err != nil || !strings.Contains(strings.Join(strings.Fields(out), ""), c.want) {
+			t.Errorf("%s %v: got %v\n%s\nwant it to contain %s", c.cmd, c.args, err, out, c.want)
+		}
+	}
+	if _, err := runCaptured(t, "decode", "-index", "3", in); err == nil || !strings.Contains(err.Error(), "the stream has 3 records") {
+		t.Errorf("decode -index 3: got %v", err)
+	}
+	if _, err := runCaptured(t, "decode", "-index", "0", "-count-footer", plain); err == nil || !strings.Contains(err.Error(), "no record-count footer") {
+		t.Errorf("decode -index 0 -count-footer without a footer: got %v", err)
+	}
+
+	// filter counts the records it keeps in a footer of its own, and
+	// migrate keeps the one it found.
+	filtered := filepath.Join(dir, "filtered.gob")
+	out, err := runCaptured(t, "filter", "-where", "i >= 1", "-out", filtered, in)
+	if err != nil || !strings.Contains(out, "2 matched") {
+		t.Fatalf("filter: %v\n%s", err, out)
+	}
+	migrated := filepath.Join(dir, "migrated.gob")
+	if _, err := runCaptured(t, "migrate", in, migrated); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]int{filtered: 2, migrated: 3} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		if err := DecodeStreamCounted(bytes.NewReader(b), func(interface{}) error { n++; return nil }); err != nil || n != want {
+			t.Errorf("%s: %d records, %v; want %d", filepath.Base(name), n, err, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package gobtool
+
+import (
+	"fmt"
+	"io"
+)
+
+// decode -summary-depth N shows the structure of a huge value without
+// building it: the top N levels decode as usual, and every container or
+// long string below them becomes a valueSummary such as "map[12 keys]",
+// "slice[3400 int]" or "string(52KB)". Summarized values are skipped on
+// the wire, as ls skips map values, so nothing below the cut is built.
+
+// summaryStringMin is the shortest string that is summarized below the
+// cut; shorter ones are shown as they are.
+const summaryStringMin = 64
+
+// valueSummary stands in for a value below the summary cut. It renders
+// as its Summary in the tree and as an object of its fields in JSON.
+type valueSummary struct {
+	Summary string
+	Kind    string // map, slice, array, struct, string or bytes
+	Type    string // the wire type, as ls names it
+	Count   int64  // entries, elements, fields present, or bytes of a string
+	Bytes   int64  // encoded size
+}
+
+func (s valueSummary) String() string { return s.Summary }
+
+// decodeSummary decodes the first value of r with the levels from depth
+// down summarized.
+func decodeSummary(r io.Reader, filename string, depth int) (interface{}, error) {
+	r, err := openPayload(r, filename)
+	if err != nil {
+		return nil, err
+	}
+	w := newWireReader(r, 0)
+	w.summarize = depth
+	id, err := w.nextMessage()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s: empty gob stream", filename)
+	}
+	if err != nil {
+		return nil, payloadError(r, err)
+	}
+	v, err := w.topValue(id, true)
+	return v, payloadError(r, err)
+}
+
+// summary reads a value of type id below the cut and returns its
+// summary, or false when the value is small enough to decode instead.
+func (w *wireReader) summary(id typeID) (interface{}, bool, error) {
+	s := valueSummary{Type: typeString(w.types, id)}
+	start := w.off
+	switch id {
+	case tString, tBytes:
+		n, err := w.readLength()
+		if err != nil {
+			return nil, true, err
+		}
+		if n < summaryStringMin {
+			buf, err := w.readFull(n)
+			if id == tString {
+				return string(buf), true, err
+			}
+			return buf, true, err
+		}
+		s.Kind, s.Count = "string", n
+		if id == tBytes {
+			s.Kind = "bytes"
+		}
+		s.Summary = fmt.Sprintf("%s(%s)", s.Kind, byteSize(n))
+		err = w.skip(n)
+		s.Bytes = w.off - start
+		return s, true, err
+	}
+	wt := w.types[id]
+	if wt == nil {
+		return nil, false, nil
+	}
+	var err error
+	switch wt.Kind {
+	case wireArray, wireSlice:
+		s.Kind = "slice"
+		if wt.Kind == wireArray {
+			s.Kind = "array"
+		}
+		if s.Count, err = w.readCount(); err != nil {
+			return nil, true, err
+		}
+		for i := int64(0); i < s.Count && err == nil; i++ {
+			_, err = w.value(wt.Elem, false)
+		}
+		s.Summary = fmt.Sprintf("%s[%d %s]", s.Kind, s.Count, typeString(w.types, wt.Elem))
+	case wireMap:
+		s.Kind = "map"
+		if s.Count, err = w.readCount(); err != nil {
+			return nil, true, err
+		}
+		for i := int64(0); i < s.Count && err == nil; i++ {
+			if _, err = w.value(wt.Key, false); err == nil {
+				_, err = w.value(wt.Elem, false)
+			}
+		}
+		s.Summary = fmt.Sprintf("map[%d %s]", s.Count, plural(s.Count, "key", "keys"))
+	case wireStruct:
+		s.Kind = "struct"
+		err = w.fields(func(f int) error {
+			if f >= len(wt.Fields) {
+				return formatErrorf("gob: field %d out of range for %s at offset %d", f, wt.Name, w.off)
+			}
+			s.Count++
+			_, err := w.value(wt.Fields[f].ID, false)
+			return err
+		})
+		s.Summary = fmt.Sprintf("struct %s{%d %s}", wt.Name, s.Count, plural(s.Count, "field", "fields"))
+	default:
+		return nil, false, nil
+	}
+	s.Bytes = w.off - start
+	s.Summary += fmt.Sprintf(" (%s)", byteSize(s.Bytes))
+	return s, true, err
+}
+
+func plural(n int64, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// byteSize renders n as 512B, 52KB or 3.4MB.
+func byteSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%dB", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.3gKB", float64(n)/(1<<10))
+	case n < 1<<30:
+		return fmt.Sprintf("%.3gMB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.3gGB", float64(n)/(1<<30))
+}
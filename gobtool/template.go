@@ -0,0 +1,72 @@
+package gobtool
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"text/template"
+)
+
+// decodeRecords decodes every value in a gob stream, such as one written
+// by EncodeChannel, as DecodeStream does, checking the record-count
+// footer when counted is set.
+func decodeRecords(r io.Reader, counted bool) ([]interface{}, error) {
+	var records []interface{}
+	err := decodeStream(counted)(r, func(rec interface{}) error {
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
+
+// templateFuncs are available to -template templates, for reaching into
+// the map[interface{}]interface{} values that index cannot address.
+var templateFuncs = template.FuncMap{
+	// get returns the value at a flat path, or nil.
+	"get": func(data interface{}, path string) interface{} {
+		v, ok := lookupPath(data, path)
+		if !ok || !v.IsValid() || !v.CanInterface() {
+			return nil
+		}
+		return v.Interface()
+	},
+	// keys returns the keys of a map in sorted order.
+	"keys": func(data interface{}) []interface{} {
+		v := indirect(reflect.ValueOf(data))
+		if !v.IsValid() || v.Kind() != reflect.Map {
+			return nil
+		}
+		var keys []interface{}
+		for _, k := range sortedMapKeys(v) {
+			keys = append(keys, k.Interface())
+		}
+		return keys
+	},
+	// inline renders a value on one line, as the flat format does.
+	"inline": func(data interface{}) string {
+		return inlineValue(reflect.ValueOf(data))
+	},
+	// typeof returns the Go type of a value.
+	"typeof": func(data interface{}) string {
+		return fmt.Sprintf("%T", data)
+	},
+}
+
+// renderTemplate executes the template in file once per record.
+func renderTemplate(w io.Writer, file string, records []interface{}) error {
+	text, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(file).Funcs(templateFuncs).Parse(string(text))
+	if err != nil {
+		return err
+	}
+	for i, rec := range records {
+		if err := tmpl.Execute(w, rec); err != nil {
+			return fmt.Errorf("record %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package gobtool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, text string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "out.tmpl")
+	if err := os.WriteFile(name, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestRenderTemplate(t *testing.T) {
+	records := []interface{}{
+		map[interface{}]interface{}{"name": "Ada", "tags": []string{"x"}, 7: "seven"},
+		map[interface{}]interface{}{"name": "Bob"},
+	}
+	tmpl := writeTemplate(t, `{{get . "name"}} {{typeof (get . "tags")}} {{range keys .}}{{.}};{{end}} {{inline (get . "tags")}}`+"\n")
+	var b strings.Builder
+	if err := renderTemplate(&b, tmpl, records); err != nil {
+		t.Fatal(err)
+	}
+	want := "Ada []string 7;name;tags; [x]\nBob <nil> name; nil\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestRenderTemplateErrors(t *testing.T) {
+	if err := renderTemplate(&strings.Builder{}, writeTemplate(t, "{{.Missing"), nil); err == nil {
+		t.Error("bad template: no error")
+	}
+	err := renderTemplate(&strings.Builder{}, writeTemplate(t, "{{index . 0}}"), []interface{}{"ok", 1})
+	if err == nil || !strings.Contains(err.Error(), "record 1") {
+		t.Errorf("failing record: got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package gobtool
+
+import "os"
+
+// defaultWidth is the output width used when stdout is not a terminal.
+const defaultWidth = 80
+
+// outputWidth is the default for -width options: the width of the
+// terminal stdout is connected to, or defaultWidth when it is not a
+// terminal or the platform cannot tell.
+func outputWidth() int {
+	if n, ok := terminalWidth(os.Stdout); ok && n > 0 {
+		return n
+	}
+	return defaultWidth
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package gobtool
+
+import "os"
+
+// terminalWidth is unsupported on this platform.
+func terminalWidth(*os.File) (int, bool) { return 0, false }
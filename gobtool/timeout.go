@@ -0,0 +1,126 @@
+package gobtool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// decodeTimeout is the deadline for decoding size bytes: base plus perMB
+// for every megabyte, so a small file cannot stall a batch for as long as
+// a large one legitimately takes. Zero means no deadline. With perMB set,
+// the deadline is at least minDecodeTimeout, so that -timeout-per-mb alone
+// does not give a tiny or empty file next to no time at all.
+func decodeTimeout(size int64, base, perMB time.Duration) time.Duration {
+	d := base + time.Duration(float64(perMB)*float64(size)/(1<<20))
+	if perMB > 0 && d < minDecodeTimeout {
+		d = minDecodeTimeout
+	}
+	return d
+}
+
+// minDecodeTimeout is the floor decodeTimeout puts under a deadline that
+// scales with the input size.
+const minDecodeTimeout = time.Second
+
+// decodeContext returns a context carrying f's decode deadline.
+func decodeContext(f inputFile, base, perMB time.Duration) (context.Context, context.CancelFunc, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	d := decodeTimeout(fi.Size(), base, perMB)
+	if d <= 0 {
+		ctx, cancel := context.WithCancel(runContext)
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithTimeoutCause(runContext, d,
+		fmt.Errorf("decode timed out after %s (%d bytes)", d, fi.Size()))
+	return ctx, cancel, nil
+}
+
+// ctxReader fails reads once its context is done, so a decode blocked on
+// input gives up at the deadline.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if c.ctx.Err() != nil {
+		return 0, context.Cause(c.ctx)
+	}
+	return c.r.Read(p)
+}
+
+// withContext runs fn and returns its error, or the context's cause if
+// the context ends first. fn is left to finish in the background, which
+// is fine for a command about to exit.
+func withContext(ctx context.Context, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// ValueDeadlineError is returned when decoding one value takes longer
+// than its deadline. It matches context.DeadlineExceeded with errors.Is.
+type ValueDeadlineError struct {
+	Timeout   time.Duration
+	BytesRead int64 // read from the input before the deadline
+}
+
+func (e *ValueDeadlineError) Error() string {
+	return fmt.Sprintf("decoding a value took longer than %s (%d bytes read)", e.Timeout, e.BytesRead)
+}
+
+func (e *ValueDeadlineError) Unwrap() error { return context.DeadlineExceeded }
+
+// countingReader counts the bytes read through it, safely for another
+// goroutine to look at.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// decodeWithDeadline runs decode on r, giving up after d, or never when
+// d is zero. This bounds one value where a context bounds the whole
+// operation, so one pathological payload cannot hold up a caller. The
+// decode cannot be stopped: on timeout its goroutine is abandoned and
+// lingers until its next read returns, so callers should close r, or
+// let it fail, once they have the error.
+func decodeWithDeadline(r io.Reader, d time.Duration, decode func(io.Reader) (interface{}, error)) (interface{}, error) {
+	if d <= 0 {
+		return decode(r)
+	}
+	type result struct {
+		v   interface{}
+		err error
+	}
+	cr := &countingReader{r: r}
+	done := make(chan result, 1)
+	go func() {
+		v, err := decode(cr)
+		done <- result{v, err}
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res.v, res.err
+	case <-timer.C:
+		return nil, &ValueDeadlineError{d, cr.n.Load()}
+	}
+}
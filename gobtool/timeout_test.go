@@ -0,0 +1,99 @@
+package gobtool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeTimeout(t *testing.T) {
+	for _, c := range []struct {
+		size        int64
+		base, perMB time.Duration
+		want        time.Duration
+	}{
+		{1 << 20, 0, 0, 0},
+		{1 << 20, 5 * time.Second, 0, 5 * time.Second},
+		{10 << 20, 0, time.Second, 10 * time.Second},
+		{2 << 20, time.Second, time.Second, 3 * time.Second},
+		// A small file under -timeout-per-mb alone gets the floor
+		// rather than a few microseconds.
+		{100, 0, time.Second, minDecodeTimeout},
+		{0, 0, time.Second, minDecodeTimeout},
+		// An explicit short -timeout without -timeout-per-mb stands.
+		{100, 100 * time.Millisecond, 0, 100 * time.Millisecond},
+	} {
+		if got := decodeTimeout(c.size, c.base, c.perMB); got != c.want {
+			t.Errorf("decodeTimeout(%d, %s, %s) = %s, want %s", c.size, c.base, c.perMB, got, c.want)
+		}
+	}
+}
+
+// slowReader gives one byte of r at a time, waiting delay before each.
+type slowReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (s slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.r.Read(p[:min(len(p), 1)])
+}
+
+// slowDecode decodes the file name through a slowReader under ctx.
+func slowDecode(t *testing.T, ctx context.Context, name string) error {
+	t.Helper()
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return withContext(ctx, func() error {
+		_, err := decodeAny(ctxReader{ctx, slowReader{bytes.NewReader(raw), 5 * time.Millisecond}}, name)
+		return err
+	})
+}
+
+func TestDecodeContextTimesOut(t *testing.T) {
+	name := writeRoot(t, syntheticData(50, 1))
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ctx, cancel, err := decodeContext(f, 50*time.Millisecond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	err = slowDecode(t, ctx, name)
+	if err == nil || !strings.Contains(err.Error(), "decode timed out after 50ms") {
+		t.Errorf("got %v, want the decode timeout", err)
+	}
+	if isRunDeadline(err) {
+		t.Errorf("%v taken for the run deadline", err)
+	}
+}
+
+func TestDecodeWithDeadline(t *testing.T) {
+	raw, err := os.ReadFile(writeRoot(t, syntheticData(50, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode := func(r io.Reader) (interface{}, error) { return decodeAny(r, "") }
+	_, err = decodeWithDeadline(slowReader{bytes.NewReader(raw), time.Millisecond}, 20*time.Millisecond, decode)
+	var de *ValueDeadlineError
+	if !errors.As(err, &de) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow value: got %v, want a ValueDeadlineError", err)
+	}
+	if de.BytesRead <= 0 || de.BytesRead >= int64(len(raw)) {
+		t.Errorf("slow value: %d of %d bytes read", de.BytesRead, len(raw))
+	}
+	if _, err := decodeWithDeadline(bytes.NewReader(raw), time.Minute, decode); err != nil {
+		t.Errorf("fast value: %v", err)
+	}
+}
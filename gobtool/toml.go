@@ -0,0 +1,243 @@
+package gobtool
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TOML output starts from the same conversion as JSON output (toJSON),
+// and then makes these coercions:
+//
+//   - map keys of any type become their printed form, so 42 becomes "42";
+//   - byte slices become base64 strings, as in JSON, except json.RawMessage,
+//     which becomes a string holding the JSON text;
+//   - time.Time becomes a TOML offset date-time, and other text marshalers
+//     become strings;
+//   - complex numbers become strings.
+//
+// An array whose elements are all tables is written as an array of tables
+// ([[a.b]]); any other array is written inline, with tables inside it as
+// inline tables. TOML 1.0 allows arrays of mixed types, so those need no
+// coercion. What TOML cannot represent is an error: a nil value anywhere,
+// since TOML has no null, and a top level that is not a table.
+
+// writeTOML writes data as a TOML document, adding what it loses to ws.
+func writeTOML(w io.Writer, data interface{}, ws *Warnings) error {
+	v, err := toJSONWarn(data, ws)
+	if err != nil {
+		return err
+	}
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("TOML needs a table at the top level, not %T", data)
+	}
+	t := &tomlWriter{w: w, ws: ws}
+	t.table(nil, root)
+	return t.err
+}
+
+type tomlWriter struct {
+	w   io.Writer
+	ws  *Warnings
+	err error
+}
+
+func (t *tomlWriter) printf(format string, args ...interface{}) {
+	if t.err == nil {
+		_, t.err = fmt.Fprintf(t.w, format, args...)
+	}
+}
+
+func (t *tomlWriter) fail(path []string, format string, args ...interface{}) {
+	if t.err == nil {
+		t.err = fmt.Errorf("%s: %s", tomlPath(path), fmt.Sprintf(format, args...))
+	}
+}
+
+// table writes the keys of tbl: plain values first, then sub-tables, then
+// arrays of tables, each group sorted by key. The header of tbl itself has
+// already been written.
+func (t *tomlWriter) table(path []string, tbl map[string]interface{}) {
+	keys := make([]string, 0, len(tbl))
+	for k := range tbl {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var tables, arrays []string
+	for _, k := range keys {
+		switch v := tbl[k].(type) {
+		case map[string]interface{}:
+			tables = append(tables, k)
+		case []interface{}:
+			if isTableArray(v) {
+				arrays = append(arrays, k)
+				continue
+			}
+			t.printf("%s = %s\n", tomlKey(k), t.inline(append(path[:len(path):len(path)], k), v))
+		default:
+			t.printf("%s = %s\n", tomlKey(k), t.inline(append(path[:len(path):len(path)], k), v))
+		}
+	}
+	for _, k := range tables {
+		sub := append(path[:len(path):len(path)], k)
+		t.printf("\n[%s]\n", tomlPath(sub))
+		t.table(sub, tbl[k].(map[string]interface{}))
+	}
+	for _, k := range arrays {
+		sub := append(path[:len(path):len(path)], k)
+		for _, e := range tbl[k].([]interface{}) {
+			t.printf("\n[[%s]]\n", tomlPath(sub))
+			t.table(sub, e.(map[string]interface{}))
+		}
+	}
+}
+
+func isTableArray(a []interface{}) bool {
+	if len(a) == 0 {
+		return false
+	}
+	for _, e := range a {
+		if _, ok := e.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// inline renders v as a TOML value on one line.
+func (t *tomlWriter) inline(path []string, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		t.fail(path, "TOML has no null value")
+		return ""
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = tomlKey(k) + " = " + t.inline(append(path[:len(path):len(path)], k), v[k])
+		}
+		if len(parts) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = t.inline(append(path[:len(path):len(path)], strconv.Itoa(i)), e)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case string:
+		return tomlString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.RawMessage:
+		t.ws.add(WarnTypeChange, tomlPath(path), "JSON text is written as a string")
+		return tomlString(string(v))
+	case []byte:
+		return tomlString(base64.StdEncoding.EncodeToString(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		if err != nil {
+			t.fail(path, "%v", err)
+		}
+		return tomlString(string(text))
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > math.MaxInt64 {
+			t.fail(path, "%d does not fit in a TOML integer", rv.Uint())
+		}
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return tomlFloat(rv.Float())
+	case reflect.String:
+		return tomlString(rv.String())
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	}
+	t.fail(path, "cannot write %T as TOML", v)
+	return ""
+}
+
+func tomlFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// tomlKey writes k bare when TOML allows it, quoted otherwise.
+func tomlKey(k string) string {
+	if k == "" {
+		return `""`
+	}
+	for _, c := range k {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return tomlString(k)
+		}
+	}
+	return k
+}
+
+func tomlPath(path []string) string {
+	if len(path) == 0 {
+		return "(root)"
+	}
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
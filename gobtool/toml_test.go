@@ -0,0 +1,402 @@
+package gobtool
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTOML(t *testing.T) {
+	data := map[interface{}]interface{}{
+		42:      "answer",
+		"name":  "a \"b\"\n",
+		"ratio": 2.0,
+		"when":  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		"raw":   json.RawMessage(`{"a":1}`),
+		"list":  []interface{}{1, "two"},
+		"owner": map[string]interface{}{"id": 7, "dotted key": true},
+		"items": []interface{}{
+			map[string]interface{}{"n": 1},
+			map[string]interface{}{"n": 2},
+		},
+	}
+	var b strings.Builder
+	var ws Warnings
+	if err := writeTOML(&b, data, &ws); err != nil {
+		t.Fatal(err)
+	}
+	want := `42 = "answer"
+list = [1, "two"]
+name = "a \"b\"\n"
+ratio = 2.0
+raw = "{\"a\":1}"
+when = 2024-05-01T12:00:00Z
+
+[owner]
+"dotted key" = true
+id = 7
+
+[[items]]
+n = 1
+
+[[items]]
+n = 2
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+	var codes []string
+	for _, w := range ws.List {
+		codes = append(codes, w.Code+" "+w.Path)
+	}
+	if got := strings.Join(codes, ", "); got != "W003 , W006 raw" {
+		t.Errorf("warnings: %s", got)
+	}
+}
+
+func TestWriteTOMLErrors(t *testing.T) {
+	for _, c := range []struct {
+		data interface{}
+		want string
+	}{
+		{[]interface{}{1}, "TOML needs a table at the top level"},
+		{map[string]interface{}{"a": map[string]interface{}{"b": nil}}, "a.b: TOML has no null value"},
+		{map[string]interface{}{"big": uint64(1 << 63)}, "big: 9223372036854775808 does not fit"},
+	} {
+		err := writeTOML(&strings.Builder{}, c.data, nil)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%v: got %v, want %q", c.data, err, c.want)
+		}
+	}
+}
+
+// TestWriteTOMLRoundTrip parses the output back and compares it with the
+// source value, after the coercions documented in toml.go.
+func TestWriteTOMLRoundTrip(t *testing.T) {
+	when := time.Date(2024, 5, 1, 12, 30, 0, 500, time.FixedZone("", 2*3600))
+	data := map[interface{}]interface{}{
+		7:        "int key",
+		"name":   "tab\tquote\" backslash\\ bell\a del\x7f é",
+		"":       "empty key",
+		"a.b":    "dotted key",
+		"big":    int64(math.MinInt64),
+		"ratio":  0.1,
+		"exp":    1e300,
+		"inf":    math.Inf(-1),
+		"ok":     false,
+		"when":   when,
+		"bytes":  []byte{0, 1, 2},
+		"raw":    json.RawMessage(`{"a":[1,2]}`),
+		"z":      complex(1, 2),
+		"mixed":  []interface{}{1, "two", []interface{}{3.5}, map[string]interface{}{"k": "v"}},
+		"empty":  map[string]interface{}{},
+		"nested": map[string]interface{}{"deeper": map[string]interface{}{"x": 1}, "list": []interface{}{}},
+		"rows": []interface{}{
+			map[string]interface{}{"n": 1, "sub": map[string]interface{}{"s": "one"}},
+			map[string]interface{}{"n": 2, "more": []interface{}{map[string]interface{}{"m": true}}},
+		},
+	}
+	var b strings.Builder
+	if err := writeTOML(&b, data, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseTOML(b.String())
+	if err != nil {
+		t.Fatalf("%v in\n%s", err, b.String())
+	}
+	want := map[string]interface{}{
+		"7":      "int key",
+		"name":   "tab\tquote\" backslash\\ bell\a del\x7f é",
+		"":       "empty key",
+		"a.b":    "dotted key",
+		"big":    int64(math.MinInt64),
+		"ratio":  0.1,
+		"exp":    1e300,
+		"inf":    math.Inf(-1),
+		"ok":     false,
+		"when":   when,
+		"bytes":  "AAEC",
+		"raw":    `{"a":[1,2]}`,
+		"z":      "(1+2i)",
+		"mixed":  []interface{}{int64(1), "two", []interface{}{3.5}, map[string]interface{}{"k": "v"}},
+		"empty":  map[string]interface{}{},
+		"nested": map[string]interface{}{"deeper": map[string]interface{}{"x": int64(1)}, "list": []interface{}{}},
+		"rows": []interface{}{
+			map[string]interface{}{"n": int64(1), "sub": map[string]interface{}{"s": "one"}},
+			map[string]interface{}{"n": int64(2), "more": []interface{}{map[string]interface{}{"m": true}}},
+		},
+	}
+	for k, w := range want {
+		g := got[k]
+		if wt, ok := w.(time.Time); ok {
+			if gt, ok := g.(time.Time); !ok || !gt.Equal(wt) {
+				t.Errorf("%q: got %v, want %v", k, g, w)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%q: got %#v, want %#v", k, g, w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d keys, want %d:\n%s", len(got), len(want), b.String())
+	}
+}
+
+// parseTOML reads the subset of TOML that writeTOML produces: table and
+// array-of-table headers, one key = value per line, basic strings, bare
+// or quoted keys, integers, floats, booleans, offset date-times, and
+// inline arrays and tables.
+func parseTOML(doc string) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	cur := root
+	for n, line := range strings.Split(doc, "\n") {
+		p := &tomlParser{s: line}
+		var err error
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "[["):
+			p.i = 2
+			cur, err = p.header(root, "]]", true)
+		case strings.HasPrefix(line, "["):
+			p.i = 1
+			cur, err = p.header(root, "]", false)
+		default:
+			err = p.keyValue(cur)
+		}
+		if err == nil && p.i != len(line) {
+			err = fmt.Errorf("trailing %q", line[p.i:])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+	}
+	return root, nil
+}
+
+type tomlParser struct {
+	s string
+	i int
+}
+
+func (p *tomlParser) expect(tok string) error {
+	if !strings.HasPrefix(p.s[p.i:], tok) {
+		return fmt.Errorf("want %q at %q", tok, p.s[p.i:])
+	}
+	p.i += len(tok)
+	return nil
+}
+
+// header walks root to the table named by the header, creating it, or
+// appending a new table to the array when array is set. Keys on the way
+// that hold an array of tables lead to its last table, as in TOML.
+func (p *tomlParser) header(root map[string]interface{}, end string, array bool) (map[string]interface{}, error) {
+	var path []string
+	for {
+		k, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, k)
+		if !strings.HasPrefix(p.s[p.i:], ".") {
+			break
+		}
+		p.i++
+	}
+	if err := p.expect(end); err != nil {
+		return nil, err
+	}
+	tbl := root
+	for i, k := range path {
+		last := i == len(path)-1
+		switch v := tbl[k].(type) {
+		case nil:
+			if last && array {
+				next := map[string]interface{}{}
+				tbl[k] = []interface{}{next}
+				return next, nil
+			}
+			next := map[string]interface{}{}
+			tbl[k] = next
+			tbl = next
+		case map[string]interface{}:
+			if last {
+				return nil, fmt.Errorf("table %s defined twice", strings.Join(path, "."))
+			}
+			tbl = v
+		case []interface{}:
+			if last && array {
+				next := map[string]interface{}{}
+				tbl[k] = append(v, next)
+				return next, nil
+			}
+			tbl = v[len(v)-1].(map[string]interface{})
+		default:
+			return nil, fmt.Errorf("%s is not a table", k)
+		}
+	}
+	return tbl, nil
+}
+
+func (p *tomlParser) keyValue(tbl map[string]interface{}) error {
+	k, err := p.key()
+	if err != nil {
+		return err
+	}
+	if err := p.expect(" = "); err != nil {
+		return err
+	}
+	if _, dup := tbl[k]; dup {
+		return fmt.Errorf("key %q defined twice", k)
+	}
+	tbl[k], err = p.value()
+	return err
+}
+
+func (p *tomlParser) key() (string, error) {
+	if strings.HasPrefix(p.s[p.i:], `"`) {
+		return p.str()
+	}
+	start := p.i
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			break
+		}
+		p.i++
+	}
+	if p.i == start {
+		return "", fmt.Errorf("want a key at %q", p.s[p.i:])
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *tomlParser) str() (string, error) {
+	if err := p.expect(`"`); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c == 0x7f:
+			return "", fmt.Errorf("unescaped control character %#x", c)
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		}
+		if p.i == len(p.s) {
+			break
+		}
+		e := p.s[p.i]
+		p.i++
+		switch e {
+		case '"', '\\':
+			b.WriteByte(e)
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'u':
+			if p.i+4 > len(p.s) {
+				return "", fmt.Errorf("short \\u escape")
+			}
+			r, err := strconv.ParseUint(p.s[p.i:p.i+4], 16, 32)
+			if err != nil {
+				return "", err
+			}
+			p.i += 4
+			b.WriteRune(rune(r))
+		default:
+			return "", fmt.Errorf("unknown escape \\%c", e)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+	if p.i == len(p.s) {
+		return nil, fmt.Errorf("missing value")
+	}
+	switch p.s[p.i] {
+	case '"':
+		return p.str()
+	case '[':
+		p.i++
+		a := []interface{}{}
+		for !strings.HasPrefix(p.s[p.i:], "]") {
+			if len(a) > 0 {
+				if err := p.expect(", "); err != nil {
+					return nil, err
+				}
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		p.i++
+		return a, nil
+	case '{':
+		tbl := map[string]interface{}{}
+		if strings.HasPrefix(p.s[p.i:], "{}") {
+			p.i += 2
+			return tbl, nil
+		}
+		p.i++
+		for {
+			if err := p.expect(" "); err != nil {
+				return nil, err
+			}
+			if err := p.keyValue(tbl); err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(p.s[p.i:], " }") {
+				p.i += 2
+				return tbl, nil
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	start := p.i
+	for p.i < len(p.s) && !strings.ContainsRune(",]} ", rune(p.s[p.i])) {
+		p.i++
+	}
+	tok := p.s[start:p.i]
+	switch tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), nil
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	if len(tok) > 10 && tok[4] == '-' && tok[10] == 'T' {
+		return time.Parse(time.RFC3339Nano, tok)
+	}
+	if strings.ContainsAny(tok, ".eE") {
+		return strconv.ParseFloat(tok, 64)
+	}
+	if tok == "" {
+		return nil, fmt.Errorf("want a value at %q", p.s[start:])
+	}
+	return strconv.ParseInt(tok, 10, 64)
+}
@@ -0,0 +1,176 @@
+package gobtool
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Decode-time transforms change values as they come out of a decode, so
+// that callers normalizing data (trimming strings, turning timestamps into
+// time.Time) need no walk of their own. They run on the same rewriter as
+// reencode, with every matching rule applied:
+//
+//   - rules apply in the order given;
+//   - when several rules match a path, each sees the previous one's result;
+//   - a transformed value is not descended into, so a rule on a map hides
+//     the rules on its entries;
+//   - the top-level value itself cannot be transformed, only what it holds.
+
+// TransformRule replaces each value whose path matches PathGlob (see
+// parsePathGlob) with what Func returns for it. Func gets nil for nil
+// values and may return nil for the zero value of the slot.
+type TransformRule struct {
+	PathGlob string
+	Func     func(v interface{}) (interface{}, error)
+}
+
+// TransformedPath is a value a rule changed, or would change in a dry run.
+// Rule is the index of the rule in the slice given.
+type TransformedPath struct {
+	Path string
+	Rule int
+}
+
+// ApplyTransforms applies rules to the values below data, changing maps in
+// place. Errors name the path of the value that failed. With dryRun set
+// nothing is changed and the result lists what would be.
+func ApplyTransforms(data interface{}, rules []TransformRule, dryRun bool) ([]TransformedPath, error) {
+	rw := &rewriter{chain: true, dryRun: dryRun, guard: make(cycleGuard)}
+	index := make(map[*reencodeRule]int)
+	for i, tr := range rules {
+		pattern, err := parsePathGlob(tr.PathGlob)
+		if err != nil {
+			return nil, fmt.Errorf("transform %d: %w", i, err)
+		}
+		r := &reencodeRule{spec: tr.PathGlob, pattern: pattern, custom: tr.Func}
+		rw.rules = append(rw.rules, r)
+		index[r] = i
+	}
+	_, err := rw.rewrite(reflect.ValueOf(data), nil, "")
+	out := make([]TransformedPath, len(rw.touched))
+	for i, t := range rw.touched {
+		out[i] = TransformedPath{t.path, index[t.rule]}
+	}
+	return out, err
+}
+
+// DecodeTransformed decodes r as decodeAny does and applies rules to the
+// result.
+func DecodeTransformed(r io.Reader, filename string, rules []TransformRule) (interface{}, error) {
+	data, err := decodeAny(r, filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ApplyTransforms(data, rules, false); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// builtinTransform makes a rule from one of reencode's transforms, given
+// as "name" or "name:arg".
+func builtinTransform(glob, fn string) (TransformRule, error) {
+	r, err := parseReencodeRule(glob+"="+fn, false)
+	if err != nil {
+		return TransformRule{}, err
+	}
+	slot := reflect.TypeOf((*interface{})(nil)).Elem()
+	return TransformRule{glob, func(v interface{}) (interface{}, error) {
+		out, err := r.apply(reflect.ValueOf(v), slot)
+		if err != nil || !out.IsValid() {
+			return nil, err
+		}
+		return out.Interface(), nil
+	}}, nil
+}
+
+// readTransformFile reads built-in transform rules from a file in a small
+// YAML subset: a list of mappings with path and transform keys.
+//
+//	# trim every string under Values
+//	- path: Values.*
+//	  transform: trim
+//	- path: "Values.created at"
+//	  transform: unix-time
+//
+// Values may be double- or single-quoted. It returns the rules and their
+// "path=transform" descriptions.
+func readTransformFile(name string) ([]TransformRule, []string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	type item struct {
+		line            int
+		path, transform string
+	}
+	var items []*item
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "-"); ok {
+			items = append(items, &item{line: n})
+			line = strings.TrimSpace(rest)
+		} else if len(items) == 0 {
+			return nil, nil, fmt.Errorf("%s:%d: want a list item starting with \"-\"", name, n)
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("%s:%d: want key: value", name, n)
+		}
+		val, err := yamlScalar(strings.TrimSpace(val))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+		it := items[len(items)-1]
+		switch strings.TrimSpace(key) {
+		case "path":
+			it.path = val
+		case "transform":
+			it.transform = val
+		default:
+			return nil, nil, fmt.Errorf("%s:%d: unknown key %q (want path or transform)", name, n, key)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rules := make([]TransformRule, 0, len(items))
+	specs := make([]string, 0, len(items))
+	for _, it := range items {
+		if it.path == "" || it.transform == "" {
+			return nil, nil, fmt.Errorf("%s:%d: rule needs both path and transform", name, it.line)
+		}
+		r, err := builtinTransform(it.path, it.transform)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %w", name, it.line, err)
+		}
+		rules = append(rules, r)
+		specs = append(specs, it.path+"="+it.transform)
+	}
+	return rules, specs, nil
+}
+
+func yamlScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
@@ -0,0 +1,373 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"reflect"
+)
+
+// Commands that rewrite a file (reencode, migrate, filter, optimize,
+// rename-key) change the values they read in their generic form, then
+// encode them again. The generic form has lost the Go types the values
+// were sent as: a struct reads as map[string]interface{} and a []string
+// as []interface{}, and encoded as they are they would go out as those
+// types, which a reader of the original types cannot decode. So these
+// commands read each record together with a Go type rebuilt from its wire
+// type, and write it back as that type.
+//
+// A rebuilt type encodes to the same wire type as the original but for
+// names: structs come out unnamed, as normalizeInts builds them, and types
+// that encode themselves go out as one payload type per method. gob
+// matches structs by field name and never by these names, so readers do
+// not notice. Values held in interfaces carry their registered name,
+// which readers do need: their types must be registered here, or be
+// unnamed types that rebuild exactly, such as []string.
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// gobPayload, binaryPayload and textPayload go back out as the payload
+// of a GobEncoder, BinaryMarshaler or TextMarshaler was read.
+type (
+	gobPayload    []byte
+	binaryPayload []byte
+	textPayload   []byte
+)
+
+func (p gobPayload) GobEncode() ([]byte, error) { return p, nil }
+
+func (p *gobPayload) GobDecode(b []byte) error {
+	*p = append((*p)[:0], b...)
+	return nil
+}
+
+func (p binaryPayload) MarshalBinary() ([]byte, error) { return p, nil }
+
+func (p *binaryPayload) UnmarshalBinary(b []byte) error {
+	*p = append((*p)[:0], b...)
+	return nil
+}
+
+func (p textPayload) MarshalText() ([]byte, error) { return p, nil }
+
+func (p *textPayload) UnmarshalText(b []byte) error {
+	*p = append((*p)[:0], b...)
+	return nil
+}
+
+// goTypes rebuilds Go types from the wire types of one stream.
+type goTypes struct {
+	wire  map[typeID]*wireType
+	built map[typeID]reflect.Type // nil while being built
+}
+
+func newGoTypes(wire map[typeID]*wireType) *goTypes {
+	return &goTypes{wire: wire, built: make(map[typeID]reflect.Type)}
+}
+
+// goType returns a Go type that gob encodes as wire type id. A recursive
+// type is an error, since reflect cannot construct one.
+func (g *goTypes) goType(id typeID) (reflect.Type, error) {
+	switch id {
+	case tBool:
+		return reflect.TypeOf(false), nil
+	case tInt:
+		return reflect.TypeOf(int64(0)), nil
+	case tUint:
+		return reflect.TypeOf(uint64(0)), nil
+	case tFloat:
+		return reflect.TypeOf(float64(0)), nil
+	case tBytes:
+		return reflect.TypeOf([]byte(nil)), nil
+	case tString:
+		return reflect.TypeOf(""), nil
+	case tComplex:
+		return reflect.TypeOf(complex128(0)), nil
+	case tInterface:
+		return interfaceType, nil
+	}
+	if t, ok := g.built[id]; ok {
+		if t == nil {
+			return nil, fmt.Errorf("recursive type %s cannot be rebuilt", typeString(g.wire, id))
+		}
+		return t, nil
+	}
+	wt := g.wire[id]
+	if wt == nil {
+		return nil, formatErrorf("gob: unknown type id %d", id)
+	}
+	g.built[id] = nil
+	t, err := g.build(wt)
+	if err != nil {
+		delete(g.built, id)
+		return nil, err
+	}
+	g.built[id] = t
+	return t, nil
+}
+
+func (g *goTypes) build(wt *wireType) (t reflect.Type, err error) {
+	// reflect panics on what no Go type can be, such as an array too big
+	// for memory; a crafted stream may ask for one
+	defer func() {
+		if r := recover(); r != nil {
+			t, err = nil, fmt.Errorf("type %s cannot be rebuilt: %v", typeString(g.wire, wt.ID), r)
+		}
+	}()
+	switch wt.Kind {
+	case wireArray, wireSlice:
+		elem, err := g.goType(wt.Elem)
+		if err != nil {
+			return nil, err
+		}
+		if wt.Kind == wireArray {
+			return reflect.ArrayOf(wt.Len, elem), nil
+		}
+		return reflect.SliceOf(elem), nil
+	case wireMap:
+		key, err := g.goType(wt.Key)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := g.goType(wt.Elem)
+		if err != nil {
+			return nil, err
+		}
+		if !key.Comparable() {
+			return nil, fmt.Errorf("map key type %s is not comparable", key)
+		}
+		return reflect.MapOf(key, elem), nil
+	case wireStruct:
+		fields := make([]reflect.StructField, len(wt.Fields))
+		for i, f := range wt.Fields {
+			if !token.IsIdentifier(f.Name) || !token.IsExported(f.Name) {
+				return nil, fmt.Errorf("struct %s has field %q, which no Go struct can", typeString(g.wire, wt.ID), f.Name)
+			}
+			ft, err := g.goType(f.ID)
+			if err != nil {
+				return nil, err
+			}
+			fields[i] = reflect.StructField{Name: f.Name, Type: ft}
+		}
+		return reflect.StructOf(fields), nil
+	case wireGobEncoder:
+		return reflect.TypeOf(gobPayload(nil)), nil
+	case wireBinaryMarshaler:
+		return reflect.TypeOf(binaryPayload(nil)), nil
+	case wireTextMarshaler:
+		return reflect.TypeOf(textPayload(nil)), nil
+	}
+	return nil, fmt.Errorf("type %s has unknown kind %d", typeString(g.wire, wt.ID), wt.Kind)
+}
+
+// registerSent checks that every value held in an interface in v, as
+// wireReader returns it, has a Go type that goes back out under the name
+// it was sent with, registering the unnamed types that rebuild exactly.
+func (g *goTypes) registerSent(v interface{}) error {
+	switch x := v.(type) {
+	case wireNamed:
+		if registeredType(x.Name) == nil {
+			// a basic value already has its type, as basicFromName gave it
+			t := reflect.TypeOf(x.Value)
+			if x.ID >= firstUserID {
+				var err error
+				if t, err = g.goType(x.ID); err != nil {
+					return fmt.Errorf("%s: %w", x.Name, err)
+				}
+			}
+			if t == nil || gobName(t) != x.Name {
+				return fmt.Errorf("type %s is not registered, so its values cannot be written back as it", x.Name)
+			}
+			if err := safeRegister(reflect.Zero(t).Interface()); err != nil {
+				return err
+			}
+		}
+		return g.registerSent(x.Value)
+	case map[interface{}]interface{}:
+		for k, e := range x {
+			if err := g.registerSent(k); err != nil {
+				return err
+			}
+			if err := g.registerSent(e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range x {
+			if err := g.registerSent(e); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range x {
+			if err := g.registerSent(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nextWithType reads the next record like next, together with the Go
+// type it goes back out as: interface{} for a record sent as an
+// interface, as EncodeChannel sends them, and otherwise a type rebuilt
+// from its wire type. A record that cannot go back out as its type is an
+// error.
+func (rr *recordReader) nextWithType() (interface{}, reflect.Type, error) {
+	id, err := rr.nextID()
+	if err != nil {
+		return nil, nil, err
+	}
+	if rr.g == nil {
+		rr.g = newGoTypes(rr.w.types)
+	}
+	t, err := rr.g.goType(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("record %d: %w", rr.n, err)
+	}
+	v, err := rr.w.topValue(id, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	rr.n++
+	if err := rr.g.registerSent(v); err != nil {
+		return nil, nil, fmt.Errorf("record %d: %w", rr.n-1, err)
+	}
+	rec, err := rr.build(v)
+	return rec, t, err
+}
+
+// readTypedValues reads every value of the gob stream r, in their
+// generic form, with the types nextWithType gives them.
+func readTypedValues(r io.Reader) (values []interface{}, types []reflect.Type, err error) {
+	values, types, _, err = readTypedStream(r)
+	return values, types, err
+}
+
+// readTypedStream is readTypedValues that also reports whether the stream
+// ended in a record-count footer.
+func readTypedStream(r io.Reader) (values []interface{}, types []reflect.Type, counted bool, err error) {
+	rr := newRecordReader(r)
+	for {
+		rec, t, err := rr.nextWithType()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, false, err
+		}
+		values = append(values, rec)
+		types = append(types, t)
+	}
+	if len(values) == 0 {
+		return nil, nil, false, errEmptyStream
+	}
+	return values, types, rr.counted, nil
+}
+
+// readTypedFile is readTypedValues for a named file, through any layer
+// openPayload removes.
+func readTypedFile(name string) ([]interface{}, []reflect.Type, error) {
+	f, err := openFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	r, err := openPayload(f, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, types, err := readTypedValues(r)
+	return values, types, payloadError(r, err)
+}
+
+// retype returns rec, a value in its generic form, as a value of t. A
+// field t has no place for is an error rather than dropped.
+func retype(t reflect.Type, rec interface{}) (interface{}, error) {
+	if t == interfaceType {
+		return rec, nil
+	}
+	v := reflect.New(t).Elem()
+	a := &assigner{}
+	if err := a.assign(v, rec, ""); err != nil {
+		return nil, err
+	}
+	if len(a.dropped) > 0 {
+		return nil, fmt.Errorf("%s has no field in %s", a.dropped[0], t)
+	}
+	return v.Interface(), nil
+}
+
+// encodeTyped encodes rec as a value of t: as an interface when t is
+// interface{}, and bare otherwise. A nil t sends rec bare as its own type.
+func encodeTyped(enc *gob.Encoder, t reflect.Type, rec interface{}) error {
+	switch t {
+	case nil:
+		return encodeRoot(enc, rec)
+	case interfaceType:
+		return enc.Encode(&rec)
+	}
+	v, err := retype(t, rec)
+	if err != nil {
+		return err
+	}
+	return enc.Encode(v)
+}
+
+// verifyTyped decodes the gob stream r with encoding/gob into the types
+// encodeStreamValues wrote values as, the way a reader of the original
+// types would, and checks every value reads back as it was and nothing
+// follows them but, when counted is set, a record-count footer that
+// matches. Values compare by their canonical form, in which a nil slice
+// or map and an empty one are the same, as gob does not tell them apart
+// either.
+func verifyTyped(r io.Reader, values []interface{}, types []reflect.Type, counted bool) error {
+	dec := gob.NewDecoder(r)
+	for i, v := range values {
+		t, want := interfaceType, v
+		switch {
+		case types != nil:
+			var err error
+			t = types[i]
+			if want, err = retype(t, v); err != nil {
+				return fmt.Errorf("value %d: %w", i, err)
+			}
+		case v != nil:
+			t = reflect.TypeOf(v)
+		}
+		got := reflect.New(t)
+		if err := dec.DecodeValue(got); err != nil {
+			return fmt.Errorf("value %d does not decode as %s: %w", i, t, err)
+		}
+		var wantBuf, gotBuf bytes.Buffer
+		if err := writeCanonical(&wantBuf, want); err != nil {
+			return err
+		}
+		if err := writeCanonical(&gotBuf, got.Elem().Interface()); err != nil {
+			return err
+		}
+		if !bytes.Equal(wantBuf.Bytes(), gotBuf.Bytes()) {
+			return fmt.Errorf("value %d differs after re-encoding", i)
+		}
+	}
+	if counted {
+		var footer recordCountFooter
+		if err := dec.Decode(&footer); err != nil {
+			return fmt.Errorf("record-count footer: %w", err)
+		}
+		if footer.Records != int64(len(values)) {
+			return fmt.Errorf("record-count footer declares %d records, %d were written", footer.Records, len(values))
+		}
+	}
+	var extra interface{}
+	if err := dec.Decode(&extra); !errors.Is(err, io.EOF) {
+		if err == nil {
+			err = fmt.Errorf("more than the %d values written", len(values))
+		}
+		return err
+	}
+	return nil
+}
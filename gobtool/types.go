@@ -0,0 +1,105 @@
+package gobtool
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+var wireKindNames = map[wireKind]string{
+	wireArray:           "array",
+	wireSlice:           "slice",
+	wireStruct:          "struct",
+	wireMap:             "map",
+	wireGobEncoder:      "GobEncoder",
+	wireBinaryMarshaler: "BinaryMarshaler",
+	wireTextMarshaler:   "TextMarshaler",
+}
+
+// readWireTypes reads every type definition in a gob stream, including
+// those sent inline with interface values, walking over the values
+// without building them. It also returns the type id of each top-level
+// value in order.
+func readWireTypes(r io.Reader) (map[typeID]*wireType, []typeID, error) {
+	w := newWireReader(r, 0)
+	var values []typeID
+	for {
+		id, err := w.nextMessage()
+		if errors.Is(err, io.EOF) {
+			return w.types, values, nil
+		}
+		if err == nil {
+			_, err = w.topValue(id, false)
+		}
+		if err != nil {
+			return w.types, values, err
+		}
+		values = append(values, id)
+	}
+}
+
+// writeWireTypes prints each definition in types by id: its kind and
+// name, where it was found, and the type ids it refers to.
+func writeWireTypes(w io.Writer, types map[typeID]*wireType) error {
+	ids := make([]typeID, 0, len(types))
+	for id := range types {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	ref := func(id typeID) string { return fmt.Sprintf("%s (id %d)", typeString(types, id), id) }
+	for _, id := range ids {
+		wt := types[id]
+		name := wt.Name
+		if name == "" {
+			name = typeString(types, id)
+		}
+		fmt.Fprintf(w, "type %d: %s %s (%d bytes at offset %d)\n", id, wireKindNames[wt.Kind], name, len(wt.raw), wt.at)
+		switch wt.Kind {
+		case wireArray:
+			fmt.Fprintf(w, "  len:  %d\n  elem: %s\n", wt.Len, ref(wt.Elem))
+		case wireSlice:
+			fmt.Fprintf(w, "  elem: %s\n", ref(wt.Elem))
+		case wireMap:
+			fmt.Fprintf(w, "  key:  %s\n  elem: %s\n", ref(wt.Key), ref(wt.Elem))
+		case wireStruct:
+			for i, f := range wt.Fields {
+				fmt.Fprintf(w, "  field %d %s: %s\n", i, f.Name, ref(f.ID))
+			}
+		}
+	}
+	return nil
+}
+
+// typesFlags registers the flags of types; the command returned
+// implements "types file.gob".
+func typesFlags(fs *flag.FlagSet) func(args []string) error {
+	fs.IntVar(&maxTypeDepth, "max-type-depth", defaultMaxTypeDepth, "fail on type definitions nested deeper than this; 0 for no limit")
+	return func(args []string) error {
+		if len(args) != 1 {
+			return errors.New("usage: types [-max-type-depth n] file.gob")
+		}
+		f, err := openFile(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r, err := openPayload(f, args[0])
+		if err != nil {
+			return err
+		}
+		types, values, err := readWireTypes(r)
+		if werr := writeWireTypes(os.Stdout, types); werr != nil {
+			return werr
+		}
+		for i, id := range values {
+			fmt.Printf("value %d: %s (id %d)\n", i, typeString(types, id), id)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], payloadError(r, err))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+package gobtool
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// nestedSliceStream returns a crafted gob stream holding an empty value
+// of n nested unnamed slice types, []...[]int, defined innermost last.
+// With cycle set the innermost refers back to the outermost instead.
+func nestedSliceStream(n int, cycle bool) []byte {
+	var stream []byte
+	message := func(body []byte) {
+		stream = append(appendUint(stream, uint64(len(body))), body...)
+	}
+	for i := 0; i < n; i++ {
+		id, elem := firstUserID+typeID(i), firstUserID+typeID(i+1)
+		if i == n-1 {
+			elem = tInt
+			if cycle {
+				elem = firstUserID
+			}
+		}
+		// -id, then wireType{SliceT: &sliceType{CommonType{}, Elem: elem}}
+		body := appendInt(nil, int64(-id))
+		body = append(body, 2, 1, 0, 1)
+		body = appendInt(body, int64(elem))
+		message(append(body, 0, 0))
+	}
+	message(append(appendInt(nil, int64(firstUserID)), 0, 0))
+	return stream
+}
+
+func TestReadWireTypesDepth(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		n       int
+		cycle   bool
+		refused bool
+	}{
+		{"at limit", defaultMaxTypeDepth, false, false},
+		{"past limit", defaultMaxTypeDepth + 1, false, true},
+		{"cycle", 2, true, true},
+	} {
+		_, values, err := readWireTypes(bytes.NewReader(nestedSliceStream(c.n, c.cycle)))
+		if c.refused {
+			if !errors.Is(err, errTypesTooDeep) {
+				t.Errorf("%s: got %v, want the nesting error", c.name, err)
+			}
+			if len(values) != 0 {
+				t.Errorf("%s: read %d values past the refused types", c.name, len(values))
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+}
+
+func TestWriteWireTypes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := encodeRoot(gob.NewEncoder(&buf), map[string][]int{"a": {1}}); err != nil {
+		t.Fatal(err)
+	}
+	types, values, err := readWireTypes(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 {
+		t.Fatalf("got %d values, want 1", len(values))
+	}
+	var out bytes.Buffer
+	if err := writeWireTypes(&out, types); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"map", "key:  string", "elem: []int", "slice"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("dump does not mention %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !gobunsafe
+
+package gobtool
+
+import "reflect"
+
+// The default build stays free of package unsafe: unexported fields are
+// only readable in a build with -tags gobunsafe, see unexported_unsafe.go.
+
+const unexportedSupported = false
+
+func unexportedField(v reflect.Value, i int) (reflect.Value, bool) {
+	return reflect.Value{}, false
+}
@@ -0,0 +1,29 @@
+//go:build gobunsafe
+
+package gobtool
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// DEBUGGING AID ONLY. This file is built only with -tags gobunsafe. It
+// reads unexported struct fields through package unsafe so that
+// decode -show-unexported can display the internals of third-party types.
+// Values read this way bypass the visibility rules of the packages that
+// own them. They are for display and must never be re-encoded, converted
+// or modified.
+
+const unexportedSupported = true
+
+// unexportedField returns field i of struct v even if it is unexported.
+// An unaddressable v is copied first, so its fields can be read.
+func unexportedField(v reflect.Value, i int) (reflect.Value, bool) {
+	if !v.CanAddr() {
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		v = cp
+	}
+	f := v.Field(i)
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem(), true
+}
@@ -0,0 +1,166 @@
+package gobtool
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cycleGuard records the containers on the current walk path, so walkers
+// can stop when a value (indirectly) contains itself. Values decoded by gob
+// never do, but the maps handed to us by callers may.
+type cycleGuard map[guardKey]bool
+
+type guardKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// descend follows pointers and interfaces down to the value they hold,
+// like indirect, marking every pointer and container it passes as on the
+// path. It reports false, marking nothing, if one of them already is;
+// otherwise leave must be called once the value has been walked.
+func (g cycleGuard) descend(v reflect.Value) (inner reflect.Value, leave func(), ok bool) {
+	var marked []guardKey
+	leave = func() {
+		for _, k := range marked {
+			delete(g, k)
+		}
+	}
+	for {
+		if k, ok := guardKeyOf(v); ok {
+			if g[k] {
+				leave()
+				return reflect.Value{}, nil, false
+			}
+			g[k] = true
+			marked = append(marked, k)
+		}
+		if !v.IsValid() || (v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface) {
+			return v, leave, true
+		}
+		if v.IsNil() {
+			return reflect.Value{}, leave, true
+		}
+		v = v.Elem()
+	}
+}
+
+func guardKeyOf(v reflect.Value) (guardKey, bool) {
+	switch v.Kind() {
+	case reflect.Map, reflect.Pointer, reflect.Slice:
+		if v.IsNil() || v.Pointer() == 0 {
+			return guardKey{}, false
+		}
+		return guardKey{v.Pointer(), v.Type()}, true
+	}
+	return guardKey{}, false
+}
+
+// sortedMapKeys returns the keys of map v ordered by their printed form,
+// so output does not depend on map iteration order.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.SliceStable(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
+
+// indirect follows pointers and interfaces down to the value they hold.
+// A nil pointer or interface comes back as an invalid Value.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isContainer reports whether v has children for walkPaths to visit.
+func isContainer(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Struct:
+		return true
+	case reflect.Slice, reflect.Array:
+		return v.Type().Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// walkPaths calls fn for v and for every value below it, passing the flat
+// path (map keys and struct fields joined by ".", indexes as "[i]") and
+// the depth. Values reach fn with pointers and interfaces already
+// followed. Returning false from fn skips the value's children.
+func walkPaths(v reflect.Value, fn func(path string, depth int, v reflect.Value) bool) {
+	walkPathsFrom(v, "", 0, cycleGuard{}, fn)
+}
+
+func walkPathsFrom(v reflect.Value, path string, depth int, guard cycleGuard, fn func(string, int, reflect.Value) bool) {
+	v, leave, ok := guard.descend(v)
+	if !ok {
+		return
+	}
+	defer leave()
+	if !fn(path, depth, v) || !v.IsValid() {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		for _, k := range sortedMapKeys(v) {
+			walkPathsFrom(v.MapIndex(k), joinPath(path, pathKey(k.Interface())), depth+1, guard, fn)
+		}
+	case reflect.Slice, reflect.Array:
+		if !isContainer(v) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			walkPathsFrom(v.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1, guard, fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkPathsFrom(v.Field(i), joinPath(path, v.Type().Field(i).Name), depth+1, guard, fn)
+			}
+		}
+	}
+}
+
+// lookupPath finds the value at path in data, as walkPaths names it.
+func lookupPath(data interface{}, path string) (reflect.Value, bool) {
+	var found reflect.Value
+	ok := false
+	walkPaths(reflect.ValueOf(data), func(p string, _ int, v reflect.Value) bool {
+		if ok {
+			return false
+		}
+		if p == path {
+			found, ok = v, true
+			return false
+		}
+		// only descend towards path
+		return p == "" || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[")
+	})
+	return found, ok
+}
+
+func joinPath(parent, seg string) string {
+	if parent == "" {
+		return seg
+	}
+	return parent + "." + seg
+}
+
+// pathKey renders a map key as a path segment, quoting keys that would
+// otherwise be ambiguous in a path.
+func pathKey(k interface{}) string {
+	s := sprint(k)
+	if s == "" || strings.ContainsAny(s, ".[]\" ") || !isCleanString(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
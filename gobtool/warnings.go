@@ -0,0 +1,149 @@
+package gobtool
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Conversions that cannot carry everything across, such as JSON and TOML
+// output, reading JSON for encode and capped output, report what they
+// lose as warnings instead of losing it silently. Each kind of loss has a
+// stable code that scripts can match on. Library functions add to a
+// *Warnings their caller passes in, which may be nil to ignore them; the
+// commands print them to stderr, write them as JSON with -warnings-out
+// and fail on them with -strict-warnings.
+
+// Warning codes. Codes are never reused or renumbered.
+const (
+	WarnKeyCollision   = "W001" // map keys that print alike, so only one value is kept
+	WarnNumberType     = "W002" // a number that lost precision or changed type
+	WarnKeyStringified = "W003" // non-string map keys written as strings
+	WarnFieldDropped   = "W004" // unexported struct fields left out
+	WarnTruncated      = "W005" // output cut short
+	WarnTypeChange     = "W006" // a value written as a string of another type
+	WarnSliceUnsorted  = "W007" // a slice -sort-slices could not sort
+	WarnUnknownType    = "W008" // an interchange annotation of no known type, kept as a map
+	WarnNoDuration     = "W009" // a -compute-durations field missing or not a time
+	WarnInvalidUTF8    = "W010" // a string that is not UTF-8, written as a bytes annotation
+)
+
+var warningNames = map[string]string{
+	WarnKeyCollision:   "key-collision",
+	WarnNumberType:     "number-type-change",
+	WarnKeyStringified: "key-stringified",
+	WarnFieldDropped:   "field-dropped",
+	WarnTruncated:      "output-truncated",
+	WarnTypeChange:     "type-change",
+	WarnSliceUnsorted:  "slice-unsorted",
+	WarnUnknownType:    "unknown-annotation",
+	WarnNoDuration:     "duration-skipped",
+	WarnInvalidUTF8:    "invalid-utf8",
+}
+
+// Warning is one loss of information, at a path as walkPaths names it.
+type Warning struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("warning %s %s at %s: %s", w.Code, w.Name, pathOrRoot(w.Path), w.Message)
+}
+
+// Warnings collects warnings, keeping only the first of each code at each
+// path, so rendering the same data twice reports it once. Its methods
+// accept a nil *Warnings and do nothing.
+type Warnings struct {
+	List []Warning
+	seen map[[2]string]bool
+}
+
+func (ws *Warnings) add(code, path, format string, args ...interface{}) {
+	if ws == nil {
+		return
+	}
+	key := [2]string{code, path}
+	if ws.seen[key] {
+		return
+	}
+	if ws.seen == nil {
+		ws.seen = make(map[[2]string]bool)
+	}
+	ws.seen[key] = true
+	ws.List = append(ws.List, Warning{code, warningNames[code], path, fmt.Sprintf(format, args...)})
+}
+
+// warningFlags are -warnings-out, -strict-warnings and -allow-warnings,
+// and hold the warnings of the command they belong to.
+type warningFlags struct {
+	Warnings
+	out    string
+	strict bool
+	allow  map[string]bool
+}
+
+func (f *warningFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.out, "warnings-out", "", "also write the warnings to this file as JSON")
+	fs.BoolVar(&f.strict, "strict-warnings", false, "fail when any warning is raised")
+	fs.Func("allow-warnings", "with -strict-warnings, codes that stay warnings, such as W003,W006", func(s string) error {
+		if f.allow == nil {
+			f.allow = make(map[string]bool)
+		}
+		for _, code := range strings.Split(s, ",") {
+			code = strings.TrimSpace(code)
+			if _, ok := warningNames[code]; !ok {
+				return fmt.Errorf("unknown warning code %q", code)
+			}
+			f.allow[code] = true
+		}
+		return nil
+	})
+}
+
+// report prints the warnings to w, sorted by path and code since map
+// order decides the order they were found in, writes -warnings-out and,
+// with -strict-warnings, fails when any code outside -allow-warnings was
+// seen.
+func (f *warningFlags) report(w io.Writer) error {
+	sort.SliceStable(f.List, func(i, j int) bool {
+		if f.List[i].Path != f.List[j].Path {
+			return f.List[i].Path < f.List[j].Path
+		}
+		return f.List[i].Code < f.List[j].Code
+	})
+	for _, warn := range f.List {
+		fmt.Fprintln(w, warn)
+	}
+	if f.out != "" {
+		list := f.List
+		if list == nil {
+			list = []Warning{}
+		}
+		b, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(f.out, append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	if !f.strict {
+		return nil
+	}
+	n := 0
+	for _, warn := range f.List {
+		if !f.allow[warn.Code] {
+			n++
+		}
+	}
+	if n > 0 {
+		return fmt.Errorf("%d warnings with -strict-warnings", n)
+	}
+	return nil
+}
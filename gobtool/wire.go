@@ -0,0 +1,832 @@
+package gobtool
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// The gob wire format can be walked without the Go types it was encoded
+// from. wireReader does exactly that: it keeps the type definitions it
+// sees and uses them to decode values generically, or to skip over them
+// without allocating anything. Structs come back as map[string]interface{},
+// maps as map[interface{}]interface{}, slices and arrays as []interface{}
+// and GobEncoder payloads as []byte.
+
+type typeID int64
+
+// Predefined type ids, fixed by encoding/gob.
+const (
+	tBool      typeID = 1
+	tInt       typeID = 2
+	tUint      typeID = 3
+	tFloat     typeID = 4
+	tBytes     typeID = 5
+	tString    typeID = 6
+	tComplex   typeID = 7
+	tInterface typeID = 8
+
+	firstUserID typeID = 64
+)
+
+// tooBig mirrors encoding/gob's sanity limit on counts and lengths.
+const tooBig = 1 << 30
+
+type wireKind int
+
+const (
+	wireArray wireKind = iota + 1
+	wireSlice
+	wireStruct
+	wireMap
+	wireGobEncoder
+	wireBinaryMarshaler
+	wireTextMarshaler
+)
+
+type wireField struct {
+	Name string
+	ID   typeID
+}
+
+// wireType is a user type definition as sent on the wire.
+type wireType struct {
+	Kind   wireKind
+	Name   string
+	ID     typeID
+	Key    typeID
+	Elem   typeID
+	Len    int
+	Fields []wireField
+
+	raw []byte // the encoded definition, as found in the stream
+	at  int64  // offset of the definition in the stream
+}
+
+var errWireOverrun = errors.New("gob: value overruns its message")
+
+// formatError is data that is not well-formed gob, as wireReader and the
+// readers built on it find it. Its message reads like encoding/gob's for
+// the same fault, but callers tell it apart by type, with errors.As.
+type formatError struct{ msg string }
+
+func (e *formatError) Error() string { return e.msg }
+
+func formatErrorf(format string, args ...interface{}) error {
+	return &formatError{fmt.Sprintf(format, args...)}
+}
+
+type wireReader struct {
+	r      *bufio.Reader
+	off    int64 // absolute offset of the next byte
+	remain int64 // bytes left in the current message
+	msg    int64 // offset of the current message's length prefix
+	types  map[typeID]*wireType
+	recs   []*bytes.Buffer // consumed bytes are copied into each of these
+	named  bool            // wrap interface values in wireNamed
+	// typeBytes counts the bytes of type definitions read so far, their
+	// message headers included.
+	typeBytes int64
+
+	// summarize, when set, is the container depth from which value
+	// returns a valueSummary in place of anything big; see summary.go.
+	summarize int
+	depth     int
+
+	// maxTypeDepth bounds how deeply the type definitions of a value may
+	// nest; checked holds the ids checked since the last definition.
+	maxTypeDepth int
+	checked      map[typeID]bool
+}
+
+// defaultMaxTypeDepth is the nesting allowed in type definitions. Real
+// types rarely go past a dozen levels; recursive types such as a linked
+// list count once, however long the list.
+const defaultMaxTypeDepth = 100
+
+// maxTypeDepth is what new wireReaders allow; see the types command.
+var maxTypeDepth = defaultMaxTypeDepth
+
+// errTypesTooDeep is returned for a value whose type definitions nest
+// past the limit, or refer to each other in a cycle no Go type can have.
+var errTypesTooDeep = errors.New("type definitions too deeply nested")
+
+// wireNamed is an interface value together with the name its concrete type
+// was registered under, for callers that need to find the Go type again.
+type wireNamed struct {
+	Name  string
+	ID    typeID // the wire type of Value
+	Value interface{}
+}
+
+func newWireReader(r io.Reader, base int64) *wireReader {
+	return &wireReader{
+		r:     bufio.NewReader(r),
+		off:   base,
+		types: make(map[typeID]*wireType),
+
+		maxTypeDepth: maxTypeDepth,
+	}
+}
+
+func (w *wireReader) readByte() (byte, error) {
+	if w.remain <= 0 {
+		return 0, errWireOverrun
+	}
+	b, err := w.r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	w.remain--
+	w.off++
+	for _, rec := range w.recs {
+		rec.WriteByte(b)
+	}
+	return b, nil
+}
+
+func (w *wireReader) readFull(n int64) ([]byte, error) {
+	if n > w.remain {
+		return nil, errWireOverrun
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(w.r, buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	w.remain -= n
+	w.off += n
+	for _, rec := range w.recs {
+		rec.Write(buf)
+	}
+	return buf, nil
+}
+
+func (w *wireReader) skip(n int64) error {
+	if len(w.recs) > 0 {
+		_, err := w.readFull(n)
+		return err
+	}
+	if n > w.remain {
+		return errWireOverrun
+	}
+	if _, err := w.r.Discard(int(n)); err != nil {
+		return unexpectedEOF(err)
+	}
+	w.remain -= n
+	w.off += n
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (w *wireReader) readUint() (uint64, error) {
+	b, err := w.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if b < 0x80 {
+		return uint64(b), nil
+	}
+	n := -int(int8(b))
+	if n > 8 {
+		return 0, formatErrorf("gob: invalid uint length %d at offset %d", n, w.off-1)
+	}
+	buf, err := w.readFull(int64(n))
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range buf {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (w *wireReader) readInt() (int64, error) {
+	u, err := w.readUint()
+	if err != nil {
+		return 0, err
+	}
+	if u&1 != 0 {
+		return ^int64(u >> 1), nil
+	}
+	return int64(u >> 1), nil
+}
+
+func (w *wireReader) readFloat() (float64, error) {
+	u, err := w.readUint()
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(bits.ReverseBytes64(u)), nil
+}
+
+func (w *wireReader) readLength() (int64, error) {
+	u, err := w.readUint()
+	if err != nil {
+		return 0, err
+	}
+	if u >= tooBig || int64(u) > w.remain {
+		return 0, formatErrorf("gob: length %d exceeds input at offset %d", u, w.off)
+	}
+	return int64(u), nil
+}
+
+// readCount reads the element count of a slice, array or map. Unlike a
+// byte length it is not bounded by the rest of the message: encoding/gob
+// flushes the value written so far with every type definition it sends
+// for an interface inside it, so a map of interfaces can count more
+// elements than its first message has bytes. Each element takes at least
+// a byte, so a bad count still ends at the end of the input; callers
+// preallocate no more than w.remain elements.
+func (w *wireReader) readCount() (int64, error) {
+	u, err := w.readUint()
+	if err != nil {
+		return 0, err
+	}
+	if u >= tooBig {
+		return 0, formatErrorf("gob: count %d too large at offset %d", u, w.off)
+	}
+	return int64(u), nil
+}
+
+func (w *wireReader) readString() (string, error) {
+	n, err := w.readLength()
+	if err != nil {
+		return "", err
+	}
+	buf, err := w.readFull(n)
+	return string(buf), err
+}
+
+// fields walks a struct encoding, calling fn with each field number.
+func (w *wireReader) fields(fn func(field int) error) error {
+	field := -1
+	for {
+		delta, err := w.readUint()
+		if err != nil {
+			return err
+		}
+		if delta == 0 {
+			return nil
+		}
+		if delta > math.MaxInt32 {
+			return formatErrorf("gob: bad field delta %d at offset %d", delta, w.off)
+		}
+		field += int(delta)
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+}
+
+// nextMessage reads the type definitions that precede the next value and
+// returns the value's type id. The message body is left unread. io.EOF is
+// returned only at a clean message boundary.
+func (w *wireReader) nextMessage() (typeID, error) {
+	for {
+		w.remain = math.MaxInt64
+		if _, err := w.r.Peek(1); err == io.EOF {
+			return 0, io.EOF
+		}
+		w.msg = w.off
+		n, err := w.readUint()
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 || n >= tooBig {
+			return 0, formatErrorf("gob: bad message length %d at offset %d", n, w.off)
+		}
+		w.remain = int64(n)
+		id, err := w.readInt()
+		if err != nil {
+			return 0, err
+		}
+		if id >= 0 {
+			return typeID(id), w.checkTypeDepth(typeID(id))
+		}
+		if err := w.typeDef(typeID(-id)); err != nil {
+			return 0, err
+		}
+		if w.remain != 0 {
+			return 0, formatErrorf("gob: extra data after type definition at offset %d", w.off)
+		}
+		w.typeBytes += w.off - w.msg
+	}
+}
+
+// typeSequence is the interface flavour of nextMessage: type definitions
+// may be sent inline, each followed by a byte count we have no use for, and
+// when they use up the current message the value continues in the next.
+func (w *wireReader) typeSequence() (typeID, error) {
+	for {
+		start := w.off
+		if w.remain == 0 {
+			w.remain = math.MaxInt64
+			n, err := w.readUint()
+			if err != nil {
+				return 0, err
+			}
+			if n == 0 || n >= tooBig {
+				return 0, formatErrorf("gob: bad message length %d at offset %d", n, w.off)
+			}
+			w.remain = int64(n)
+		}
+		id, err := w.readInt()
+		if err != nil {
+			return 0, err
+		}
+		if id >= 0 {
+			return typeID(id), w.checkTypeDepth(typeID(id))
+		}
+		if err := w.typeDef(typeID(-id)); err != nil {
+			return 0, err
+		}
+		if w.remain > 0 {
+			if _, err := w.readUint(); err != nil {
+				return 0, err
+			}
+		}
+		w.typeBytes += w.off - start
+	}
+}
+
+func (w *wireReader) typeDef(id typeID) error {
+	if id < firstUserID {
+		return formatErrorf("gob: redefinition of builtin type id %d", id)
+	}
+	rec := new(bytes.Buffer)
+	w.recs = append(w.recs, rec)
+	defer func() { w.recs = w.recs[:len(w.recs)-1] }()
+
+	wt := &wireType{ID: id, at: w.off}
+	common := func(field int) error {
+		if field != 0 {
+			return errBadTypeDef
+		}
+		return w.fields(func(f int) error {
+			switch f {
+			case 0:
+				s, err := w.readString()
+				wt.Name = s
+				return err
+			case 1:
+				_, err := w.readInt()
+				return err
+			}
+			return errBadTypeDef
+		})
+	}
+	readID := func(dst *typeID) error {
+		v, err := w.readInt()
+		*dst = typeID(v)
+		return err
+	}
+	err := w.fields(func(f int) error {
+		if wt.Kind != 0 {
+			return errBadTypeDef
+		}
+		switch f {
+		case 0:
+			wt.Kind = wireArray
+			return w.fields(func(g int) error {
+				switch g {
+				case 0:
+					return common(g)
+				case 1:
+					return readID(&wt.Elem)
+				case 2:
+					n, err := w.readInt()
+					wt.Len = int(n)
+					return err
+				}
+				return errBadTypeDef
+			})
+		case 1:
+			wt.Kind = wireSlice
+			return w.fields(func(g int) error {
+				switch g {
+				case 0:
+					return common(g)
+				case 1:
+					return readID(&wt.Elem)
+				}
+				return errBadTypeDef
+			})
+		case 2:
+			wt.Kind = wireStruct
+			return w.fields(func(g int) error {
+				switch g {
+				case 0:
+					return common(g)
+				case 1:
+					n, err := w.readLength()
+					if err != nil {
+						return err
+					}
+					for i := int64(0); i < n; i++ {
+						var fld wireField
+						err := w.fields(func(h int) error {
+							switch h {
+							case 0:
+								s, err := w.readString()
+								fld.Name = s
+								return err
+							case 1:
+								return readID(&fld.ID)
+							}
+							return errBadTypeDef
+						})
+						if err != nil {
+							return err
+						}
+						wt.Fields = append(wt.Fields, fld)
+					}
+					return nil
+				}
+				return errBadTypeDef
+			})
+		case 3:
+			wt.Kind = wireMap
+			return w.fields(func(g int) error {
+				switch g {
+				case 0:
+					return common(g)
+				case 1:
+					return readID(&wt.Key)
+				case 2:
+					return readID(&wt.Elem)
+				}
+				return errBadTypeDef
+			})
+		case 4, 5, 6:
+			wt.Kind = wireGobEncoder + wireKind(f-4)
+			return w.fields(common)
+		}
+		return errBadTypeDef
+	})
+	if err != nil {
+		return fmt.Errorf("gob: type definition %d: %w", id, err)
+	}
+	if wt.Kind == 0 {
+		return formatErrorf("gob: empty type definition %d", id)
+	}
+	wt.raw = rec.Bytes()
+	w.types[id] = wt
+	w.checked = nil
+	return nil
+}
+
+// checkTypeDepth checks that the definitions type id refers to nest at
+// most maxTypeDepth levels, so nothing that follows them recursively can
+// run out of stack. A reference back to a type being followed ends the
+// walk: through a named type that is an ordinary recursive type, but a
+// cycle of unnamed types would nest forever and is an error.
+func (w *wireReader) checkTypeDepth(id typeID) error {
+	if w.maxTypeDepth <= 0 || w.checked[id] {
+		return nil
+	}
+	const active = -1
+	depths := make(map[typeID]int) // of types done, or active on the path
+	var path []typeID
+	var walk func(id typeID) (int, error)
+	walk = func(id typeID) (int, error) {
+		wt := w.types[id]
+		if wt == nil {
+			return 0, nil // builtin, or undefined and reported when read
+		}
+		if d, ok := depths[id]; ok {
+			if d != active {
+				return d, nil
+			}
+			for i := len(path) - 1; i >= 0; i-- {
+				if w.types[path[i]].Name != "" {
+					return 0, nil
+				}
+				if path[i] == id {
+					break
+				}
+			}
+			return 0, fmt.Errorf("gob: type %d: %w: unnamed types refer to each other in a cycle", id, errTypesTooDeep)
+		}
+		if len(path) >= w.maxTypeDepth {
+			return 0, fmt.Errorf("gob: type %d: %w (limit %d)", path[0], errTypesTooDeep, w.maxTypeDepth)
+		}
+		depths[id] = active
+		path = append(path, id)
+		refs := []typeID{wt.Key, wt.Elem}
+		for _, f := range wt.Fields {
+			refs = append(refs, f.ID)
+		}
+		d := 0
+		for _, ref := range refs {
+			sub, err := walk(ref)
+			if err != nil {
+				return 0, err
+			}
+			d = max(d, sub)
+		}
+		path = path[:len(path)-1]
+		depths[id] = d + 1
+		return d + 1, nil
+	}
+	if _, err := walk(id); err != nil {
+		return err
+	}
+	if w.checked == nil {
+		w.checked = make(map[typeID]bool)
+	}
+	w.checked[id] = true
+	return nil
+}
+
+var errBadTypeDef = errors.New("malformed type definition")
+
+// topValue reads the body of a value message of type id, which nextMessage
+// has just returned.
+func (w *wireReader) topValue(id typeID, keep bool) (interface{}, error) {
+	v, err := w.singleton(id, keep)
+	if err != nil {
+		return nil, err
+	}
+	if w.remain != 0 {
+		return nil, formatErrorf("gob: %d bytes left over at offset %d", w.remain, w.off)
+	}
+	return v, nil
+}
+
+// singleton reads a value sent on its own, as at top level or inside an
+// interface: anything but a struct is preceded by a zero field delta.
+func (w *wireReader) singleton(id typeID, keep bool) (interface{}, error) {
+	if wt := w.types[id]; wt == nil || wt.Kind != wireStruct {
+		if delta, err := w.readUint(); err != nil {
+			return nil, err
+		} else if delta != 0 {
+			return nil, formatErrorf("gob: corrupted data: non-zero delta for singleton at offset %d", w.off)
+		}
+	}
+	return w.value(id, keep)
+}
+
+// value decodes (or, when keep is false, skips) one value of type id.
+func (w *wireReader) value(id typeID, keep bool) (interface{}, error) {
+	if w.summarize > 0 && keep && w.depth >= w.summarize {
+		if s, ok, err := w.summary(id); ok {
+			return s, err
+		}
+	}
+	switch id {
+	case tBool:
+		u, err := w.readUint()
+		return u != 0, err
+	case tInt:
+		return w.readInt()
+	case tUint:
+		return w.readUint()
+	case tFloat:
+		return w.readFloat()
+	case tBytes, tString:
+		n, err := w.readLength()
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			return nil, w.skip(n)
+		}
+		buf, err := w.readFull(n)
+		if id == tString {
+			return string(buf), err
+		}
+		return buf, err
+	case tComplex:
+		re, err := w.readFloat()
+		if err != nil {
+			return nil, err
+		}
+		im, err := w.readFloat()
+		return complex(re, im), err
+	case tInterface:
+		v, _, err := w.iface(keep)
+		return v, err
+	}
+	wt := w.types[id]
+	if wt == nil {
+		return nil, formatErrorf("gob: unknown type id %d at offset %d", id, w.off)
+	}
+	switch wt.Kind {
+	case wireArray, wireSlice:
+		n, err := w.readCount()
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		if keep {
+			out = make([]interface{}, 0, min(n, w.remain))
+		}
+		w.depth++
+		defer func() { w.depth-- }()
+		for i := int64(0); i < n; i++ {
+			v, err := w.value(wt.Elem, keep)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				out = append(out, v)
+			}
+		}
+		if !keep {
+			return nil, nil
+		}
+		return out, nil
+	case wireMap:
+		n, err := w.readCount()
+		if err != nil {
+			return nil, err
+		}
+		var out map[interface{}]interface{}
+		if keep {
+			out = make(map[interface{}]interface{}, min(n, w.remain))
+		}
+		w.depth++
+		defer func() { w.depth-- }()
+		for i := int64(0); i < n; i++ {
+			k, err := w.value(wt.Key, keep)
+			if err != nil {
+				return nil, err
+			}
+			v, err := w.value(wt.Elem, keep)
+			if err != nil {
+				return nil, err
+			}
+			if keep {
+				out[hashableKey(k)] = v
+			}
+		}
+		if !keep {
+			return nil, nil
+		}
+		return out, nil
+	case wireStruct:
+		var out map[string]interface{}
+		if keep {
+			out = make(map[string]interface{}, len(wt.Fields))
+		}
+		w.depth++
+		defer func() { w.depth-- }()
+		err := w.fields(func(f int) error {
+			if f >= len(wt.Fields) {
+				return formatErrorf("gob: field %d out of range for %s at offset %d", f, wt.Name, w.off)
+			}
+			v, err := w.value(wt.Fields[f].ID, keep)
+			if keep {
+				out[wt.Fields[f].Name] = v
+			}
+			return err
+		})
+		if err != nil || !keep {
+			return nil, err
+		}
+		return out, nil
+	default:
+		return w.value(tBytes, keep)
+	}
+}
+
+// iface decodes an interface value and also returns the registered name
+// of its concrete type ("" for a nil interface).
+func (w *wireReader) iface(keep bool) (interface{}, string, error) {
+	name, err := w.readString()
+	if err != nil || name == "" {
+		return nil, "", err
+	}
+	v, err := w.ifaceValue(name, keep)
+	return v, name, err
+}
+
+// ifaceValue reads the rest of a non-nil interface value once its type
+// name has been read.
+func (w *wireReader) ifaceValue(name string, keep bool) (interface{}, error) {
+	id, err := w.typeSequence()
+	if err != nil {
+		return nil, err
+	}
+	// The byte count that follows only runs to the next type definition
+	// sent inline for a nested interface, not necessarily to the end of
+	// the value, so it is no use for skipping: walk the value instead.
+	if _, err := w.readUint(); err != nil {
+		return nil, err
+	}
+	v, err := w.singleton(id, keep)
+	if err != nil || !keep {
+		return nil, err
+	}
+	if w.named {
+		return wireNamed{name, id, basicFromName(name, v)}, nil
+	}
+	return basicFromName(name, v), nil
+}
+
+// basicFromName converts a generically decoded scalar to the Go type named
+// by its interface registration, so an "int" comes back as int rather
+// than int64.
+func basicFromName(name string, v interface{}) interface{} {
+	switch x := v.(type) {
+	case int64:
+		switch name {
+		case "int":
+			return int(x)
+		case "int8":
+			return int8(x)
+		case "int16":
+			return int16(x)
+		case "int32":
+			return int32(x)
+		}
+	case uint64:
+		switch name {
+		case "uint":
+			return uint(x)
+		case "uint8":
+			return uint8(x)
+		case "uint16":
+			return uint16(x)
+		case "uint32":
+			return uint32(x)
+		case "uintptr":
+			return uintptr(x)
+		}
+	case float64:
+		if name == "float32" {
+			return float32(x)
+		}
+	case complex128:
+		if name == "complex64" {
+			return complex64(x)
+		}
+	}
+	return v
+}
+
+// hashableKey stands in a printable string for keys Go cannot hash.
+func hashableKey(k interface{}) interface{} {
+	switch x := k.(type) {
+	case []byte, []interface{}, map[string]interface{}, map[interface{}]interface{}:
+		return fmt.Sprint(k)
+	case wireNamed:
+		return wireNamed{x.Name, x.ID, hashableKey(x.Value)}
+	}
+	return k
+}
+
+// appendUint and appendInt encode integers the way gob does.
+func appendUint(b []byte, u uint64) []byte {
+	if u < 0x80 {
+		return append(b, byte(u))
+	}
+	n := (bits.Len64(u) + 7) / 8
+	b = append(b, byte(-n))
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(u>>(8*i)))
+	}
+	return b
+}
+
+func appendInt(b []byte, i int64) []byte {
+	var u uint64
+	if i < 0 {
+		u = uint64(^i<<1) | 1
+	} else {
+		u = uint64(i << 1)
+	}
+	return appendUint(b, u)
+}
+
+// writeTypeDefs writes the known type definitions as messages of their own,
+// in id order, so a fresh gob.Decoder can make sense of values that use
+// them. Definitions made inside [skipOff, skipEnd) are left out, since the
+// bytes being decoded already carry them.
+func writeTypeDefs(buf *bytes.Buffer, types map[typeID]*wireType, skipOff, skipEnd int64) {
+	ids := make([]typeID, 0, len(types))
+	for id, wt := range types {
+		if wt.at < skipOff || wt.at >= skipEnd {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		body := appendInt(nil, -int64(id))
+		body = append(body, types[id].raw...)
+		buf.Write(appendUint(nil, uint64(len(body))))
+		buf.Write(body)
+	}
+}
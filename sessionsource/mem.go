@@ -0,0 +1,85 @@
+package sessionsource
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memStore is the data behind one mem: source name.
+type memStore struct {
+	mu       sync.Mutex
+	sessions map[string]memSession
+}
+
+type memSession struct {
+	data    []byte
+	modTime time.Time
+}
+
+var (
+	memStoresMu sync.Mutex
+	memStores   = make(map[string]*memStore)
+)
+
+// Mem is the in-memory source mem:name opens. Sources of the same name
+// share their sessions for the life of the process.
+type Mem string
+
+func (s Mem) store() *memStore {
+	memStoresMu.Lock()
+	defer memStoresMu.Unlock()
+	m := memStores[string(s)]
+	if m == nil {
+		m = &memStore{sessions: make(map[string]memSession)}
+		memStores[string(s)] = m
+	}
+	return m
+}
+
+// List returns the sessions sorted by name.
+func (s Mem) List(context.Context) ([]Ref, error) {
+	m := s.store()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	refs := make([]Ref, 0, len(m.sessions))
+	for name, sess := range m.sessions {
+		refs = append(refs, Ref{Name: name, Size: int64(len(sess.data)), ModTime: sess.modTime})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs, nil
+}
+
+func (s Mem) Read(_ context.Context, ref Ref) ([]byte, error) {
+	m := s.store()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, ok := m.sessions[ref.Name]
+	if !ok {
+		return nil, fmt.Errorf("mem:%s: no session %q: %w", string(s), ref.Name, fs.ErrNotExist)
+	}
+	return sess.data, nil
+}
+
+// Write stores a copy of data, stamped with the current time.
+func (s Mem) Write(_ context.Context, name string, data []byte) error {
+	m := s.store()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[name] = memSession{append([]byte(nil), data...), time.Now()}
+	return nil
+}
+
+func (s Mem) Delete(_ context.Context, ref Ref) error {
+	m := s.store()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[ref.Name]; !ok {
+		return fmt.Errorf("mem:%s: no session %q: %w", string(s), ref.Name, fs.ErrNotExist)
+	}
+	delete(m.sessions, ref.Name)
+	return nil
+}
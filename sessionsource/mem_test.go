@@ -0,0 +1,59 @@
+package sessionsource
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestMem(t *testing.T) {
+	ctx := context.Background()
+	src, err := Open("mem:" + t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := src.(Writer)
+	data := []byte("payload")
+	for _, name := range []string{"b", "a"} {
+		if err := w.Write(ctx, name, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data[0] = 'X' // Write keeps a copy
+
+	// A second source of the same name sees the same sessions.
+	again, err := Open("mem:" + t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := again.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 || refs[0].Name != "a" || refs[1].Name != "b" || refs[0].Size != 7 || refs[0].ModTime.IsZero() {
+		t.Fatalf("List = %+v", refs)
+	}
+	got, err := again.Read(ctx, refs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "payload" {
+		t.Errorf("Read = %q", got)
+	}
+
+	if err := src.(Deleter).Delete(ctx, refs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := again.Read(ctx, refs[0]); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Read after Delete: %v", err)
+	}
+	if err := src.(Deleter).Delete(ctx, refs[0]); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("second Delete: %v", err)
+	}
+
+	other, _ := Open("mem:" + t.Name() + "-other")
+	if refs, _ := other.List(ctx); len(refs) != 0 {
+		t.Errorf("a source of another name lists %+v", refs)
+	}
+}
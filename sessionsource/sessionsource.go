@@ -0,0 +1,74 @@
+// Package sessionsource names stores of gob-encoded sessions by URI and
+// opens them, so that code reading sessions is written once against
+// Source, and a store of another kind only has to implement the interface
+// and register its URI scheme.
+//
+// A source is named by a URI, scheme:rest. A name without a scheme is
+// opened as scheme "file", which the program registers for paths to files
+// and directories. mem:name is an in-memory source, for tests.
+package sessionsource
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ref names one session in a source.
+type Ref struct {
+	Name    string // a file path, or the key in other stores
+	Size    int64
+	ModTime time.Time // zero when the source does not know it
+}
+
+// Source lists and reads stored sessions.
+type Source interface {
+	List(ctx context.Context) ([]Ref, error)
+	Read(ctx context.Context, ref Ref) ([]byte, error)
+}
+
+// Writer is implemented by sources that can store a session.
+type Writer interface {
+	Write(ctx context.Context, name string, data []byte) error
+}
+
+// Deleter is implemented by sources that can delete a session.
+type Deleter interface {
+	Delete(ctx context.Context, ref Ref) error
+}
+
+var (
+	schemesMu sync.Mutex
+	schemes   = map[string]func(rest string) (Source, error){
+		"mem": func(rest string) (Source, error) { return Mem(rest), nil },
+	}
+)
+
+// Register makes URIs scheme:rest open through open. It panics if the
+// scheme is already registered.
+func Register(scheme string, open func(rest string) (Source, error)) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	if _, dup := schemes[scheme]; dup {
+		panic("session source scheme registered twice: " + scheme)
+	}
+	schemes[scheme] = open
+}
+
+// Open opens the source uri names. A scheme must be at least two
+// characters, so a Windows drive letter is read as a path.
+func Open(uri string) (Source, error) {
+	scheme, rest, ok := strings.Cut(uri, ":")
+	if !ok || len(scheme) < 2 || strings.ContainsAny(scheme, `/\.`) {
+		scheme, rest = "file", uri
+	}
+	schemesMu.Lock()
+	open := schemes[scheme]
+	schemesMu.Unlock()
+	if open == nil {
+		return nil, fmt.Errorf("%s: unknown session source scheme %q", uri, scheme)
+	}
+	return open(rest)
+}
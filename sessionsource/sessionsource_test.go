@@ -0,0 +1,41 @@
+package sessionsource
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegisterAndOpen(t *testing.T) {
+	var opened string
+	Register("test-scheme", func(rest string) (Source, error) {
+		opened = rest
+		return Mem(rest), nil
+	})
+	src, err := Open("test-scheme:a:b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != "a:b" || src != Mem("a:b") {
+		t.Errorf("opened %q as %#v", opened, src)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("registering a scheme twice did not panic")
+			}
+		}()
+		Register("test-scheme", nil)
+	}()
+
+	if _, err := Open("nope:x"); err == nil || !strings.Contains(err.Error(), `unknown session source scheme "nope"`) {
+		t.Errorf("unknown scheme: %v", err)
+	}
+	// Paths, including Windows ones, are opened as scheme file, which
+	// only the program registers.
+	for _, uri := range []string{"dir/s.gob", `C:\s.gob`, "./a:b"} {
+		if _, err := Open(uri); err == nil || !strings.Contains(err.Error(), `scheme "file"`) {
+			t.Errorf("Open(%q): %v", uri, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// check calls the C API of libgob.so on the gob file argv[1]. It prints
+// the JSON to stdout and fails unless GobFree gives the buffer back.
+//
+// The mmap threshold is pinned below the size of the JSON, so the buffer
+// is mapped on its own and GobFree must unmap it: mallinfo2's hblkhd,
+// the bytes in mapped blocks, drops by at least its size.
+
+#include <malloc.h>
+#include <stdio.h>
+#include <stdlib.h>
+
+#include "libgob.h"
+
+int main(int argc, char **argv) {
+	if (argc != 2) {
+		fprintf(stderr, "usage: check file.gob\n");
+		return 2;
+	}
+	mallopt(M_MMAP_THRESHOLD, 64 << 10);
+
+	FILE *f = fopen(argv[1], "rb");
+	if (f == NULL) {
+		perror(argv[1]);
+		return 2;
+	}
+	static char input[4 << 20];
+	size_t n = fread(input, 1, sizeof input, f);
+	fclose(f);
+
+	char *out;
+	int outLen;
+	if (GobDecodeToJSON(input, (int)n, NULL, NULL) != 2) {
+		fprintf(stderr, "nil output pointers: want status 2\n");
+		return 1;
+	}
+	int status = GobDecodeToJSON(input, (int)n, &out, &outLen);
+	if (status != 0) {
+		fprintf(stderr, "status %d: %s\n", status, out);
+		return 1;
+	}
+	if (out[outLen] != '\0') {
+		fprintf(stderr, "buffer is not NUL-terminated\n");
+		return 1;
+	}
+	fwrite(out, 1, outLen, stdout);
+
+	size_t before = mallinfo2().hblkhd;
+	GobFree(out);
+	size_t after = mallinfo2().hblkhd;
+	if (after > before || before - after < (size_t)outLen) {
+		fprintf(stderr, "GobFree released %zd bytes of a %d-byte buffer\n", (ssize_t)(before - after), outLen);
+		return 1;
+	}
+
+	// A bad stream gets status 1 and an error message, freed the same way.
+	status = GobDecodeToJSON("not gob", 7, &out, &outLen);
+	if (status != 1 || outLen == 0) {
+		fprintf(stderr, "bad input: got status %d, %d-byte message\n", status, outLen);
+		return 1;
+	}
+	GobFree(out);
+	return 0;
+}